@@ -61,6 +61,13 @@ Attention:
   3. Degenerate bases/residues like "RYMM.." are also supported.
      But do not use degenerate bases/residues in regular expression, you need
      convert them to regular expression, e.g., change "N" or "X"  to ".".
+  4. Both strands of each sequence are searched by default, and products on
+     the negative strand are reverse complemented, i.e., in the orientation
+     from the forward primer to the reverse primer. Products from both strands
+     are all outputted. Switch on "--both-strands" to append the strand
+     producing the amplicon to the header, e.g., "strand=-", which is placed
+     before the mismatches given by "-M/--output-mismatches".
+     It is not compatible with "-P/--only-positive-strand".
 
 Examples:
   0. no region given.
@@ -121,7 +128,9 @@ Examples:
 		runtime.GOMAXPROCS(config.Threads)
 		bwt.CheckEndSymbol = false
 
+		bench := getBenchmarkStats(cmd)
 		files := getFileListFromArgsAndFile(cmd, args, true, "infile-list", true)
+		bench.addInputs(files...)
 
 		outfh, err := xopen.Wopen(outFile)
 		checkError(err)
@@ -137,6 +146,10 @@ Examples:
 		outputMismatches := getFlagBool(cmd, "output-mismatches")
 		strict := getFlagBool(cmd, "strict-mode")
 		onlyPositiveStrand := getFlagBool(cmd, "only-positive-strand")
+		bothStrands := getFlagBool(cmd, "both-strands")
+		if bothStrands && onlyPositiveStrand {
+			checkError(fmt.Errorf("flag --both-strands is not compatible with -P/--only-positive-strand"))
+		}
 		outFmtBED := getFlagBool(cmd, "bed")
 		saveUnmatched := getFlagBool(cmd, "save-unmatched")
 
@@ -307,6 +320,7 @@ Examples:
 						checkError(err)
 						break
 					}
+					bench.addRecord()
 					if fastxReader.IsFastq {
 						config.LineWidth = 0
 						fastx.ForcelyOutputFastq = true
@@ -394,8 +408,8 @@ Examples:
 								tmpSeq = record.Seq
 
 								record.Seq = record.Seq.SubSeq(loc[0], loc[1])
-								if outputMismatches {
-									record.Name = []byte(fmt.Sprintf("%s mismatches=%d(%d+%d)", name0, mis[0]+mis[1], mis[0], mis[1]))
+								if outputMismatches || bothStrands {
+									record.Name = ampliconHeader(name0, strand, bothStrands, outputMismatches, mis)
 								}
 								results = append(results, string(record.Format(config.LineWidth)))
 
@@ -459,6 +473,7 @@ Examples:
 					checkError(err)
 					break
 				}
+				bench.addRecord()
 				if fastxReader.IsFastq {
 					config.LineWidth = 0
 					fastx.ForcelyOutputFastq = true
@@ -530,8 +545,8 @@ Examples:
 						tmpSeq = record.Seq
 
 						record.Seq = record.Seq.SubSeq(loc[0], loc[1])
-						if outputMismatches {
-							record.Name = []byte(fmt.Sprintf("%s mismatches=%d(%d+%d)", name0, 0, 0, 0))
+						if outputMismatches || bothStrands {
+							record.Name = ampliconHeader(name0, strand, bothStrands, outputMismatches, []int{0, 0})
 						}
 						record.FormatToWriter(outfh, config.LineWidth)
 
@@ -568,6 +583,7 @@ func init() {
 	ampliconCmd.Flags().BoolP("flanking-region", "f", false, "region is flanking region")
 	ampliconCmd.Flags().BoolP("strict-mode", "s", false, "strict mode, i.e., discarding seqs not fully matching (shorter) given region range")
 	ampliconCmd.Flags().BoolP("only-positive-strand", "P", false, "only search on positive strand")
+	ampliconCmd.Flags().BoolP("both-strands", "", false, `search on both strands (default behaviour), and append the strand producing the amplicon to the header, e.g., "strand=-"`)
 	ampliconCmd.Flags().BoolP("bed", "", false, "output in BED6+1 format with amplicon as the 7th column")
 	ampliconCmd.Flags().BoolP("immediate-output", "I", false, "print output immediately, do not use write buffer")
 	ampliconCmd.Flags().BoolP("save-unmatched", "u", false, "also save records that do not match any primer")
@@ -584,6 +600,20 @@ func amplicon_mismatches(s1, s2 []byte) int {
 	return n
 }
 
+// ampliconHeader appends the strand and/or mismatches to the header of an amplicon.
+func ampliconHeader(name0 string, strand string, withStrand bool, withMismatches bool, mis []int) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(name0)
+	if withStrand {
+		buf.WriteString(" strand=")
+		buf.WriteString(strand)
+	}
+	if withMismatches {
+		fmt.Fprintf(&buf, " mismatches=%d(%d+%d)", mis[0]+mis[1], mis[0], mis[1])
+	}
+	return buf.Bytes()
+}
+
 func loadPrimers(file string) ([][3]string, error) {
 	fh, err := os.Open(file)
 	if err != nil {
@@ -677,6 +707,15 @@ func (finder *AmpliconFinder) Reset(sequence []byte, maxMismatch int) error {
 	return nil
 }
 
+// ResetWithFMIndex is similar to Reset, but uses an upper-case sequence and
+// its FM-index computed outside, so they can be shared by multiple finders.
+func (finder *AmpliconFinder) ResetWithFMIndex(sequence []byte, index *fmi.FMIndex, maxMismatch int) {
+	finder.Seq = sequence
+	finder.MaxMismatch = maxMismatch
+	finder.FMindex = index
+	finder.searched, finder.found = false, false
+}
+
 // NewAmpliconFinder returns a AmpliconFinder struct.
 func NewAmpliconFinder(sequence, forwardPrimer, reversePrimerRC []byte, maxMismatch int) (*AmpliconFinder, error) {
 	if len(sequence) == 0 {
@@ -0,0 +1,262 @@
+// Copyright © 2016-2024 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"runtime"
+
+	"github.com/biogo/hts/sam"
+	"github.com/cespare/xxhash/v2"
+	"github.com/shenwei356/bio/seq"
+	"github.com/shenwei356/bio/seqio/fastx"
+	"github.com/shenwei356/xopen"
+	"github.com/spf13/cobra"
+)
+
+// bamRelabelCmd represents the bam-relabel command
+var bamRelabelCmd = &cobra.Command{
+	GroupID: "bam",
+
+	Use:   "bam-relabel",
+	Short: "rename reads with values of a BAM tag",
+	Long: `rename reads with values of a BAM tag
+
+A map of read names to values of the tag (-T/--tag) is built from the BAM
+file (-b/--bam), then records of FASTA/Q files are streamed and renamed
+with the template given by -r/--replacement.
+
+Replacement symbols:
+    {name}  Full name of the record
+    {id}    ID of the record
+    {key}   Name of the tag, e.g., BC
+    {tag}   Value of the tag, e.g., ACGTACGT
+    {nr}    Record number, starting from 1
+
+Attention:
+  1. Read names in the BAM file are matched with IDs of records, you can use
+     --id-regexp to extract read names, e.g., '^(\S+)\/[12]' for removing
+     '/1' and '/2' suffixes.
+  2. For reads with multiple alignments, the tag value in the first alignment
+     is used, and a warning is shown if other alignments have different values.
+  3. Records absent from the BAM file or without the tag are kept unchanged by
+     default, use "-m/--if-miss drop" to drop them.
+  4. Only hashes of read names and distinct tag values are kept in memory.
+
+Examples:
+  1. Appending the barcode to the header.
+       seqkit bam-relabel -b aln.bam -T BC reads.fq.gz -o relabeled.fq.gz
+  2. Prefixing the read ID with the barcode, and dropping records without it.
+       seqkit bam-relabel -b aln.bam -T BC -r '{tag}_{id}' -m drop reads.fq.gz
+
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		config := getConfigs(cmd)
+		alphabet := config.Alphabet
+		idRegexp := config.IDRegexp
+		outFile := config.OutFile
+		quiet := config.Quiet
+		seq.AlphabetGuessSeqLengthThreshold = config.AlphabetGuessSeqLength
+		seq.ValidateSeq = false
+		runtime.GOMAXPROCS(config.Threads)
+
+		bamFile := getFlagString(cmd, "bam")
+		tagName := getFlagString(cmd, "tag")
+		replacement := getFlagString(cmd, "replacement")
+		ifMiss := getFlagString(cmd, "if-miss")
+		nrWidth := getFlagPositiveInt(cmd, "nr-width")
+
+		if bamFile == "" {
+			checkError(fmt.Errorf("flag -b (--bam) needed"))
+		}
+		if len(tagName) != 2 {
+			checkError(fmt.Errorf("value of flag -T (--tag) should be a tag of two characters, e.g., BC"))
+		}
+		if replacement == "" {
+			checkError(fmt.Errorf("value of flag -r (--replacement) should not be empty"))
+		}
+		var dropMiss bool
+		switch ifMiss {
+		case "keep":
+		case "drop":
+			dropMiss = true
+		default:
+			checkError(fmt.Errorf(`invalid value of flag -m (--if-miss): %s, available: "keep", "drop"`, ifMiss))
+		}
+
+		bench := getBenchmarkStats(cmd)
+		files := getFileListFromArgsAndFile(cmd, args, true, "infile-list", true)
+		bench.addInputs(files...)
+		for _, file := range files {
+			if file == bamFile {
+				checkError(fmt.Errorf("the BAM file can not be used as an input file: %s", file))
+			}
+		}
+
+		// ------------------------------------------------------------
+		// read name -> index of tag value
+
+		if !quiet {
+			log.Infof("reading tag %s from BAM file: %s", tagName, bamFile)
+		}
+		tag := []byte(tagName)
+		name2tag := make(map[uint64]uint32, 1<<20)
+		tagValues := make([]string, 0, 1024)
+		tag2idx := make(map[string]uint32, 1024)
+
+		bamReader := NewBamReader(bamFile, config.Threads)
+		var r *sam.Record
+		var err error
+		var aux sam.Aux
+		var ok bool
+		var h uint64
+		var v string
+		var idx, idx0 uint32
+		var nAln, nConflicts int
+		for {
+			r, err = bamReader.Read()
+			if err != nil {
+				if err == io.EOF {
+					break
+				}
+				checkError(err)
+			}
+			bench.addRecord()
+			nAln++
+
+			if aux, ok = r.Tag(tag); !ok {
+				continue
+			}
+			v = aux.String()[5:] // TG:T:VALUE
+
+			if idx, ok = tag2idx[v]; !ok {
+				idx = uint32(len(tagValues))
+				tag2idx[v] = idx
+				tagValues = append(tagValues, v)
+			}
+
+			h = xxhash.Sum64String(r.Name)
+			if idx0, ok = name2tag[h]; ok {
+				if idx0 != idx {
+					nConflicts++
+				}
+				continue
+			}
+			name2tag[h] = idx
+		}
+		checkError(bamReader.Close())
+		tag2idx = nil
+
+		if !quiet {
+			log.Infof("%d reads with tag %s found in %d alignments, with %d distinct values", len(name2tag), tagName, nAln, len(tagValues))
+			if nConflicts > 0 {
+				log.Warningf("%d alignments have tag values different from those of the first alignments of the reads, which are ignored", nConflicts)
+			}
+		}
+
+		// ------------------------------------------------------------
+		// renaming
+
+		template := []byte(reBamRelabelKey.ReplaceAllString(replacement, tagName))
+		withName := reBamRelabelName.Match(template)
+		withID := reBamRelabelID.Match(template)
+		withNR := reNR.Match(template)
+		nrFormat := fmt.Sprintf("%%0%dd", nrWidth)
+
+		outfh, err := xopen.Wopen(outFile)
+		checkError(err)
+		defer outfh.Close()
+
+		var record *fastx.Record
+		var name []byte
+		var nRenamed, nMiss int
+		for _, file := range files {
+			fastxReader, err := fastx.NewReader(alphabet, file, idRegexp)
+			checkError(err)
+			nr := 0
+			for {
+				record, err = fastxReader.Read()
+				if err != nil {
+					if err == io.EOF {
+						break
+					}
+					checkError(err)
+					break
+				}
+				bench.addRecord()
+				if fastxReader.IsFastq {
+					config.LineWidth = 0
+					fastx.ForcelyOutputFastq = true
+				}
+				nr++
+
+				if idx, ok = name2tag[xxhash.Sum64(record.ID)]; !ok {
+					nMiss++
+					if !dropMiss {
+						record.FormatToWriter(outfh, config.LineWidth)
+					}
+					continue
+				}
+
+				name = reBamRelabelTag.ReplaceAllLiteral(template, []byte(tagValues[idx]))
+				if withNR {
+					name = reNR.ReplaceAll(name, []byte(fmt.Sprintf(nrFormat, nr)))
+				}
+				if withID {
+					name = reBamRelabelID.ReplaceAllLiteral(name, record.ID)
+				}
+				if withName {
+					name = reBamRelabelName.ReplaceAllLiteral(name, record.Name)
+				}
+				record.Name = name
+				nRenamed++
+
+				record.FormatToWriter(outfh, config.LineWidth)
+			}
+			fastxReader.Close()
+		}
+
+		if !quiet {
+			if dropMiss {
+				log.Infof("%d records renamed, %d records without the tag dropped", nRenamed, nMiss)
+			} else {
+				log.Infof("%d records renamed, %d records without the tag kept unchanged", nRenamed, nMiss)
+			}
+		}
+	},
+}
+
+var reBamRelabelName = regexp.MustCompile(`\{name\}`)
+var reBamRelabelID = regexp.MustCompile(`\{id\}`)
+var reBamRelabelKey = regexp.MustCompile(`\{key\}`)
+var reBamRelabelTag = regexp.MustCompile(`\{tag\}`)
+
+func init() {
+	RootCmd.AddCommand(bamRelabelCmd)
+
+	bamRelabelCmd.Flags().StringP("bam", "b", "", "BAM file for reading tag values of reads")
+	bamRelabelCmd.Flags().StringP("tag", "T", "", "BAM tag, e.g., BC")
+	bamRelabelCmd.Flags().StringP("replacement", "r", "{name} {key}:{tag}", `template of new names, supporting "{name}", "{id}", "{key}", "{tag}" and "{nr}"`)
+	bamRelabelCmd.Flags().StringP("if-miss", "m", "keep", `policy for records absent from the BAM file or without the tag: "keep" or "drop"`)
+	bamRelabelCmd.Flags().IntP("nr-width", "", 1, `minimum width for {nr} in flag -r/--replacement. e.g., formatting "1" to "001" by --nr-width 3`)
+}
@@ -501,7 +501,9 @@ var bamCmd = &cobra.Command{
 		outFile := config.OutFile
 		runtime.GOMAXPROCS(config.Threads)
 
+		bench := getBenchmarkStats(cmd)
 		files := getFileListFromArgsAndFile(cmd, args, true, "infile-list", true)
+		bench.addInputs(files...)
 
 		mapQual := getFlagInt(cmd, "map-qual")
 		field := getFlagString(cmd, "field")
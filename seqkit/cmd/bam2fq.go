@@ -0,0 +1,325 @@
+// Copyright © 2016-2024 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+
+	"github.com/biogo/hts/sam"
+	"github.com/shenwei356/bio/seq"
+	"github.com/shenwei356/util/pathutil"
+	"github.com/shenwei356/xopen"
+	"github.com/spf13/cobra"
+)
+
+// bam2fqCmd represents the bam2fq command
+var bam2fqCmd = &cobra.Command{
+	GroupID: "bam",
+
+	Use:   "bam2fq",
+	Short: "convert BAM to FASTQ files, optionally split by read groups",
+	Long: `convert BAM to FASTQ files, optionally split by read groups
+
+Reads are restored in their original orientation as "seqkit revcomp-bam" does:
+sequences of alignments on the reverse strand (flag 0x10) are
+reverse-complemented and the qualities are reversed.
+
+Output files in -O/--out-dir:
+  1. Read 1 (0x40) and read 2 (0x80) of paired reads (0x1) are saved to
+     PREFIX_1.fq.gz and PREFIX_2.fq.gz, in the same order. Mates are kept in
+     memory until the other ones are found, so name-sorted or collated BAM
+     files (e.g., "samtools collate") are recommended to save memory.
+     Mates without the other ones are saved to PREFIX_singleton.fq.gz.
+  2. Single-end reads are saved to PREFIX.fq.gz.
+  3. PREFIX is "reads" by default. With --split-rg, reads are split by read
+     groups in BAM headers, and PREFIX is the ID of the read group, with
+     characters other than letters, digits, ".", "-" and "_" replaced with "_".
+     Reads without RG tags, or with read groups absent in the headers, are
+     saved with the prefix "unassigned".
+  4. The file extension can be changed with -e/--extension, e.g., ".fq"
+     for plain text files.
+
+Attention:
+  1. Secondary (0x100) and supplementary (0x800) alignments are skipped by
+     default. With --include-secondary, they are outputted right away without
+     waiting for the mates, so read pairs in the output files might be out of
+     sync.
+  2. Reads with hard-clipped bases can not be fully reconstructed, these
+     reads are skipped by default. Use "-H/--hard-clipped keep" to output the
+     remaining parts.
+  3. Reads without qualities ("*") are given the Phred quality of
+     --default-qual.
+
+Input:
+  BAM files are given as positional arguments, with -X/--infile-list,
+  or with -b/--bam as "seqkit bam-relabel" does.
+
+Examples:
+  1. Splitting reads by read groups.
+       seqkit bam2fq --split-rg -O reads aln.bam
+       seqkit bam2fq --split-rg -O reads -b aln.bam
+
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		config := getConfigs(cmd)
+		quiet := config.Quiet
+		seq.ValidateSeq = false
+		runtime.GOMAXPROCS(config.Threads)
+
+		bamFile := getFlagString(cmd, "bam")
+		outdir := getFlagString(cmd, "out-dir")
+		force := getFlagBool(cmd, "force")
+		extension := getFlagString(cmd, "extension")
+		splitRG := getFlagBool(cmd, "split-rg")
+		includeSecondary := getFlagBool(cmd, "include-secondary")
+		hardClipped := getFlagString(cmd, "hard-clipped")
+		defaultQual := getFlagNonNegativeInt(cmd, "default-qual")
+
+		var keepHardClipped bool
+		switch hardClipped {
+		case "drop":
+		case "keep":
+			keepHardClipped = true
+		default:
+			checkError(fmt.Errorf(`invalid value of flag -H (--hard-clipped): %s, available: "drop", "keep"`, hardClipped))
+		}
+		if defaultQual > 93 {
+			checkError(fmt.Errorf("value of flag --default-qual should be in range of [0, 93]: %d", defaultQual))
+		}
+		if outdir == "" {
+			checkError(fmt.Errorf("value of flag -O (--out-dir) should not be empty"))
+		}
+
+		if bamFile != "" {
+			args = append([]string{bamFile}, args...)
+		}
+
+		bench := getBenchmarkStats(cmd)
+		files := getFileListFromArgsAndFile(cmd, args, true, "infile-list", true)
+		bench.addInputs(files...)
+
+		existed, err := pathutil.DirExists(outdir)
+		checkError(err)
+		if existed {
+			empty, err := pathutil.IsEmpty(outdir)
+			checkError(err)
+			if !empty {
+				if force {
+					checkError(os.RemoveAll(outdir))
+					checkError(os.MkdirAll(outdir, 0755))
+				} else {
+					log.Warningf("outdir not empty: %s, you can use --force to overwrite", outdir)
+				}
+			}
+		} else {
+			checkError(os.MkdirAll(outdir, 0755))
+		}
+
+		// output files are opened when needed
+		outfhs := make(map[string]*xopen.Writer)
+		defer func() {
+			for _, outfh := range outfhs {
+				checkError(outfh.Close())
+			}
+		}()
+		write := func(prefix, suffix string, read *bam2fqRead) {
+			file := filepath.Join(outdir, prefix+suffix+extension)
+			outfh, ok := outfhs[file]
+			if !ok {
+				outfh, err = xopen.Wopen(file)
+				checkError(err)
+				outfhs[file] = outfh
+			}
+			outfh.WriteByte('@')
+			outfh.WriteString(read.name)
+			outfh.WriteByte('\n')
+			outfh.Write(read.seq)
+			outfh.WriteString("\n+\n")
+			outfh.Write(read.qual)
+			outfh.WriteByte('\n')
+		}
+
+		tagRG := []byte("RG")
+		// unpaired mates, keys are read names
+		pending := make(map[string]*bam2fqRead)
+
+		var r *sam.Record
+		var aux sam.Aux
+		var ok bool
+		var rg string
+		var prefix string
+		var read, mate *bam2fqRead
+		var prefixes map[string]string
+		var nReads, nPairs, nSingle, nSingleton, nSkipped, nHardClipped, nUnassigned int
+		for _, file := range files {
+			bamReader := NewBamReader(file, config.Threads)
+
+			if splitRG {
+				prefixes = make(map[string]string)
+				for _, g := range bamReader.Header().RGs() {
+					prefixes[g.Name()] = bam2fqPrefix(g.Name())
+				}
+				if len(prefixes) == 0 && !quiet {
+					log.Warningf("no read groups found in the header of %s", file)
+				}
+			}
+
+			for {
+				r, err = bamReader.Read()
+				if err != nil {
+					if err == io.EOF {
+						break
+					}
+					checkError(err)
+				}
+				bench.addRecord()
+
+				if !includeSecondary && r.Flags&(sam.Secondary|sam.Supplementary) != 0 {
+					nSkipped++
+					continue
+				}
+
+				if bamHardClipped(r) {
+					nHardClipped++
+					if !keepHardClipped {
+						continue
+					}
+				}
+
+				prefix = "reads"
+				if splitRG {
+					rg = ""
+					if aux, ok = r.Tag(tagRG); ok {
+						rg, _ = aux.Value().(string)
+					}
+					if prefix, ok = prefixes[rg]; !ok {
+						prefix = "unassigned"
+						nUnassigned++
+					}
+				}
+
+				read = &bam2fqRead{name: r.Name, prefix: prefix}
+				read.seq, read.qual, _ = revcompBamRead(r, defaultQual)
+				read.read1 = r.Flags&sam.Read1 != 0
+				nReads++
+
+				if r.Flags&sam.Paired == 0 || r.Flags&(sam.Read1|sam.Read2) == 0 {
+					write(prefix, "", read)
+					nSingle++
+					continue
+				}
+
+				if r.Flags&(sam.Secondary|sam.Supplementary) != 0 {
+					if read.read1 {
+						write(prefix, "_1", read)
+					} else {
+						write(prefix, "_2", read)
+					}
+					continue
+				}
+
+				if mate, ok = pending[r.Name]; !ok || mate.read1 == read.read1 {
+					if ok { // the same mate appears again
+						write(mate.prefix, "_singleton", mate)
+						nSingleton++
+					}
+					pending[r.Name] = read
+					continue
+				}
+				delete(pending, r.Name)
+				if !read.read1 {
+					read, mate = mate, read
+				}
+				write(read.prefix, "_1", read)
+				write(mate.prefix, "_2", mate)
+				nPairs++
+			}
+			checkError(bamReader.Close())
+		}
+
+		// mates without the other ones
+		if len(pending) > 0 {
+			names := make([]string, 0, len(pending))
+			for name := range pending {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				read = pending[name]
+				write(read.prefix, "_singleton", read)
+			}
+			nSingleton += len(pending)
+		}
+
+		if !quiet {
+			log.Infof("%d reads outputted to %d files in %s: %d pairs, %d single-end reads, %d singletons",
+				nReads, len(outfhs), outdir, nPairs, nSingle, nSingleton)
+			if nSkipped > 0 {
+				log.Infof("%d secondary/supplementary alignments skipped", nSkipped)
+			}
+			if nUnassigned > 0 {
+				log.Warningf("%d reads without known read groups saved with the prefix: unassigned", nUnassigned)
+			}
+			if nHardClipped > 0 {
+				if keepHardClipped {
+					log.Warningf("%d hard-clipped reads outputted, which are not complete", nHardClipped)
+				} else {
+					log.Warningf("%d hard-clipped reads skipped", nHardClipped)
+				}
+			}
+		}
+	},
+}
+
+// bam2fqRead is a read restored from a BAM record.
+type bam2fqRead struct {
+	name   string
+	prefix string
+	seq    []byte
+	qual   []byte
+	read1  bool
+}
+
+var reBam2fqPrefix = regexp.MustCompile(`[^A-Za-z0-9._\-]`)
+
+// bam2fqPrefix returns the prefix of output files of a read group.
+func bam2fqPrefix(rg string) string {
+	return reBam2fqPrefix.ReplaceAllString(rg, "_")
+}
+
+func init() {
+	RootCmd.AddCommand(bam2fqCmd)
+
+	bam2fqCmd.Flags().StringP("bam", "b", "", "BAM file, an alternative to positional arguments")
+	bam2fqCmd.Flags().StringP("out-dir", "O", "bam2fq", "output directory")
+	bam2fqCmd.Flags().BoolP("force", "f", false, "overwrite output directory")
+	bam2fqCmd.Flags().StringP("extension", "e", ".fq.gz", `output file extension, e.g., ".fq", ".fq.gz", ".fq.xz", or ".fq.zst"`)
+	bam2fqCmd.Flags().BoolP("split-rg", "", false, "split reads by read groups in BAM headers")
+	bam2fqCmd.Flags().BoolP("include-secondary", "", false, "include secondary and supplementary alignments")
+	bam2fqCmd.Flags().StringP("hard-clipped", "H", "drop", `policy for hard-clipped reads which can not be fully reconstructed: "drop" or "keep"`)
+	bam2fqCmd.Flags().IntP("default-qual", "", 1, "Phred quality for reads without qualities")
+}
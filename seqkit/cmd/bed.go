@@ -24,6 +24,7 @@ import (
 	"fmt"
 	"os"
 	"runtime"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -138,3 +139,57 @@ func ReadBedFilteredFeatures(file string, chrs []string) ([]BedFeature, error) {
 	}
 	return BedFeatures, nil
 }
+
+// bedCoverage is the intervals of a chromosome in a BED file.
+type bedCoverage struct {
+	Intervals int      // number of intervals
+	Regions   [][2]int // merged regions, 1-based and end included
+}
+
+// bedCoverages groups BED features by chromosomes and merges overlapping regions.
+func bedCoverages(features []BedFeature) map[string]*bedCoverage {
+	m := make(map[string]*bedCoverage, 128)
+	var c *bedCoverage
+	var ok bool
+	for _, f := range features {
+		if c, ok = m[f.Chr]; !ok {
+			c = &bedCoverage{}
+			m[f.Chr] = c
+		}
+		c.Intervals++
+		c.Regions = append(c.Regions, [2]int{f.Start, f.End})
+	}
+
+	for _, c = range m {
+		sort.Slice(c.Regions, func(i, j int) bool { return c.Regions[i][0] < c.Regions[j][0] })
+		merged := c.Regions[:1]
+		for _, r := range c.Regions[1:] {
+			last := &merged[len(merged)-1]
+			if r[0] <= last[1]+1 {
+				if r[1] > last[1] {
+					last[1] = r[1]
+				}
+			} else {
+				merged = append(merged, r)
+			}
+		}
+		c.Regions = merged
+	}
+	return m
+}
+
+// CoveredBases returns the number of bases covered in a sequence of the given length.
+func (c *bedCoverage) CoveredBases(length int) int {
+	var n, s, e int
+	for _, r := range c.Regions {
+		s, e = r[0], r[1]
+		if s > length {
+			break
+		}
+		if e > length {
+			e = length
+		}
+		n += e - s + 1
+	}
+	return n
+}
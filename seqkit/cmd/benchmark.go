@@ -0,0 +1,151 @@
+// Copyright © 2016-2024 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// benchmark mode (--benchmark): elapsed time, records, input/output bytes
+// and throughput are reported to stderr on completion of a command.
+
+type benchmarkKey struct{}
+
+// benchmarkStats holds input files and the number of records processed by
+// a command, which are given by the command itself. All methods are no-ops
+// for a nil *benchmarkStats, i.e., when --benchmark is not given.
+type benchmarkStats struct {
+	start   time.Time
+	files   []string
+	records int64
+	counted int32
+}
+
+// getBenchmarkStats returns the benchmarkStats of a command, or nil if
+// --benchmark is not given.
+func getBenchmarkStats(cmd *cobra.Command) *benchmarkStats {
+	ctx := cmd.Context()
+	if ctx == nil {
+		return nil
+	}
+	b, _ := ctx.Value(benchmarkKey{}).(*benchmarkStats)
+	return b
+}
+
+// addInputs adds input files, the sizes of which are reported.
+func (b *benchmarkStats) addInputs(files ...string) {
+	if b == nil {
+		return
+	}
+	b.files = append(b.files, files...)
+}
+
+// addRecord counts a record processed. It's safe for concurrent use.
+func (b *benchmarkStats) addRecord() {
+	b.addRecords(1)
+}
+
+// addRecords counts n records processed, e.g., 2 for a read pair.
+func (b *benchmarkStats) addRecords(n int64) {
+	if b == nil {
+		return
+	}
+	atomic.AddInt64(&b.records, n)
+	atomic.StoreInt32(&b.counted, 1)
+}
+
+func benchmarkStart(cmd *cobra.Command, args []string) {
+	if !getFlagBool(cmd, "benchmark") {
+		return
+	}
+	ctx := cmd.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	cmd.SetContext(context.WithValue(ctx, benchmarkKey{}, &benchmarkStats{start: time.Now()}))
+}
+
+func benchmarkReport(cmd *cobra.Command, args []string) {
+	b := getBenchmarkStats(cmd)
+	if b == nil || getFlagBool(cmd, "quiet") {
+		return
+	}
+	elapsed := time.Since(b.start)
+
+	// input bytes
+	var inBytes int64
+	inBytesOK := len(b.files) > 0
+	for _, file := range b.files {
+		size, ok := benchmarkFileSize(file)
+		if !ok {
+			inBytesOK = false
+			break
+		}
+		inBytes += size
+	}
+
+	// output bytes
+	outBytes, outBytesOK := benchmarkFileSize(getFlagString(cmd, "out-file"))
+
+	secs := elapsed.Seconds()
+	if secs <= 0 {
+		secs = 1e-9
+	}
+	items := make([]string, 0, 4)
+	items = append(items, fmt.Sprintf("elapsed time: %s", elapsed.Round(time.Millisecond)))
+	if atomic.LoadInt32(&b.counted) == 1 {
+		records := atomic.LoadInt64(&b.records)
+		items = append(items, fmt.Sprintf("records: %d (%.1f records/s)", records, float64(records)/secs))
+	} else {
+		items = append(items, "records: NA")
+	}
+	if inBytesOK {
+		items = append(items, fmt.Sprintf("input: %.2f MB (%.2f MB/s)", float64(inBytes)/1e6, float64(inBytes)/1e6/secs))
+	} else {
+		items = append(items, "input: NA")
+	}
+	if outBytesOK {
+		items = append(items, fmt.Sprintf("output: %.2f MB (%.2f MB/s)", float64(outBytes)/1e6, float64(outBytes)/1e6/secs))
+	} else {
+		items = append(items, "output: NA")
+	}
+	fmt.Fprintf(os.Stderr, "[benchmark] %s\n", strings.Join(items, ", "))
+}
+
+// benchmarkFileSize returns the size of a regular file.
+// stdin/stdout are not supported, as they are closed after reading/writing.
+func benchmarkFileSize(file string) (int64, bool) {
+	if isStdin(file) {
+		return 0, false
+	}
+	fi, err := os.Stat(file)
+	if err != nil || !fi.Mode().IsRegular() {
+		return 0, false
+	}
+	return fi.Size(), true
+}
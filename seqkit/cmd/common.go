@@ -96,7 +96,9 @@ Note:
 			checkError(fmt.Errorf("flag -s (--by-seq) needed when using -e (--check-embedded-seqs)"))
 		}
 
+		bench := getBenchmarkStats(cmd)
 		files := getFileListFromArgsAndFile(cmd, args, true, "infile-list", true)
+		bench.addInputs(files...)
 
 		if len(files) < 2 {
 			checkError(errors.New("at least 2 files needed"))
@@ -181,6 +183,7 @@ Note:
 						checkError(err)
 						break
 					}
+					bench.addRecord()
 
 					_seq = record.Seq.Seq
 					if ignoreCase {
@@ -567,6 +570,7 @@ Note:
 					checkError(err)
 					break
 				}
+				bench.addRecord()
 
 				if bySeq {
 					_seq = record.Seq.Seq
@@ -0,0 +1,236 @@
+// Copyright © 2016-2024 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+
+	"github.com/shenwei356/bio/seq"
+	"github.com/shenwei356/bio/seqio/fastx"
+	"github.com/shenwei356/xopen"
+	"github.com/spf13/cobra"
+)
+
+// compositionCmd represents the composition command
+var compositionCmd = &cobra.Command{
+	GroupID: "basic",
+
+	Use:     "composition",
+	Aliases: []string{"count-bases"},
+	Short:   "count bases/residues of each file or each record",
+	Long: `count bases/residues of each file or each record
+
+Letters:
+  DNA/RNA : A, C, G, T, U, N
+  Protein : 20 standard amino acids, X and * (stop, -p/--protein)
+  other   : all letters not listed above, e.g., degenerate bases
+  gap     : gap letters given by -G/--gap-letters
+
+  Lower-case letters are counted separately, unless -i/--ignore-case is given.
+
+Output (tab-delimited):
+  1. By default, counts of each file are reported in a long table:
+       file, letter, count, percentage
+  2. Per record (-r/--per-record), counts are reported in a matrix:
+       file, id, length, <one column per letter>
+     Use -P/--percentage to report percentages instead of counts.
+
+  Percentages are computed with the sequence length (gaps included).
+
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		config := getConfigs(cmd)
+		alphabet := config.Alphabet
+		idRegexp := config.IDRegexp
+		outFile := config.OutFile
+		seq.AlphabetGuessSeqLengthThreshold = config.AlphabetGuessSeqLength
+		seq.ValidateSeq = false
+		runtime.GOMAXPROCS(config.Threads)
+
+		bench := getBenchmarkStats(cmd)
+		files := getFileListFromArgsAndFile(cmd, args, true, "infile-list", true)
+		bench.addInputs(files...)
+
+		perRecord := getFlagBool(cmd, "per-record")
+		percentage := getFlagBool(cmd, "percentage")
+		protein := getFlagBool(cmd, "protein")
+		ignoreCase := getFlagBool(cmd, "ignore-case")
+		gapLetters := getFlagString(cmd, "gap-letters")
+
+		if percentage && !perRecord {
+			checkError(fmt.Errorf("flag -P (--percentage) should be used with -r (--per-record)"))
+		}
+
+		outfh, err := xopen.Wopen(outFile)
+		checkError(err)
+		defer outfh.Close()
+
+		var record *fastx.Record
+		var counts, total [256]int
+		var letters []string
+		var classes [256]int
+		var i int
+		var b byte
+		var n, length, lengthTotal int
+		var values []int
+		var first = true
+
+		for _, file := range files {
+			fastxReader, err := fastx.NewReader(alphabet, file, idRegexp)
+			checkError(err)
+
+			total = [256]int{}
+			lengthTotal = 0
+			for {
+				record, err = fastxReader.Read()
+				if err != nil {
+					if err == io.EOF {
+						break
+					}
+					checkError(err)
+					break
+				}
+				bench.addRecord()
+
+				if first {
+					letters, classes = compositionClasses(protein, ignoreCase, gapLetters)
+					values = make([]int, len(letters))
+					if perRecord {
+						outfh.WriteString("file\tid\tlength")
+						for _, l := range letters {
+							outfh.WriteString("\t" + l)
+						}
+						outfh.WriteString("\n")
+					} else {
+						outfh.WriteString("file\tletter\tcount\tpercentage\n")
+					}
+					first = false
+				}
+
+				length = len(record.Seq.Seq)
+				lengthTotal += length
+				if !perRecord {
+					for _, b = range record.Seq.Seq {
+						total[b]++
+					}
+					continue
+				}
+
+				counts = [256]int{}
+				for _, b = range record.Seq.Seq {
+					counts[b]++
+				}
+				for i = range values {
+					values[i] = 0
+				}
+				for i, n = range counts {
+					if n > 0 {
+						values[classes[i]] += n
+					}
+				}
+
+				fmt.Fprintf(outfh, "%s\t%s\t%d", file, record.ID, length)
+				for _, n = range values {
+					if percentage {
+						if length == 0 {
+							outfh.WriteString("\t0.00")
+						} else {
+							fmt.Fprintf(outfh, "\t%.2f", float64(n)/float64(length)*100)
+						}
+					} else {
+						fmt.Fprintf(outfh, "\t%d", n)
+					}
+				}
+				outfh.WriteString("\n")
+			}
+			fastxReader.Close()
+
+			if perRecord || first {
+				continue
+			}
+
+			for i = range values {
+				values[i] = 0
+			}
+			for i, n = range total {
+				if n > 0 {
+					values[classes[i]] += n
+				}
+			}
+			for i, n = range values {
+				if lengthTotal == 0 {
+					fmt.Fprintf(outfh, "%s\t%s\t%d\t0.00\n", file, letters[i], n)
+				} else {
+					fmt.Fprintf(outfh, "%s\t%s\t%d\t%.2f\n", file, letters[i], n, float64(n)/float64(lengthTotal)*100)
+				}
+			}
+		}
+	},
+}
+
+// compositionClasses returns the column names and the column index of each letter.
+func compositionClasses(protein bool, ignoreCase bool, gapLetters string) ([]string, [256]int) {
+	bases := "ACGTUN"
+	if protein {
+		bases = "ACDEFGHIKLMNPQRSTVWYX*"
+	}
+
+	letters := make([]string, 0, len(bases)*2+2)
+	for i := 0; i < len(bases); i++ {
+		letters = append(letters, bases[i:i+1])
+	}
+	if !ignoreCase {
+		for i := 0; i < len(bases); i++ {
+			if bases[i] >= 'A' && bases[i] <= 'Z' {
+				letters = append(letters, string(bases[i]+32))
+			}
+		}
+	}
+	letters = append(letters, "other", "gap")
+	iOther, iGap := len(letters)-2, len(letters)-1
+
+	var classes [256]int
+	for i := range classes {
+		classes[i] = iOther
+	}
+	for i, l := range letters[:iOther] {
+		classes[l[0]] = i
+		if ignoreCase && l[0] >= 'A' && l[0] <= 'Z' {
+			classes[l[0]+32] = i
+		}
+	}
+	for i := 0; i < len(gapLetters); i++ {
+		classes[gapLetters[i]] = iGap
+	}
+	return letters, classes
+}
+
+func init() {
+	RootCmd.AddCommand(compositionCmd)
+
+	compositionCmd.Flags().BoolP("per-record", "r", false, "report counts of each record")
+	compositionCmd.Flags().BoolP("percentage", "P", false, "report percentages instead of counts, only for -r/--per-record")
+	compositionCmd.Flags().BoolP("protein", "p", false, "count amino acids")
+	compositionCmd.Flags().BoolP("ignore-case", "i", false, "ignore case")
+	compositionCmd.Flags().StringP("gap-letters", "G", "-.", "gap letters")
+}
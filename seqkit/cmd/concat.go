@@ -24,6 +24,7 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"io"
 	"runtime"
 	"strings"
 
@@ -51,6 +52,18 @@ Attention:
    3. Description are also concatenated with a separator (-s/--separator).
    4. Order of sequences with different IDs are random.
 
+Concatenating paired-end reads:
+   Paired-end reads given by -1/--read1 and -2/--read2 are concatenated into
+   one record per read pair, i.e., read1 + spacer + read2, where the spacer is
+   N bases of "N" (--separator-n), with qualities of --spacer-qual for FASTQ.
+   Qualities of the two mates are kept as they are. Read2 can be reverse
+   complemented with --revcom-read2. Output records have the IDs of read1
+   with the mate suffix "/1" removed, followed by descriptions of read1.
+   Reads in the two files should be paired in the same order, please run
+   "seqkit pair" first if not. E.g.,
+
+     seqkit concat -1 reads_1.fq.gz -2 reads_2.fq.gz --separator-n 10 -o reads.fq.gz
+
 `,
 	Run: func(cmd *cobra.Command, args []string) {
 		config := getConfigs(cmd)
@@ -66,7 +79,49 @@ Attention:
 		full := getFlagBool(cmd, "full")
 		separator := getFlagString(cmd, "separator")
 
+		read1 := getFlagString(cmd, "read1")
+		read2 := getFlagString(cmd, "read2")
+		bench := getBenchmarkStats(cmd)
+		if read1 != "" || read2 != "" {
+			if read1 == "" || read2 == "" {
+				checkError(fmt.Errorf("flag -1/--read1 and -2/--read2 needed"))
+			}
+			if read1 == read2 {
+				checkError(fmt.Errorf("values of flag -1/--read1 and -2/--read2 can not be the same"))
+			}
+			if len(args) > 0 {
+				checkError(fmt.Errorf("no positional arguments are allowed for paired reads: %s", strings.Join(args, " ")))
+			}
+			if full {
+				checkError(fmt.Errorf("flag -f/--full is not supported for paired reads"))
+			}
+			nSpacer := getFlagNonNegativeInt(cmd, "separator-n")
+			spacerQual := getFlagString(cmd, "spacer-qual")
+			if len(spacerQual) != 1 {
+				checkError(fmt.Errorf("value of flag --spacer-qual should be a single character: %s", spacerQual))
+			}
+			revcom2 := getFlagBool(cmd, "revcom-read2")
+
+			outfh, err := xopen.Wopen(outFile)
+			checkError(err)
+			defer outfh.Close()
+
+			n, err := concatPairedReads(outfh, read1, read2, alphabet, idRegexp, nSpacer, spacerQual[0], revcom2, lineWidth, bench)
+			checkError(err)
+
+			if !quiet {
+				log.Infof("%d read pairs concatenated", n)
+			}
+			return
+		}
+		for _, flag := range []string{"separator-n", "spacer-qual", "revcom-read2"} {
+			if cmd.Flags().Lookup(flag).Changed {
+				checkError(fmt.Errorf("flag --%s should be used with -1/--read1 and -2/--read2", flag))
+			}
+		}
+
 		files := getFileListFromArgsAndFile(cmd, args, true, "infile-list", true)
+		bench.addInputs(files...)
 
 		if len(files) < 2 {
 			checkError(errors.New("at least 2 files needed"))
@@ -91,6 +146,7 @@ Attention:
 
 			seqs, err := fastx.GetSeqs(file, alphabet, config.Threads, 1024, idRegexp)
 			checkError(err)
+			bench.addRecords(int64(len(seqs)))
 
 			if len(seqs) == 0 {
 				log.Warningf("no seqs found in file: %s", file)
@@ -246,6 +302,11 @@ func init() {
 
 	concateCmd.Flags().BoolP("full", "f", false, "keep all sequences, like full/outer join")
 	concateCmd.Flags().StringP("separator", "s", "|", "separator for descriptions of records with the same ID")
+	concateCmd.Flags().StringP("read1", "1", "", "read1 file, for concatenating paired-end reads")
+	concateCmd.Flags().StringP("read2", "2", "", "read2 file, for concatenating paired-end reads")
+	concateCmd.Flags().IntP("separator-n", "", 10, `number of "N" bases between concatenated paired-end reads`)
+	concateCmd.Flags().StringP("spacer-qual", "", "!", `quality character of "N" bases between concatenated paired-end reads`)
+	concateCmd.Flags().BoolP("revcom-read2", "", false, "reverse complement read2 before concatenating paired-end reads")
 }
 
 func product(lists ...[]int) (results [][]int) {
@@ -279,3 +340,56 @@ func product(lists ...[]int) (results [][]int) {
 
 	return results
 }
+
+// concatPairedReads concatenates each pair of reads in two files into
+// one record, i.e., read1 + spacer + read2, and returns the number of pairs.
+func concatPairedReads(outfh *xopen.Writer, read1, read2 string, alphabet *seq.Alphabet, idRegexp string,
+	nSpacer int, spacerQual byte, revcom2 bool, lineWidth int, bench *benchmarkStats) (int, error) {
+	reader, err := newPairedReader(alphabet, read1, read2, idRegexp, bench)
+	if err != nil {
+		return 0, err
+	}
+	defer reader.Close()
+
+	spacer := bytes.Repeat([]byte{'N'}, nSpacer)
+	spacerQuals := bytes.Repeat([]byte{spacerQual}, nSpacer)
+
+	var record1, record2 *fastx.Record
+	var id []byte
+	var n int
+	for {
+		record1, record2, err = reader.Read()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return n, err
+		}
+		id = mateBaseName(record1.ID)
+		if reader.reader1.IsFastq != reader.reader2.IsFastq {
+			return n, fmt.Errorf("concatenating FASTA and FASTQ is not allowed")
+		}
+		if reader.IsFastq() {
+			lineWidth = 0
+			fastx.ForcelyOutputFastq = true
+		}
+
+		if revcom2 {
+			record2.Seq.RevComInplace()
+		}
+
+		// ID of read1 without the mate suffix, followed by the description
+		if bytes.HasPrefix(record1.Name, record1.ID) {
+			record1.Name = append(append([]byte{}, id...), record1.Name[len(record1.ID):]...)
+		}
+		record1.ID = id
+
+		record1.Seq.Seq = append(append(record1.Seq.Seq, spacer...), record2.Seq.Seq...)
+		if reader.IsFastq() {
+			record1.Seq.Qual = append(append(record1.Seq.Qual, spacerQuals...), record2.Seq.Qual...)
+		}
+		record1.FormatToWriter(outfh, lineWidth)
+		n++
+	}
+	return n, nil
+}
@@ -0,0 +1,304 @@
+// Copyright © 2016-2024 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"sort"
+
+	"github.com/shenwei356/bio/seq"
+	"github.com/shenwei356/bio/seqio/fastx"
+	"github.com/shenwei356/xopen"
+	"github.com/spf13/cobra"
+)
+
+// consensusCmd represents the consensus command
+var consensusCmd = &cobra.Command{
+	GroupID: "misc",
+
+	Use:   "consensus",
+	Short: "build a consensus sequence from aligned sequences",
+	Long: `build a consensus sequence from aligned sequences
+
+Attention:
+  1. All sequences should be aligned, i.e., of the same length.
+  2. Letters are case-insensitive, and U is treated as T when computing
+     degenerate bases.
+  3. Only one consensus record is outputted (FASTA), with the ID given by
+     --id, for all sequences in all input files.
+
+Rules for each column:
+  1. Gaps (letters given by -G/--gap-letters) are ignored by default, i.e.,
+     frequencies are computed with the number of non-gap letters. Columns
+     with only gaps are outputted as "-". Switch on -g/--count-gaps to
+     treat gaps as a symbol, then the consensus can also be a gap.
+  2. If the coverage, i.e., the proportion of sequences with a non-gap
+     letter, is below --threshold, N (X for protein) is outputted.
+  3. The most frequent letter is outputted if its frequency is >= -m/--min-freq
+     and it's the only most frequent one. Otherwise, N (X for protein) is
+     outputted, unless -a/--ambiguous is given.
+  4. With -a/--ambiguous (only for DNA/RNA), the most frequent letters are
+     added (tied letters are added together) until their total frequency
+     reaches -m/--min-freq, and the IUPAC degenerate base of them is
+     outputted, e.g., R for A and G.
+
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		config := getConfigs(cmd)
+		alphabet := config.Alphabet
+		idRegexp := config.IDRegexp
+		outFile := config.OutFile
+		quiet := config.Quiet
+		seq.AlphabetGuessSeqLengthThreshold = config.AlphabetGuessSeqLength
+		seq.ValidateSeq = false
+		runtime.GOMAXPROCS(config.Threads)
+
+		bench := getBenchmarkStats(cmd)
+		files := getFileListFromArgsAndFile(cmd, args, true, "infile-list", true)
+		bench.addInputs(files...)
+
+		minFreq := getFlagFloat64(cmd, "min-freq")
+		threshold := getFlagFloat64(cmd, "threshold")
+		ambiguous := getFlagBool(cmd, "ambiguous")
+		countGaps := getFlagBool(cmd, "count-gaps")
+		gapLetters := getFlagString(cmd, "gap-letters")
+		id := getFlagString(cmd, "id")
+
+		if minFreq <= 0 || minFreq > 1 {
+			checkError(fmt.Errorf("value of flag -m (--min-freq) should be in range of (0, 1]"))
+		}
+		if threshold < 0 || threshold > 1 {
+			checkError(fmt.Errorf("value of flag --threshold should be in range of [0, 1]"))
+		}
+		if id == "" {
+			checkError(fmt.Errorf("value of flag --id should not be empty"))
+		}
+
+		// symbols: A-Z, '*', and gap
+		const nSymbols = 28
+		const iStop = 26
+		const iGap = 27
+		var symbolIdx [256]int
+		for i := range symbolIdx {
+			symbolIdx[i] = -1
+		}
+		for b := 'A'; b <= 'Z'; b++ {
+			symbolIdx[b] = int(b - 'A')
+			symbolIdx[b+32] = int(b - 'A')
+		}
+		symbolIdx['*'] = iStop
+		for i := 0; i < len(gapLetters); i++ {
+			symbolIdx[gapLetters[i]] = iGap
+		}
+
+		var counts []uint32 // L * nSymbols
+		var seqLen int
+		var nSeqs int
+		var firstID []byte
+		var isProtein bool
+		var checkAlphabet = true
+
+		var record *fastx.Record
+		var j int
+		for _, file := range files {
+			fastxReader, err := fastx.NewReader(alphabet, file, idRegexp)
+			checkError(err)
+			for {
+				record, err = fastxReader.Read()
+				if err != nil {
+					if err == io.EOF {
+						break
+					}
+					checkError(err)
+					break
+				}
+				bench.addRecord()
+
+				if checkAlphabet {
+					isProtein = fastxReader.Alphabet() == seq.Protein
+					checkAlphabet = false
+				}
+
+				if nSeqs == 0 {
+					seqLen = len(record.Seq.Seq)
+					firstID = []byte(string(record.ID))
+					counts = make([]uint32, seqLen*nSymbols)
+				} else if len(record.Seq.Seq) != seqLen {
+					checkError(fmt.Errorf("sequences should be aligned (of the same length): %s (%d) != %s (%d)",
+						record.ID, len(record.Seq.Seq), firstID, seqLen))
+				}
+				nSeqs++
+
+				for i, b := range record.Seq.Seq {
+					if j = symbolIdx[b]; j < 0 {
+						checkError(fmt.Errorf("invalid letter '%c' at position %d of sequence: %s", b, i+1, record.ID))
+					}
+					counts[i*nSymbols+j]++
+				}
+			}
+			fastxReader.Close()
+		}
+
+		if nSeqs == 0 {
+			if !quiet {
+				log.Warningf("no sequences found")
+			}
+			return
+		}
+
+		var ambBase byte = 'N'
+		if isProtein {
+			ambBase = 'X'
+			if ambiguous {
+				if !quiet {
+					log.Warningf("flag -a (--ambiguous) does not take effect on protein sequences")
+				}
+				ambiguous = false
+			}
+		}
+
+		consensus := make([]byte, seqLen)
+		order := make([]int, nSymbols)
+		var c []uint32
+		var nonGap, total, top uint32
+		var cum uint32
+		var bits byte
+		var k int
+		var nAmb int
+		for i := 0; i < seqLen; i++ {
+			c = counts[i*nSymbols : (i+1)*nSymbols]
+
+			nonGap = 0
+			for j = 0; j < iGap; j++ {
+				nonGap += c[j]
+			}
+			if nonGap == 0 {
+				consensus[i] = '-'
+				continue
+			}
+			if float64(nonGap)/float64(nSeqs) < threshold {
+				consensus[i] = ambBase
+				nAmb++
+				continue
+			}
+
+			total = nonGap
+			if !countGaps {
+				c[iGap] = 0
+			} else {
+				total += c[iGap]
+			}
+
+			for j = range order {
+				order[j] = j
+			}
+			sort.SliceStable(order, func(a, b int) bool { return c[order[a]] > c[order[b]] })
+			top = c[order[0]]
+
+			if !ambiguous {
+				if float64(top)/float64(total) >= minFreq && c[order[1]] < top {
+					if order[0] == iGap {
+						consensus[i] = '-'
+					} else {
+						consensus[i] = consensusSymbol(order[0])
+					}
+				} else {
+					consensus[i] = ambBase
+					nAmb++
+				}
+				continue
+			}
+
+			// adding letters until the total frequency reaches the min-freq
+			cum, bits = 0, 0
+			for k = 0; k < nSymbols && c[order[k]] > 0; k++ {
+				cum += c[order[k]]
+				if order[k] != iGap {
+					bits |= iupacBits[consensusSymbol(order[k])]
+				}
+				if float64(cum)/float64(total) >= minFreq &&
+					(k+1 == nSymbols || c[order[k+1]] < c[order[k]]) {
+					break
+				}
+			}
+			if k == 0 && order[0] < iStop { // a single letter
+				consensus[i] = consensusSymbol(order[0])
+			} else if bits == 0 {
+				consensus[i] = '-'
+			} else {
+				consensus[i] = iupacBases[bits]
+				if consensus[i] == 'T' && c['U'-'A'] > c['T'-'A'] {
+					consensus[i] = 'U'
+				}
+				nAmb++
+			}
+		}
+
+		outfh, err := xopen.Wopen(outFile)
+		checkError(err)
+		defer outfh.Close()
+
+		record, err = fastx.NewRecordWithoutValidation(seq.Unlimit, []byte(id), []byte(id), []byte{}, consensus)
+		checkError(err)
+		record.FormatToWriter(outfh, config.LineWidth)
+
+		if !quiet {
+			log.Infof("consensus of %d sequences (%d columns) built, with %d ambiguous positions", nSeqs, seqLen, nAmb)
+		}
+	},
+}
+
+// consensusSymbol returns the letter for the symbol index used in consensusCmd.
+func consensusSymbol(i int) byte {
+	if i == 26 {
+		return '*'
+	}
+	return byte('A' + i)
+}
+
+// iupacBits maps IUPAC nucleotide codes to bits of A(1), C(2), G(4) and T/U(8).
+var iupacBits = func() [256]byte {
+	var m [256]byte
+	for b, v := range map[byte]byte{
+		'A': 1, 'C': 2, 'G': 4, 'T': 8, 'U': 8,
+		'M': 3, 'R': 5, 'W': 9, 'S': 6, 'Y': 10, 'K': 12,
+		'V': 7, 'H': 11, 'D': 13, 'B': 14, 'N': 15,
+	} {
+		m[b] = v
+	}
+	return m
+}()
+
+// iupacBases maps bits of A(1), C(2), G(4) and T(8) to IUPAC nucleotide codes.
+var iupacBases = [16]byte{'N', 'A', 'C', 'M', 'G', 'R', 'S', 'V', 'T', 'W', 'Y', 'H', 'K', 'D', 'B', 'N'}
+
+func init() {
+	RootCmd.AddCommand(consensusCmd)
+
+	consensusCmd.Flags().Float64P("min-freq", "m", 0.5, "minimum frequency of the most frequent letter (or letters with -a/--ambiguous) in a column")
+	consensusCmd.Flags().BoolP("ambiguous", "a", false, "output IUPAC degenerate bases for ties or when the most frequent base is below -m/--min-freq (only for DNA/RNA)")
+	consensusCmd.Flags().Float64P("threshold", "", 0, "minimum coverage (proportion of sequences with non-gap letters) of a column, N is outputted below it")
+	consensusCmd.Flags().BoolP("count-gaps", "g", false, "count gaps as a symbol instead of ignoring them")
+	consensusCmd.Flags().StringP("gap-letters", "G", "-.", "gap letters")
+	consensusCmd.Flags().StringP("id", "", "consensus", "sequence ID of the consensus record")
+}
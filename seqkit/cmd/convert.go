@@ -65,7 +65,9 @@ var convertCmd = &cobra.Command{
 		seq.NMostCommonThreshold = getFlagPositiveInt(cmd, "thresh-B-in-n-most-common")
 		threshIllumina1p5Frac := getFlagFloat64(cmd, "thresh-illumina1.5-frac")
 
+		bench := getBenchmarkStats(cmd)
 		files := getFileListFromArgsAndFile(cmd, args, true, "infile-list", true)
+		bench.addInputs(files...)
 
 		outfh, err := xopen.Wopen(outFile)
 		checkError(err)
@@ -180,6 +182,7 @@ var convertCmd = &cobra.Command{
 					checkError(err)
 					break
 				}
+				bench.addRecord()
 				if fastxReader.IsFastq {
 					config.LineWidth = 0
 					fastx.ForcelyOutputFastq = true
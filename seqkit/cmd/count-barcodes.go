@@ -0,0 +1,413 @@
+// Copyright © 2016-2024 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+
+	"github.com/shenwei356/bio/seq"
+	"github.com/shenwei356/bio/seqio/fastx"
+	"github.com/shenwei356/xopen"
+	"github.com/spf13/cobra"
+)
+
+// countBarcodesCmd represents the count-barcodes command
+var countBarcodesCmd = &cobra.Command{
+	GroupID: "search",
+
+	Use:     "count-barcodes",
+	Aliases: []string{"count-reads-per-barcode"},
+	Short:   "count reads per barcode, without writing demultiplexed reads",
+	Long: `count reads per barcode, without writing demultiplexed reads
+
+Barcode file:
+  A 2- or 3-column tab-delimited file, with columns of sample name,
+  barcode 1 and optional barcode 2 (for dual barcodes). Blank lines and
+  lines starting with "#" are ignored. Barcodes of a file could have
+  different lengths, and they should only contain A, C, G and T.
+
+Locating barcodes:
+  1. By default, barcodes are inline ones at the 5' end of reads. Barcode 1
+     is matched with reads in positional arguments or -1/--read1, and
+     barcode 2 is matched with reads in -2/--read2, which are needed for
+     dual barcodes.
+  2. With -H/--in-header, barcodes are read from the last field of the
+     header separated by ":", e.g., "ACGTACGT+TTGACCAA" of
+     "@read1 1:N:0:ACGTACGT+TTGACCAA", where the two barcodes are
+     separated by "+".
+
+Matching:
+  1. Barcodes are compared with the case ignored, and up to
+     -m/--max-mismatch mismatches are allowed for each barcode.
+     Bases other than A, C, G, T in reads are counted as mismatches.
+  2. A read (pair) is assigned to the sample with the fewest total
+     mismatches. Reads matching no sample are counted as "undetermined",
+     and reads matching multiple samples with the same fewest mismatches
+     are counted as "ambiguous".
+
+Output (tab-delimited):
+  sample, barcode, reads, and percentage, in the order of the barcode file,
+  followed by rows of "undetermined" and "ambiguous".
+
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		config := getConfigs(cmd)
+		bench := getBenchmarkStats(cmd)
+		alphabet := config.Alphabet
+		idRegexp := config.IDRegexp
+		outFile := config.OutFile
+		quiet := config.Quiet
+		seq.AlphabetGuessSeqLengthThreshold = config.AlphabetGuessSeqLength
+		seq.ValidateSeq = false
+		runtime.GOMAXPROCS(config.Threads)
+
+		barcodeFile := getFlagString(cmd, "barcodes")
+		if barcodeFile == "" {
+			checkError(fmt.Errorf("flag -b (--barcodes) needed"))
+		}
+		maxMismatch := getFlagNonNegativeInt(cmd, "max-mismatch")
+		inHeader := getFlagBool(cmd, "in-header")
+
+		read1 := getFlagString(cmd, "read1")
+		read2 := getFlagString(cmd, "read2")
+		paired := read1 != "" || read2 != ""
+		var files []string
+		if paired {
+			if read1 == "" || read2 == "" {
+				checkError(fmt.Errorf("flag -1/--read1 and -2/--read2 needed"))
+			}
+			if read1 == read2 {
+				checkError(fmt.Errorf("values of flag -1/--read1 and -2/--read2 can not be the same"))
+			}
+			if len(args) > 0 {
+				checkError(fmt.Errorf("no positional arguments are allowed for paired reads: %s", strings.Join(args, " ")))
+			}
+			if inHeader {
+				checkError(fmt.Errorf("flag -H/--in-header is not needed for paired reads, please use reads of either end"))
+			}
+		} else {
+			files = getFileListFromArgsAndFile(cmd, args, true, "infile-list", true)
+			bench.addInputs(files...)
+		}
+
+		matcher, err := newBarcodeMatcher(barcodeFile, maxMismatch)
+		checkError(err)
+		if matcher.dual && !paired && !inHeader {
+			checkError(fmt.Errorf("dual barcodes need paired reads (-1/--read1 and -2/--read2), or barcodes in headers (-H/--in-header)"))
+		}
+		if !quiet {
+			log.Infof("%d barcode(s) loaded", len(matcher.names))
+		}
+
+		outfh, err := xopen.Wopen(outFile)
+		checkError(err)
+		defer outfh.Close()
+
+		counts := make([]int, len(matcher.names))
+		var nUndetermined, nAmbiguous, n int
+		count := func(i int) {
+			n++
+			switch i {
+			case barcodeUndetermined:
+				nUndetermined++
+			case barcodeAmbiguous:
+				nAmbiguous++
+			default:
+				counts[i]++
+			}
+		}
+
+		var record, record2 *fastx.Record
+		if paired {
+			reader, err := newPairedReader(alphabet, read1, read2, idRegexp, bench)
+			checkError(err)
+
+			for {
+				record, record2, err = reader.Read()
+				if err != nil {
+					if err == io.EOF {
+						break
+					}
+					checkError(err)
+				}
+
+				count(matcher.match(record.Seq.Seq, record2.Seq.Seq))
+			}
+			reader.Close()
+		} else {
+			var b1, b2 []byte
+			for _, file := range files {
+				fastxReader, err := fastx.NewReader(alphabet, file, idRegexp)
+				checkError(err)
+				for {
+					record, err = fastxReader.Read()
+					if err != nil {
+						if err == io.EOF {
+							break
+						}
+						checkError(err)
+						break
+					}
+					bench.addRecord()
+
+					if inHeader {
+						b1, b2 = barcodesFromHeader(record.Name)
+						count(matcher.match(b1, b2))
+					} else {
+						count(matcher.match(record.Seq.Seq, nil))
+					}
+				}
+				fastxReader.Close()
+			}
+		}
+
+		pct := func(c int) float64 {
+			if n == 0 {
+				return 0
+			}
+			return float64(c) / float64(n) * 100
+		}
+		outfh.WriteString("sample\tbarcode\treads\tpercentage\n")
+		for i, name := range matcher.names {
+			outfh.WriteString(fmt.Sprintf("%s\t%s\t%d\t%.2f\n", name, matcher.label(i), counts[i], pct(counts[i])))
+		}
+		outfh.WriteString(fmt.Sprintf("undetermined\t-\t%d\t%.2f\n", nUndetermined, pct(nUndetermined)))
+		outfh.WriteString(fmt.Sprintf("ambiguous\t-\t%d\t%.2f\n", nAmbiguous, pct(nAmbiguous)))
+
+		if !quiet {
+			log.Infof("%d reads (pairs) processed, %d undetermined, %d ambiguous", n, nUndetermined, nAmbiguous)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(countBarcodesCmd)
+
+	countBarcodesCmd.Flags().StringP("barcodes", "b", "", `tab-delimited barcode file, with columns of sample name, barcode 1 and optional barcode 2`)
+	countBarcodesCmd.Flags().IntP("max-mismatch", "m", 0, "max mismatches allowed for each barcode")
+	countBarcodesCmd.Flags().BoolP("in-header", "H", false, `barcodes are in the last field of headers, e.g., "1:N:0:ACGTACGT+TTGACCAA"`)
+	countBarcodesCmd.Flags().StringP("read1", "1", "", "(gzipped) read1 file, for inline dual barcodes")
+	countBarcodesCmd.Flags().StringP("read2", "2", "", "(gzipped) read2 file, for inline dual barcodes")
+}
+
+const (
+	barcodeUndetermined = -1
+	barcodeAmbiguous    = -2
+)
+
+// barcodeMatcher assigns reads to samples by barcodes.
+type barcodeMatcher struct {
+	names    []string
+	barcodes [][2][]byte // upper case
+	dual     bool
+
+	maxMismatch int
+	exact       map[string]int // exact barcode (pair) -> index, -2 for duplicates
+	minLen      [2]int
+	maxLen      [2]int
+}
+
+func newBarcodeMatcher(file string, maxMismatch int) (*barcodeMatcher, error) {
+	fh, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("load barcodes from '%s': %s", file, err)
+	}
+	defer fh.Close()
+
+	m := &barcodeMatcher{
+		maxMismatch: maxMismatch,
+		names:       make([]string, 0, 96),
+		barcodes:    make([][2][]byte, 0, 96),
+		exact:       make(map[string]int, 96),
+	}
+
+	var text string
+	var items []string
+	var b [2][]byte
+	var nDual int
+	names := make(map[string]struct{}, 96)
+	scanner := bufio.NewScanner(fh)
+	for scanner.Scan() {
+		text = strings.TrimSpace(scanner.Text())
+		if text == "" || text[0] == '#' {
+			continue
+		}
+		items = strings.Split(text, "\t")
+		if len(items) < 2 || len(items) > 3 {
+			return nil, fmt.Errorf("invalid barcode line (2 or 3 columns needed): %s", text)
+		}
+		if _, ok := names[items[0]]; ok {
+			return nil, fmt.Errorf("duplicated sample name: %s", items[0])
+		}
+		names[items[0]] = struct{}{}
+
+		b = [2][]byte{bytes.ToUpper([]byte(items[1])), nil}
+		if len(items) == 3 && items[2] != "" {
+			b[1] = bytes.ToUpper([]byte(items[2]))
+			nDual++
+		}
+		for _, bc := range b {
+			if !barcodeIsACGT(bc) {
+				return nil, fmt.Errorf("invalid barcode of sample %s, only A, C, G, T are allowed: %s", items[0], bc)
+			}
+		}
+		if len(b[0]) == 0 {
+			return nil, fmt.Errorf("empty barcode of sample: %s", items[0])
+		}
+
+		key := string(b[0]) + "+" + string(b[1])
+		if i, ok := m.exact[key]; ok {
+			return nil, fmt.Errorf("duplicated barcode(s) for samples %s and %s: %s", m.names[i], items[0], m.label(i))
+		}
+		m.exact[key] = len(m.names)
+		m.names = append(m.names, items[0])
+		m.barcodes = append(m.barcodes, b)
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, fmt.Errorf("load barcodes from '%s': %s", file, err)
+	}
+	if len(m.names) == 0 {
+		return nil, fmt.Errorf("no valid barcodes found in file: %s", file)
+	}
+	if nDual > 0 && nDual < len(m.names) {
+		return nil, fmt.Errorf("single and dual barcodes can not be mixed in file: %s", file)
+	}
+	m.dual = nDual > 0
+
+	m.minLen = [2]int{len(m.barcodes[0][0]), len(m.barcodes[0][1])}
+	m.maxLen = m.minLen
+	for _, b = range m.barcodes {
+		for j := 0; j < 2; j++ {
+			if len(b[j]) < m.minLen[j] {
+				m.minLen[j] = len(b[j])
+			}
+			if len(b[j]) > m.maxLen[j] {
+				m.maxLen[j] = len(b[j])
+			}
+		}
+	}
+	return m, nil
+}
+
+// barcodeIsACGT checks whether an upper-case barcode only contains A, C, G and T.
+func barcodeIsACGT(b []byte) bool {
+	for _, c := range b {
+		switch c {
+		case 'A', 'C', 'G', 'T':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// label returns the barcode (pair) of a sample.
+func (m *barcodeMatcher) label(i int) string {
+	if m.barcodes[i][1] == nil {
+		return string(m.barcodes[i][0])
+	}
+	return string(m.barcodes[i][0]) + "+" + string(m.barcodes[i][1])
+}
+
+// match returns the index of the sample for the sequences (or barcodes) s1
+// and s2, barcodeUndetermined or barcodeAmbiguous.
+func (m *barcodeMatcher) match(s1, s2 []byte) int {
+	// exact match, only when all barcodes have the same length
+	if m.minLen[0] == m.maxLen[0] && m.minLen[1] == m.maxLen[1] &&
+		len(s1) >= m.minLen[0] && (!m.dual || len(s2) >= m.minLen[1]) {
+		key := string(bytes.ToUpper(s1[:m.minLen[0]]))
+		if m.dual {
+			key += "+" + string(bytes.ToUpper(s2[:m.minLen[1]]))
+		} else {
+			key += "+"
+		}
+		if i, ok := m.exact[key]; ok {
+			return i
+		}
+		if m.maxMismatch == 0 {
+			return barcodeUndetermined
+		}
+	}
+
+	best, bestMis := barcodeUndetermined, -1
+	var mis, mis2 int
+	var ok bool
+	for i, b := range m.barcodes {
+		if mis, ok = barcodeMismatches(b[0], s1, m.maxMismatch); !ok {
+			continue
+		}
+		if m.dual {
+			if mis2, ok = barcodeMismatches(b[1], s2, m.maxMismatch); !ok {
+				continue
+			}
+			mis += mis2
+		}
+		if bestMis < 0 || mis < bestMis {
+			best, bestMis = i, mis
+		} else if mis == bestMis {
+			best = barcodeAmbiguous
+		}
+	}
+	return best
+}
+
+// barcodeMismatches counts mismatches between a barcode and the prefix of s,
+// and returns false if s is shorter than the barcode or there are more than
+// max mismatches.
+func barcodeMismatches(barcode, s []byte, max int) (int, bool) {
+	if len(s) < len(barcode) {
+		return 0, false
+	}
+	var n int
+	var c byte
+	for i, b := range barcode {
+		c = s[i]
+		if c >= 'a' && c <= 'z' {
+			c -= 32
+		}
+		if c != b {
+			n++
+			if n > max {
+				return n, false
+			}
+		}
+	}
+	return n, true
+}
+
+// barcodesFromHeader extracts barcodes from the last field of a FASTQ header
+// separated by ":", e.g., "ACGTACGT+TTGACCAA" of "read1 1:N:0:ACGTACGT+TTGACCAA".
+func barcodesFromHeader(head []byte) ([]byte, []byte) {
+	if i := bytes.LastIndexByte(head, ':'); i >= 0 {
+		head = head[i+1:]
+	}
+	head = bytes.TrimSpace(head)
+	if i := bytes.IndexByte(head, '+'); i >= 0 {
+		return head[:i], head[i+1:]
+	}
+	return head, nil
+}
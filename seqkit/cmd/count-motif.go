@@ -0,0 +1,459 @@
+// Copyright © 2016-2024 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/shenwei356/bio/seq"
+	"github.com/shenwei356/bio/seqio/fastx"
+	"github.com/shenwei356/bwt"
+	"github.com/shenwei356/bwt/fmi"
+	"github.com/shenwei356/xopen"
+	"github.com/spf13/cobra"
+)
+
+// countMotifCmd represents the count-motif command
+var countMotifCmd = &cobra.Command{
+	GroupID: "search",
+
+	Use:     "count-motif",
+	Aliases: []string{"count-overlaps"},
+	Short:   "count occurrences of motifs in each sequence, mismatch allowed",
+	Long: `count occurrences of motifs in each sequence, mismatch allowed
+
+Output is a TSV matrix, with the first column of sequence IDs and one column
+for each motif, in the order of motifs given. Column names are motifs given
+by -p/--motif, or IDs of records in the FASTA file given by -f/--motif-file.
+
+Attention:
+  1. Motifs are matched in the same ways as "seqkit locate": plain sequences,
+     regular expressions (-r/--use-regexp), degenerate bases (-d/--degenerate),
+     or plain sequences with mismatches (-m/--max-mismatch, via FM-index).
+  2. Non-overlapping occurrences, found from left to right, are counted by
+     default. Switch on --overlapping to count all occurrences, e.g., "AA"
+     occurs twice in "AAA" with --overlapping, and once otherwise.
+     For -r/--use-regexp, an overlapping occurrence starts from the next
+     position of the start of the previous one. Empty matches are not counted.
+     Anchors like ^, $ and \b are always matched against the whole sequence.
+  3. Only the positive strand is searched by default. Switch on --both-strands
+     to also search the negative strand, and counts of the two strands are
+     summed. So a palindromic motif like "GAATTC" is counted twice for each
+     site. It's ignored for protein sequences.
+  4. Records are processed in parallel with "-j/--threads", and the output
+     order is the same as the input.
+
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		config := getConfigs(cmd)
+		alphabet := config.Alphabet
+		idRegexp := config.IDRegexp
+		outFile := config.OutFile
+		quiet := config.Quiet
+		seq.AlphabetGuessSeqLengthThreshold = config.AlphabetGuessSeqLength
+		seq.ValidateSeq = false
+		runtime.GOMAXPROCS(config.Threads)
+
+		bwt.CheckEndSymbol = false
+
+		bench := getBenchmarkStats(cmd)
+		files := getFileListFromArgsAndFile(cmd, args, true, "infile-list", true)
+		bench.addInputs(files...)
+
+		motifs0 := getFlagStringSlice(cmd, "motif")
+		motifFile := getFlagString(cmd, "motif-file")
+		degenerate := getFlagBool(cmd, "degenerate")
+		useRegexp := getFlagBool(cmd, "use-regexp")
+		ignoreCase := getFlagBool(cmd, "ignore-case")
+		mismatches := getFlagNonNegativeInt(cmd, "max-mismatch")
+		overlapping := getFlagBool(cmd, "overlapping")
+		bothStrands := getFlagBool(cmd, "both-strands")
+
+		if len(motifs0) == 0 && motifFile == "" {
+			checkError(fmt.Errorf("one of flags -p (--motif) and -f (--motif-file) needed"))
+		}
+		if len(motifs0) > 0 && motifFile != "" {
+			checkError(fmt.Errorf("flags -p (--motif) and -f (--motif-file) are not compatible"))
+		}
+		if degenerate && useRegexp {
+			checkError(fmt.Errorf("flags -d (--degenerate) and -r (--use-regexp) are not compatible"))
+		}
+		if mismatches > 0 && (degenerate || useRegexp) {
+			checkError(fmt.Errorf("flags -d (--degenerate) and -r (--use-regexp) are not allowed when giving flag -m (--max-mismatch)"))
+		}
+
+		// motifs in order
+		var names []string
+		var motifs [][]byte
+		if motifFile != "" {
+			fastxReader, err := fastx.NewReader(seq.Unlimit, motifFile, idRegexp)
+			checkError(err)
+			for {
+				record, err := fastxReader.Read()
+				if err != nil {
+					if err == io.EOF {
+						break
+					}
+					checkError(err)
+					break
+				}
+				names = append(names, string(record.ID))
+				motifs = append(motifs, []byte(string(record.Seq.Seq)))
+			}
+			fastxReader.Close()
+			if len(motifs) == 0 {
+				checkError(fmt.Errorf("no FASTA sequences found in motif file: %s", motifFile))
+			}
+		} else {
+			for _, p := range motifs0 {
+				if p == "" {
+					continue
+				}
+				names = append(names, p)
+				motifs = append(motifs, []byte(p))
+			}
+			if len(motifs) == 0 {
+				checkError(fmt.Errorf("no valid motifs given"))
+			}
+		}
+
+		var regexps, regexpsNext []*regexp.Regexp
+		if degenerate || useRegexp {
+			regexps = make([]*regexp.Regexp, len(motifs))
+			if overlapping {
+				regexpsNext = make([]*regexp.Regexp, len(motifs))
+			}
+			var s string
+			for i, m := range motifs {
+				if degenerate {
+					pattern2seq, err := seq.NewSeq(seq.Unlimit, m)
+					checkError(err)
+					s = pattern2seq.Degenerate2Regexp()
+				} else {
+					s = string(m)
+				}
+				if ignoreCase {
+					s = "(?i)" + s
+				}
+				re, err := regexp.Compile(s)
+				if err != nil {
+					checkError(fmt.Errorf("invalid motif: %s: %s", names[i], err))
+				}
+				regexps[i] = re
+
+				if overlapping {
+					// the previous character is consumed, so that anchors
+					// like ^, \b and $ are matched against the whole sequence.
+					regexpsNext[i] = regexp.MustCompile(`(?s:.)(?:` + s + `)`)
+				}
+			}
+		} else {
+			for i, m := range motifs {
+				if mismatches > len(m) {
+					checkError(fmt.Errorf("mismatch should be <= length of motif: %s", names[i]))
+				}
+				if ignoreCase {
+					motifs[i] = bytes.ToLower(m)
+				}
+			}
+		}
+		if !quiet {
+			log.Infof("%d motifs loaded", len(motifs))
+		}
+
+		counter := &motifCounter{
+			motifs:      motifs,
+			regexps:     regexps,
+			regexpsNext: regexpsNext,
+			mismatches:  mismatches,
+			overlapping: overlapping,
+			ignoreCase:  ignoreCase,
+		}
+
+		outfh, err := xopen.Wopen(outFile)
+		checkError(err)
+		defer outfh.Close()
+
+		outfh.WriteString("seqID")
+		for _, name := range names {
+			outfh.WriteString("\t" + name)
+		}
+		outfh.WriteString("\n")
+
+		type Arecord struct {
+			id   uint64
+			line []byte
+		}
+
+		var wg sync.WaitGroup
+		ch := make(chan *Arecord, config.Threads)
+		tokens := make(chan int, config.Threads)
+
+		done := make(chan int)
+		go func() {
+			m := make(map[uint64]*Arecord, config.Threads)
+			var id uint64 = 1
+			var ok bool
+			var _r *Arecord
+			for r := range ch {
+				m[r.id] = r
+				for {
+					if _r, ok = m[id]; !ok {
+						break
+					}
+					outfh.Write(_r.line)
+					delete(m, id)
+					id++
+				}
+			}
+			done <- 1
+		}()
+
+		var record *fastx.Record
+		var id uint64
+		var onlyPositiveStrand, warned bool
+		for _, file := range files {
+			fastxReader, err := fastx.NewReader(alphabet, file, idRegexp)
+			checkError(err)
+
+			checkAlphabet := true
+			onlyPositiveStrand = !bothStrands
+			for {
+				record, err = fastxReader.Read()
+				if err != nil {
+					if err == io.EOF {
+						break
+					}
+					checkError(err)
+					break
+				}
+				bench.addRecord()
+
+				if checkAlphabet {
+					if bothStrands && (fastxReader.Alphabet() == seq.Unlimit || fastxReader.Alphabet() == seq.Protein) {
+						if !warned && !quiet {
+							log.Warningf("flag --both-strands is ignored for protein/unlimit sequences")
+							warned = true
+						}
+						onlyPositiveStrand = true
+					}
+					checkAlphabet = false
+				}
+
+				tokens <- 1
+				wg.Add(1)
+				id++
+				go func(record *fastx.Record, id uint64, onlyPositiveStrand bool) {
+					defer func() {
+						wg.Done()
+						<-tokens
+					}()
+
+					counts := counter.Count(record.Seq.Seq, nil)
+					if !onlyPositiveStrand {
+						counts = counter.Count(record.Seq.RevCom().Seq, counts)
+					}
+
+					line := make([]byte, 0, len(record.ID)+len(counts)*4+1)
+					line = append(line, record.ID...)
+					for _, c := range counts {
+						line = append(line, '\t')
+						line = strconv.AppendInt(line, int64(c), 10)
+					}
+					line = append(line, '\n')
+					ch <- &Arecord{id: id, line: line}
+				}(record.Clone(), id, onlyPositiveStrand)
+			}
+			fastxReader.Close()
+		}
+
+		wg.Wait()
+		close(ch)
+		<-done
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(countMotifCmd)
+
+	countMotifCmd.Flags().StringSliceP("motif", "p", []string{}, `motif (multiple values supported. Attention: use double quotation marks for patterns containing comma, e.g., -p '"A{2,}"')`)
+	countMotifCmd.Flags().StringP("motif-file", "f", "", "motif file (FASTA format)")
+	countMotifCmd.Flags().BoolP("degenerate", "d", false, "motifs contain degenerate bases")
+	countMotifCmd.Flags().BoolP("use-regexp", "r", false, "motifs are regular expressions")
+	countMotifCmd.Flags().BoolP("ignore-case", "i", false, "ignore case")
+	countMotifCmd.Flags().IntP("max-mismatch", "m", 0, "max mismatch when matching by seq")
+	countMotifCmd.Flags().BoolP("overlapping", "", false, "count overlapping occurrences")
+	countMotifCmd.Flags().BoolP("both-strands", "", false, "also search on the negative strand, counts of the two strands are summed")
+}
+
+// motifCounter counts occurrences of motifs in sequences.
+// It's safe for concurrent use.
+type motifCounter struct {
+	motifs      [][]byte
+	regexps     []*regexp.Regexp // for -d and -r
+	regexpsNext []*regexp.Regexp // for -d and -r with --overlapping, see countRegexp
+	mismatches  int
+	overlapping bool
+	ignoreCase  bool
+}
+
+// Count adds the numbers of occurrences of all motifs in s to counts,
+// a new slice is created if counts is nil.
+func (c *motifCounter) Count(s []byte, counts []int) []int {
+	if counts == nil {
+		counts = make([]int, len(c.motifs))
+	}
+	if len(s) == 0 {
+		return counts
+	}
+
+	if c.regexps != nil {
+		for i, re := range c.regexps {
+			if c.overlapping {
+				counts[i] += c.countRegexpOverlapping(re, c.regexpsNext[i], s)
+			} else {
+				counts[i] += c.countRegexp(re, s)
+			}
+		}
+		return counts
+	}
+
+	if c.ignoreCase {
+		s = bytes.ToLower(s)
+	}
+
+	if c.mismatches == 0 {
+		for i, m := range c.motifs {
+			counts[i] += c.countExact(m, s)
+		}
+		return counts
+	}
+
+	sfmi := fmi.NewFMIndex()
+	_, err := sfmi.Transform(s)
+	if err != nil {
+		checkError(fmt.Errorf("fail to build FMIndex for sequence: %s", err))
+	}
+	for i, m := range c.motifs {
+		locs, err := sfmi.Locate(m, c.mismatches)
+		if err != nil {
+			checkError(fmt.Errorf("fail to search motif '%s': %s", m, err))
+		}
+		counts[i] += c.countLocations(locs, len(m), len(s))
+	}
+	return counts
+}
+
+func (c *motifCounter) countExact(m, s []byte) int {
+	if len(m) == 0 {
+		return 0
+	}
+	var n, i, j int
+	step := len(m)
+	if c.overlapping {
+		step = 1
+	}
+	for {
+		j = bytes.Index(s[i:], m)
+		if j < 0 {
+			break
+		}
+		n++
+		i += j + step
+		if i >= len(s) {
+			break
+		}
+	}
+	return n
+}
+
+// countRegexp counts non-overlapping and non-empty matches.
+func (c *motifCounter) countRegexp(re *regexp.Regexp, s []byte) int {
+	var n int
+	for _, loc := range re.FindAllIndex(s, -1) {
+		if loc[1] > loc[0] {
+			n++
+		}
+	}
+	return n
+}
+
+// countRegexpOverlapping counts overlapping and non-empty matches.
+// The sequence is not re-sliced at the start of a search, which breaks
+// anchors like ^ and \b. Instead, the search of a match starting from i
+// uses reNext, i.e., "(?s:.)(?:re)", on s[i-1:], where the character
+// before i is consumed as the context.
+func (c *motifCounter) countRegexpOverlapping(re, reNext *regexp.Regexp, s []byte) int {
+	var n, i, start, end int
+	var loc []int
+	for i < len(s) {
+		if i == 0 {
+			loc = re.FindIndex(s)
+			if loc == nil {
+				break
+			}
+			start, end = loc[0], loc[1]
+		} else {
+			loc = reNext.FindIndex(s[i-1:])
+			if loc == nil {
+				break
+			}
+			start, end = i+loc[0], i-1+loc[1]
+		}
+		if end > start {
+			n++
+		}
+		i = start + 1
+	}
+	return n
+}
+
+// countLocations counts locations from FM-index, which are overlapping.
+func (c *motifCounter) countLocations(locs []int, m int, l int) int {
+	if c.overlapping {
+		var n int
+		for _, i := range locs {
+			if i+m <= l {
+				n++
+			}
+		}
+		return n
+	}
+
+	sort.Ints(locs)
+	var n int
+	next := 0
+	for _, i := range locs {
+		if i < next || i+m > l {
+			continue
+		}
+		n++
+		next = i + m
+	}
+	return n
+}
@@ -0,0 +1,245 @@
+// Copyright © 2016-2024 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"runtime"
+
+	"github.com/pkg/errors"
+	"github.com/shenwei356/bio/seq"
+	"github.com/shenwei356/bio/seqio/fastx"
+	"github.com/shenwei356/xopen"
+	"github.com/spf13/cobra"
+)
+
+// deinterleaveCmd represents the deinterleave command
+var deinterleaveCmd = &cobra.Command{
+	GroupID: "set",
+
+	Use:   "deinterleave",
+	Short: "split interleaved paired-end reads into two files, or check the consistency",
+	Long: `split interleaved paired-end reads into two files, or check the consistency
+
+Consecutive records in the input, i.e., the 1st and 2nd, the 3rd and 4th,
+and so on, are treated as mates. Mates should share the same base read name,
+i.e., the ID parsed by --id-regexp, with the suffix '/1' or '/2' removed.
+
+Attention:
+  1. Both flags -1/--out1 and -2/--out2 are needed for splitting reads.
+     The program stops with an error on the first inconsistent pair.
+  2. Use --check-only for validating the input without writing reads.
+     The numbers of validated and inconsistent pairs, and the first
+     inconsistent pair are reported, and the program exits with a non-zero
+     status if any inconsistency, which is handy for CI.
+  3. A pair never spans two input files, and a file with an odd number of
+     records is inconsistent.
+
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		config := getConfigs(cmd)
+		alphabet := config.Alphabet
+		idRegexp := config.IDRegexp
+		lineWidth := config.LineWidth
+		seq.AlphabetGuessSeqLengthThreshold = config.AlphabetGuessSeqLength
+		seq.ValidateSeq = false
+		runtime.GOMAXPROCS(config.Threads)
+
+		out1 := getFlagString(cmd, "out1")
+		out2 := getFlagString(cmd, "out2")
+		checkOnly := getFlagBool(cmd, "check-only")
+
+		bench := getBenchmarkStats(cmd)
+		files := getFileListFromArgsAndFile(cmd, args, true, "infile-list", true)
+		bench.addInputs(files...)
+
+		if checkOnly {
+			if out1 != "" || out2 != "" {
+				checkError(fmt.Errorf("flag --check-only is not compatible with -1/--out1 and -2/--out2"))
+			}
+
+			outfh, err := xopen.Wopen(config.OutFile)
+			checkError(err)
+
+			result := &deinterleaveCheckResult{}
+			for _, file := range files {
+				checkError(deinterleaveFile(alphabet, idRegexp, file, result, nil, bench))
+			}
+			result.write(outfh)
+			outfh.Close()
+
+			if result.inconsistent > 0 {
+				os.Exit(1)
+			}
+			return
+		}
+
+		if out1 == "" || out2 == "" {
+			checkError(fmt.Errorf("flag -1/--out1 and -2/--out2 needed"))
+		}
+		if out1 == out2 {
+			checkError(fmt.Errorf("values of flag -1/--out1 and -2/--out2 can not be the same"))
+		}
+
+		outfh1, err := xopen.Wopen(out1)
+		checkError(err)
+		defer outfh1.Close()
+		outfh2, err := xopen.Wopen(out2)
+		checkError(err)
+		defer outfh2.Close()
+
+		result := &deinterleaveCheckResult{}
+		write := func(isFastq bool, record1, record2 *fastx.Record) error {
+			if result.inconsistent > 0 {
+				return fmt.Errorf("inconsistent pair #%d in %s: %s, %s",
+					result.first.idx, result.first.file, result.first.id1, result.first.id2)
+			}
+			if isFastq {
+				config.LineWidth = 0
+				fastx.ForcelyOutputFastq = true
+			}
+			record1.FormatToWriter(outfh1, config.LineWidth)
+			record2.FormatToWriter(outfh2, config.LineWidth)
+			return nil
+		}
+		for _, file := range files {
+			checkError(deinterleaveFile(alphabet, idRegexp, file, result, write, bench))
+			if result.inconsistent > 0 { // the last record of a file with an odd number of records
+				checkError(fmt.Errorf("unpaired record #%d in %s: %s", result.first.idx*2-1, result.first.file, result.first.id1))
+			}
+			config.LineWidth = lineWidth
+		}
+
+		if !config.Quiet {
+			log.Infof("%d pairs written to %s and %s", result.pairs, out1, out2)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(deinterleaveCmd)
+
+	deinterleaveCmd.Flags().StringP("out1", "1", "", "output file of read 1")
+	deinterleaveCmd.Flags().StringP("out2", "2", "", "output file of read 2")
+	deinterleaveCmd.Flags().BoolP("check-only", "", false, "only check if consecutive records are mates without writing reads, and exit with a non-zero status if any inconsistency")
+}
+
+// deinterleavePair is an inconsistent pair, with its index in the file.
+type deinterleavePair struct {
+	file     string
+	idx      uint64
+	id1, id2 string // id2 is empty for the unpaired last record
+}
+
+// deinterleaveCheckResult is the result of checking interleaved files.
+type deinterleaveCheckResult struct {
+	pairs        uint64
+	inconsistent uint64
+
+	first deinterleavePair
+}
+
+var reMateSuffix = regexp.MustCompile(`/[12]$`)
+
+// mateBaseName returns the read name with the suffix '/1' or '/2' removed.
+func mateBaseName(id []byte) []byte {
+	if loc := reMateSuffix.FindIndex(id); loc != nil {
+		return id[:loc[0]]
+	}
+	return id
+}
+
+// deinterleaveFile checks consecutive records of a file, and calls write for
+// every consistent pair if write is not nil.
+func deinterleaveFile(alphabet *seq.Alphabet, idRegexp string, file string,
+	result *deinterleaveCheckResult, write func(bool, *fastx.Record, *fastx.Record) error, bench *benchmarkStats) error {
+
+	reader, err := fastx.NewReader(alphabet, file, idRegexp)
+	if err != nil {
+		return errors.Wrap(err, file)
+	}
+	defer reader.Close()
+
+	var record, record1 *fastx.Record
+	var idx uint64
+	for {
+		record, err = reader.Read()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return errors.Wrap(err, file)
+		}
+		bench.addRecord()
+
+		if record1 == nil {
+			record1 = record.Clone()
+			continue
+		}
+
+		idx++
+		if bytes.Equal(mateBaseName(record1.ID), mateBaseName(record.ID)) {
+			result.pairs++
+			if write != nil {
+				if err = write(reader.IsFastq, record1, record); err != nil {
+					return err
+				}
+			}
+		} else {
+			result.inconsistent++
+			if result.inconsistent == 1 {
+				result.first = deinterleavePair{file: file, idx: idx, id1: string(record1.ID), id2: string(record.ID)}
+			}
+			if write != nil {
+				return write(reader.IsFastq, record1, record)
+			}
+		}
+		record1 = nil
+	}
+
+	if record1 != nil {
+		idx++
+		result.inconsistent++
+		if result.inconsistent == 1 {
+			result.first = deinterleavePair{file: file, idx: idx, id1: string(record1.ID)}
+		}
+	}
+	return nil
+}
+
+func (r *deinterleaveCheckResult) write(outfh *xopen.Writer) {
+	fmt.Fprintf(outfh, "pairs validated: %d\n", r.pairs)
+	fmt.Fprintf(outfh, "inconsistent pairs: %d\n", r.inconsistent)
+	if r.inconsistent == 0 {
+		return
+	}
+	if r.first.id2 == "" {
+		fmt.Fprintf(outfh, "first inconsistency: unpaired last record #%d in %s: %s\n",
+			r.first.idx*2-1, r.first.file, r.first.id1)
+	} else {
+		fmt.Fprintf(outfh, "first inconsistency: pair #%d (records #%d and #%d) in %s: %s, %s\n",
+			r.first.idx, r.first.idx*2-1, r.first.idx*2, r.first.file, r.first.id1, r.first.id2)
+	}
+}
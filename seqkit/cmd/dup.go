@@ -54,7 +54,9 @@ You may need "seqkit rename" to make the the sequence IDs unique.
 
 		times := getFlagPositiveInt(cmd, "times")
 
+		bench := getBenchmarkStats(cmd)
 		files := getFileListFromArgsAndFile(cmd, args, true, "infile-list", true)
+		bench.addInputs(files...)
 
 		outfh, err := xopen.Wopen(outFile)
 		checkError(err)
@@ -75,6 +77,7 @@ You may need "seqkit rename" to make the the sequence IDs unique.
 					checkError(err)
 					break
 				}
+				bench.addRecord()
 				if fastxReader.IsFastq {
 					fastx.ForcelyOutputFastq = true
 				}
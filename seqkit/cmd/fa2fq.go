@@ -21,10 +21,13 @@
 package cmd
 
 import (
+	"bufio"
 	"bytes"
 	"fmt"
 	"io"
 	"runtime"
+	"strconv"
+	"strings"
 
 	"github.com/shenwei356/bio/seq"
 	"github.com/shenwei356/bio/seqio/fastx"
@@ -37,13 +40,30 @@ var fa2fqCmd = &cobra.Command{
 	GroupID: "format",
 
 	Use:   "fa2fq",
-	Short: "retrieve corresponding FASTQ records by a FASTA file",
-	Long: `retrieve corresponding FASTQ records by a FASTA file
+	Short: "retrieve corresponding FASTQ records by a FASTA file, or add fake qualities",
+	Long: `retrieve corresponding FASTQ records by a FASTA file, or add fake qualities
+
+Two modes:
+  a) With -f/--fasta-file, FASTQ records in the input files are searched
+     for FASTA records with the same IDs, and the matched regions are outputted.
+  b) Without -f/--fasta-file, input FASTA records are converted to FASTQ
+     with synthetic qualities, i.e., the reverse of "seqkit fq2fa".
 
 Attention:
   1. We assume the FASTA file comes from the FASTQ file,
      so they share sequence IDs, and sequences in FASTA
      should be subseq of sequences in FASTQ file.
+  2. Synthetic qualities are placeholders for tools only accepting FASTQ,
+     they should never be treated as real base calling qualities.
+     A Phred score of --qual (default 40) is used for all bases, and the
+     encoding is Phred+33 by default (-b/--qual-ascii-base).
+  3. Per-record qualities can be given via --qual-from-file, a three-column
+     tab-delimited file of sequence IDs, types of qualities, and qualities.
+     The type is either "phred" for a Phred score of all bases of the record,
+     or "string" for an encoded quality string with the same length of the
+     sequence. Records not in the file use the value of --qual. E.g.,
+         read_1    phred     30
+         read_2    string    IIIII?????
 
 `,
 	Run: func(cmd *cobra.Command, args []string) {
@@ -57,12 +77,39 @@ Attention:
 		runtime.GOMAXPROCS(config.Threads)
 
 		fileFasta := getFlagString(cmd, "fasta-file")
-		if fileFasta == "" {
-			checkError(fmt.Errorf("flag -f (--fasta-file) needed"))
-		}
 		onlyPositiveStrand := getFlagBool(cmd, "only-positive-strand")
 
+		bench := getBenchmarkStats(cmd)
 		files := getFileListFromArgsAndFile(cmd, args, true, "infile-list", true)
+		bench.addInputs(files...)
+
+		if fileFasta == "" {
+			qual := getFlagNonNegativeInt(cmd, "qual")
+			qBase := getFlagPositiveInt(cmd, "qual-ascii-base")
+			if qBase+qual > 126 {
+				checkError(fmt.Errorf("the value of --qual (%d) is too big for the ASCII base %d", qual, qBase))
+			}
+
+			var quals map[string]fa2fqQual
+			qualFile := getFlagString(cmd, "qual-from-file")
+			if qualFile != "" {
+				var err error
+				quals, err = fa2fqReadQuals(qualFile, qBase)
+				checkError(err)
+				if !config.Quiet {
+					log.Infof("%d qualities loaded from %s", len(quals), qualFile)
+				}
+			}
+
+			log.Warningf("qualities are synthetic (Phred %d, ASCII base %d), do not treat them as real data", qual, qBase)
+
+			outfh, err := xopen.Wopen(outFile)
+			checkError(err)
+			defer outfh.Close()
+
+			checkError(fa2fqFakeQual(outfh, files, alphabet, idRegexp, byte(qBase+qual), qBase, quals, bench))
+			return
+		}
 
 		records, err := fastx.GetSeqsMap(fileFasta, seq.Unlimit, config.Threads, 10, "")
 		checkError(err)
@@ -94,6 +141,7 @@ Attention:
 					checkError(err)
 					break
 				}
+				bench.addRecord()
 
 				if checkingFastq && !fastxReader.IsFastq {
 					checkError(fmt.Errorf("this command only works for FASTQ format"))
@@ -147,4 +195,130 @@ func init() {
 
 	fa2fqCmd.Flags().StringP("fasta-file", "f", "", "FASTA file)")
 	fa2fqCmd.Flags().BoolP("only-positive-strand", "P", false, "only search on positive strand")
+	fa2fqCmd.Flags().IntP("qual", "", 40, "Phred quality score of all bases for converting FASTA to FASTQ without -f/--fasta-file")
+	fa2fqCmd.Flags().StringP("qual-from-file", "", "", "tab-delimited file of sequence IDs, types (phred or string) and qualities of each record")
+	fa2fqCmd.Flags().IntP("qual-ascii-base", "b", 33, "ASCII BASE, 33 for Phred+33")
+}
+
+// fa2fqFakeQual converts FASTA records to FASTQ with synthetic qualities.
+func fa2fqFakeQual(outfh *xopen.Writer, files []string, alphabet *seq.Alphabet, idRegexp string,
+	q byte, qBase int, quals map[string]fa2fqQual, bench *benchmarkStats) error {
+	var record *fastx.Record
+	var qs fa2fqQual
+	var ok bool
+	var qual []byte
+	var b byte
+	var nFromFile int
+	for _, file := range files {
+		fastxReader, err := fastx.NewReader(alphabet, file, idRegexp)
+		if err != nil {
+			return err
+		}
+
+		for {
+			record, err = fastxReader.Read()
+			if err != nil {
+				if err == io.EOF {
+					break
+				}
+				return err
+			}
+			bench.addRecord()
+
+			if fastxReader.IsFastq {
+				fastxReader.Close()
+				return fmt.Errorf("%s: FASTA format needed for converting to FASTQ", file)
+			}
+
+			if cap(qual) < len(record.Seq.Seq) {
+				qual = make([]byte, len(record.Seq.Seq))
+			}
+			qual = qual[:len(record.Seq.Seq)]
+
+			b = q
+			if qs, ok = quals[string(record.ID)]; ok {
+				nFromFile++
+				if qs.phred >= 0 {
+					b = byte(qBase + qs.phred)
+				} else if len(qs.qual) == len(qual) {
+					copy(qual, qs.qual)
+					b = 0
+				} else {
+					fastxReader.Close()
+					return fmt.Errorf("the quality string of %s has a different length (%d) from the sequence (%d)", record.ID, len(qs.qual), len(qual))
+				}
+			}
+			if b > 0 {
+				for i := range qual {
+					qual[i] = b
+				}
+			}
+
+			outfh.Write(_mark_fastq)
+			outfh.Write(record.Name)
+			outfh.Write(_mark_newline)
+			outfh.Write(record.Seq.Seq)
+			outfh.Write(_mark_newline)
+			outfh.Write(_mark_plus_newline)
+			outfh.Write(qual)
+			outfh.Write(_mark_newline)
+		}
+		fastxReader.Close()
+	}
+
+	if quals != nil && nFromFile < len(quals) {
+		log.Warningf("%d of %d qualities in --qual-from-file are not used", len(quals)-nFromFile, len(quals))
+	}
+	return nil
+}
+
+// fa2fqQual is a quality of a record given by --qual-from-file,
+// either a Phred score of all bases or a quality string.
+type fa2fqQual struct {
+	phred int // -1 for a quality string
+	qual  string
+}
+
+// fa2fqReadQuals reads a tab-delimited file of sequence IDs, types of qualities, and qualities.
+func fa2fqReadQuals(file string, qBase int) (map[string]fa2fqQual, error) {
+	fh, err := xopen.Ropen(file)
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+
+	quals := make(map[string]fa2fqQual)
+	scanner := bufio.NewScanner(fh)
+	scanner.Buffer(make([]byte, 0, 1<<20), 1<<30)
+	var line string
+	var items []string
+	var phred int
+	var n int
+	for scanner.Scan() {
+		n++
+		line = strings.TrimRight(scanner.Text(), "\r\n")
+		if len(line) == 0 {
+			continue
+		}
+		items = strings.Split(line, "\t")
+		if len(items) < 3 {
+			return nil, fmt.Errorf("three columns (ID, type and quality) needed in --qual-from-file, line %d: %s", n, line)
+		}
+		switch items[1] {
+		case "phred":
+			phred, err = strconv.Atoi(items[2])
+			if err != nil || phred < 0 || qBase+phred > 126 {
+				return nil, fmt.Errorf("invalid Phred score in --qual-from-file, line %d: %s", n, items[2])
+			}
+			quals[items[0]] = fa2fqQual{phred: phred}
+		case "string":
+			quals[items[0]] = fa2fqQual{phred: -1, qual: items[2]}
+		default:
+			return nil, fmt.Errorf(`invalid type of quality in --qual-from-file, line %d: %s. available: "phred" and "string"`, n, items[1])
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+	return quals, nil
 }
@@ -21,6 +21,7 @@
 package cmd
 
 import (
+	"bufio"
 	"bytes"
 	"fmt"
 	"os"
@@ -47,13 +48,28 @@ var faidxCmd = &cobra.Command{
 
 This command is similar with "samtools faidx" but has some extra features:
 
-  1. output full header line with the flag -f
+  1. output full header line with the flag -f (--full-head, or --full-header),
+     for both whole sequences and regions. For regions, coordinates are
+     appended to the full header, e.g., ">chr1 some description:101-200".
   2. support regular expression as sequence ID with the flag -r
   3. if you have large number of IDs, you can use:
         seqkit faidx seqs.fasta -l IDs.txt
 
 Attention:
   1. The flag -U/--update-faidx is recommended to ensure the .fai file matches the FASTA file.
+  2. The flag --write-fai writes the FASTA index of the output file along with
+     it in one pass, i.e., <out-file>.fai, or <out-file>.seqkit.fai with -f/--full-head.
+     Byte offsets are computed from the output records, which are wrapped
+     by -w/--line-width. Only plain output files are supported.
+
+Region file (-l/--region-file):
+  One region per line, with an optional second tab-delimited column as
+  the name of the output record, e.g.,
+      chr1:101-200<TAB>geneA
+      chr2:1-50
+  Records with names use them as headers, others use the coordinates.
+  Duplicated names are made unique by appending numeric suffixes (e.g., geneA_2),
+  with a warning.
 
 The definition of region is 1-based and with some custom design.
 
@@ -66,7 +82,7 @@ Examples:
 		fai.MapWholeFile = false
 		quiet := config.Quiet
 
-		fullHead := getFlagBool(cmd, "full-head")
+		fullHead := getFlagBool(cmd, "full-head") || getFlagBool(cmd, "full-header")
 		ignoreCase := getFlagBool(cmd, "ignore-case")
 		useRegexp := getFlagBool(cmd, "use-regexp")
 		regionFile := getFlagString(cmd, "region-file")
@@ -74,10 +90,13 @@ Examples:
 		immediateOutput := getFlagBool(cmd, "immediate-output")
 
 		updateFaidx := getFlagBool(cmd, "update-faidx")
+		writeFai := getFlagBool(cmd, "write-fai")
 
+		bench := getBenchmarkStats(cmd)
 		files := getFileListFromArgsAndFile(cmd, args, false, "infile-list", false)
 
 		file := files[0]
+		bench.addInputs(file)
 
 		if file == "-" {
 			checkError(fmt.Errorf("stdin not supported"))
@@ -95,6 +114,8 @@ Examples:
 
 		var err error
 		regions := make([]string, 0, 256)
+		regionNames := make([]string, 0, 256)
+		var hasRegionNames bool
 		if regionFile != "" {
 			var reader *breader.BufferedReader
 			reader, err = breader.NewDefaultBufferedReader(regionFile)
@@ -108,9 +129,21 @@ Examples:
 					if r == "" {
 						continue
 					}
+					name := ""
+					if i := strings.IndexByte(r, '\t'); i >= 0 {
+						name = strings.TrimSpace(r[i+1:])
+						r = r[:i]
+						if name != "" {
+							hasRegionNames = true
+						}
+					}
 					regions = append(regions, r)
+					regionNames = append(regionNames, name)
 				}
 			}
+			if hasRegionNames && useRegexp {
+				checkError(fmt.Errorf("names in region file (-l/--region-file) are not supported with -r/--use-regexp"))
+			}
 			if !quiet {
 				if len(regions) == 0 {
 					log.Warningf("%d patterns loaded from file", len(regions))
@@ -211,7 +244,13 @@ Examples:
 		var ok bool
 		if !useRegexp {
 			var begin, end int
-			for _, query := range queries {
+			var name string
+			usedNames := make(map[string]struct{}, len(regionNames))
+			givenNames := make(map[string]struct{}, len(regionNames)) // avoid conflicts with renamed ones
+			for _, name = range regionNames {
+				givenNames[name] = struct{}{}
+			}
+			for i, query := range queries {
 				id, begin, end = parseRegion(query)
 
 				if ignoreCase {
@@ -222,8 +261,30 @@ Examples:
 					continue
 				}
 
-				faidxQueries = append(faidxQueries, faidxQuery{ID: id, Region: [2]int{begin, end}})
+				name = ""
+				if i < len(regionNames) && regionNames[i] != "" {
+					name = regionNames[i]
+					if _, ok = usedNames[name]; ok {
+						for j := 2; ; j++ {
+							newName := fmt.Sprintf("%s_%d", name, j)
+							if _, ok = usedNames[newName]; ok {
+								continue
+							}
+							if _, ok = givenNames[newName]; !ok {
+								name = newName
+								break
+							}
+						}
+						if !quiet {
+							log.Warningf("duplicated name in region file: %s, renamed to %s", regionNames[i], name)
+						}
+					}
+					usedNames[name] = struct{}{}
+				}
+
+				faidxQueries = append(faidxQueries, faidxQuery{ID: id, Region: [2]int{begin, end}, Name: name})
 			}
+
 		} else {
 			queriesRe := make([]*regexp.Regexp, len(queries))
 			for i, query := range queries {
@@ -244,7 +305,16 @@ Examples:
 			}
 		}
 
-		var head string
+		var faiWriter *faidxIndexWriter
+		if writeFai {
+			faiWriter, err = newFaidxIndexWriter(config.OutFile, fullHead, config.IDRegexp, config.LineWidth)
+			checkError(err)
+			defer func() {
+				checkError(faiWriter.Close())
+			}()
+		}
+
+		var head, name string
 		var subseq []byte
 		var text []byte
 		var buffer *bytes.Buffer
@@ -257,11 +327,19 @@ Examples:
 			if (region[0] == 1 && region[1] == -1) || (region[0] > 0 && region[1] < 0) { // full record or region like [5, -5].
 				subseq, _ = faidx.SubSeq(head, region[0], region[1])
 
-				outfh.WriteString(fmt.Sprintf(">%s\n", head))
+				if faidxQ.Name != "" {
+					name = faidxQ.Name
+				} else {
+					name = head
+				}
 			} else if region[0] <= region[1] {
 				subseq, _ = faidx.SubSeq(head, region[0], region[1])
 
-				outfh.WriteString(fmt.Sprintf(">%s:%d-%d\n", head, region[0], region[1]))
+				if faidxQ.Name != "" {
+					name = faidxQ.Name
+				} else {
+					name = fmt.Sprintf("%s:%d-%d", head, region[0], region[1])
+				}
 			} else { // reverse complement sequence
 				subseq, _ = faidx.SubSeq(head, region[1], region[0])
 				alphabet = config.Alphabet
@@ -277,8 +355,13 @@ Examples:
 				}
 				subseq = _s.RevComInplace().Seq
 
-				outfh.WriteString(fmt.Sprintf(">%s:%d-%d\n", head, region[0], region[1]))
+				if faidxQ.Name != "" {
+					name = faidxQ.Name
+				} else {
+					name = fmt.Sprintf("%s:%d-%d", head, region[0], region[1])
+				}
 			}
+			outfh.WriteString(fmt.Sprintf(">%s\n", name))
 
 			text, buffer = wrapByteSlice(subseq, config.LineWidth, buffer)
 			outfh.Write(text)
@@ -288,13 +371,102 @@ Examples:
 			}
 
 			outfh.WriteString("\n")
+
+			if writeFai {
+				checkError(faiWriter.Add(name, len(subseq), len(text)))
+			}
 		}
 	},
 }
 
+// faidxIndexWriter generates the FASTA index of the output file on the fly,
+// with byte offsets computed from the lengths of records written.
+type faidxIndexWriter struct {
+	fh        *os.File
+	w         *bufio.Writer
+	file      string
+	idRe      *regexp.Regexp
+	lineWidth int
+
+	offset int64
+	names  map[string]struct{}
+}
+
+func newFaidxIndexWriter(outFile string, fullHead bool, idRegexp string, lineWidth int) (*faidxIndexWriter, error) {
+	if outFile == "-" {
+		return nil, fmt.Errorf("flag --write-fai needs an output file given by -o/--out-file")
+	}
+	lower := strings.ToLower(outFile)
+	for _, suffix := range []string{".gz", ".xz", ".zst", ".bz2"} {
+		if strings.HasSuffix(lower, suffix) {
+			return nil, fmt.Errorf("flag --write-fai does not support compressed output file: %s", outFile)
+		}
+	}
+
+	w := &faidxIndexWriter{lineWidth: lineWidth, names: make(map[string]struct{}, 1024)}
+	if fullHead {
+		w.file = outFile + ".seqkit.fai"
+	} else {
+		w.file = outFile + ".fai"
+		var err error
+		w.idRe, err = regexp.Compile(idRegexp)
+		if err != nil {
+			return nil, fmt.Errorf("fail to compile ID regexp: %s", err)
+		}
+	}
+
+	var err error
+	w.fh, err = os.Create(w.file)
+	if err != nil {
+		return nil, err
+	}
+	w.w = bufio.NewWriter(w.fh)
+	return w, nil
+}
+
+// Add adds an index record of a FASTA record with the header (without '>'),
+// the sequence length, and the size of the wrapped sequence text.
+func (w *faidxIndexWriter) Add(head string, seqLen int, textLen int) error {
+	start := w.offset + int64(len(head)) + 2
+	w.offset = start + int64(textLen) + 1
+
+	id := head
+	if w.idRe != nil {
+		id = string(fastx.ParseHeadID(w.idRe, []byte(head)))
+	}
+	id = strings.ReplaceAll(id, "\t", " ")
+	if _, ok := w.names[id]; ok {
+		log.Warningf("duplicated sequence ID %s in output, only the first one is indexed in %s", id, w.file)
+		return nil
+	}
+	w.names[id] = struct{}{}
+
+	var basesPerLine int
+	if w.lineWidth > 0 && seqLen > w.lineWidth {
+		basesPerLine = w.lineWidth
+	} else {
+		basesPerLine = seqLen
+	}
+	var bytesPerLine int
+	if basesPerLine > 0 {
+		bytesPerLine = basesPerLine + 1
+	}
+	_, err := fmt.Fprintf(w.w, "%s\t%d\t%d\t%d\t%d\n", id, seqLen, start, basesPerLine, bytesPerLine)
+	return err
+}
+
+// Close flushes and closes the index file.
+func (w *faidxIndexWriter) Close() error {
+	if err := w.w.Flush(); err != nil {
+		return err
+	}
+	return w.fh.Close()
+}
+
 type faidxQuery struct {
 	ID     string
 	Region [2]int
+	Name   string // optional name from the region file
 }
 
 func init() {
@@ -303,10 +475,12 @@ func init() {
 	faidxCmd.Flags().BoolP("use-regexp", "r", false, "IDs are regular expression. But subseq region is not supported here.")
 	faidxCmd.Flags().BoolP("ignore-case", "i", false, "ignore case")
 	faidxCmd.Flags().BoolP("full-head", "f", false, "print full header line instead of just ID. New fasta index file ending with .seqkit.fai will be created")
-	faidxCmd.Flags().StringP("region-file", "l", "", "file containing a list of regions")
+	faidxCmd.Flags().BoolP("full-header", "", false, "the same as -f/--full-head")
+	faidxCmd.Flags().StringP("region-file", "l", "", "file containing a list of regions, with an optional tab-delimited second column for names of output records")
 
 	faidxCmd.Flags().BoolP("immediate-output", "I", false, "print output immediately, do not use write buffer")
 	faidxCmd.Flags().BoolP("update-faidx", "U", false, "update the fasta index file if it exists. Use this if you are not sure whether the fasta file changed")
+	faidxCmd.Flags().BoolP("write-fai", "", false, "also write the FASTA index of the output file (given by -o/--out-file) on the fly")
 
 	faidxCmd.SetUsageTemplate(`Usage:{{if .Runnable}}
   {{if .HasAvailableFlags}}{{appendIfNotPresent .UseLine "[flags]"}}{{else}}{{.UseLine}}{{end}}{{end}}{{if .HasAvailableSubCommands}}
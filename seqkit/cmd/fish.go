@@ -162,7 +162,9 @@ Attention:
 			seq.ValidateSeq = true
 		}
 
+		bench := getBenchmarkStats(cmd)
 		files := getFileListFromArgsAndFile(cmd, args, true, "infile-list", true)
+		bench.addInputs(files...)
 
 		var alns []*AlignedSeq
 		if len(files) == 0 {
@@ -213,6 +215,7 @@ Attention:
 					checkError(err)
 					break
 				}
+				bench.addRecord()
 
 				if checkSeqType {
 					isFastq = fastxReader.IsFastq
@@ -0,0 +1,139 @@
+// Copyright © 2016-2024 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"io"
+	"runtime"
+
+	"github.com/shenwei356/bio/seq"
+	"github.com/shenwei356/bio/seqio/fastx"
+	"github.com/shenwei356/xopen"
+	"github.com/spf13/cobra"
+)
+
+// foldCmd represents the fold command
+var foldCmd = &cobra.Command{
+	GroupID: "format",
+
+	Use:     "fold",
+	Aliases: []string{"rewrap"},
+	Short:   "rewrap FASTA/Q sequences and qualities to a new line width",
+	Long: `rewrap FASTA/Q sequences and qualities to a new line width
+
+Sequences of FASTA/Q records, and also quality strings of FASTQ records,
+are rewrapped to the line width given by the global flag -w/--line-width
+(default 60). Use "-w 0" to unwrap them into single lines.
+
+Attention:
+  1. Unlike other commands, which only wrap sequences of FASTA records,
+     both sequence and quality lines of FASTQ records are wrapped here,
+     with the separator line being "+" only.
+  2. Multi-line FASTQ files are read correctly by seqkit, but they are not
+     supported by many other tools, including "seqkit sana" which only
+     accepts single-line FASTQ. Unwrapping with "seqkit fold -w 0" is
+     lossless and outputs single-line FASTQ for these tools.
+
+Examples:
+  1. Rewrap to 80 bases per line:
+       seqkit fold -w 80 seqs.fa.gz -o seqs.w80.fa.gz
+  2. Unwrap FASTA/Q:
+       seqkit fold -w 0 reads.fq.gz -o reads.unwrapped.fq.gz
+
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		config := getConfigs(cmd)
+		alphabet := config.Alphabet
+		idRegexp := config.IDRegexp
+		lineWidth := config.LineWidth
+		outFile := config.OutFile
+		seq.AlphabetGuessSeqLengthThreshold = config.AlphabetGuessSeqLength
+		seq.ValidateSeq = false
+		runtime.GOMAXPROCS(config.Threads)
+
+		bench := getBenchmarkStats(cmd)
+		files := getFileListFromArgsAndFile(cmd, args, true, "infile-list", true)
+		bench.addInputs(files...)
+
+		outfh, err := xopen.Wopen(outFile)
+		checkError(err)
+		defer outfh.Close()
+
+		var record *fastx.Record
+		for _, file := range files {
+			fastxReader, err := fastx.NewReader(alphabet, file, idRegexp)
+			checkError(err)
+
+			for {
+				record, err = fastxReader.Read()
+				if err != nil {
+					if err == io.EOF {
+						break
+					}
+					checkError(err)
+					break
+				}
+				bench.addRecord()
+
+				if fastxReader.IsFastq {
+					writeFastqRecordWrapped(outfh, record, lineWidth)
+					continue
+				}
+				record.FormatToWriter(outfh, lineWidth)
+			}
+			fastxReader.Close()
+		}
+	},
+}
+
+// writeFastqRecordWrapped writes a FASTQ record with both the sequence
+// and the quality wrapped to the given width, 0 for no wrapping.
+func writeFastqRecordWrapped(outfh *xopen.Writer, record *fastx.Record, width int) {
+	outfh.WriteByte('@')
+	outfh.Write(record.Name)
+	outfh.WriteByte('\n')
+	writeWrappedLines(outfh, record.Seq.Seq, width)
+	outfh.WriteString("+\n")
+	writeWrappedLines(outfh, record.Seq.Qual, width)
+}
+
+// writeWrappedLines writes s in lines of at most width bytes.
+// An empty s is written as an empty line, to keep FASTQ records valid.
+func writeWrappedLines(outfh *xopen.Writer, s []byte, width int) {
+	if len(s) == 0 || width < 1 {
+		outfh.Write(s)
+		outfh.WriteByte('\n')
+		return
+	}
+	var end int
+	for i := 0; i < len(s); i += width {
+		end = i + width
+		if end > len(s) {
+			end = len(s)
+		}
+		outfh.Write(s[i:end])
+		outfh.WriteByte('\n')
+	}
+}
+
+func init() {
+	RootCmd.AddCommand(foldCmd)
+}
@@ -21,6 +21,7 @@
 package cmd
 
 import (
+	"fmt"
 	"io"
 	"runtime"
 
@@ -38,6 +39,14 @@ var fq2faCmd = &cobra.Command{
 	Short: "convert FASTQ to FASTA",
 	Long: `convert FASTQ to FASTA
 
+Filtering low-complexity reads:
+  Flag --min-entropy skips reads with Shannon entropy of base composition
+  lower than the threshold. The entropy is computed over the full read in
+  bits (0-2), with ambiguous bases (not A/C/G/T/U) excluded. e.g.,
+  a homopolymer read has an entropy of 0, and a read with equal numbers
+  of A, C, G and T has an entropy of 2. Reads with no unambiguous bases
+  have an entropy of 0.
+
 `,
 	Run: func(cmd *cobra.Command, args []string) {
 		config := getConfigs(cmd)
@@ -49,13 +58,22 @@ var fq2faCmd = &cobra.Command{
 		seq.ValidateSeq = false
 		runtime.GOMAXPROCS(config.Threads)
 
+		minEntropy := getFlagFloat64(cmd, "min-entropy")
+		if minEntropy < 0 || minEntropy > 2 {
+			checkError(fmt.Errorf("value of flag --min-entropy should be in range of [0, 2]"))
+		}
+		filterEntropy := minEntropy > 0
+
+		bench := getBenchmarkStats(cmd)
 		files := getFileListFromArgsAndFile(cmd, args, true, "infile-list", true)
+		bench.addInputs(files...)
 
 		outfh, err := xopen.Wopen(outFile)
 		checkError(err)
 		defer outfh.Close()
 
 		var record *fastx.Record
+		var nDropped int
 		for _, file := range files {
 			fastxReader, err := fastx.NewReader(alphabet, file, idRegexp)
 			checkError(err)
@@ -69,6 +87,12 @@ var fq2faCmd = &cobra.Command{
 					checkError(err)
 					break
 				}
+				bench.addRecord()
+
+				if filterEntropy && seqEntropy(record.Seq.Seq) < minEntropy {
+					nDropped++
+					continue
+				}
 
 				record.Seq.Qual = []byte{}
 				// record.FormatToWriter(outfh, lineWidth)
@@ -76,9 +100,15 @@ var fq2faCmd = &cobra.Command{
 			}
 			fastxReader.Close()
 		}
+
+		if filterEntropy && !config.Quiet {
+			log.Infof("%d reads dropped with entropy < %v", nDropped, minEntropy)
+		}
 	},
 }
 
 func init() {
 	RootCmd.AddCommand(fq2faCmd)
+
+	fq2faCmd.Flags().Float64P("min-entropy", "", 0, "skip reads with Shannon entropy of base composition (0-2 bits, ambiguous bases excluded) lower than this value, 0 for no filtering")
 }
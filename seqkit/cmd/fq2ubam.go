@@ -0,0 +1,241 @@
+// Copyright © 2016-2024 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/biogo/hts/bam"
+	"github.com/biogo/hts/sam"
+	"github.com/pkg/errors"
+	"github.com/shenwei356/bio/seq"
+	"github.com/shenwei356/bio/seqio/fastx"
+	"github.com/shenwei356/xopen"
+	"github.com/spf13/cobra"
+)
+
+// fq2ubamCmd represents the fq2ubam command
+var fq2ubamCmd = &cobra.Command{
+	GroupID: "bam",
+
+	Use:   "fq2ubam",
+	Short: "convert FASTQ to unaligned BAM (uBAM)",
+	Long: `convert FASTQ to unaligned BAM (uBAM)
+
+Each read is written as an unmapped BAM record, with the name, sequence
+and quality preserved.
+
+Input:
+  1. Single-end reads: positional arguments or -X/--infile-list.
+  2. Paired-end reads: -1/--read1 and -2/--read2. Reads in the two files
+     should be in the same order, and their IDs should be the same,
+     suffixes '/1' and '/2' are ignored and removed from the read names.
+
+Flags of records:
+  single-end reads: 0x4 (unmapped)
+  read 1          : 0x1 | 0x4 | 0x8 | 0x40  (77)
+  read 2          : 0x1 | 0x4 | 0x8 | 0x80  (141)
+
+Attention:
+  1. Quality scores should be in Phred+33 encoding, otherwise please
+     convert them with "seqkit convert" first. Qualities out of the range
+     of Phred+33 are reported as errors, and a warning is shown if all
+     qualities are no less than 64 ('@'), which looks like Phred+64.
+  2. FASTA records are also accepted, the qualities would be absent ("*").
+  3. A read group line (@RG) is added to the header and a RG:Z tag is added
+     to every record, when -r/--read-group is given.
+
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		config := getConfigs(cmd)
+		bench := getBenchmarkStats(cmd)
+		alphabet := config.Alphabet
+		idRegexp := config.IDRegexp
+		outFile := config.OutFile
+		quiet := config.Quiet
+		seq.AlphabetGuessSeqLengthThreshold = config.AlphabetGuessSeqLength
+		seq.ValidateSeq = false
+		runtime.GOMAXPROCS(config.Threads)
+
+		read1 := getFlagString(cmd, "read1")
+		read2 := getFlagString(cmd, "read2")
+		rgID := getFlagString(cmd, "read-group")
+		rgSample := getFlagString(cmd, "sample")
+		rgLibrary := getFlagString(cmd, "library")
+		rgPlatform := getFlagString(cmd, "platform")
+
+		paired := read1 != "" || read2 != ""
+		if paired {
+			if read1 == "" || read2 == "" {
+				checkError(fmt.Errorf("flag -1/--read1 and -2/--read2 should be both given for paired-end reads"))
+			}
+			if read1 == read2 {
+				checkError(fmt.Errorf("values of flag -1/--read1 and -2/--read2 can not be the same"))
+			}
+			if len(args) > 0 {
+				checkError(fmt.Errorf("no positional arguments are allowed when using -1/--read1 and -2/--read2: %s", strings.Join(args, " ")))
+			}
+		}
+		if rgID == "" && (rgSample != "" || rgLibrary != "" || rgPlatform != "") {
+			checkError(fmt.Errorf("flag -r/--read-group needed when using -s/--sample, -l/--library or -p/--platform"))
+		}
+
+		header, err := sam.NewHeader(nil, nil)
+		checkError(err)
+
+		var aux []sam.Aux
+		if rgID != "" {
+			rg, err := sam.NewReadGroup(rgID, "", "", rgLibrary, "", rgPlatform, "", rgSample, "", "", time.Time{}, 0)
+			checkError(err)
+			checkError(header.AddReadGroup(rg))
+
+			tag, err := sam.NewAux(sam.NewTag("RG"), rgID)
+			checkError(err)
+			aux = []sam.Aux{tag}
+		}
+
+		outfh, err := xopen.Wopen(outFile)
+		checkError(err)
+		defer outfh.Close()
+
+		bamWriter, err := bam.NewWriter(outfh, header, config.Threads)
+		checkError(err)
+		var minQual byte = 127
+		defer func() {
+			checkError(bamWriter.Close())
+
+			if !quiet && minQual >= 64 && minQual < 127 {
+				log.Warningf("all qualities are no less than 64 ('@'), the encoding might be Phred+64, please check it")
+			}
+		}()
+
+		if !paired {
+			files := getFileListFromArgsAndFile(cmd, args, true, "infile-list", true)
+			bench.addInputs(files...)
+
+			var record *fastx.Record
+			for _, file := range files {
+				fastxReader, err := fastx.NewReader(alphabet, file, idRegexp)
+				checkError(err)
+
+				for {
+					record, err = fastxReader.Read()
+					if err != nil {
+						if err == io.EOF {
+							break
+						}
+						checkError(err)
+						break
+					}
+					bench.addRecord()
+
+					checkError(writeUBAMRecord(bamWriter, record, sam.Unmapped, aux, &minQual))
+				}
+				fastxReader.Close()
+			}
+			return
+		}
+
+		bench.addInputs(read1, read2)
+		reader1, err := fastx.NewReader(alphabet, read1, idRegexp)
+		checkError(errors.Wrap(err, read1))
+		defer reader1.Close()
+		reader2, err := fastx.NewReader(alphabet, read2, idRegexp)
+		checkError(errors.Wrap(err, read2))
+		defer reader2.Close()
+
+		flag1 := sam.Paired | sam.Unmapped | sam.MateUnmapped | sam.Read1
+		flag2 := sam.Paired | sam.Unmapped | sam.MateUnmapped | sam.Read2
+
+		var record1, record2 *fastx.Record
+		var err1, err2 error
+		for {
+			record1, err1 = reader1.Read()
+			record2, err2 = reader2.Read()
+			if err1 == io.EOF && err2 == io.EOF {
+				break
+			}
+			if err1 == io.EOF {
+				checkError(fmt.Errorf("%s has fewer reads than %s", read1, read2))
+			}
+			if err2 == io.EOF {
+				checkError(fmt.Errorf("%s has fewer reads than %s", read2, read1))
+			}
+			checkError(errors.Wrap(err1, read1))
+			checkError(errors.Wrap(err2, read2))
+
+			name := mateBaseName(record1.ID)
+			if !bytes.Equal(name, mateBaseName(record2.ID)) {
+				checkError(fmt.Errorf("read IDs do not match: %s (%s) vs %s (%s). please check the order of reads or the flag --id-regexp",
+					record1.ID, read1, record2.ID, read2))
+			}
+			record1.ID = name
+			record2.ID = name
+
+			bench.addRecords(2)
+
+			checkError(writeUBAMRecord(bamWriter, record1, flag1, aux, &minQual))
+			checkError(writeUBAMRecord(bamWriter, record2, flag2, aux, &minQual))
+		}
+	},
+}
+
+// writeUBAMRecord writes a FASTA/Q record as an unmapped BAM record,
+// minQual is updated with the minimum quality character.
+func writeUBAMRecord(w *bam.Writer, record *fastx.Record, flags sam.Flags, aux []sam.Aux, minQual *byte) error {
+	var qual []byte
+	if len(record.Seq.Qual) > 0 {
+		qual = make([]byte, len(record.Seq.Qual))
+		for i, q := range record.Seq.Qual {
+			if q < 33 || q > 126 {
+				return fmt.Errorf("invalid Phred+33 quality '%c' in read: %s", q, record.ID)
+			}
+			if q < *minQual {
+				*minQual = q
+			}
+			qual[i] = q - 33
+		}
+	}
+
+	r, err := sam.NewRecord(string(record.ID), nil, nil, -1, -1, 0, 0, nil, bytes.ToUpper(record.Seq.Seq), qual, aux)
+	if err != nil {
+		return errors.Wrap(err, string(record.ID))
+	}
+	r.Flags = flags
+
+	return w.Write(r)
+}
+
+func init() {
+	RootCmd.AddCommand(fq2ubamCmd)
+
+	fq2ubamCmd.Flags().StringP("read1", "1", "", "(gzipped) read1 file")
+	fq2ubamCmd.Flags().StringP("read2", "2", "", "(gzipped) read2 file")
+	fq2ubamCmd.Flags().StringP("read-group", "r", "", "read group ID, a @RG header line and RG:Z tags will be added")
+	fq2ubamCmd.Flags().StringP("sample", "s", "", "sample name (SM) of the read group")
+	fq2ubamCmd.Flags().StringP("library", "l", "", "library (LB) of the read group")
+	fq2ubamCmd.Flags().StringP("platform", "p", "", "platform (PL) of the read group, e.g., ILLUMINA, ONT, PACBIO")
+}
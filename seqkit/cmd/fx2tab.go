@@ -21,11 +21,13 @@
 package cmd
 
 import (
+	"bufio"
 	"bytes"
 	"crypto/md5"
 	"encoding/hex"
 	"fmt"
 	"io"
+	"regexp"
 	"runtime"
 	"sort"
 	"strings"
@@ -49,6 +51,26 @@ like sequence length, GC content/GC skew.
 Attention:
   1. Fixed three columns (ID, sequence, quality) are outputted for either FASTA
      or FASTQ, except when flag -n/--name is on. This is for format compatibility.
+  2. Use -c/--columns to choose and order output columns by names, e.g.,
+     -c id,seq,gc,length. Columns added by other flags (e.g., -l, -g, -B)
+     but not included are appended at the end. Available names, the same as
+     names of the corresponding flags:
+         id, name, seq, qual, length, gc, gc-skew, alphabet, avg-qual,
+         seq-hash, tm, count:BASES (e.g., count:AT), content:BASES (e.g., content:N)
+     Flags -I/--case-sensitive and -b/--qual-ascii-base still apply.
+     The header line is only outputted with -H/--header-line.
+  3. For FASTA files, when only the ID/name, sequence, and (empty) quality
+     columns are outputted in this order, sequences are streamed in
+     chunks from the input file, instead of loading whole records into
+     memory, which is useful for huge sequences like chromosomes.
+     The output is the same. Use --stream-seq to make sure the streaming
+     mode is used, an error is reported if other columns are requested.
+  4. Flag --tm computes the nearest-neighbor melting temperature (°C) of DNA
+     oligos with the unified parameters and salt correction of SantaLucia
+     (1998). It is mostly useful for short sequences like primers and probes.
+     Ambiguous bases are reported as errors by default, or approximated by
+     averaging the parameters of all possible bases with
+     '--tm-ambiguous average'. NaN is outputted for sequences shorter than 2 bp.
 
 `,
 	Run: func(cmd *cobra.Command, args []string) {
@@ -60,7 +82,9 @@ Attention:
 		seq.ValidateSeq = false
 		runtime.GOMAXPROCS(config.Threads)
 
+		bench := getBenchmarkStats(cmd)
 		files := getFileListFromArgsAndFile(cmd, args, true, "infile-list", true)
+		bench.addInputs(files...)
 
 		onlyID := getFlagBool(cmd, "only-id")
 		printLength := getFlagBool(cmd, "length")
@@ -76,72 +100,149 @@ Attention:
 		qBase := getFlagPositiveInt(cmd, "qual-ascii-base")
 		printSeqHash := getFlagBool(cmd, "seq-hash")
 		noQual := getFlagBool(cmd, "no-qual")
+		selectedColumns := getFlagStringSlice(cmd, "columns")
+		forceStreamSeq := getFlagBool(cmd, "stream-seq")
+		printTm := getFlagBool(cmd, "tm")
+		tmNa := getFlagFloat64(cmd, "tm-na")
+		tmOligoConc := getFlagFloat64(cmd, "tm-oligo-conc")
+		if tmNa <= 0 || tmOligoConc <= 0 {
+			checkError(fmt.Errorf("values of flags --tm-na and --tm-oligo-conc should be positive"))
+		}
+		tmConditions := &tmParams{Na: tmNa / 1000, PrimerConc: tmOligoConc / 1e9}
+		switch tmAmbiguous := getFlagString(cmd, "tm-ambiguous"); tmAmbiguous {
+		case "error":
+		case "average":
+			tmConditions.Average = true
+		default:
+			checkError(fmt.Errorf("invalid value of flag --tm-ambiguous: %s. available: error, average", tmAmbiguous))
+		}
 
-		outfh, err := xopen.Wopen(outFile)
-		checkError(err)
-		defer outfh.Close()
-
-		if printTitle {
-			if onlyName {
-				if onlyID {
-					outfh.WriteString("#id")
-				} else {
-					outfh.WriteString("#name")
-				}
+		var columns []fx2tabColumn
+		if onlyName {
+			if onlyID {
+				columns = append(columns, fx2tabColumn{"id", fx2tabColID, ""})
 			} else {
-				if onlyID {
-					if noQual {
-						outfh.WriteString("#id\tseq")
-					} else {
-						outfh.WriteString("#id\tseq\tqual")
-					}
-				} else {
-					if noQual {
-						outfh.WriteString("#name\tseq")
-					} else {
-						outfh.WriteString("#name\tseq\tqual")
-					}
-
-				}
+				columns = append(columns, fx2tabColumn{"name", fx2tabColName, ""})
 			}
-			if printLength {
-				outfh.WriteString("\tlength")
+		} else {
+			if onlyID {
+				columns = append(columns, fx2tabColumn{"id", fx2tabColID, ""})
+			} else {
+				columns = append(columns, fx2tabColumn{"name", fx2tabColName, ""})
 			}
-			if printGC {
-				outfh.WriteString("\tGC")
+			columns = append(columns, fx2tabColumn{"seq", fx2tabColSeq, ""})
+			if !noQual {
+				columns = append(columns, fx2tabColumn{"qual", fx2tabColQual, ""})
 			}
-			if printGCSkew {
-				outfh.WriteString("\tGC-Skew")
+		}
+		// optional columns
+		var optColumns []fx2tabColumn
+		if printLength {
+			optColumns = append(optColumns, fx2tabColumn{"length", fx2tabColLength, ""})
+		}
+		if printGC {
+			optColumns = append(optColumns, fx2tabColumn{"GC", fx2tabColGC, ""})
+		}
+		if printGCSkew {
+			optColumns = append(optColumns, fx2tabColumn{"GC-Skew", fx2tabColGCSkew, ""})
+		}
+		for _, bc := range baseCounts {
+			optColumns = append(optColumns, fx2tabColumn{bc, fx2tabColBaseCount, bc})
+		}
+		for _, bc := range baseContents {
+			optColumns = append(optColumns, fx2tabColumn{bc, fx2tabColBaseContent, bc})
+		}
+		if printAlphabet {
+			optColumns = append(optColumns, fx2tabColumn{"alphabet", fx2tabColAlphabet, ""})
+		}
+		if printAvgQual {
+			optColumns = append(optColumns, fx2tabColumn{"avg.qual", fx2tabColAvgQual, ""})
+		}
+		if printSeqHash {
+			optColumns = append(optColumns, fx2tabColumn{"seq.hash", fx2tabColSeqHash, ""})
+		}
+		if printTm {
+			optColumns = append(optColumns, fx2tabColumn{"Tm", fx2tabColTm, ""})
+		}
+
+		if len(selectedColumns) > 0 {
+			columns = make([]fx2tabColumn, 0, len(selectedColumns)+len(optColumns))
+			selected := make(map[fx2tabColumn]bool, len(selectedColumns))
+			var col fx2tabColumn
+			var err error
+			for _, name := range selectedColumns {
+				col, err = parseFx2tabColumn(name)
+				checkError(err)
+				columns = append(columns, col)
+				selected[col] = true
 			}
-			if len(baseCounts) > 0 {
-				for _, bc := range baseCounts {
-					outfh.WriteString(fmt.Sprintf("\t%s", bc))
+			// columns given by other flags but not selected are appended
+			for _, col = range optColumns {
+				if !selected[col] {
+					columns = append(columns, col)
 				}
 			}
-			if len(baseContents) > 0 {
-				for _, bc := range baseContents {
-					outfh.WriteString(fmt.Sprintf("\t%s", bc))
+		} else {
+			columns = append(columns, optColumns...)
+		}
+
+		// streaming sequences for columns of (id|name, seq[, qual])
+		streamSeq := len(columns) >= 2 && len(columns) <= 3 &&
+			(columns[0].kind == fx2tabColID || columns[0].kind == fx2tabColName) &&
+			columns[1].kind == fx2tabColSeq &&
+			(len(columns) == 2 || columns[2].kind == fx2tabColQual)
+		if forceStreamSeq && !streamSeq {
+			checkError(fmt.Errorf("flag --stream-seq only supports outputting columns of ID/name, sequence, and quality in this order"))
+		}
+		var idRe *regexp.Regexp
+		if streamSeq && columns[0].kind == fx2tabColID {
+			var err error
+			idRe, err = regexp.Compile(idRegexp)
+			checkError(err)
+		}
+
+		outfh, err := xopen.Wopen(outFile)
+		checkError(err)
+		defer outfh.Close()
+
+		if printTitle {
+			for i, col := range columns {
+				if i == 0 {
+					outfh.WriteString("#")
+				} else {
+					outfh.Write(_tab)
 				}
+				outfh.WriteString(col.header)
 			}
-			if printAlphabet {
-				outfh.WriteString("\talphabet")
-			}
-			if printAvgQual {
-				outfh.WriteString("\tavg.qual")
-			}
-			if printSeqHash {
-				outfh.WriteString("\tseq.hash")
-			}
-
 			outfh.WriteString("\n")
 		}
 
-		var name []byte
 		var g, c float64
+		var tm float64
+		var gcComputed bool
 		var record *fastx.Record
 		var sum [md5.Size]byte
+		var fh *xopen.Reader
+		var br *bufio.Reader
+		var fastxReader *fastx.Reader
 		for _, file := range files {
-			fastxReader, err := fastx.NewReader(alphabet, file, idRegexp)
+			fh = nil
+			if streamSeq {
+				fh, err = xopen.Ropen(file)
+				if err == xopen.ErrNoContent {
+					continue
+				}
+				checkError(err)
+				br = bufio.NewReaderSize(fh, 1<<16)
+				if fx2tabIsFasta(br) {
+					checkError(fx2tabStreamFasta(outfh, br, idRe, idRegexp == fastx.DefaultIDRegexp, len(columns) == 3))
+					checkError(fh.Close())
+					continue
+				}
+				fastxReader, err = fastx.NewReaderFromIO(alphabet, br, idRegexp)
+			} else {
+				fastxReader, err = fastx.NewReader(alphabet, file, idRegexp)
+			}
 			checkError(err)
 			for {
 				record, err = fastxReader.Read()
@@ -152,89 +253,135 @@ Attention:
 					checkError(err)
 					break
 				}
-				if onlyID {
-					name = record.ID
-				} else {
-					name = record.Name
-				}
-				if onlyName {
-					outfh.Write(name)
-				} else {
-					//outfh.WriteString(fmt.Sprintf("%s\t%s\t%s", name,
-					//	record.Seq.Seq, record.Seq.Qual))
+				bench.addRecord()
 
-					// outfh.WriteString(fmt.Sprintf("%s\t", name))
-					outfh.Write(name)
-					outfh.Write(_tab)
-					outfh.Write(record.Seq.Seq)
-					if !noQual {
+				gcComputed = false
+				for i, col := range columns {
+					if i > 0 {
 						outfh.Write(_tab)
-						outfh.Write(record.Seq.Qual)
 					}
-				}
 
-				if printLength {
-					outfh.WriteString(fmt.Sprintf("\t%d", len(record.Seq.Seq)))
-				}
-				if printGC || printGCSkew {
-					g = record.Seq.BaseContent("G")
-					c = record.Seq.BaseContent("C")
-				}
-
-				if printGC {
-					outfh.WriteString(fmt.Sprintf("\t%.2f", (g+c)*100))
-				}
-				if printGCSkew {
-					outfh.WriteString(fmt.Sprintf("\t%.2f", (g-c)/(g+c)*100))
-				}
-
-				if len(baseCounts) > 0 {
-					for _, bc := range baseCounts {
+					switch col.kind {
+					case fx2tabColID:
+						outfh.Write(record.ID)
+					case fx2tabColName:
+						outfh.Write(record.Name)
+					case fx2tabColSeq:
+						outfh.Write(record.Seq.Seq)
+					case fx2tabColQual:
+						outfh.Write(record.Seq.Qual)
+					case fx2tabColLength:
+						outfh.WriteString(fmt.Sprintf("%d", len(record.Seq.Seq)))
+					case fx2tabColGC, fx2tabColGCSkew:
+						if !gcComputed {
+							g = record.Seq.BaseContent("G")
+							c = record.Seq.BaseContent("C")
+							gcComputed = true
+						}
+						if col.kind == fx2tabColGC {
+							outfh.WriteString(fmt.Sprintf("%.2f", (g+c)*100))
+						} else {
+							outfh.WriteString(fmt.Sprintf("%.2f", (g-c)/(g+c)*100))
+						}
+					case fx2tabColBaseCount:
 						if caseSensitive {
-							outfh.WriteString(fmt.Sprintf("\t%d", record.Seq.BaseCountCaseSensitive(bc)))
+							outfh.WriteString(fmt.Sprintf("%d", record.Seq.BaseCountCaseSensitive(col.arg)))
 						} else {
-							outfh.WriteString(fmt.Sprintf("\t%d", record.Seq.BaseCount(bc)))
+							outfh.WriteString(fmt.Sprintf("%d", record.Seq.BaseCount(col.arg)))
 						}
-					}
-				}
-
-				if len(baseContents) > 0 {
-					for _, bc := range baseContents {
+					case fx2tabColBaseContent:
 						if caseSensitive {
-							outfh.WriteString(fmt.Sprintf("\t%.2f", record.Seq.BaseContentCaseSensitive(bc)*100))
+							outfh.WriteString(fmt.Sprintf("%.2f", record.Seq.BaseContentCaseSensitive(col.arg)*100))
 						} else {
-							outfh.WriteString(fmt.Sprintf("\t%.2f", record.Seq.BaseContent(bc)*100))
+							outfh.WriteString(fmt.Sprintf("%.2f", record.Seq.BaseContent(col.arg)*100))
 						}
+					case fx2tabColAlphabet:
+						outfh.WriteString(alphabetStr(record.Seq.Seq))
+					case fx2tabColAvgQual:
+						outfh.WriteString(fmt.Sprintf("%.2f", record.Seq.AvgQual(qBase)))
+					case fx2tabColSeqHash:
+						if caseSensitive {
+							sum = md5.Sum(record.Seq.Seq)
+						} else {
+							sum = md5.Sum(bytes.ToLower(record.Seq.Seq))
+						}
+						outfh.WriteString(hex.EncodeToString(sum[:]))
+					case fx2tabColTm:
+						tm, err = oligoTm(record.Seq.Seq, tmConditions)
+						if err != nil {
+							checkError(fmt.Errorf("%s: %s", record.ID, err))
+						}
+						outfh.WriteString(fmt.Sprintf("%.2f", tm))
 					}
 				}
 
-				if printAlphabet {
-					outfh.WriteString(fmt.Sprintf("\t%s", alphabetStr(record.Seq.Seq)))
-				}
-
-				if printAvgQual {
-					outfh.WriteString(fmt.Sprintf("\t%.2f", record.Seq.AvgQual(qBase)))
-				}
-
-				if printSeqHash {
-					if caseSensitive {
-						sum = md5.Sum(record.Seq.Seq)
-						outfh.WriteString(fmt.Sprintf("\t%s", hex.EncodeToString(sum[:])))
-					} else {
-						sum = md5.Sum(bytes.ToLower(record.Seq.Seq))
-						outfh.WriteString(fmt.Sprintf("\t%s", hex.EncodeToString(sum[:])))
-					}
-
-				}
-
 				// outfh.WriteString("\n")
 				outfh.Write(_mark_newline)
 			}
 			fastxReader.Close()
+			if fh != nil {
+				checkError(fh.Close())
+			}
 		}
 	},
 }
 
+const (
+	fx2tabColID = iota
+	fx2tabColName
+	fx2tabColSeq
+	fx2tabColQual
+	fx2tabColLength
+	fx2tabColGC
+	fx2tabColGCSkew
+	fx2tabColBaseCount
+	fx2tabColBaseContent
+	fx2tabColAlphabet
+	fx2tabColAvgQual
+	fx2tabColSeqHash
+	fx2tabColTm
+)
+
+// fx2tabColumn is an output column of fx2tab.
+type fx2tabColumn struct {
+	header string
+	kind   int
+	arg    string // bases for base count/content
+}
+
+var fx2tabColumnNames = map[string]fx2tabColumn{
+	"id":       {"id", fx2tabColID, ""},
+	"name":     {"name", fx2tabColName, ""},
+	"seq":      {"seq", fx2tabColSeq, ""},
+	"qual":     {"qual", fx2tabColQual, ""},
+	"length":   {"length", fx2tabColLength, ""},
+	"gc":       {"GC", fx2tabColGC, ""},
+	"gc-skew":  {"GC-Skew", fx2tabColGCSkew, ""},
+	"alphabet": {"alphabet", fx2tabColAlphabet, ""},
+	"avg-qual": {"avg.qual", fx2tabColAvgQual, ""},
+	"seq-hash": {"seq.hash", fx2tabColSeqHash, ""},
+	"tm":       {"Tm", fx2tabColTm, ""},
+}
+
+const fx2tabColumnsHelp = "id, name, seq, qual, length, gc, gc-skew, alphabet, avg-qual, seq-hash, tm, count:BASES, content:BASES"
+
+// parseFx2tabColumn parses a column name given by --columns.
+func parseFx2tabColumn(name string) (fx2tabColumn, error) {
+	lname := strings.ToLower(strings.TrimSpace(name))
+	if col, ok := fx2tabColumnNames[lname]; ok {
+		return col, nil
+	}
+	if strings.HasPrefix(lname, "count:") && len(name) > len("count:") {
+		bc := strings.TrimSpace(name)[len("count:"):]
+		return fx2tabColumn{bc, fx2tabColBaseCount, bc}, nil
+	}
+	if strings.HasPrefix(lname, "content:") && len(name) > len("content:") {
+		bc := strings.TrimSpace(name)[len("content:"):]
+		return fx2tabColumn{bc, fx2tabColBaseContent, bc}, nil
+	}
+	return fx2tabColumn{}, fmt.Errorf("invalid column name: %s. available: %s", name, fx2tabColumnsHelp)
+}
+
 func init() {
 	RootCmd.AddCommand(fx2tabCmd)
 
@@ -252,6 +399,12 @@ func init() {
 	fx2tabCmd.Flags().IntP("qual-ascii-base", "b", 33, "ASCII BASE, 33 for Phred+33")
 	fx2tabCmd.Flags().BoolP("seq-hash", "s", false, "print hash (MD5) of sequence")
 	fx2tabCmd.Flags().BoolP("no-qual", "Q", false, "only output two column even for FASTQ file")
+	fx2tabCmd.Flags().StringSliceP("columns", "c", []string{}, "names and order of columns to output, e.g., -c id,seq,gc,length. available: "+fx2tabColumnsHelp)
+	fx2tabCmd.Flags().BoolP("tm", "", false, "print nearest-neighbor melting temperature (°C) of DNA oligos. type 'seqkit fx2tab -h' for details")
+	fx2tabCmd.Flags().Float64P("tm-na", "", 50, "concentration of Na+ (mM) for computing Tm")
+	fx2tabCmd.Flags().Float64P("tm-oligo-conc", "", 250, "concentration of oligos (nM) for computing Tm")
+	fx2tabCmd.Flags().StringP("tm-ambiguous", "", "error", `how to handle ambiguous bases for computing Tm: "error" or "average"`)
+	fx2tabCmd.Flags().BoolP("stream-seq", "", false, "make sure sequences of FASTA files are streamed in chunks, only for outputting ID/name, sequence, and quality. type 'seqkit fx2tab -h' for details")
 
 }
 
@@ -269,3 +422,125 @@ func alphabetStr(s []byte) string {
 }
 
 var _tab = []byte{'\t'}
+var _cr = []byte{'\r'}
+
+// fx2tabIsFasta checks if the input is in FASTA format by the first non-empty byte.
+func fx2tabIsFasta(br *bufio.Reader) bool {
+	for n := 1; ; n++ {
+		p, err := br.Peek(n)
+		if len(p) < n {
+			return false
+		}
+		if p[n-1] != '\n' {
+			return p[n-1] == '>'
+		}
+		if err != nil || n == br.Size() {
+			return false
+		}
+	}
+}
+
+// fx2tabStreamFasta outputs FASTA records in tabular format, where sequences
+// are written in chunks without loading whole records into memory.
+// Headers and sequence lines are parsed in the same way as fastx.Reader.
+func fx2tabStreamFasta(outfh *xopen.Writer, br *bufio.Reader, idRe *regexp.Regexp, defaultIDRegexp bool, withQual bool) error {
+	var line []byte
+	var err error
+	var head bytes.Buffer
+	var lineStart = true // at the start of a line
+	var inHead bool      // reading the header line
+	var first = true
+	var pendingCR bool // a '\r' at the end of the last chunk of a long line
+
+	finishRecord := func() {
+		if withQual {
+			outfh.Write(_tab)
+		}
+		outfh.Write(_mark_newline)
+	}
+	writeHead := func(h []byte) {
+		if !first {
+			finishRecord()
+		}
+		first = false
+		if idRe != nil {
+			h = fx2tabParseHeadID(idRe, defaultIDRegexp, h)
+		}
+		outfh.Write(h)
+		outfh.Write(_tab)
+	}
+
+	var eol bool
+	for {
+		line, err = br.ReadSlice('\n')
+		if err != nil && err != bufio.ErrBufferFull && err != io.EOF {
+			return err
+		}
+
+		eol = len(line) > 0 && line[len(line)-1] == '\n'
+		if lineStart && len(line) > 0 && line[0] == '>' {
+			inHead = true
+			head.Reset()
+			line = line[1:]
+		}
+		lineStart = eol
+
+		if inHead {
+			head.Write(line)
+			if eol || err == io.EOF {
+				writeHead(dropCRLF(head.Bytes()))
+				inHead = false
+			}
+		} else if !first { // only empty lines are allowed before the first header
+			if eol {
+				line = line[:len(line)-1]
+			}
+			if pendingCR { // only dropped at the end of a line
+				if len(line) > 0 || !(eol || err == io.EOF) {
+					outfh.Write(_cr)
+				}
+				pendingCR = false
+			}
+			if len(line) > 0 && line[len(line)-1] == '\r' {
+				line = line[:len(line)-1]
+				if !eol && err == bufio.ErrBufferFull {
+					pendingCR = true
+				}
+			}
+			outfh.Write(line)
+		}
+
+		if err == io.EOF {
+			break
+		}
+	}
+	if !first {
+		finishRecord()
+	}
+	return nil
+}
+
+// fx2tabParseHeadID parses sequence ID from the header in the same way as fastx.Reader.
+func fx2tabParseHeadID(idRe *regexp.Regexp, defaultIDRegexp bool, head []byte) []byte {
+	if defaultIDRegexp {
+		if i := bytes.IndexByte(head, ' '); i > 0 {
+			return head[0:i]
+		}
+		if i := bytes.IndexByte(head, '\t'); i > 0 {
+			return head[0:i]
+		}
+		return head
+	}
+	return fastx.ParseHeadID(idRe, head)
+}
+
+// dropCRLF removes the trailing "\n" or "\r\n".
+func dropCRLF(data []byte) []byte {
+	if len(data) > 0 && data[len(data)-1] == '\n' {
+		data = data[:len(data)-1]
+	}
+	if len(data) > 0 && data[len(data)-1] == '\r' {
+		data = data[:len(data)-1]
+	}
+	return data
+}
@@ -29,6 +29,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 
 	"github.com/cespare/xxhash/v2"
 	"github.com/shenwei356/bwt"
@@ -77,9 +78,75 @@ Attention:
         seqkit faidx seqs.fasta --infile-list IDs.txt
   6. For multiple patterns, you can either set "-p" multiple times, i.e.,
      -p pattern1 -p pattern2, or give a file of patterns via "-f/--pattern-file".
+  7. For a large number of patterns:
+     a) Literal IDs/names are stored in a hash table, so the speed does not
+        drop with more patterns. Switch on "--literal" to make sure patterns
+        are treated as plain IDs/names, an error is reported if any pattern
+        contains regular expression metacharacters (except "." and "|").
+     b) Regular expressions are compiled once and checked one by one.
+        Switch on "--merge-regexp" to merge them into a single alternation
+        regular expression, which is matched in one pass.
+  8. When matching by ID or full name (without -r, -s and -v), if the
+     record-offset index file (<input>.skidx) created by "seqkit index build"
+     exists and matches the input file, matched records are read directly
+     via the index instead of parsing the whole file.
+  9. When searching by sequences with multiple patterns, a record matches if
+     any pattern is found by default. Switch on "--and" to require all
+     patterns to be found, where each pattern is searched independently
+     (on either strand, with mismatches allowed by -m/--max-mismatch).
+     With -v/--invert-match, records not containing all patterns are selected.
+  10. Switch on "--by-desc" to match the description only, i.e., the part of
+      the header after the ID, with leading spaces removed. The ID is parsed by
+      --id-regexp (or --id-ncbi), and the description starts from the end of
+      the whole match of the regular expression. For headers not matching the
+      regular expression, the description is the part after the first space
+      or tab. Records without descriptions have empty ones. E.g., records with
+      descriptions mentioning "plasmid":
+         seqkit grep --by-desc -r -i -p plasmid seqs.fa
+  11. Use "--reject-file" to save records not outputted, i.e., records failing
+      the filter, empty records, and short ones skipped by --skip-short, to
+      another file, so the input is partitioned in a single pass. It works
+      with all match modes, including -v/--invert-match, and the output is
+      compressed according to the file suffix. E.g.,
+         seqkit grep -f IDs.txt reads.fq.gz -o hits.fq.gz --reject-file others.fq.gz
+  12. Use "--illumina-tile" to select reads from given flowcell tiles, parsed
+      from Illumina read IDs of "instrument:run:flowcell:lane:tile:x:y"
+      (Casava 1.8+) or "instrument:lane:tile:x:y" (older ones). Reads with
+      malformed IDs are skipped with a warning. Combine with -v/--invert-match
+      to exclude the tiles. E.g.,
+         seqkit grep --illumina-tile 1101,2101 reads.fq.gz
+  13. Use "--bed" to select sequences whose IDs are chromosome names with at
+      least one interval in a BED file. Per-sequence overlap counts, i.e.,
+      the number of intervals and covered bases (overlapping intervals are
+      merged), of outputted sequences can be saved to a TSV file with
+      "--bed-report". E.g.,
+         seqkit grep --bed regions.bed genome.fa --bed-report coverage.tsv
+  14. Use "--max-matches N" to stop reading after N records are outputted,
+      i.e., matched records, or non-matching ones with -v/--invert-match.
+      Records outputted multiple times with -D are counted once, and with
+      -C/--count, the count is at most N. The cutoff is exact even with
+      multiple threads (-j) for searching with mismatches (-m), as records
+      are outputted in the input order, though a few more records might be
+      read and searched before stopping. It is not compatible with
+      --reject-file.
+  15. Use "--in-refs" to select query records whose sequences are substrings
+      of any sequence in a reference file, i.e., the inverse of searching
+      by sequences. An FM-index is built for all reference sequences, which
+      takes about 12 bytes per base, so it's designed for references of
+      moderate sizes instead of large genomes. Both strands of references
+      are searched unless -P/--only-positive-strand is given, up to
+      -m/--max-mismatch mismatches are allowed, and -i/--ignore-case is
+      supported. Locations of all hits of outputted records can be saved to
+      a TSV file with "--in-refs-report", with columns: id, ref, strand,
+      start, end (1-based, on the positive strand of the reference) and
+      mismatches. E.g.,
+         seqkit grep --in-refs amplicons.fa -m 1 primers.fa --in-refs-report hits.tsv
 
 You can specify the sequence region for searching with the flag -R (--region).
 The definition of region is 1-based and with some custom design.
+For records shorter than the region, the existing part of the region is searched,
+e.g., the whole sequence of a 30-bp read for the region 1:50. Switch on
+--skip-short to skip these records (they are not outputted, even with -v).
 
 Examples:
 %s
@@ -98,7 +165,9 @@ Examples:
 
 		bwt.CheckEndSymbol = false
 
+		bench := getBenchmarkStats(cmd)
 		files := getFileListFromArgsAndFile(cmd, args, true, "infile-list", true)
+		bench.addInputs(files...)
 
 		justCount := getFlagBool(cmd, "count")
 		pattern := getFlagStringSlice(cmd, "pattern")
@@ -110,18 +179,92 @@ Examples:
 		onlyPositiveStrand := getFlagBool(cmd, "only-positive-strand")
 		mismatches := getFlagNonNegativeInt(cmd, "max-mismatch")
 		byName := getFlagBool(cmd, "by-name")
+		byDesc := getFlagBool(cmd, "by-desc")
 		ignoreCase := getFlagBool(cmd, "ignore-case")
 		degenerate := getFlagBool(cmd, "degenerate")
 		region := getFlagString(cmd, "region")
 		circular := getFlagBool(cmd, "circular")
 		allowDups := getFlagBool(cmd, "allow-duplicated-patterns")
+		literal := getFlagBool(cmd, "literal")
+		mergeRegexp := getFlagBool(cmd, "merge-regexp")
+		matchAll := getFlagBool(cmd, "and")
 
 		immediateOutput := getFlagBool(cmd, "immediate-output")
+		rejectFile := getFlagString(cmd, "reject-file")
+		maxMatches := getFlagNonNegativeInt(cmd, "max-matches")
+		if maxMatches > 0 && rejectFile != "" {
+			checkError(fmt.Errorf("flag --max-matches is not compatible with --reject-file"))
+		}
+
+		illuminaTiles := getFlagStringSlice(cmd, "illumina-tile")
+		byTile := len(illuminaTiles) > 0
+		tiles := make(map[string]struct{}, len(illuminaTiles))
+		if byTile {
+			if cmd.Flags().Lookup("pattern").Changed || patternFile != "" || bySeq || byName || byDesc ||
+				useRegexp || degenerate || mismatches > 0 || region != "" || matchAll {
+				checkError(fmt.Errorf("flag --illumina-tile is not compatible with -p, -f, -s, -n, --by-desc, -r, -d, -m, -R and --and"))
+			}
+			for _, t := range illuminaTiles {
+				if _, err := strconv.Atoi(t); err != nil {
+					checkError(fmt.Errorf("invalid tile in --illumina-tile, numbers expected: %s", t))
+				}
+				tiles[t] = struct{}{}
+			}
+		}
+
+		bedFile := getFlagString(cmd, "bed")
+		bedReportFile := getFlagString(cmd, "bed-report")
+		byBed := bedFile != ""
+		var bedCovs map[string]*bedCoverage
+		if byBed {
+			if byTile || cmd.Flags().Lookup("pattern").Changed || patternFile != "" || bySeq || byName || byDesc ||
+				useRegexp || degenerate || mismatches > 0 || region != "" || matchAll {
+				checkError(fmt.Errorf("flag --bed is not compatible with --illumina-tile, -p, -f, -s, -n, --by-desc, -r, -d, -m, -R and --and"))
+			}
+			Threads = config.Threads // threads of ReadBedFeatures
+			features, err := ReadBedFeatures(bedFile)
+			checkError(err)
+			bedCovs = bedCoverages(features)
+			if !quiet {
+				log.Infof("%d BED intervals of %d chromosomes loaded", len(features), len(bedCovs))
+			}
+		} else if bedReportFile != "" {
+			checkError(fmt.Errorf("flag --bed-report must be used with flag --bed"))
+		}
 
-		if len(pattern) == 0 && patternFile == "" {
+		refsFile := getFlagString(cmd, "in-refs")
+		refsReportFile := getFlagString(cmd, "in-refs-report")
+		byRefs := refsFile != ""
+		var refIdx *grepRefIndex
+		var refMismatches int
+		if byRefs {
+			if byTile || byBed || cmd.Flags().Lookup("pattern").Changed || patternFile != "" || byName || byDesc ||
+				useRegexp || degenerate || region != "" || matchAll || circular {
+				checkError(fmt.Errorf("flag --in-refs is not compatible with --illumina-tile, --bed, -p, -f, -n, --by-desc, -r, -d, -R, --and and -c"))
+			}
+			if !quiet {
+				log.Infof("build FM-index for reference sequences in %s ...", refsFile)
+			}
+			var err error
+			refIdx, err = newGrepRefIndex(refsFile, alphabet, idRegexp, ignoreCase)
+			checkError(err)
+			if !quiet {
+				log.Infof("%d reference sequences (%d bases) indexed", len(refIdx.ids), len(refIdx.text)-len(refIdx.ids)+1)
+			}
+			// searching is done with the FM-index of references, instead of those of records.
+			refMismatches, mismatches = mismatches, 0
+		} else if refsReportFile != "" {
+			checkError(fmt.Errorf("flag --in-refs-report must be used with flag --in-refs"))
+		}
+
+		if len(pattern) == 0 && patternFile == "" && !byTile && !byBed && !byRefs {
 			checkError(fmt.Errorf("one of flags -p (--pattern) and -f (--pattern-file) needed"))
 		}
 
+		if byDesc && (byName || bySeq) {
+			checkError(fmt.Errorf("flag --by-desc is not compatible with -n (--by-name) and -s (--by-seq)"))
+		}
+
 		// check pattern with unquoted comma
 		hasUnquotedComma := false
 		for _, _pattern := range pattern {
@@ -162,9 +305,25 @@ Examples:
 			checkError(fmt.Errorf("could not give both flags -d (--degenerate) and -r (--use-regexp)"))
 		}
 
+		if literal && (useRegexp || degenerate || bySeq) {
+			checkError(fmt.Errorf("flag --literal is not allowed when giving flag -r (--use-regexp), -d (--degenerate) or -s (--by-seq)"))
+		}
+		if mergeRegexp {
+			if !(useRegexp || degenerate) {
+				checkError(fmt.Errorf("flag -r (--use-regexp) or -d (--degenerate) needed when giving flag --merge-regexp"))
+			}
+			if deleteMatched {
+				checkError(fmt.Errorf("flag --delete-matched is not allowed when giving flag --merge-regexp"))
+			}
+		}
+
 		var start, end int
 		var err error
 		var limitRegion bool
+		skipShort := getFlagBool(cmd, "skip-short")
+		if skipShort && region == "" {
+			checkError(fmt.Errorf("flag -R (--region) needed when using --skip-short"))
+		}
 		if region != "" {
 			limitRegion = true
 			if !bySeq {
@@ -187,6 +346,15 @@ Examples:
 			}
 		}
 
+		if matchAll {
+			if !bySeq {
+				checkError(fmt.Errorf("flag --and only works with flag -s (--by-seq)"))
+			}
+			if mergeRegexp || deleteMatched {
+				checkError(fmt.Errorf("flag --and is not allowed when giving flag --merge-regexp or --delete-matched"))
+			}
+		}
+
 		// prepare pattern
 		patternsR := make(map[uint64]*regexp.Regexp, 1<<10)
 		patternsN := make(map[uint64]int, 1<<20)
@@ -212,7 +380,7 @@ Examples:
 							log.Warningf(`symbol ">" detected, it should not be a part of the sequence ID/name: %s`, p)
 						} else if p[0] == '@' {
 							log.Warningf(`symbol "@" detected, it should not be a part of the sequence ID/name. %s`, p)
-						} else if !byName && usingDefaultIDRegexp && strings.ContainsAny(p, "\t ") {
+						} else if !byName && !byDesc && usingDefaultIDRegexp && strings.ContainsAny(p, "\t ") {
 							log.Warningf("space found in pattern, you may need use -n/--by-name: %s", p)
 						}
 					}
@@ -250,6 +418,9 @@ Examples:
 							checkError(fmt.Errorf("illegal DNA/RNA/Protein sequence: %s", p))
 						}
 					} else {
+						if literal && reRegexpMeta.MatchString(p) {
+							checkError(fmt.Errorf("regular expression metacharacter found in pattern with flag --literal: %s", p))
+						}
 						if ignoreCase {
 							patternsN[xxhash.Sum64String(strings.ToLower(p))]++
 						} else {
@@ -272,7 +443,7 @@ Examples:
 						log.Warningf(`symbol ">" detected, it should not be a part of the sequence ID/name: %s`, p)
 					} else if p[0] == '@' {
 						log.Warningf(`symbol "@" detected, it should not be a part of the sequence ID/name. %s`, p)
-					} else if !byName && usingDefaultIDRegexp && strings.ContainsAny(p, "\t ") {
+					} else if !byName && !byDesc && usingDefaultIDRegexp && strings.ContainsAny(p, "\t ") {
 						log.Warningf("space found in pattern, you may need use -n/--by-name: %s", p)
 					}
 				}
@@ -310,6 +481,9 @@ Examples:
 						checkError(fmt.Errorf("illegal DNA/RNA/Protein sequence: %s", p))
 					}
 				} else {
+					if literal && reRegexpMeta.MatchString(p) {
+						checkError(fmt.Errorf("regular expression metacharacter found in pattern with flag --literal: %s", p))
+					}
 					if ignoreCase {
 						patternsN[xxhash.Sum64String(strings.ToLower(p))]++
 					} else {
@@ -319,13 +493,135 @@ Examples:
 			}
 		}
 
+		// merge regular expressions into a single one
+		var reMerged *regexp.Regexp
+		if mergeRegexp && len(patternsR) > 0 {
+			exprs := make([]string, 0, len(patternsR))
+			for _, re := range patternsR {
+				exprs = append(exprs, "(?:"+re.String()+")")
+			}
+			reMerged, err = regexp.Compile(strings.Join(exprs, "|"))
+			checkError(err)
+		}
+
 		outfh, err := xopen.Wopen(outFile)
 		checkError(err)
 		defer outfh.Close()
 
+		var rejfh *xopen.Writer
+		if rejectFile != "" {
+			if justCount {
+				checkError(fmt.Errorf("flag --reject-file is not compatible with -C/--count"))
+			}
+			if rejectFile == outFile && outFile != "-" {
+				checkError(fmt.Errorf("the file of --reject-file should be different from -o/--out-file"))
+			}
+			rejfh, err = xopen.Wopen(rejectFile)
+			checkError(err)
+			defer rejfh.Close()
+		}
+
+		var bedfh *xopen.Writer
+		if bedReportFile != "" {
+			bedfh, err = xopen.Wopen(bedReportFile)
+			checkError(err)
+			defer bedfh.Close()
+			bedfh.WriteString("id\tlength\tintervals\tcovered_bases\n")
+		}
+
+		var refsfh *xopen.Writer
+		if refsReportFile != "" {
+			refsfh, err = xopen.Wopen(refsReportFile)
+			checkError(err)
+			defer refsfh.Close()
+			refsfh.WriteString("id\tref\tstrand\tstart\tend\tmismatches\n")
+		}
+		var refHits []grepRefHit
+
 		var record *fastx.Record
 		strands := []byte{'+', '-'}
 
+		// for --and, checking if every pattern is found in a record, on either strand.
+		// It returns false right after a pattern is not found.
+		var patternsRList []*regexp.Regexp
+		if matchAll {
+			patternsRList = make([]*regexp.Regexp, 0, len(patternsR))
+			for _, re := range patternsR {
+				patternsRList = append(patternsRList, re)
+			}
+		}
+		matchAllPatterns := func(record *fastx.Record, sfmis []*fmi.FMIndex) bool {
+			targets := make([][]byte, 0, 2)
+			var sequence *seq.Seq
+			var target []byte
+			for _, strand := range strands {
+				if strand == '-' && onlyPositiveStrand {
+					break
+				}
+				sequence = record.Seq
+				if strand == '-' {
+					sequence = record.Seq.RevCom()
+				}
+				if limitRegion {
+					target = sequence.SubSeq(start, end).Seq
+				} else if circular {
+					target = make([]byte, len(sequence.Seq)*2)
+					copy(target[0:len(sequence.Seq)], sequence.Seq)
+					copy(target[len(sequence.Seq):], sequence.Seq)
+				} else {
+					target = sequence.Seq
+				}
+				if ignoreCase && !(degenerate || useRegexp) {
+					target = bytes.ToLower(target)
+				}
+				if mismatches > 0 {
+					if _, err := sfmis[len(targets)].Transform(target); err != nil {
+						checkError(fmt.Errorf("fail to build FMIndex for sequence: %s", record.Name))
+					}
+				}
+				targets = append(targets, target)
+			}
+
+			var found bool
+			if degenerate || useRegexp {
+				for _, re := range patternsRList {
+					found = false
+					for _, target = range targets {
+						if re.Match(target) {
+							found = true
+							break
+						}
+					}
+					if !found {
+						return false
+					}
+				}
+				return true
+			}
+
+			var err error
+			for _, k := range patternsS {
+				found = false
+				for j, target := range targets {
+					if mismatches > 0 {
+						found, err = sfmis[j].Match(k, mismatches)
+						if err != nil {
+							checkError(fmt.Errorf("fail to search pattern '%s' on seq '%s': %s", k, record.Name, err))
+						}
+					} else {
+						found = bytes.Contains(target, k)
+					}
+					if found {
+						break
+					}
+				}
+				if !found {
+					return false
+				}
+			}
+			return true
+		}
+
 		var count int
 
 		// -------------------------------------------------------------------
@@ -339,6 +635,7 @@ Examples:
 			}
 
 			var wg sync.WaitGroup
+			var stopReading int32 // set by the collector when --max-matches is reached
 			ch := make(chan *Arecord, config.Threads)
 			tokens := make(chan int, config.Threads)
 
@@ -349,9 +646,29 @@ Examples:
 				var ok bool
 				var _r *Arecord
 
+				// output a matched record, unless the maximum number is reached
+				var nMatched int
+				output := func(r *Arecord) {
+					if maxMatches > 0 && nMatched >= maxMatches {
+						return
+					}
+					nMatched++
+					if justCount {
+						count++
+					} else {
+						r.record.FormatToWriter(outfh, config.LineWidth)
+						if immediateOutput {
+							outfh.Flush()
+						}
+					}
+					if maxMatches > 0 && nMatched >= maxMatches {
+						atomic.StoreInt32(&stopReading, 1)
+					}
+				}
+
 				id = 1
 				for r := range ch {
-					if justCount {
+					if justCount && maxMatches == 0 {
 						if r.ok {
 							count++
 						}
@@ -362,9 +679,11 @@ Examples:
 
 					if _id == id { // right there
 						if r.ok {
-							r.record.FormatToWriter(outfh, config.LineWidth)
+							output(r)
+						} else if rejfh != nil && r.record != nil {
+							r.record.FormatToWriter(rejfh, config.LineWidth)
 							if immediateOutput {
-								outfh.Flush()
+								rejfh.Flush()
 							}
 						}
 						id++
@@ -375,9 +694,11 @@ Examples:
 
 					if _r, ok = m[id]; ok { // check buffered
 						if _r.ok {
-							_r.record.FormatToWriter(outfh, config.LineWidth)
+							output(_r)
+						} else if rejfh != nil && _r.record != nil {
+							_r.record.FormatToWriter(rejfh, config.LineWidth)
 							if immediateOutput {
-								outfh.Flush()
+								rejfh.Flush()
 							}
 						}
 						delete(m, id)
@@ -397,9 +718,11 @@ Examples:
 						_r = m[_id]
 
 						if _r.ok {
-							_r.record.FormatToWriter(outfh, config.LineWidth)
+							output(_r)
+						} else if rejfh != nil && _r.record != nil {
+							_r.record.FormatToWriter(rejfh, config.LineWidth)
 							if immediateOutput {
-								outfh.Flush()
+								rejfh.Flush()
 							}
 						}
 					}
@@ -408,12 +731,17 @@ Examples:
 			}()
 
 			var id uint64
+		PARALLEL:
 			for _, file := range files {
 				fastxReader, err := fastx.NewReader(alphabet, file, idRegexp)
 				checkError(err)
 
 				checkAlphabet := true
 				for {
+					if atomic.LoadInt32(&stopReading) == 1 {
+						fastxReader.Close()
+						break PARALLEL
+					}
 					record, err = fastxReader.Read()
 					if err != nil {
 						if err == io.EOF {
@@ -422,8 +750,18 @@ Examples:
 						checkError(err)
 						break
 					}
+					bench.addRecord()
+
+					if fastxReader.IsFastq {
+						config.LineWidth = 0
+						fastx.ForcelyOutputFastq = true
+					}
 
 					if len(record.Seq.Seq) == 0 {
+						if rejfh != nil {
+							id++
+							ch <- &Arecord{record: record.Clone(), ok: false, id: id}
+						}
 						continue
 					}
 
@@ -434,11 +772,6 @@ Examples:
 						checkAlphabet = false
 					}
 
-					if fastxReader.IsFastq {
-						config.LineWidth = 0
-						fastx.ForcelyOutputFastq = true
-					}
-
 					tokens <- 1
 					wg.Add(1)
 					id++
@@ -448,68 +781,77 @@ Examples:
 							<-tokens
 						}()
 
+						if skipShort && !regionCovered(len(record.Seq.Seq), start, end) {
+							ch <- &Arecord{record: record, ok: false, id: id}
+							return
+						}
+
 						var sequence *seq.Seq
 						var target []byte
 						var hit bool
 						// var k string
 						var k []byte
 
-						sfmi := fmi.NewFMIndex()
+						if matchAll {
+							hit = matchAllPatterns(record, []*fmi.FMIndex{fmi.NewFMIndex(), fmi.NewFMIndex()})
+						} else {
+							sfmi := fmi.NewFMIndex()
 
-						for _, strand := range strands {
-							if hit {
-								break
-							}
+							for _, strand := range strands {
+								if hit {
+									break
+								}
 
-							if strand == '-' && onlyPositiveStrand {
-								break
-							}
+								if strand == '-' && onlyPositiveStrand {
+									break
+								}
 
-							sequence = record.Seq
-							if strand == '-' {
-								sequence = record.Seq.RevCom()
-							}
-							if limitRegion {
-								target = sequence.SubSeq(start, end).Seq
-							} else if circular {
-								// concat two copies of sequence, and do not change orginal sequence
-								target = make([]byte, len(sequence.Seq)*2)
-								copy(target[0:len(sequence.Seq)], sequence.Seq)
-								copy(target[len(sequence.Seq):], sequence.Seq)
-							} else {
-								target = sequence.Seq
-							}
+								sequence = record.Seq
+								if strand == '-' {
+									sequence = record.Seq.RevCom()
+								}
+								if limitRegion {
+									target = sequence.SubSeq(start, end).Seq
+								} else if circular {
+									// concat two copies of sequence, and do not change orginal sequence
+									target = make([]byte, len(sequence.Seq)*2)
+									copy(target[0:len(sequence.Seq)], sequence.Seq)
+									copy(target[len(sequence.Seq):], sequence.Seq)
+								} else {
+									target = sequence.Seq
+								}
 
-							if ignoreCase {
-								target = bytes.ToLower(target)
-							}
+								if ignoreCase {
+									target = bytes.ToLower(target)
+								}
 
-							_, err = sfmi.Transform(target)
-							if err != nil {
-								checkError(fmt.Errorf("fail to build FMIndex for sequence: %s", record.Name))
-							}
-							// for k = range patternsS {
-							for _, k = range patternsS {
-								// hit, err = sfmi.Match([]byte(k), mismatches)
-								hit, err = sfmi.Match(k, mismatches)
+								_, err = sfmi.Transform(target)
 								if err != nil {
-									checkError(fmt.Errorf("fail to search pattern '%s' on seq '%s': %s", k, record.Name, err))
+									checkError(fmt.Errorf("fail to build FMIndex for sequence: %s", record.Name))
 								}
-								if hit {
-									break
+								// for k = range patternsS {
+								for _, k = range patternsS {
+									// hit, err = sfmi.Match([]byte(k), mismatches)
+									hit, err = sfmi.Match(k, mismatches)
+									if err != nil {
+										checkError(fmt.Errorf("fail to search pattern '%s' on seq '%s': %s", k, record.Name, err))
+									}
+									if hit {
+										break
+									}
 								}
-							}
 
+							}
 						}
 
 						if invertMatch {
 							if hit {
-								ch <- &Arecord{record: nil, ok: false, id: id}
+								ch <- &Arecord{record: record, ok: false, id: id}
 								return
 							}
 						} else {
 							if !hit {
-								ch <- &Arecord{record: nil, ok: false, id: id}
+								ch <- &Arecord{record: record, ok: false, id: id}
 								return
 							}
 						}
@@ -542,7 +884,89 @@ Examples:
 		var h uint64
 		var strand byte
 		var i, n int // for output records multiple times when duplicated patterns are given.
+
+		// records matched by ID or name could be read directly with the index (seqkit index)
+		useIndex := !bySeq && !byDesc && !useRegexp && !degenerate && !invertMatch && rejfh == nil && !byTile && !byBed && !byRefs
+		var nMalformed int
+		var nMatched int // for --max-matches
+		var idRe *regexp.Regexp
+		if (useIndex && !byName) || (byDesc && !usingDefaultIDRegexp) {
+			idRe, err = regexp.Compile(idRegexp)
+			checkError(err)
+		}
+	FILES:
 		for _, file := range files {
+			if maxMatches > 0 && nMatched >= maxMatches {
+				break
+			}
+			if useIndex {
+				if idx := loadSeqIndex(file, quiet); idx != nil {
+					if idx.fastq {
+						config.LineWidth = 0
+						fastx.ForcelyOutputFastq = true
+					}
+					idxReader, err := newSeqIndexReader(file, idx, alphabet, idRegexp)
+					checkError(err)
+					for j, name := range idx.names {
+						if byName {
+							target = name
+						} else {
+							target = fastx.ParseHeadID(idRe, name)
+						}
+						h = xxhash.Sum64(target)
+						if ignoreCase {
+							h = xxhash.Sum64(bytes.ToLower(target))
+						}
+						if n, ok = patternsN[h]; !ok {
+							continue
+						}
+						if deleteMatched {
+							delete(patternsN, h)
+						}
+
+						fastxReader, err := idxReader.seek(idx.offsets[j])
+						checkError(err)
+						record, err = fastxReader.Read()
+						if err != nil {
+							checkError(fmt.Errorf("%s: failed to read record via the index, please rebuild it: %s", file, err))
+						}
+						bench.addRecord()
+						fastxReader.Close()
+
+						if len(record.Seq.Seq) == 0 {
+							continue
+						}
+
+						if justCount {
+							count++
+							if allowDups && n > 1 && maxMatches == 0 {
+								count += n - 1
+							}
+						} else {
+							record.FormatToWriter(outfh, config.LineWidth)
+							if allowDups && n > 1 {
+								for i = 0; i < n-1; i++ {
+									record.FormatToWriter(outfh, config.LineWidth)
+								}
+							}
+						}
+
+						if immediateOutput {
+							outfh.Flush()
+						}
+
+						nMatched++
+						if maxMatches > 0 && nMatched >= maxMatches {
+							break
+						}
+					}
+					checkError(idxReader.Close())
+
+					config.LineWidth = lineWidth
+					continue
+				}
+			}
+
 			fastxReader, err := fastx.NewReader(alphabet, file, idRegexp)
 			checkError(err)
 
@@ -556,8 +980,17 @@ Examples:
 					checkError(err)
 					break
 				}
+				bench.addRecord()
+
+				if fastxReader.IsFastq {
+					config.LineWidth = 0
+					fastx.ForcelyOutputFastq = true
+				}
 
-				if len(record.Seq.Seq) == 0 {
+				if len(record.Seq.Seq) == 0 || (skipShort && !regionCovered(len(record.Seq.Seq), start, end)) {
+					if rejfh != nil {
+						record.FormatToWriter(rejfh, config.LineWidth)
+					}
 					continue
 				}
 
@@ -568,13 +1001,14 @@ Examples:
 					checkAlphabet = false
 				}
 
-				if fastxReader.IsFastq {
-					config.LineWidth = 0
-					fastx.ForcelyOutputFastq = true
-				}
-
 				if byName {
 					target = record.Name
+				} else if byDesc {
+					if usingDefaultIDRegexp {
+						target = record.Desc
+					} else {
+						target = grepParseHeadDesc(idRe, record.Name)
+					}
 				} else if bySeq {
 
 				} else {
@@ -585,109 +1019,148 @@ Examples:
 
 				n = 1
 
-				for _, strand = range strands {
-					if hit {
-						break
+				if byTile {
+					if target, ok = parseIlluminaTile(record.ID); !ok {
+						nMalformed++
+						if rejfh != nil {
+							record.FormatToWriter(rejfh, config.LineWidth)
+						}
+						continue
 					}
-
-					if strand == '-' {
-						if bySeq {
-							if onlyPositiveStrand {
-								break
-							}
-						} else {
+					_, hit = tiles[string(target)]
+				} else if byBed {
+					_, hit = bedCovs[string(record.ID)]
+				} else if byRefs {
+					refHits, err = refIdx.search(record.Seq, refMismatches, !onlyPositiveStrand, refsfh != nil && !invertMatch)
+					if err != nil {
+						checkError(fmt.Errorf("fail to search sequence '%s' in references: %s", record.Name, err))
+					}
+					hit = len(refHits) > 0
+				} else if matchAll {
+					hit = matchAllPatterns(record, nil) // mismatches == 0 here
+				} else {
+					for _, strand = range strands {
+						if hit {
 							break
 						}
-					}
 
-					if bySeq {
-						sequence = record.Seq
 						if strand == '-' {
-							sequence = record.Seq.RevCom()
-						}
-						if limitRegion {
-							target = sequence.SubSeq(start, end).Seq
-						} else if circular {
-							// concat two copies of sequence, and do not change orginal sequence
-							target = make([]byte, len(sequence.Seq)*2)
-							copy(target[0:len(sequence.Seq)], sequence.Seq)
-							copy(target[len(sequence.Seq):], sequence.Seq)
-						} else {
-							target = sequence.Seq
-						}
-					}
-
-					if degenerate || useRegexp {
-						for h, re = range patternsR {
-							if re.Match(target) {
-								hit = true
-								if deleteMatched && !invertMatch {
-									delete(patternsR, h)
+							if bySeq {
+								if onlyPositiveStrand {
+									break
 								}
+							} else {
 								break
 							}
 						}
-					} else if bySeq {
-						if ignoreCase {
-							target = bytes.ToLower(target)
+
+						if bySeq {
+							sequence = record.Seq
+							if strand == '-' {
+								sequence = record.Seq.RevCom()
+							}
+							if limitRegion {
+								target = sequence.SubSeq(start, end).Seq
+							} else if circular {
+								// concat two copies of sequence, and do not change orginal sequence
+								target = make([]byte, len(sequence.Seq)*2)
+								copy(target[0:len(sequence.Seq)], sequence.Seq)
+								copy(target[len(sequence.Seq):], sequence.Seq)
+							} else {
+								target = sequence.Seq
+							}
 						}
-						if mismatches == 0 {
-							// for k = range patternsS {
-							for _, k = range patternsS {
-								// if bytes.Contains(target, []byte(k)) {
-								if bytes.Contains(target, k) {
+
+						if reMerged != nil {
+							hit = reMerged.Match(target)
+						} else if degenerate || useRegexp {
+							for h, re = range patternsR {
+								if re.Match(target) {
 									hit = true
-									// if deleteMatched && !invertMatch {
-									// 	delete(patternsS, k)
-									// }
+									if deleteMatched && !invertMatch {
+										delete(patternsR, h)
+									}
 									break
 								}
 							}
-						} else {
-							_, err = sfmi.Transform(target)
-							if err != nil {
-								checkError(fmt.Errorf("fail to build FMIndex for sequence: %s", record.Name))
+						} else if bySeq {
+							if ignoreCase {
+								target = bytes.ToLower(target)
 							}
-							// for k = range patternsS {
-							for _, k = range patternsS {
-								// hit, err = sfmi.Match([]byte(k), mismatches)
-								hit, err = sfmi.Match(k, mismatches)
+							if mismatches == 0 {
+								// for k = range patternsS {
+								for _, k = range patternsS {
+									// if bytes.Contains(target, []byte(k)) {
+									if bytes.Contains(target, k) {
+										hit = true
+										// if deleteMatched && !invertMatch {
+										// 	delete(patternsS, k)
+										// }
+										break
+									}
+								}
+							} else {
+								_, err = sfmi.Transform(target)
 								if err != nil {
-									checkError(fmt.Errorf("fail to search pattern '%s' on seq '%s': %s", k, record.Name, err))
+									checkError(fmt.Errorf("fail to build FMIndex for sequence: %s", record.Name))
 								}
-								if hit {
-									break
+								// for k = range patternsS {
+								for _, k = range patternsS {
+									// hit, err = sfmi.Match([]byte(k), mismatches)
+									hit, err = sfmi.Match(k, mismatches)
+									if err != nil {
+										checkError(fmt.Errorf("fail to search pattern '%s' on seq '%s': %s", k, record.Name, err))
+									}
+									if hit {
+										break
+									}
 								}
 							}
-						}
-					} else {
-						h = xxhash.Sum64(target)
-						if ignoreCase {
-							h = xxhash.Sum64(bytes.ToLower(target))
-						}
-						if n, ok = patternsN[h]; ok {
-							hit = true
-							if deleteMatched && !invertMatch {
-								delete(patternsN, h)
+						} else {
+							h = xxhash.Sum64(target)
+							if ignoreCase {
+								h = xxhash.Sum64(bytes.ToLower(target))
+							}
+							if n, ok = patternsN[h]; ok {
+								hit = true
+								if deleteMatched && !invertMatch {
+									delete(patternsN, h)
+								}
 							}
 						}
+
 					}
+				}
 
+				if hit == invertMatch {
+					if rejfh != nil {
+						record.FormatToWriter(rejfh, config.LineWidth)
+						if immediateOutput {
+							rejfh.Flush()
+						}
+					}
+					continue
 				}
 
-				if invertMatch {
-					if hit {
-						continue
+				if bedfh != nil {
+					if cov, ok := bedCovs[string(record.ID)]; ok {
+						fmt.Fprintf(bedfh, "%s\t%d\t%d\t%d\n", record.ID, len(record.Seq.Seq),
+							cov.Intervals, cov.CoveredBases(len(record.Seq.Seq)))
+					} else {
+						fmt.Fprintf(bedfh, "%s\t%d\t0\t0\n", record.ID, len(record.Seq.Seq))
 					}
-				} else {
-					if !hit {
-						continue
+				}
+
+				if refsfh != nil {
+					for _, h := range refHits {
+						fmt.Fprintf(refsfh, "%s\t%s\t%c\t%d\t%d\t%d\n", record.ID, refIdx.ids[h.ref],
+							h.strand, h.start, h.end, h.mismatches)
 					}
 				}
 
 				if justCount {
 					count++
-					if allowDups && n > 1 {
+					if allowDups && n > 1 && maxMatches == 0 {
 						count += n - 1
 					}
 				} else {
@@ -702,12 +1175,22 @@ Examples:
 				if immediateOutput {
 					outfh.Flush()
 				}
+
+				nMatched++
+				if maxMatches > 0 && nMatched >= maxMatches {
+					fastxReader.Close()
+					break FILES
+				}
 			}
 			fastxReader.Close()
 
 			config.LineWidth = lineWidth
 		}
 
+		if nMalformed > 0 && !quiet {
+			log.Warningf("%d records with malformed Illumina read IDs skipped", nMalformed)
+		}
+
 		if justCount {
 			fmt.Fprintf(outfh, "%d\n", count)
 		}
@@ -721,20 +1204,88 @@ func init() {
 	grepCmd.Flags().BoolP("allow-duplicated-patterns", "D", false, "output records multiple times when duplicated patterns are given")
 	grepCmd.Flags().StringP("pattern-file", "f", "", "pattern file (one record per line)")
 	grepCmd.Flags().BoolP("use-regexp", "r", false, "patterns are regular expression")
+	grepCmd.Flags().BoolP("literal", "", false, "patterns are literal IDs/names, report error if any pattern contains regular expression metacharacters")
+	grepCmd.Flags().BoolP("merge-regexp", "", false, "merge all regular expressions into one, which is faster for a large number of patterns")
 	grepCmd.Flags().BoolP("delete-matched", "", false, "delete a pattern right after being matched, this keeps the firstly matched data and speedups when using regular expressions")
 	grepCmd.Flags().BoolP("invert-match", "v", false, "invert the sense of matching, to select non-matching records")
 	grepCmd.Flags().BoolP("by-name", "n", false, "match by full name instead of just ID")
+	grepCmd.Flags().BoolP("by-desc", "", false, "match by description, i.e., the part of the header after the ID parsed by --id-regexp")
 	grepCmd.Flags().BoolP("by-seq", "s", false, "search subseq on seq. Both positive and negative strand are searched by default, you might use -P/--only-positive-strand. Mismatch allowed using flag -m/--max-mismatch")
 	grepCmd.Flags().BoolP("only-positive-strand", "P", false, "only search on the positive strand")
 	grepCmd.Flags().IntP("max-mismatch", "m", 0, "max mismatch when matching by seq. For large genomes like human genome, using mapping/alignment tools would be faster")
+	grepCmd.Flags().BoolP("and", "", false, "only match records containing all patterns when searching by sequence (-s)")
 	grepCmd.Flags().BoolP("ignore-case", "i", false, "ignore case")
 	grepCmd.Flags().BoolP("degenerate", "d", false, "pattern/motif contains degenerate base")
 	grepCmd.Flags().StringP("region", "R", "", "specify sequence region for searching. "+
 		"e.g 1:12 for first 12 bases, -12:-1 for last 12 bases")
+	grepCmd.Flags().BoolP("skip-short", "", false, "skip records shorter than the region given by -R/--region, instead of searching the existing part")
 	grepCmd.Flags().BoolP("circular", "c", false, "circular genome")
 	grepCmd.Flags().BoolP("immediate-output", "I", false, "print output immediately, do not use write buffer")
+	grepCmd.Flags().StringSliceP("illumina-tile", "", []string{}, "only match reads from these flowcell tiles parsed from Illumina read IDs, e.g., --illumina-tile 1101,2101")
+	grepCmd.Flags().StringP("bed", "", "", "only match sequences whose IDs are chromosome names with any interval in this BED file")
+	grepCmd.Flags().StringP("bed-report", "", "", "write per-sequence numbers of BED intervals and covered bases of outputted sequences to this TSV file, used with --bed")
+	grepCmd.Flags().StringP("in-refs", "", "", `only match records whose sequences are substrings of any sequence in this file, type "seqkit grep -h" for details`)
+	grepCmd.Flags().StringP("in-refs-report", "", "", "write locations of outputted records in reference sequences to this TSV file, used with --in-refs")
+	grepCmd.Flags().IntP("max-matches", "", 0, "stop reading after outputting N records, i.e., matched ones, or non-matching ones with -v (0 for no limit)")
+	grepCmd.Flags().StringP("reject-file", "", "", `write records not outputted, e.g., non-matching ones, to this file, for partitioning the input in one pass`)
 	grepCmd.Flags().BoolP("count", "C", false, "just print a count of matching records. with the -v/--invert-match flag, count non-matching records")
 }
 
+// "." and "|" are not included as they are common in sequence IDs/names.
+var reRegexpMeta = regexp.MustCompile(`[\\^$*+?()\[\]{}]`)
+
 var reUnquotedComma = regexp.MustCompile(`\{[^\}]*$|^[^\{]*\}`)
 var helpUnquotedComma = `possible unquoted comma detected, please use double quotation marks for patterns containing comma, e.g., -p '"A{2,}"' or -p "\"A{2,}\""`
+
+// regionCovered checks if a sequence of the given length fully covers the region.
+func regionCovered(length, start, end int) bool {
+	_, _, ok := seq.SubLocation(length, start, end)
+	if !ok {
+		return false
+	}
+	if start > 0 && end > 0 {
+		return end <= length
+	}
+	if start < 0 {
+		return -start <= length
+	}
+	return true
+}
+
+// grepParseHeadDesc returns the description of a header, i.e., the part after
+// the whole match of the ID regular expression, with leading spaces removed.
+// For headers not matching the regular expression, the part after the first
+// space or tab is returned.
+func grepParseHeadDesc(idRe *regexp.Regexp, head []byte) []byte {
+	var i int
+	if loc := idRe.FindIndex(head); loc != nil {
+		i = loc[1]
+	} else if i = bytes.IndexAny(head, " \t"); i < 0 {
+		return head[len(head):]
+	}
+	return bytes.TrimLeft(head[i:], " \t")
+}
+
+// parseIlluminaTile returns the tile of an Illumina read ID, in the formats of
+// "instrument:run:flowcell:lane:tile:x:y" or "instrument:lane:tile:x:y".
+func parseIlluminaTile(id []byte) ([]byte, bool) {
+	fields := bytes.Split(id, []byte{':'})
+	var tile []byte
+	switch len(fields) {
+	case 7:
+		tile = fields[4]
+	case 5:
+		tile = fields[2]
+	default:
+		return nil, false
+	}
+	if len(tile) == 0 {
+		return nil, false
+	}
+	for _, b := range tile {
+		if b < '0' || b > '9' {
+			return nil, false
+		}
+	}
+	return tile, true
+}
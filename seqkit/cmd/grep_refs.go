@@ -0,0 +1,170 @@
+// Copyright © 2016-2024 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/shenwei356/bio/seq"
+	"github.com/shenwei356/bio/seqio/fastx"
+	"github.com/shenwei356/bwt/fmi"
+)
+
+// grepRefSeparator separates reference sequences in the FM-index,
+// matches spanning it are discarded.
+const grepRefSeparator = byte(1)
+
+// grepRefIndex is an FM-index of reference sequences for "grep --in-refs",
+// for checking whether query sequences are substrings of any reference.
+type grepRefIndex struct {
+	fmi     *fmi.FMIndex
+	text    []byte
+	ids     [][]byte
+	offsets []int // start positions of references in text
+	lens    []int
+
+	ignoreCase bool
+}
+
+// grepRefHit is a location of a query in a reference.
+type grepRefHit struct {
+	ref        int
+	strand     byte
+	start, end int // 1-based, on the positive strand of the reference
+	mismatches int
+}
+
+func newGrepRefIndex(file string, alphabet *seq.Alphabet, idRegexp string, ignoreCase bool) (*grepRefIndex, error) {
+	fastxReader, err := fastx.NewReader(alphabet, file, idRegexp)
+	if err != nil {
+		return nil, err
+	}
+	defer fastxReader.Close()
+
+	idx := &grepRefIndex{ignoreCase: ignoreCase}
+	var record *fastx.Record
+	text := make([]byte, 0, 1<<20)
+	for {
+		record, err = fastxReader.Read()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+		if len(record.Seq.Seq) == 0 {
+			continue
+		}
+		if len(idx.ids) > 0 {
+			text = append(text, grepRefSeparator)
+		}
+		idx.ids = append(idx.ids, []byte(string(record.ID)))
+		idx.offsets = append(idx.offsets, len(text))
+		idx.lens = append(idx.lens, len(record.Seq.Seq))
+		if ignoreCase {
+			text = append(text, bytes.ToLower(record.Seq.Seq)...)
+		} else {
+			text = append(text, record.Seq.Seq...)
+		}
+	}
+	if len(idx.ids) == 0 {
+		return nil, fmt.Errorf("no valid reference sequences found in file: %s", file)
+	}
+	for _, b := range text {
+		if b >= 128 {
+			return nil, fmt.Errorf("non-ASCII characters found in reference sequences of file: %s", file)
+		}
+	}
+
+	idx.fmi = fmi.NewFMIndex()
+	if _, err = idx.fmi.Transform(text); err != nil {
+		return nil, fmt.Errorf("fail to build FM-index for reference sequences: %s", err)
+	}
+	idx.text = text
+	return idx, nil
+}
+
+// search searches a query on the positive strand (and the negative strand if
+// bothStrands is true) of references, with up to mismatches mismatches.
+// Only the first hit is returned unless all is true.
+func (idx *grepRefIndex) search(query *seq.Seq, mismatches int, bothStrands bool, all bool) ([]grepRefHit, error) {
+	var hits []grepRefHit
+	var q []byte
+	var err error
+	var found bool
+	var locs []int
+	var i, e, mis int
+
+	for _, strand := range []byte{'+', '-'} {
+		if strand == '-' {
+			if !bothStrands {
+				break
+			}
+			q = query.RevCom().Seq
+		} else {
+			q = query.Seq
+		}
+		if idx.ignoreCase {
+			q = bytes.ToLower(q)
+		}
+		if len(q) == 0 {
+			return nil, nil
+		}
+
+		// exact matches never span separators
+		if mismatches == 0 && !all {
+			if found, err = idx.fmi.Match(q, 0); err != nil {
+				return nil, err
+			}
+			if found {
+				return []grepRefHit{{strand: strand}}, nil
+			}
+			continue
+		}
+
+		if locs, err = idx.fmi.Locate(q, mismatches); err != nil {
+			return nil, err
+		}
+		for _, loc := range locs {
+			// the reference containing the start position
+			i = sort.SearchInts(idx.offsets, loc+1) - 1
+			e = loc + len(q)
+			if e > idx.offsets[i]+idx.lens[i] {
+				continue
+			}
+			mis = 0
+			for j, b := range q {
+				if idx.text[loc+j] != b {
+					mis++
+				}
+			}
+			hits = append(hits, grepRefHit{ref: i, strand: strand,
+				start: loc - idx.offsets[i] + 1, end: e - idx.offsets[i], mismatches: mis})
+			if !all {
+				return hits, nil
+			}
+		}
+	}
+	return hits, nil
+}
@@ -0,0 +1,172 @@
+// Copyright © 2016-2024 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
+
+	"github.com/shenwei356/bio/seq"
+	"github.com/shenwei356/bio/seqio/fastx"
+	"github.com/shenwei356/xopen"
+	"github.com/spf13/cobra"
+)
+
+// guessEncodingCmd represents the guess-encoding command
+var guessEncodingCmd = &cobra.Command{
+	GroupID: "format",
+
+	Use:     "guess-encoding",
+	Aliases: []string{"encoding"},
+	Short:   "guess the quality encoding of FASTQ files",
+	Long: `guess the quality encoding of FASTQ files
+
+Quality characters of the leading -n/--nrecords records of each file are
+checked, and the observed range of ASCII codes is compared with typical
+ranges of quality encodings:
+
+    Sanger          Phred+33    ASCII  33-126
+    Solexa          Solexa+64   ASCII  59-126
+    Illumina-1.3+   Phred+64    ASCII  64-126
+    Illumina-1.5+   Phred+64    ASCII  66-126
+    Illumina-1.8+   Phred+33    ASCII  33-126
+
+Output columns (tab-delimited):
+  1. file        input file
+  2. records     number of records checked
+  3. min_qual    minimum quality character, and its ASCII code
+  4. max_qual    maximum quality character, and its ASCII code
+  5. encoding    the likely encoding, i.e., the compatible one with the
+                 highest lower bound of the range. Sanger is reported for
+                 Phred+33, which is the same as Illumina-1.8+.
+  6. compatible  all compatible encodings, separated by commas. Ranges of
+                 quality characters in the leading records might be narrower
+                 than those of the whole file, check them when multiple
+                 encodings with different offsets are reported.
+
+Attention:
+  1. FASTA files are reported as errors, with a non-zero exit status.
+  2. "NA" is outputted for files without records.
+  3. Use "seqkit convert" to convert the quality encoding.
+
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		config := getConfigs(cmd)
+		alphabet := config.Alphabet
+		idRegexp := config.IDRegexp
+		outFile := config.OutFile
+		seq.AlphabetGuessSeqLengthThreshold = config.AlphabetGuessSeqLength
+		seq.ValidateSeq = false
+		runtime.GOMAXPROCS(config.Threads)
+
+		nrecords := getFlagPositiveInt(cmd, "nrecords")
+
+		bench := getBenchmarkStats(cmd)
+		files := getFileListFromArgsAndFile(cmd, args, true, "infile-list", true)
+		bench.addInputs(files...)
+
+		outfh, err := xopen.Wopen(outFile)
+		checkError(err)
+		defer outfh.Close()
+
+		outfh.WriteString("file\trecords\tmin_qual\tmax_qual\tencoding\tcompatible\n")
+
+		var record *fastx.Record
+		var n int
+		var min, max byte
+		var q byte
+		var compatible []seq.QualityEncoding
+		var names []string
+		for _, file := range files {
+			fastxReader, err := fastx.NewReader(alphabet, file, idRegexp)
+			checkError(err)
+
+			n = 0
+			min, max = 255, 0
+			for n < nrecords {
+				record, err = fastxReader.Read()
+				if err != nil {
+					if err == io.EOF {
+						break
+					}
+					checkError(err)
+					break
+				}
+				bench.addRecord()
+				if !fastxReader.IsFastq {
+					outfh.Close()
+					checkError(fmt.Errorf("%s: FASTA format detected, FASTQ format is needed", file))
+				}
+
+				for _, q = range record.Seq.Qual {
+					if q < min {
+						min = q
+					}
+					if q > max {
+						max = q
+					}
+				}
+				n++
+			}
+			fastxReader.Close()
+
+			if n == 0 || min > max {
+				outfh.WriteString(fmt.Sprintf("%s\t%d\tNA\tNA\tNA\tNA\n", file, n))
+				continue
+			}
+
+			compatible = guessQualityEncodings(min, max)
+			if len(compatible) == 0 {
+				outfh.WriteString(fmt.Sprintf("%s\t%d\t%c(%d)\t%c(%d)\t%s\t%s\n", file, n, min, min, max, max, seq.Unknown, seq.Unknown))
+				continue
+			}
+			names = names[:0]
+			for _, e := range compatible {
+				names = append(names, e.String())
+			}
+			outfh.WriteString(fmt.Sprintf("%s\t%d\t%c(%d)\t%c(%d)\t%s\t%s\n", file, n, min, min, max, max,
+				compatible[len(compatible)-1], strings.Join(names, ",")))
+		}
+	},
+}
+
+// guessQualityEncodings returns quality encodings of which the typical ranges
+// contain the observed range of quality characters, sorted by the lower bounds
+// of ranges, and Sanger is placed after Illumina-1.8+ for Phred+33.
+func guessQualityEncodings(min, max byte) []seq.QualityEncoding {
+	encodings := make([]seq.QualityEncoding, 0, seq.NQualityEncoding)
+	var r []int
+	for _, e := range []seq.QualityEncoding{seq.Illumina1p8, seq.Sanger, seq.Solexa, seq.Illumina1p3, seq.Illumina1p5} {
+		r = e.QualityRange()
+		if int(min) >= r[0] && int(max) <= r[1] {
+			encodings = append(encodings, e)
+		}
+	}
+	return encodings
+}
+
+func init() {
+	RootCmd.AddCommand(guessEncodingCmd)
+
+	guessEncodingCmd.Flags().IntP("nrecords", "n", 1000, "number of leading records to check for each file")
+}
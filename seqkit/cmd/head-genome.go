@@ -70,7 +70,9 @@ Attention:
 
 		minWords := getFlagPositiveInt(cmd, "mini-common-words")
 
+		bench := getBenchmarkStats(cmd)
 		files := getFileListFromArgsAndFile(cmd, args, true, "infile-list", true)
+		bench.addInputs(files...)
 
 		outfh, err := xopen.Wopen(outFile)
 		checkError(err)
@@ -94,6 +96,7 @@ Attention:
 					checkError(err)
 					break
 				}
+				bench.addRecord()
 
 				if fastxReader.IsFastq {
 					config.LineWidth = 0
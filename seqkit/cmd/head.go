@@ -21,6 +21,7 @@
 package cmd
 
 import (
+	"fmt"
 	"io"
 	"runtime"
 
@@ -41,6 +42,11 @@ var headCmd = &cobra.Command{
 For returning the last N records, use:
     seqkit range -r -N:-1 seqs.fasta
 
+For previewing the first N raw lines of the decompressed data, use -l/--lines.
+The input is not parsed, so it also works on malformed files for debugging.
+Files compressed with gzip, xz, zstd and bzip2 are supported, and reading
+stops right after N lines.
+
 `,
 	Run: func(cmd *cobra.Command, args []string) {
 		config := getConfigs(cmd)
@@ -53,13 +59,24 @@ For returning the last N records, use:
 		runtime.GOMAXPROCS(config.Threads)
 
 		number := getFlagPositiveInt(cmd, "number")
+		lines := getFlagNonNegativeInt(cmd, "lines")
+		if lines > 0 && cmd.Flags().Lookup("number").Changed {
+			checkError(fmt.Errorf("flag -l (--lines) is not compatible with -n (--number)"))
+		}
 
+		bench := getBenchmarkStats(cmd)
 		files := getFileListFromArgsAndFile(cmd, args, true, "infile-list", true)
+		bench.addInputs(files...)
 
 		outfh, err := xopen.Wopen(outFile)
 		checkError(err)
 		defer outfh.Close()
 
+		if lines > 0 {
+			headLines(outfh, files, lines)
+			return
+		}
+
 		var record *fastx.Record
 		i := 0
 		for _, file := range files {
@@ -75,6 +92,7 @@ For returning the last N records, use:
 					checkError(err)
 					break
 				}
+				bench.addRecord()
 				if fastxReader.IsFastq {
 					config.LineWidth = 0
 					fastx.ForcelyOutputFastq = true
@@ -96,4 +114,37 @@ For returning the last N records, use:
 func init() {
 	RootCmd.AddCommand(headCmd)
 	headCmd.Flags().IntP("number", "n", 10, "print first N FASTA/Q records")
+	headCmd.Flags().IntP("lines", "l", 0, "print first N raw lines instead of records, without parsing")
+}
+
+// headLines outputs the first N lines of the files, which are not parsed.
+func headLines(outfh *xopen.Writer, files []string, n int) {
+	var i int
+	var line []byte
+	for _, file := range files {
+		fh, err := xopen.Ropen(file)
+		checkError(err)
+
+		for i < n {
+			line, err = fh.ReadBytes('\n')
+			if len(line) > 0 {
+				outfh.Write(line)
+				if line[len(line)-1] != '\n' {
+					outfh.WriteByte('\n')
+				}
+				i++
+			}
+			if err != nil {
+				if err == io.EOF {
+					break
+				}
+				checkError(err)
+			}
+		}
+		fh.Close()
+
+		if i == n {
+			return
+		}
+	}
 }
@@ -22,14 +22,18 @@ package cmd
 
 import (
 	"bufio"
+	"bytes"
 	"fmt"
 	"io"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"regexp"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 
 	"github.com/cznic/sortutil"
 	"github.com/shenwei356/bio/seq"
@@ -43,10 +47,47 @@ import (
 func checkError(err error) {
 	if err != nil {
 		log.Error(err)
+		runCleanups()
 		os.Exit(-1)
 	}
 }
 
+// cleanups are called before exiting in checkError or on receiving
+// SIGINT/SIGTERM, e.g., for removing temporary files, as deferred functions
+// are not called by os.Exit.
+var cleanups []func()
+var cleanupsMu sync.Mutex
+var cleanupsSignalOnce sync.Once
+
+// addCleanup registers a function to call before exiting on errors or
+// signals. The signal handler is installed once, with the first function.
+func addCleanup(f func()) {
+	cleanupsMu.Lock()
+	cleanups = append(cleanups, f)
+	cleanupsMu.Unlock()
+
+	cleanupsSignalOnce.Do(func() {
+		sigChan := make(chan os.Signal, 1)
+		signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+		go func() {
+			<-sigChan
+			runCleanups()
+			os.Exit(1)
+		}()
+	})
+}
+
+// runCleanups calls registered functions in the reverse order, only once.
+func runCleanups() {
+	cleanupsMu.Lock()
+	fs := cleanups
+	cleanups = nil
+	cleanupsMu.Unlock()
+	for i := len(fs) - 1; i >= 0; i-- {
+		fs[i]()
+	}
+}
+
 func getFileList(args []string, checkFile bool) []string {
 	files := make([]string, 0, 1000)
 	if len(args) == 0 {
@@ -558,6 +599,64 @@ func readKVs(file string, ignoreCase bool) (map[string]string, error) {
 	return kvs, nil
 }
 
+// readKVTable reads a tab-delimited key-value file with multiple value columns,
+// where the first line contains the column names. Keys are in the first column,
+// and values of each key are saved in a map of column names. Missing values
+// of rows with fewer columns are absent in the maps.
+func readKVTable(file string, ignoreCase bool) ([]string, map[string]map[string]string, error) {
+	fh, err := xopen.Ropen(file)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer fh.Close()
+
+	var names []string
+	rows := make(map[string]map[string]string)
+	scanner := bufio.NewScanner(fh)
+	scanner.Buffer(make([]byte, 0, 1<<20), 1<<30)
+	var line, key string
+	var items []string
+	var row map[string]string
+	for scanner.Scan() {
+		line = strings.TrimRight(scanner.Text(), "\r\n")
+		if len(line) == 0 {
+			continue
+		}
+		items = strings.Split(line, "\t")
+		if names == nil {
+			if len(items) < 2 {
+				return nil, nil, fmt.Errorf("at least two columns needed in the header line: %s", line)
+			}
+			seen := make(map[string]struct{}, len(items))
+			for _, name := range items {
+				if _, ok := seen[name]; ok {
+					return nil, nil, fmt.Errorf("duplicated column name in the header line: %s", name)
+				}
+				seen[name] = struct{}{}
+			}
+			names = items
+			continue
+		}
+
+		key = items[0]
+		if ignoreCase {
+			key = strings.ToLower(key)
+		}
+		row = make(map[string]string, len(names)-1)
+		for i, v := range items[1:] {
+			if i+1 >= len(names) {
+				break
+			}
+			row[names[i+1]] = v
+		}
+		rows[key] = row
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+	return names, rows, nil
+}
+
 // ParseByteSize parses byte size from string
 func ParseByteSize(val string) (int64, error) {
 	val = strings.Trim(val, " \t\r\n")
@@ -607,3 +706,86 @@ func ParseByteSize(val string) (int64, error) {
 	}
 	return int64(size * float64(u)), nil
 }
+
+// pairedOutFiles returns the output files of paired reads read1 and read2.
+// If outdir is empty or the directory of read1, files are saved in the directory
+// of read1 with the suffix added, e.g., read_1.<suffix>.fq.gz. Otherwise,
+// base names are kept in outdir, which is created if not existed.
+func pairedOutFiles(read1, read2, outdir, suffix string) ([]string, error) {
+	addSuffix := outdir == "" || filepath.Clean(filepath.Dir(read1)) == filepath.Clean(outdir)
+	if outdir == "" {
+		outdir = filepath.Dir(read1)
+	} else if err := os.MkdirAll(outdir, 0755); err != nil {
+		return nil, err
+	}
+	outFiles := make([]string, 2)
+	for i, file := range []string{read1, read2} {
+		if addSuffix {
+			base, ext := filepathTrimExtension(filepath.Base(file))
+			outFiles[i] = filepath.Join(outdir, base+"."+suffix+ext)
+		} else {
+			outFiles[i] = filepath.Join(outdir, filepath.Base(file))
+		}
+		if filepath.Clean(outFiles[i]) == filepath.Clean(read1) || filepath.Clean(outFiles[i]) == filepath.Clean(read2) {
+			return nil, fmt.Errorf("output file can not be the same as the input file: %s", outFiles[i])
+		}
+	}
+	return outFiles, nil
+}
+
+// pairedReader reads read pairs from two files, where reads are paired in
+// the same order. IDs of mates are compared with the suffixes '/1' and '/2' removed.
+// Read pairs are counted in bench, which can be nil.
+type pairedReader struct {
+	read1, read2     string
+	reader1, reader2 *fastx.Reader
+	bench            *benchmarkStats
+}
+
+func newPairedReader(alphabet *seq.Alphabet, read1, read2 string, idRegexp string, bench *benchmarkStats) (*pairedReader, error) {
+	reader1, err := fastx.NewReader(alphabet, read1, idRegexp)
+	if err != nil {
+		return nil, err
+	}
+	reader2, err := fastx.NewReader(alphabet, read2, idRegexp)
+	if err != nil {
+		reader1.Close()
+		return nil, err
+	}
+	bench.addInputs(read1, read2)
+	return &pairedReader{read1: read1, read2: read2, reader1: reader1, reader2: reader2, bench: bench}, nil
+}
+
+// Read returns the next read pair, or io.EOF after the last pair.
+// Records are reused by the readers, please Clone them if needed.
+func (r *pairedReader) Read() (*fastx.Record, *fastx.Record, error) {
+	record1, err1 := r.reader1.Read()
+	record2, err2 := r.reader2.Read()
+	if err1 == io.EOF && err2 == io.EOF {
+		return nil, nil, io.EOF
+	}
+	if err1 == io.EOF || err2 == io.EOF {
+		return nil, nil, fmt.Errorf("unequal numbers of reads in %s and %s, please run \"seqkit pair\" first", r.read1, r.read2)
+	}
+	if err1 != nil {
+		return nil, nil, err1
+	}
+	if err2 != nil {
+		return nil, nil, err2
+	}
+	if !bytes.Equal(mateBaseName(record1.ID), mateBaseName(record2.ID)) {
+		return nil, nil, fmt.Errorf(`unpaired reads: %s and %s, please run "seqkit pair" first or set the flag --id-regexp`, record1.ID, record2.ID)
+	}
+	r.bench.addRecords(2)
+	return record1, record2, nil
+}
+
+// IsFastq tells whether the reads are in FASTQ format.
+func (r *pairedReader) IsFastq() bool {
+	return r.reader1.IsFastq
+}
+
+func (r *pairedReader) Close() {
+	r.reader1.Close()
+	r.reader2.Close()
+}
@@ -0,0 +1,324 @@
+// Copyright © 2016-2024 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/shenwei356/bio/seq"
+	"github.com/shenwei356/bio/seqio/fastx"
+	"github.com/shenwei356/xopen"
+	"github.com/spf13/cobra"
+)
+
+// hpcCmd represents the hpc command
+var hpcCmd = &cobra.Command{
+	GroupID: "edit",
+
+	Use:     "hpc",
+	Aliases: []string{"homopolymer-compress"},
+	Short:   "homopolymer-compress sequences, i.e., collapse runs of identical bases",
+	Long: `homopolymer-compress sequences, i.e., collapse runs of identical bases
+
+Each run of identical bases (case sensitive unless -i/--ignore-case) is
+collapsed to a single base, the first one of the run, e.g., "AAACGGT" to
+"ACGT". Gaps and ambiguous bases are treated as ordinary letters.
+
+Qualities of FASTQ records:
+  The quality of a collapsed position is chosen by --qual-mode:
+    first: the quality of the first base of the run (default)
+    max:   the maximum quality of the run
+    mean:  the arithmetic mean of quality values of the run, rounded
+
+Run lengths and restoring (--run-lengths FILE):
+  1. Run lengths of collapsed positions are saved to the tab-delimited file,
+     with two columns: the sequence ID and comma-separated run lengths,
+     e.g., "read1<TAB>3,1,2,1" for the example above.
+  2. With -R/--restore, homopolymer-compressed sequences are expanded back
+     with the run lengths in the file, in which records must be in the same
+     order as the input. Sequences are restored losslessly, while the quality
+     of a collapsed position is repeated for the whole run.
+
+The compression ratio, i.e., the number of bases after compression divided
+by that before compression, is reported unless --quiet is given.
+
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		config := getConfigs(cmd)
+		alphabet := config.Alphabet
+		idRegexp := config.IDRegexp
+		outFile := config.OutFile
+		quiet := config.Quiet
+		seq.AlphabetGuessSeqLengthThreshold = config.AlphabetGuessSeqLength
+		seq.ValidateSeq = false
+		runtime.GOMAXPROCS(config.Threads)
+
+		ignoreCase := getFlagBool(cmd, "ignore-case")
+		runLengthsFile := getFlagString(cmd, "run-lengths")
+		restore := getFlagBool(cmd, "restore")
+		qualMode := getFlagString(cmd, "qual-mode")
+		switch qualMode {
+		case "first", "max", "mean":
+		default:
+			checkError(fmt.Errorf("invalid value of flag --qual-mode, available values: first, max, mean: %s", qualMode))
+		}
+		if restore && runLengthsFile == "" {
+			checkError(fmt.Errorf("flag --run-lengths needed when giving flag -R/--restore"))
+		}
+
+		bench := getBenchmarkStats(cmd)
+		files := getFileListFromArgsAndFile(cmd, args, true, "infile-list", true)
+		bench.addInputs(files...)
+
+		outfh, err := xopen.Wopen(outFile)
+		checkError(err)
+		defer outfh.Close()
+
+		var rlReader *bufio.Reader
+		var rlWriter *xopen.Writer
+		if runLengthsFile != "" {
+			if restore {
+				fh, err := xopen.Ropen(runLengthsFile)
+				checkError(err)
+				defer fh.Close()
+				rlReader = bufio.NewReader(fh)
+			} else {
+				rlWriter, err = xopen.Wopen(runLengthsFile)
+				checkError(err)
+				defer rlWriter.Close()
+			}
+		}
+
+		var record *fastx.Record
+		var runs []int
+		var s, q []byte
+		var id string
+		var nBefore, nAfter int64
+		var nSeqs int
+		for _, file := range files {
+			fastxReader, err := fastx.NewReader(alphabet, file, idRegexp)
+			checkError(err)
+
+			for {
+				record, err = fastxReader.Read()
+				if err != nil {
+					if err == io.EOF {
+						break
+					}
+					checkError(err)
+					break
+				}
+				bench.addRecord()
+				if fastxReader.IsFastq {
+					config.LineWidth = 0
+					fastx.ForcelyOutputFastq = true
+				}
+
+				if restore {
+					id, runs, err = readHpcRunLengths(rlReader, runs)
+					if err == io.EOF {
+						checkError(fmt.Errorf("no run lengths left in %s for record: %s", runLengthsFile, record.ID))
+					}
+					checkError(err)
+					if id != string(record.ID) {
+						checkError(fmt.Errorf("unmatched IDs of the record (%s) and run lengths (%s), records should be in the same order", record.ID, id))
+					}
+					if len(runs) != len(record.Seq.Seq) {
+						checkError(fmt.Errorf("unmatched sequence length (%d) and number of run lengths (%d): %s", len(record.Seq.Seq), len(runs), record.ID))
+					}
+					nBefore += int64(len(record.Seq.Seq))
+					record.Seq.Seq, record.Seq.Qual = hpcRestore(record.Seq.Seq, record.Seq.Qual, runs, s[:0], q[:0])
+					s, q = record.Seq.Seq, record.Seq.Qual
+					nAfter += int64(len(record.Seq.Seq))
+				} else {
+					nBefore += int64(len(record.Seq.Seq))
+					record.Seq.Seq, record.Seq.Qual, runs = hpcCompress(record.Seq.Seq, record.Seq.Qual, ignoreCase, qualMode, runs[:0])
+					nAfter += int64(len(record.Seq.Seq))
+
+					if rlWriter != nil {
+						rlWriter.Write(record.ID)
+						rlWriter.WriteByte('\t')
+						for i, r := range runs {
+							if i > 0 {
+								rlWriter.WriteByte(',')
+							}
+							rlWriter.WriteString(strconv.Itoa(r))
+						}
+						rlWriter.WriteByte('\n')
+					}
+				}
+				nSeqs++
+
+				record.FormatToWriter(outfh, config.LineWidth)
+			}
+			fastxReader.Close()
+		}
+
+		if restore {
+			if _, _, err = readHpcRunLengths(rlReader, runs); err != io.EOF {
+				checkError(err)
+				checkError(fmt.Errorf("more run lengths than records found in %s", runLengthsFile))
+			}
+		}
+
+		if !quiet {
+			var ratio float64
+			if nBefore > 0 {
+				ratio = float64(nAfter) / float64(nBefore)
+			}
+			if restore {
+				log.Infof("%d sequences restored, %d bases expanded to %d bases", nSeqs, nBefore, nAfter)
+			} else {
+				log.Infof("%d sequences compressed, %d bases to %d bases, compression ratio: %.4f", nSeqs, nBefore, nAfter, ratio)
+			}
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(hpcCmd)
+
+	hpcCmd.Flags().BoolP("ignore-case", "i", false, "ignore case when comparing bases")
+	hpcCmd.Flags().StringP("qual-mode", "", "first", `quality of a collapsed position of FASTQ records, available values: first, max, mean`)
+	hpcCmd.Flags().StringP("run-lengths", "", "", `file to save run lengths of collapsed positions, or to read them with -R/--restore`)
+	hpcCmd.Flags().BoolP("restore", "R", false, `restore homopolymer-compressed sequences with run lengths given by --run-lengths`)
+}
+
+// hpcCompress collapses runs of identical bases in place, and returns
+// the compressed sequence and qualities, and run lengths appended to runs.
+func hpcCompress(s, qual []byte, ignoreCase bool, qualMode string, runs []int) ([]byte, []byte, []int) {
+	if len(s) == 0 {
+		return s, qual, runs
+	}
+	hasQual := len(qual) == len(s)
+	var j, start, sum, k int
+	var b, c byte
+	var qmax byte
+	for i := 1; i <= len(s); i++ {
+		if i < len(s) {
+			b, c = s[i], s[start]
+			if ignoreCase {
+				b, c = hpcLower(b), hpcLower(c)
+			}
+			if b == c {
+				continue
+			}
+		}
+
+		// the run of s[start:i]
+		s[j] = s[start]
+		if hasQual {
+			switch qualMode {
+			case "max":
+				qmax = qual[start]
+				for k = start + 1; k < i; k++ {
+					if qual[k] > qmax {
+						qmax = qual[k]
+					}
+				}
+				qual[j] = qmax
+			case "mean":
+				sum = 0
+				for k = start; k < i; k++ {
+					sum += int(qual[k])
+				}
+				qual[j] = byte((2*sum + i - start) / (2 * (i - start)))
+			default:
+				qual[j] = qual[start]
+			}
+		}
+		runs = append(runs, i-start)
+		j++
+		start = i
+	}
+	if hasQual {
+		qual = qual[:j]
+	}
+	return s[:j], qual, runs
+}
+
+func hpcLower(b byte) byte {
+	if 'A' <= b && b <= 'Z' {
+		return b + 32
+	}
+	return b
+}
+
+// hpcRestore expands homopolymer-compressed sequence and qualities with
+// run lengths, the results are appended to bufS and bufQ.
+func hpcRestore(s, qual []byte, runs []int, bufS, bufQ []byte) ([]byte, []byte) {
+	hasQual := len(qual) == len(s)
+	var k int
+	for i, r := range runs {
+		for k = 0; k < r; k++ {
+			bufS = append(bufS, s[i])
+		}
+		if hasQual {
+			for k = 0; k < r; k++ {
+				bufQ = append(bufQ, qual[i])
+			}
+		}
+	}
+	if !hasQual {
+		return bufS, qual
+	}
+	return bufS, bufQ
+}
+
+// readHpcRunLengths reads the ID and run lengths of the next record, and
+// returns io.EOF if there are no records left. Blank lines are skipped.
+func readHpcRunLengths(r *bufio.Reader, runs []int) (string, []int, error) {
+	var line string
+	var err error
+	for {
+		line, err = r.ReadString('\n')
+		line = strings.TrimRight(line, "\r\n")
+		if line != "" {
+			break
+		}
+		if err != nil {
+			return "", runs, err
+		}
+	}
+
+	runs = runs[:0]
+	i := strings.IndexByte(line, '\t')
+	if i < 0 {
+		return "", runs, fmt.Errorf("invalid run length line (2 columns needed): %s", line)
+	}
+	id := line[:i]
+	if i+1 < len(line) {
+		var n int
+		for _, v := range strings.Split(line[i+1:], ",") {
+			n, err = strconv.Atoi(v)
+			if err != nil || n < 1 {
+				return id, runs, fmt.Errorf("invalid run length for %s: %s", id, v)
+			}
+			runs = append(runs, n)
+		}
+	}
+	return id, runs, nil
+}
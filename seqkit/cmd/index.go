@@ -0,0 +1,499 @@
+// Copyright © 2016-2024 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/biogo/hts/bgzf"
+	"github.com/shenwei356/bio/seq"
+	"github.com/shenwei356/bio/seqio/fastx"
+	"github.com/shenwei356/xopen"
+	"github.com/spf13/cobra"
+)
+
+// indexCmd represents the index command
+var indexCmd = &cobra.Command{
+	GroupID: "basic",
+
+	Use:   "index",
+	Short: "create record-offset index files for fast random access of FASTA/Q records",
+	Long: `create record-offset index files for fast random access of FASTA/Q records
+
+An index file (<input>.skidx) stores the offset and full header of each record
+of a FASTA/Q file. When the index file exists and matches the input file,
+these commands seek to records directly instead of scanning the whole file:
+
+  1. seqkit grep    matching by ID or full name (-n/--by-name),
+                    without -r/--use-regexp, -s/--by-seq or -v/--invert-match.
+  2. seqkit range   all ranges.
+  3. seqkit subseq  --chr, --gtf or --bed, for FASTQ files and compressed files,
+                    as plain FASTA files are accessed with the FASTA index (.seqkit.fai).
+
+Attention:
+  1. Supported inputs are plain text files and BGZF-compressed files
+     (e.g., created with "bgzip"). Normal gzip and other compression formats
+     are not supported as they are not seekable.
+  2. The size and modification time of the input file are saved in the index
+     file. An outdated index file is ignored with a warning, please rebuild it.
+  3. Record IDs are parsed from the saved headers with --id-regexp when
+     using the index, so the index does not depend on the ID regular expression.
+
+Subcommands:
+  build   create index files
+
+`,
+}
+
+// indexBuildCmd represents the index build command
+var indexBuildCmd = &cobra.Command{
+	Use:   "build",
+	Short: "create record-offset index files",
+	Long: `create record-offset index files
+
+Examples:
+  1. Plain text files
+      seqkit index build reads.fq
+  2. Compressed files, which should be compressed with bgzip
+      bgzip reads.fq
+      seqkit index build reads.fq.gz
+
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		config := getConfigs(cmd)
+		quiet := config.Quiet
+		runtime.GOMAXPROCS(config.Threads)
+
+		bench := getBenchmarkStats(cmd)
+		files := getFileListFromArgsAndFile(cmd, args, true, "infile-list", true)
+		bench.addInputs(files...)
+
+		for _, file := range files {
+			if isStdin(file) {
+				checkError(fmt.Errorf("index can not be built for stdin"))
+			}
+			idx, err := buildSeqIndex(file)
+			checkError(err)
+
+			fileIdx := file + seqIndexExt
+			checkError(idx.write(fileIdx))
+			if !quiet {
+				log.Infof("%d records indexed: %s", len(idx.offsets), fileIdx)
+			}
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(indexCmd)
+	indexCmd.AddCommand(indexBuildCmd)
+}
+
+// seqIndexExt is the file extension of record-offset index files.
+const seqIndexExt = ".skidx"
+
+const seqIndexVersion = "1"
+
+// seqIndex stores offsets and headers of records of a FASTA/Q file.
+// For BGZF files, offsets are virtual offsets: (block offset << 16) | offset in block.
+type seqIndex struct {
+	size  int64
+	mtime int64
+	fastq bool
+	bgzf  bool
+
+	offsets []uint64
+	names   [][]byte
+}
+
+// isBGZF checks the gzip header with the "BC" extra subfield.
+func isBGZF(b []byte) bool {
+	return len(b) >= 14 && b[0] == 0x1f && b[1] == 0x8b && b[3]&4 != 0 && b[12] == 'B' && b[13] == 'C'
+}
+
+// offsetByteReader is a io.ByteReader returning the offset of the last read byte.
+type offsetByteReader interface {
+	ReadByte() (byte, error)
+	offset() uint64
+}
+
+type plainOffsetReader struct {
+	r *bufio.Reader
+	n uint64
+}
+
+func (r *plainOffsetReader) ReadByte() (byte, error) {
+	b, err := r.r.ReadByte()
+	if err == nil {
+		r.n++
+	}
+	return b, err
+}
+
+func (r *plainOffsetReader) offset() uint64 { return r.n - 1 }
+
+type bgzfOffsetReader struct {
+	r *bgzf.Reader
+}
+
+func (r *bgzfOffsetReader) ReadByte() (byte, error) { return r.r.ReadByte() }
+
+func (r *bgzfOffsetReader) offset() uint64 {
+	off := r.r.LastChunk().Begin
+	return uint64(off.File)<<16 | uint64(off.Block)
+}
+
+// readLineWithOffset reads a line, and returns the offset of the first byte,
+// the content (only kept for lines starting with '>', '@' or '+'),
+// and the line length without "\r\n".
+func readLineWithOffset(r offsetByteReader) (start uint64, line []byte, n int, err error) {
+	var b, last byte
+	var keep bool
+	first := true
+	for {
+		b, err = r.ReadByte()
+		if err != nil {
+			if err == io.EOF && !first {
+				err = nil
+			}
+			break
+		}
+		if first {
+			start = r.offset()
+			keep = b == '>' || b == '@' || b == '+'
+			first = false
+		}
+		if b == '\n' {
+			break
+		}
+		if keep {
+			line = append(line, b)
+		}
+		last = b
+		n++
+	}
+	if last == '\r' {
+		if keep {
+			line = line[:n-1]
+		}
+		n--
+	}
+	return
+}
+
+// buildSeqIndex scans a plain or BGZF-compressed FASTA/Q file.
+func buildSeqIndex(file string) (*seqIndex, error) {
+	fi, err := os.Stat(file)
+	if err != nil {
+		return nil, err
+	}
+	fh, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+
+	idx := &seqIndex{size: fi.Size(), mtime: fi.ModTime().UnixNano()}
+
+	br := bufio.NewReaderSize(fh, os.Getpagesize()<<8)
+	magic, _ := br.Peek(18)
+
+	var r offsetByteReader
+	switch {
+	case len(magic) >= 2 && magic[0] == 0x1f && magic[1] == 0x8b:
+		if !isBGZF(magic) {
+			return nil, fmt.Errorf("%s: only BGZF-compressed (e.g., by bgzip) gzip files are supported", file)
+		}
+		idx.bgzf = true
+		if _, err = fh.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+		bg, err := bgzf.NewReader(fh, 1)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", file, err)
+		}
+		defer bg.Close()
+		r = &bgzfOffsetReader{r: bg}
+	case bytes.HasPrefix(magic, []byte{0xfd, 0x37, 0x7a, 0x58, 0x5a, 0x00}),
+		bytes.HasPrefix(magic, []byte{0x28, 0xB5, 0x2f, 0xfd}),
+		bytes.HasPrefix(magic, []byte("BZh")):
+		return nil, fmt.Errorf("%s: only plain text and BGZF-compressed files are supported", file)
+	default:
+		r = &plainOffsetReader{r: br}
+	}
+
+	var start uint64
+	var line []byte
+	var n, state, seqLen, qualLen int
+	var formatChecked bool
+	for {
+		start, line, n, err = readLineWithOffset(r)
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, fmt.Errorf("%s: %s", file, err)
+		}
+
+		if !formatChecked {
+			if n == 0 {
+				continue
+			}
+			switch line0(line) {
+			case '>':
+				idx.fastq = false
+			case '@':
+				idx.fastq = true
+			default:
+				return nil, fmt.Errorf("%s: invalid FASTA/Q format", file)
+			}
+			formatChecked = true
+		}
+
+		if !idx.fastq {
+			if line0(line) == '>' {
+				idx.offsets = append(idx.offsets, start)
+				idx.names = append(idx.names, line[1:])
+			}
+			continue
+		}
+
+		switch state {
+		case 0: // head
+			if n == 0 {
+				continue
+			}
+			if line0(line) != '@' {
+				return nil, fmt.Errorf("%s: invalid FASTQ format, header line expected at record %d", file, len(idx.offsets)+1)
+			}
+			idx.offsets = append(idx.offsets, start)
+			idx.names = append(idx.names, line[1:])
+			seqLen = 0
+			state = 1
+		case 1: // sequence
+			if line0(line) == '+' {
+				qualLen = 0
+				state = 2
+				continue
+			}
+			seqLen += n
+		case 2: // quality
+			qualLen += n
+			if qualLen >= seqLen {
+				state = 0
+			}
+		}
+	}
+	if idx.fastq && state != 0 {
+		return nil, fmt.Errorf("%s: truncated FASTQ record: %s", file, idx.names[len(idx.names)-1])
+	}
+
+	return idx, nil
+}
+
+func line0(line []byte) byte {
+	if len(line) == 0 {
+		return 0
+	}
+	return line[0]
+}
+
+// write saves the index to a file.
+func (idx *seqIndex) write(file string) error {
+	outfh, err := xopen.Wopen(file)
+	if err != nil {
+		return err
+	}
+
+	format := "FASTA"
+	if idx.fastq {
+		format = "FASTQ"
+	}
+	compression := "none"
+	if idx.bgzf {
+		compression = "bgzf"
+	}
+	fmt.Fprintf(outfh, "#seqkit-index\t%s\n", seqIndexVersion)
+	fmt.Fprintf(outfh, "#size\t%d\n", idx.size)
+	fmt.Fprintf(outfh, "#mtime\t%d\n", idx.mtime)
+	fmt.Fprintf(outfh, "#format\t%s\n", format)
+	fmt.Fprintf(outfh, "#compression\t%s\n", compression)
+	for i, off := range idx.offsets {
+		fmt.Fprintf(outfh, "%d\t%s\n", off, idx.names[i])
+	}
+
+	return outfh.Close()
+}
+
+// readSeqIndex reads an index file.
+func readSeqIndex(file string) (*seqIndex, error) {
+	fh, err := xopen.Ropen(file)
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+
+	idx := &seqIndex{}
+	scanner := bufio.NewScanner(fh)
+	scanner.Buffer(make([]byte, 0, 1<<16), 1<<30)
+	var line string
+	var items []string
+	var off uint64
+	var nHeaders int
+	for scanner.Scan() {
+		line = strings.TrimRight(scanner.Text(), "\r")
+		if line == "" {
+			continue
+		}
+		items = strings.SplitN(line, "\t", 2)
+		if len(items) != 2 {
+			return nil, fmt.Errorf("invalid index file: %s", file)
+		}
+		if line[0] == '#' {
+			nHeaders++
+			switch items[0] {
+			case "#seqkit-index":
+				if items[1] != seqIndexVersion {
+					return nil, fmt.Errorf("unsupported index version (%s): %s", items[1], file)
+				}
+			case "#size":
+				idx.size, err = strconv.ParseInt(items[1], 10, 64)
+			case "#mtime":
+				idx.mtime, err = strconv.ParseInt(items[1], 10, 64)
+			case "#format":
+				idx.fastq = items[1] == "FASTQ"
+			case "#compression":
+				idx.bgzf = items[1] == "bgzf"
+			}
+			if err != nil {
+				return nil, fmt.Errorf("invalid index file: %s", file)
+			}
+			continue
+		}
+		off, err = strconv.ParseUint(items[0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid offset in index file: %s: %s", file, items[0])
+		}
+		idx.offsets = append(idx.offsets, off)
+		idx.names = append(idx.names, []byte(items[1]))
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+	if nHeaders < 5 {
+		return nil, fmt.Errorf("invalid index file: %s", file)
+	}
+	return idx, nil
+}
+
+// loadSeqIndex returns the index of a file if the index file exists
+// and matches the file, otherwise nil is returned.
+func loadSeqIndex(file string, quiet bool) *seqIndex {
+	if isStdin(file) {
+		return nil
+	}
+	fileIdx := file + seqIndexExt
+	if !FileExists(fileIdx) {
+		return nil
+	}
+	fi, err := os.Stat(file)
+	if err != nil {
+		return nil
+	}
+	idx, err := readSeqIndex(fileIdx)
+	if err != nil {
+		if !quiet {
+			log.Warningf("ignore the index file: %s", err)
+		}
+		return nil
+	}
+	if idx.size != fi.Size() || idx.mtime != fi.ModTime().UnixNano() {
+		if !quiet {
+			log.Warningf(`ignore the outdated index file: %s, please rebuild it with "seqkit index build %s"`,
+				fileIdx, filepath.Base(file))
+		}
+		return nil
+	}
+	if !quiet {
+		log.Infof("%d records loaded from index file: %s", len(idx.offsets), fileIdx)
+	}
+	return idx
+}
+
+// seqIndexReader reads records from given offsets.
+type seqIndexReader struct {
+	fh *os.File
+	bg *bgzf.Reader
+
+	alphabet *seq.Alphabet
+	idRegexp string
+}
+
+func newSeqIndexReader(file string, idx *seqIndex, alphabet *seq.Alphabet, idRegexp string) (*seqIndexReader, error) {
+	fh, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	r := &seqIndexReader{fh: fh, alphabet: alphabet, idRegexp: idRegexp}
+	if idx.bgzf {
+		r.bg, err = bgzf.NewReader(fh, 1)
+		if err != nil {
+			fh.Close()
+			return nil, fmt.Errorf("%s: %s", file, err)
+		}
+	}
+	return r, nil
+}
+
+// seek returns a FASTA/Q reader starting from the offset.
+// Please call Close() of the returned reader after using it.
+func (r *seqIndexReader) seek(offset uint64) (*fastx.Reader, error) {
+	var src io.Reader
+	if r.bg != nil {
+		if err := r.bg.Seek(bgzf.Offset{File: int64(offset >> 16), Block: uint16(offset)}); err != nil {
+			return nil, err
+		}
+		src = r.bg
+	} else {
+		if _, err := r.fh.Seek(int64(offset), io.SeekStart); err != nil {
+			return nil, err
+		}
+		src = r.fh
+	}
+	// hide the Close method, as the reader closes the source at the end of the file.
+	return fastx.NewReaderFromIO(r.alphabet, struct{ io.Reader }{src}, r.idRegexp)
+}
+
+// Close closes the file.
+func (r *seqIndexReader) Close() error {
+	if r.bg != nil {
+		r.bg.Close()
+	}
+	return r.fh.Close()
+}
@@ -26,6 +26,7 @@ import (
 	"io"
 	"regexp"
 	"runtime"
+	"sort"
 	"sync"
 
 	"github.com/shenwei356/bio/seq"
@@ -61,6 +62,30 @@ Attention:
      you can increase the value of "-j/--threads" to accelerate processing.
   5. When using flag --circular, end position of matched subsequence that 
      crossing genome sequence end would be greater than sequence length.
+  6. Flag --flank appends N bases upstream and downstream of each match
+     as four extra columns: upstream, downstream, and their lengths, which
+     could be shorter than N near sequence ends (wrapped for --circular).
+     For matches on the negative strand, flanks are reverse complemented,
+     i.e., upstream is on the 5' side of the match on the negative strand.
+  7. Flag --best-only only reports the best match of each pattern in a record:
+     the one with the fewest mismatches, and then the leftmost one (on the
+     positive strand), and then the one on the positive strand. Matches on
+     both strands are compared unless -P/--only-positive-strand is given.
+     The number of mismatches is appended as an extra column "mismatches",
+     or saved in the score column for GTF/BED output. Matches of degenerate
+     bases (-d) or regular expressions (-r) have no mismatches.
+     Patterns are outputted in lexicographic order of names.
+  8. Flag --density reports match densities instead of matches, i.e., the
+     numbers of matches of each pattern per kb of sequences, for each record,
+     or each window with --window and --step (default: the window size).
+     Columns: seqID, patternName, start, end, matches, matches_per_kb,
+     where start and end are 1-based positions of the window, and the last
+     window could be shorter. Matches on both strands are counted unless
+     -P/--only-positive-strand is given, and a match is assigned to windows
+     by its start position on the positive strand. Overlapping matches are
+     all counted, use --non-overlapping to count only non-overlapping ones
+     on each strand, chosen from left to right. Windows without matches
+     are also outputted, for making tracks.
 
 `,
 	Run: func(cmd *cobra.Command, args []string) {
@@ -77,7 +102,9 @@ Attention:
 
 		bwt.CheckEndSymbol = false
 
+		bench := getBenchmarkStats(cmd)
 		files := getFileListFromArgsAndFile(cmd, args, true, "infile-list", true)
+		bench.addInputs(files...)
 
 		pattern := getFlagStringSlice(cmd, "pattern")
 		patternFile := getFlagString(cmd, "pattern-file")
@@ -93,8 +120,31 @@ Attention:
 		hideMatched := getFlagBool(cmd, "hide-matched")
 		circular := getFlagBool(cmd, "circular")
 		len2show := getFlagNonNegativeInt(cmd, "max-len-to-show")
+		flank := getFlagNonNegativeInt(cmd, "flank")
+		if flank > 0 && (outFmtGTF || outFmtBED) {
+			checkError(fmt.Errorf("flag --flank is not supported for GTF or BED output"))
+		}
 
 		immediateOutput := getFlagBool(cmd, "immediate-output")
+		bestOnly := getFlagBool(cmd, "best-only")
+
+		density := getFlagBool(cmd, "density")
+		window := getFlagNonNegativeInt(cmd, "window")
+		step := getFlagNonNegativeInt(cmd, "step")
+		nonOverlapping := getFlagBool(cmd, "non-overlapping")
+		if density {
+			if outFmtGTF || outFmtBED || flank > 0 || bestOnly {
+				checkError(fmt.Errorf("flag --density is not compatible with --gtf, --bed, --flank and --best-only"))
+			}
+			if step > 0 && window == 0 {
+				checkError(fmt.Errorf("flag --step should be used with --window"))
+			}
+			if step == 0 {
+				step = window
+			}
+		} else if window > 0 || step > 0 || nonOverlapping {
+			checkError(fmt.Errorf("flags --window, --step and --non-overlapping should be used with --density"))
+		}
 
 		if config.Alphabet == seq.Protein {
 			onlyPositiveStrand = true
@@ -249,12 +299,21 @@ Attention:
 		checkError(err)
 		defer outfh.Close()
 
-		if !(outFmtGTF || outFmtBED) {
+		if density {
+			outfh.WriteString("seqID\tpatternName\tstart\tend\tmatches\tmatches_per_kb\n")
+		} else if !(outFmtGTF || outFmtBED) {
 			if hideMatched {
-				outfh.WriteString("seqID\tpatternName\tpattern\tstrand\tstart\tend\n")
+				outfh.WriteString("seqID\tpatternName\tpattern\tstrand\tstart\tend")
 			} else {
-				outfh.WriteString("seqID\tpatternName\tpattern\tstrand\tstart\tend\tmatched\n")
+				outfh.WriteString("seqID\tpatternName\tpattern\tstrand\tstart\tend\tmatched")
 			}
+			if flank > 0 {
+				outfh.WriteString("\tupstream\tdownstream\tupstream_len\tdownstream_len")
+			}
+			if bestOnly {
+				outfh.WriteString("\tmismatches")
+			}
+			outfh.WriteString("\n")
 		}
 
 		// -------------------------------------------------------------------
@@ -263,6 +322,116 @@ Attention:
 		var record *fastx.Record
 		_onlyPositiveStrand := onlyPositiveStrand
 
+		// --best-only and --density pick or count matches of each pattern
+		// in a record at output time, patterns are in lexicographic order.
+		var pNames []string
+		if bestOnly || density {
+			pNames = make([]string, 0, len(patterns))
+			for pName := range patterns {
+				pNames = append(pNames, pName)
+			}
+			sort.Strings(pNames)
+		}
+
+		// summaryRows returns output rows of a record for --best-only and --density.
+		// The sequence should have been prepared (lower-cased or doubled for circular),
+		// and l is the original length.
+		summaryRows := func(record *fastx.Record, l int, matcher *locateMatcher) []string {
+			err := matcher.setRecord(record.Seq, l, _onlyPositiveStrand)
+			if err != nil {
+				checkError(fmt.Errorf("fail to build FMIndex for sequence: %s", record.Name))
+			}
+
+			rows := make([]string, 0, len(pNames))
+			var hits []locateHit
+			var hit locateHit
+			var strand string
+			var w, st, nw, start, end int
+			var counts []int
+			if density {
+				w, st = window, step
+				if w == 0 || w > l {
+					w, st = l, l
+				}
+				nw = 1
+				if l > w {
+					nw += (l - w + st - 1) / st
+				}
+				counts = make([]int, nw)
+			}
+			for _, pName := range pNames {
+				pSeq := patterns[pName]
+				hits, err = matcher.matches(pName, hits[:0])
+				if err != nil {
+					checkError(fmt.Errorf("fail to search pattern '%s' on seq '%s': %s", pName, record.Name, err))
+				}
+
+				if density {
+					locateWindowCounts(hits, l, w, st, nonOverlapping, counts)
+					for k := 0; k < nw; k++ {
+						start = k * st
+						end = start + w
+						if end > l {
+							end = l
+						}
+						rows = append(rows, fmt.Sprintf("%s\t%s\t%d\t%d\t%d\t%.4f\n",
+							record.ID, pName, start+1, end, counts[k],
+							float64(counts[k])*1000/float64(end-start)))
+					}
+					continue
+				}
+
+				if len(hits) == 0 {
+					continue
+				}
+				hit = locateBestHit(hits)
+				strand = string(hit.strand)
+
+				if outFmtGTF {
+					rows = append(rows, fmt.Sprintf("%s\t%s\t%s\t%d\t%d\t%d\t%s\t%s\tgene_id \"%s\"; \n",
+						record.ID,
+						"SeqKit",
+						"location",
+						hit.begin,
+						hit.end,
+						hit.mismatches,
+						strand,
+						".",
+						pName))
+				} else if outFmtBED {
+					rows = append(rows, fmt.Sprintf("%s\t%d\t%d\t%s\t%d\t%s\n",
+						record.ID,
+						hit.begin-1,
+						hit.end,
+						pName,
+						hit.mismatches,
+						strand))
+				} else if hideMatched {
+					rows = append(rows, fmt.Sprintf("%s\t%s\t%s\t%s\t%d\t%d%s\t%d\n",
+						record.ID,
+						pName,
+						prune(pSeq, len2show),
+						strand,
+						hit.begin,
+						hit.end,
+						locateFlanks(record.Seq, l, hit.begin, hit.end, hit.strand, flank, circular),
+						hit.mismatches))
+				} else {
+					rows = append(rows, fmt.Sprintf("%s\t%s\t%s\t%s\t%d\t%d\t%s%s\t%d\n",
+						record.ID,
+						pName,
+						prune(pSeq, len2show),
+						strand,
+						hit.begin,
+						hit.end,
+						prune(hit.matched, len2show),
+						locateFlanks(record.Seq, l, hit.begin, hit.end, hit.strand, flank, circular),
+						hit.mismatches))
+				}
+			}
+			return rows
+		}
+
 		if mismatches > 0 || useFMI {
 			type Arecord struct {
 				id     uint64
@@ -357,6 +526,7 @@ Attention:
 						checkError(err)
 						break
 					}
+					bench.addRecord()
 
 					if len(record.Seq.Seq) == 0 {
 						continue
@@ -406,6 +576,18 @@ Attention:
 							record.Seq.Seq = append(record.Seq.Seq, record.Seq.Seq...)
 						}
 
+						if bestOnly || density {
+							matcher := newLocateMatcher(patterns, regexps, mismatches, useFMI, nonGreedy, circular)
+							for _, row := range summaryRows(record, l, matcher) {
+								_ch <- row
+							}
+							close(_ch)
+							<-_done
+
+							ch <- &Arecord{record: results, id: id, ok: len(results) > 0}
+							return
+						}
+
 						_, err = sfmi.Transform(record.Seq.Seq)
 						if err != nil {
 							checkError(fmt.Errorf("fail to build FMIndex for sequence: %s", record.Name))
@@ -453,22 +635,24 @@ Attention:
 											"+")
 									} else {
 										if hideMatched {
-											_ch <- fmt.Sprintf("%s\t%s\t%s\t%s\t%d\t%d\n",
+											_ch <- fmt.Sprintf("%s\t%s\t%s\t%s\t%d\t%d%s\n",
 												record.ID,
 												pName,
 												prune(patterns[pName], len2show), // patterns[pName],
 												"+",
 												begin,
-												end)
+												end,
+												locateFlanks(record.Seq, l, begin, end, '+', flank, circular))
 										} else {
-											_ch <- fmt.Sprintf("%s\t%s\t%s\t%s\t%d\t%d\t%s\n",
+											_ch <- fmt.Sprintf("%s\t%s\t%s\t%s\t%d\t%d\t%s%s\n",
 												record.ID,
 												pName,
 												prune(patterns[pName], len2show), // patterns[pName],
 												"+",
 												begin,
 												end,
-												prune(record.Seq.Seq[i:i+len(pSeq)], len2show)) // record.Seq.Seq[i:i+len(pSeq)])
+												prune(record.Seq.Seq[i:i+len(pSeq)], len2show), // record.Seq.Seq[i:i+len(pSeq)])
+												locateFlanks(record.Seq, l, begin, end, '+', flank, circular))
 										}
 									}
 								}
@@ -538,22 +722,24 @@ Attention:
 											"-")
 									} else {
 										if hideMatched {
-											_ch <- fmt.Sprintf("%s\t%s\t%s\t%s\t%d\t%d\n",
+											_ch <- fmt.Sprintf("%s\t%s\t%s\t%s\t%d\t%d%s\n",
 												record.ID,
 												pName,
 												prune(patterns[pName], len2show), // patterns[pName],
 												"-",
 												begin,
-												end)
+												end,
+												locateFlanks(record.Seq, l, begin, end, '-', flank, circular))
 										} else {
-											_ch <- fmt.Sprintf("%s\t%s\t%s\t%s\t%d\t%d\t%s\n",
+											_ch <- fmt.Sprintf("%s\t%s\t%s\t%s\t%d\t%d\t%s%s\n",
 												record.ID,
 												pName,
 												prune(patterns[pName], len2show), // patterns[pName],
 												"-",
 												begin,
 												end,
-												prune(seqRP.Seq[i:i+len(pSeq)], len2show)) // seqRP.Seq[i:i+len(pSeq)])
+												prune(seqRP.Seq[i:i+len(pSeq)], len2show), // seqRP.Seq[i:i+len(pSeq)])
+												locateFlanks(record.Seq, l, begin, end, '-', flank, circular))
 										}
 									}
 								}
@@ -597,6 +783,10 @@ Attention:
 		if mismatches > 0 || useFMI {
 			sfmi = fmi.NewFMIndex()
 		}
+		var matcher *locateMatcher
+		if bestOnly || density {
+			matcher = newLocateMatcher(patterns, regexps, mismatches, useFMI, nonGreedy, circular)
+		}
 
 		for _, file := range files {
 			fastxReader, err := fastx.NewReader(alphabet, file, idRegexp)
@@ -612,6 +802,7 @@ Attention:
 					checkError(err)
 					break
 				}
+				bench.addRecord()
 
 				if len(record.Seq.Seq) == 0 {
 					continue
@@ -634,6 +825,16 @@ Attention:
 					record.Seq.Seq = append(record.Seq.Seq, record.Seq.Seq...)
 				}
 
+				if bestOnly || density {
+					for _, row := range summaryRows(record, l, matcher) {
+						outfh.WriteString(row)
+					}
+					if immediateOutput {
+						outfh.Flush()
+					}
+					continue
+				}
+
 				if mismatches > 0 || useFMI {
 					_, err = sfmi.Transform(record.Seq.Seq)
 					if err != nil {
@@ -677,22 +878,24 @@ Attention:
 									"+"))
 							} else {
 								if hideMatched {
-									outfh.WriteString(fmt.Sprintf("%s\t%s\t%s\t%s\t%d\t%d\n",
+									outfh.WriteString(fmt.Sprintf("%s\t%s\t%s\t%s\t%d\t%d%s\n",
 										record.ID,
 										pName,
 										prune(patterns[pName], len2show), // patterns[pName],
 										"+",
 										begin,
-										end))
+										end,
+										locateFlanks(record.Seq, l, begin, end, '+', flank, circular)))
 								} else {
-									outfh.WriteString(fmt.Sprintf("%s\t%s\t%s\t%s\t%d\t%d\t%s\n",
+									outfh.WriteString(fmt.Sprintf("%s\t%s\t%s\t%s\t%d\t%d\t%s%s\n",
 										record.ID,
 										pName,
 										prune(patterns[pName], len2show), // patterns[pName],
 										"+",
 										begin,
 										end,
-										prune(record.Seq.Seq[i:i+len(pSeq)], len2show))) // record.Seq.Seq[i:i+len(pSeq)]))
+										prune(record.Seq.Seq[i:i+len(pSeq)], len2show), // record.Seq.Seq[i:i+len(pSeq)]))
+										locateFlanks(record.Seq, l, begin, end, '+', flank, circular)))
 								}
 							}
 						}
@@ -744,22 +947,24 @@ Attention:
 									"-"))
 							} else {
 								if hideMatched {
-									outfh.WriteString(fmt.Sprintf("%s\t%s\t%s\t%s\t%d\t%d\n",
+									outfh.WriteString(fmt.Sprintf("%s\t%s\t%s\t%s\t%d\t%d%s\n",
 										record.ID,
 										pName,
 										prune(patterns[pName], len2show), // patterns[pName],
 										"-",
 										begin,
-										end))
+										end,
+										locateFlanks(record.Seq, l, begin, end, '-', flank, circular)))
 								} else {
-									outfh.WriteString(fmt.Sprintf("%s\t%s\t%s\t%s\t%d\t%d\t%s\n",
+									outfh.WriteString(fmt.Sprintf("%s\t%s\t%s\t%s\t%d\t%d\t%s%s\n",
 										record.ID,
 										pName,
 										prune(patterns[pName], len2show), // patterns[pName],
 										"-",
 										begin,
 										end,
-										prune(seqRP.Seq[i:i+len(pSeq)], len2show))) // seqRP.Seq[i:i+len(pSeq)]))
+										prune(seqRP.Seq[i:i+len(pSeq)], len2show), // seqRP.Seq[i:i+len(pSeq)]))
+										locateFlanks(record.Seq, l, begin, end, '-', flank, circular)))
 								}
 							}
 						}
@@ -835,22 +1040,24 @@ Attention:
 								"+"))
 						} else {
 							if hideMatched {
-								outfh.WriteString(fmt.Sprintf("%s\t%s\t%s\t%s\t%d\t%d\n",
+								outfh.WriteString(fmt.Sprintf("%s\t%s\t%s\t%s\t%d\t%d%s\n",
 									record.ID,
 									pName,
 									prune(patterns[pName], len2show), // patterns[pName],
 									"+",
 									begin,
-									end))
+									end,
+									locateFlanks(record.Seq, l, begin, end, '+', flank, circular)))
 							} else {
-								outfh.WriteString(fmt.Sprintf("%s\t%s\t%s\t%s\t%d\t%d\t%s\n",
+								outfh.WriteString(fmt.Sprintf("%s\t%s\t%s\t%s\t%d\t%d\t%s%s\n",
 									record.ID,
 									pName,
 									prune(patterns[pName], len2show), // patterns[pName],
 									"+",
 									begin,
 									end,
-									prune(record.Seq.Seq[begin-1:end], len2show))) // record.Seq.Seq[begin-1:end]))
+									prune(record.Seq.Seq[begin-1:end], len2show), // record.Seq.Seq[begin-1:end]))
+									locateFlanks(record.Seq, l, begin, end, '+', flank, circular)))
 							}
 						}
 						// locs = append(locs, [2]int{begin, end})
@@ -934,22 +1141,24 @@ Attention:
 								"-"))
 						} else {
 							if hideMatched {
-								outfh.WriteString(fmt.Sprintf("%s\t%s\t%s\t%s\t%d\t%d\n",
+								outfh.WriteString(fmt.Sprintf("%s\t%s\t%s\t%s\t%d\t%d%s\n",
 									record.ID,
 									pName,
 									prune(patterns[pName], len2show), // patterns[pName],
 									"-",
 									begin,
-									end))
+									end,
+									locateFlanks(record.Seq, l, begin, end, '-', flank, circular)))
 							} else {
-								outfh.WriteString(fmt.Sprintf("%s\t%s\t%s\t%s\t%d\t%d\t%s\n",
+								outfh.WriteString(fmt.Sprintf("%s\t%s\t%s\t%s\t%d\t%d\t%s%s\n",
 									record.ID,
 									pName,
 									prune(patterns[pName], len2show), // patterns[pName],
 									"-",
 									begin,
 									end,
-									prune(seqRP.Seq[offset+loc[0]:offset+loc[1]], len2show))) // seqRP.Seq[offset+loc[0]:offset+loc[1]]))
+									prune(seqRP.Seq[offset+loc[0]:offset+loc[1]], len2show), // seqRP.Seq[offset+loc[0]:offset+loc[1]]))
+									locateFlanks(record.Seq, l, begin, end, '-', flank, circular)))
 							}
 						}
 						// locsNeg = append(locsNeg, [2]int{begin, end})
@@ -994,6 +1203,231 @@ func init() {
 	locateCmd.Flags().IntP("max-len-to-show", "s", 0, "show at most X characters for the search pattern or matched sequences")
 	locateCmd.Flags().BoolP("circular", "c", false, `circular genome. type "seqkit locate -h" for details`)
 	locateCmd.Flags().BoolP("immediate-output", "I", false, "print output immediately, do not use write buffer")
+	locateCmd.Flags().IntP("flank", "", 0, "append N bases of upstream and downstream flanking sequences of matches as extra columns")
+	locateCmd.Flags().BoolP("density", "", false, `output numbers of matches per kb of each record or window, instead of matches. type "seqkit locate -h" for details`)
+	locateCmd.Flags().IntP("window", "", 0, "window size for --density, 0 for whole sequences")
+	locateCmd.Flags().IntP("step", "", 0, "step size of windows for --density, 0 for the window size")
+	locateCmd.Flags().BoolP("non-overlapping", "", false, "only count non-overlapping matches for --density")
+	locateCmd.Flags().BoolP("best-only", "", false, `only report the match with the fewest mismatches (and then the leftmost one) of each pattern in a record. type "seqkit locate -h" for details`)
+}
+
+// locateHit is a match of a pattern.
+type locateHit struct {
+	strand     byte
+	begin, end int    // 1-based positions on the positive strand
+	loc        [2]int // 0-based location (end excluded) on the searched strand
+	matched    []byte
+	mismatches int
+}
+
+// locateBestHit returns the match with the fewest mismatches,
+// and then the leftmost one, and then the one on the positive strand.
+func locateBestHit(hits []locateHit) locateHit {
+	best := hits[0]
+	for _, hit := range hits[1:] {
+		if hit.mismatches < best.mismatches ||
+			(hit.mismatches == best.mismatches &&
+				(hit.begin < best.begin || (hit.begin == best.begin && hit.strand == '+' && best.strand == '-'))) {
+			best = hit
+		}
+	}
+	return best
+}
+
+// locateWindowCounts counts matches in windows of size w and step st
+// of a sequence with the length of l. A match is assigned to windows by
+// its start position on the positive strand. With nonOverlapping,
+// only non-overlapping matches on each strand, chosen from left to right,
+// are counted.
+func locateWindowCounts(hits []locateHit, l, w, st int, nonOverlapping bool, counts []int) {
+	for k := range counts {
+		counts[k] = 0
+	}
+	nw := len(counts)
+	var pos, k, kmin, kmax, lastEnd int
+	var strand byte
+	for _, hit := range hits {
+		if nonOverlapping {
+			if hit.strand != strand {
+				strand, lastEnd = hit.strand, 0
+			}
+			if hit.loc[0] < lastEnd {
+				continue
+			}
+			lastEnd = hit.loc[1]
+		}
+
+		pos = (hit.begin - 1) % l // 0-based start position on the positive strand
+		kmax = pos / st
+		if kmax >= nw {
+			kmax = nw - 1
+		}
+		kmin = 0
+		if pos-w+1 > 0 {
+			kmin = (pos - w + st) / st
+		}
+		for k = kmin; k <= kmax; k++ {
+			counts[k]++
+		}
+	}
+}
+
+// locateMatcher enumerates matches of patterns in a record, for --best-only
+// and --density. It's not safe for concurrent use.
+type locateMatcher struct {
+	patterns   map[string][]byte
+	regexps    map[string]*regexp.Regexp
+	mismatches int
+	useFMI     bool
+	nonGreedy  bool
+	circular   bool
+
+	sfmi, sfmiRC *fmi.FMIndex
+
+	s, sRC             []byte
+	l                  int
+	onlyPositiveStrand bool
+}
+
+func newLocateMatcher(patterns map[string][]byte, regexps map[string]*regexp.Regexp,
+	mismatches int, useFMI bool, nonGreedy bool, circular bool) *locateMatcher {
+	m := &locateMatcher{
+		patterns:   patterns,
+		regexps:    regexps,
+		mismatches: mismatches,
+		useFMI:     useFMI,
+		nonGreedy:  nonGreedy,
+		circular:   circular,
+	}
+	if mismatches > 0 || useFMI {
+		m.sfmi = fmi.NewFMIndex()
+		m.sfmiRC = fmi.NewFMIndex()
+	}
+	return m
+}
+
+// setRecord sets the sequence to search, which has been doubled for
+// circular sequences, and l is the original length.
+func (m *locateMatcher) setRecord(s *seq.Seq, l int, onlyPositiveStrand bool) error {
+	m.s, m.l, m.onlyPositiveStrand = s.Seq, l, onlyPositiveStrand
+	m.sRC = nil
+	if !onlyPositiveStrand {
+		m.sRC = s.RevCom().Seq
+	}
+	if m.sfmi == nil {
+		return nil
+	}
+	if _, err := m.sfmi.Transform(m.s); err != nil {
+		return err
+	}
+	if !onlyPositiveStrand {
+		if _, err := m.sfmiRC.Transform(m.sRC); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// matches appends all matches of a pattern to hits, on the positive strand
+// and then the negative strand, and each from left to right on the strand.
+func (m *locateMatcher) matches(pName string, hits []locateHit) ([]locateHit, error) {
+	pSeq := m.patterns[pName]
+	var positions [][2]int
+	var s []byte
+	var hit locateHit
+	l := m.l
+	for _, negative := range []bool{false, true} {
+		if negative && m.onlyPositiveStrand {
+			break
+		}
+		s = m.s
+		if negative {
+			s = m.sRC
+		}
+
+		positions = positions[:0]
+		if m.sfmi != nil {
+			sfmi := m.sfmi
+			if negative {
+				sfmi = m.sfmiRC
+			}
+			loc, err := sfmi.Locate(pSeq, m.mismatches)
+			if err != nil {
+				return hits, err
+			}
+			sort.Ints(loc)
+			for _, i := range loc {
+				if i+len(pSeq) <= len(s) {
+					positions = append(positions, [2]int{i, i + len(pSeq)})
+				}
+			}
+		} else {
+			positions = locateFindAll(s, pSeq, m.regexps[pName], m.nonGreedy, positions)
+		}
+
+		for _, loc := range positions {
+			if m.circular && loc[0]+1 > l { // 2nd clone of original part
+				continue
+			}
+			hit = locateHit{strand: '+', begin: loc[0] + 1, end: loc[1], loc: loc, matched: s[loc[0]:loc[1]]}
+			if negative {
+				hit.strand = '-'
+				hit.begin, hit.end = l-loc[1]+1, l-loc[0]
+				if loc[1] > l {
+					hit.begin += l
+					hit.end += l
+				}
+			}
+			if m.mismatches > 0 {
+				hit.mismatches = locateMismatches(hit.matched, pSeq)
+			}
+			hits = append(hits, hit)
+		}
+	}
+	return hits, nil
+}
+
+// locateFindAll returns 0-based half-open locations of all matches of
+// a plain pattern p, or a regular expression re if it is not nil.
+func locateFindAll(s []byte, p []byte, re *regexp.Regexp, nonGreedy bool, locs [][2]int) [][2]int {
+	var offset, i int
+	var loc []int
+	for {
+		if re != nil {
+			loc = re.FindSubmatchIndex(s[offset:])
+			if loc == nil {
+				break
+			}
+		} else {
+			i = bytes.Index(s[offset:], p)
+			if i < 0 {
+				break
+			}
+			loc = []int{i, i + len(p)}
+		}
+		locs = append(locs, [2]int{offset + loc[0], offset + loc[1]})
+
+		if nonGreedy {
+			offset = offset + loc[1] + 1
+		} else {
+			offset = offset + loc[0] + 1
+		}
+		if offset >= len(s) {
+			break
+		}
+	}
+	return locs
+}
+
+// locateMismatches returns the number of mismatches between a matched
+// sequence and the pattern of the same length.
+func locateMismatches(matched, p []byte) (n int) {
+	for i, b := range matched {
+		if b != p[i] {
+			n++
+		}
+	}
+	return n
 }
 
 func prune(s []byte, n int) []byte {
@@ -1008,3 +1442,43 @@ func prune(s []byte, n int) []byte {
 
 	return []byte(string(s[:n]) + "...")
 }
+
+// locateFlanks returns extra columns of flanking sequences of a match
+// at [begin, end] (1-based) of a sequence with the original length of l.
+func locateFlanks(s *seq.Seq, l int, begin, end int, strand byte, n int, circular bool) string {
+	if n == 0 {
+		return ""
+	}
+
+	up := locateRegion(s.Seq, l, begin-1-n, begin-1, circular)
+	down := locateRegion(s.Seq, l, end, end+n, circular)
+	if strand == '-' {
+		rcUp, _ := seq.NewSeqWithoutValidation(s.Alphabet, down)
+		rcDown, _ := seq.NewSeqWithoutValidation(s.Alphabet, up)
+		up, down = rcUp.RevComInplace().Seq, rcDown.RevComInplace().Seq
+	}
+	return fmt.Sprintf("\t%s\t%s\t%d\t%d", up, down, len(up), len(down))
+}
+
+// locateRegion returns a copy of s[start:end] (0-based) of the positive strand,
+// clamped to the sequence ends, or wrapped for circular sequences.
+func locateRegion(s []byte, l int, start, end int, circular bool) []byte {
+	if !circular {
+		if start < 0 {
+			start = 0
+		}
+		if end > l {
+			end = l
+		}
+		if start >= end {
+			return []byte{}
+		}
+		return []byte(string(s[start:end]))
+	}
+
+	region := make([]byte, 0, end-start)
+	for i := start; i < end; i++ {
+		region = append(region, s[(i%l+l)%l])
+	}
+	return region
+}
@@ -0,0 +1,172 @@
+// Copyright © 2016-2024 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+
+	"github.com/shenwei356/bio/seq"
+	"github.com/shenwei356/bio/seqio/fastx"
+	"github.com/shenwei356/xopen"
+	"github.com/spf13/cobra"
+)
+
+// maskConvertCmd represents the mask-convert command
+var maskConvertCmd = &cobra.Command{
+	GroupID: "edit",
+
+	Use:     "mask-convert",
+	Aliases: []string{"mask-lowercase"},
+	Short:   "convert soft-masked (lowercase) regions to hard-masked (N) or unmasked ones",
+	Long: `convert soft-masked (lowercase) regions to hard-masked (N) or unmasked ones
+
+Modes (choose one):
+  --soft2hard    lowercase letters are replaced with the letter given by
+                 --mask-char (default N), i.e., hard masking.
+  --strip-soft   lowercase letters are converted to uppercase, i.e.,
+                 removing soft masking.
+
+Attention:
+  1. Converting hard-masked regions to soft-masked ones is not supported,
+     as original bases are lost after hard masking.
+  2. Letters other than a-z, e.g., gaps, are not changed. For FASTQ,
+     qualities are kept.
+  3. Records are streamed in one pass, and the number of converted bases
+     is reported unless --quiet is given.
+
+Examples:
+  1. Hard masking a soft-masked genome.
+      seqkit mask-convert --soft2hard genome.fa.gz -o genome.hardmasked.fa.gz
+  2. Hard masking soft-masked protein sequences with X.
+      seqkit mask-convert --soft2hard --mask-char X proteins.fa
+
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		config := getConfigs(cmd)
+		alphabet := config.Alphabet
+		idRegexp := config.IDRegexp
+		lineWidth := config.LineWidth
+		outFile := config.OutFile
+		quiet := config.Quiet
+		seq.AlphabetGuessSeqLengthThreshold = config.AlphabetGuessSeqLength
+		seq.ValidateSeq = false
+		runtime.GOMAXPROCS(config.Threads)
+
+		soft2hard := getFlagBool(cmd, "soft2hard")
+		stripSoft := getFlagBool(cmd, "strip-soft")
+		maskChar := getFlagString(cmd, "mask-char")
+
+		if soft2hard == stripSoft {
+			checkError(fmt.Errorf("one and only one of --soft2hard and --strip-soft needed"))
+		}
+		if len(maskChar) != 1 {
+			checkError(fmt.Errorf("the value of --mask-char should be a single character: %s", maskChar))
+		}
+		mask := maskChar[0]
+
+		bench := getBenchmarkStats(cmd)
+		files := getFileListFromArgsAndFile(cmd, args, true, "infile-list", true)
+		bench.addInputs(files...)
+
+		outfh, err := xopen.Wopen(outFile)
+		checkError(err)
+		defer outfh.Close()
+
+		var record *fastx.Record
+		var nSeqs, nMaskedSeqs, nBases, n, total int
+		for _, file := range files {
+			fastxReader, err := fastx.NewReader(alphabet, file, idRegexp)
+			checkError(err)
+			for {
+				record, err = fastxReader.Read()
+				if err != nil {
+					if err == io.EOF {
+						break
+					}
+					checkError(err)
+					break
+				}
+				bench.addRecord()
+				if fastxReader.IsFastq {
+					config.LineWidth = 0
+					fastx.ForcelyOutputFastq = true
+				}
+				nSeqs++
+				total += len(record.Seq.Seq)
+
+				if soft2hard {
+					n = maskConvertSoft2Hard(record.Seq.Seq, mask)
+				} else {
+					n = maskConvertStripSoft(record.Seq.Seq)
+				}
+				if n > 0 {
+					nMaskedSeqs++
+					nBases += n
+				}
+
+				record.FormatToWriter(outfh, config.LineWidth)
+			}
+			fastxReader.Close()
+
+			config.LineWidth = lineWidth
+		}
+
+		if !quiet {
+			log.Infof("%d bases (of %d) in %d records (of %d) converted", nBases, total, nMaskedSeqs, nSeqs)
+		}
+	},
+}
+
+// maskConvertSoft2Hard replaces lowercase letters with the mask letter in place,
+// and returns the number of replaced letters.
+func maskConvertSoft2Hard(s []byte, mask byte) int {
+	var n int
+	for i, b := range s {
+		if b >= 'a' && b <= 'z' {
+			s[i] = mask
+			n++
+		}
+	}
+	return n
+}
+
+// maskConvertStripSoft converts lowercase letters to uppercase in place,
+// and returns the number of converted letters.
+func maskConvertStripSoft(s []byte) int {
+	var n int
+	for i, b := range s {
+		if b >= 'a' && b <= 'z' {
+			s[i] = b - 32
+			n++
+		}
+	}
+	return n
+}
+
+func init() {
+	RootCmd.AddCommand(maskConvertCmd)
+
+	maskConvertCmd.Flags().BoolP("soft2hard", "", false, "replace lowercase letters with the letter of --mask-char")
+	maskConvertCmd.Flags().BoolP("strip-soft", "", false, "convert lowercase letters to uppercase")
+	maskConvertCmd.Flags().StringP("mask-char", "", "N", "letter for hard masking")
+}
@@ -94,7 +94,9 @@ Output (BED3 format):
 		reQuery, err := regexp.Compile(reQueryStr)
 		checkError(err)
 
+		bench := getBenchmarkStats(cmd)
 		files := getFileListFromArgsAndFile(cmd, args, true, "infile-list", true)
+		bench.addInputs(files...)
 
 		if !config.Quiet {
 			if len(files) == 1 && isStdin(files[0]) {
@@ -70,7 +70,9 @@ Examples:
 		runtime.GOMAXPROCS(config.Threads)
 		quiet := config.Quiet
 
+		bench := getBenchmarkStats(cmd)
 		files := getFileListFromArgsAndFile(cmd, args, true, "infile-list", true)
+		bench.addInputs(files...)
 		var err error
 
 		mPoints := []_mutatePoint{}
@@ -228,6 +230,7 @@ Examples:
 					checkError(err)
 					break
 				}
+				bench.addRecord()
 
 				if checkFQ && fastxReader.IsFastq {
 					checkError(fmt.Errorf("FASTQ not supported"))
@@ -0,0 +1,248 @@
+// Copyright © 2016-2024 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"sort"
+
+	"github.com/shenwei356/bio/seq"
+	"github.com/shenwei356/bio/seqio/fastx"
+	"github.com/shenwei356/util/byteutil"
+	"github.com/shenwei356/xopen"
+	"github.com/spf13/cobra"
+)
+
+// orfCmd represents the orf command
+var orfCmd = &cobra.Command{
+	GroupID: "search",
+
+	Use:     "orf",
+	Aliases: []string{"longest-orf"},
+	Short:   "find the longest or all open reading frames (ORFs)",
+	Long: `find the longest or all open reading frames (ORFs)
+
+An ORF starts with a start codon and ends with a stop codon (included).
+For each stop codon, only the longest ORF (with the most upstream in-frame
+start codon) is considered. ORFs without stop codons are not reported.
+
+By default, the longest ORF of each record in the three frames of the positive
+strand is outputted. Use -b/--both-strands to scan all six frames, and -a/--all
+to output all ORFs not shorter than -m/--min-len.
+
+Start and stop codons are determined by the translate table (-T).
+Only ATG is used as the start codon unless -s/--alt-starts is given,
+which also uses alternative start codons of the translate table.
+Type 'seqkit translate -l 0' to list available tables, and 'seqkit translate -l N'
+to show details of table N.
+
+Header format:
+
+    >{ID}_orf{N} frame={frame} strand={+/-} begin={begin} end={end} len={len} {description}
+
+    frame    +1, +2, +3, -1, -2, or -3, the same as "seqkit translate"
+    begin    1-based start position on the positive strand
+    end      1-based end position on the positive strand
+    len      ORF length (nt, including the stop codon)
+
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		config := getConfigs(cmd)
+		alphabet := config.Alphabet
+		idRegexp := config.IDRegexp
+		outFile := config.OutFile
+		lineWidth := config.LineWidth
+		seq.AlphabetGuessSeqLengthThreshold = config.AlphabetGuessSeqLength
+		seq.ValidateSeq = false
+		runtime.GOMAXPROCS(config.Threads)
+
+		translTable := getFlagPositiveInt(cmd, "transl-table")
+		table, ok := seq.CodonTables[translTable]
+		if !ok {
+			checkError(fmt.Errorf("invalid translate table: %d", translTable))
+		}
+		minLen := getFlagNonNegativeInt(cmd, "min-len")
+		bothStrands := getFlagBool(cmd, "both-strands")
+		all := getFlagBool(cmd, "all")
+		altStarts := getFlagBool(cmd, "alt-starts")
+		protein := getFlagBool(cmd, "protein")
+
+		starts := map[string]struct{}{"ATG": {}}
+		if altStarts {
+			starts = table.InitCodons
+		}
+
+		bench := getBenchmarkStats(cmd)
+		files := getFileListFromArgsAndFile(cmd, args, true, "infile-list", true)
+		bench.addInputs(files...)
+
+		outfh, err := xopen.Wopen(outFile)
+		checkError(err)
+		defer outfh.Close()
+
+		var record *fastx.Record
+		var fastxReader *fastx.Reader
+		var orfs []orf
+		var rc *seq.Seq
+		var s, prot []byte
+		var strand string
+		var begin, end int
+		once := true
+		for _, file := range files {
+			fastxReader, err = fastx.NewReader(alphabet, file, idRegexp)
+			checkError(err)
+
+			for {
+				record, err = fastxReader.Read()
+				if err != nil {
+					if err == io.EOF {
+						break
+					}
+					checkError(err)
+					break
+				}
+				bench.addRecord()
+
+				if once {
+					if !(record.Seq.Alphabet == seq.DNA || record.Seq.Alphabet == seq.DNAredundant ||
+						record.Seq.Alphabet == seq.RNA || record.Seq.Alphabet == seq.RNAredundant) {
+						checkError(fmt.Errorf(`command 'seqkit orf' only apply to DNA/RNA sequences`))
+					}
+					once = false
+				}
+
+				orfs = findORFs(record.Seq.Seq, 1, starts, table.StopCodons, minLen, orfs[:0])
+				if bothStrands {
+					rc = record.Seq.RevCom()
+					orfs = findORFs(rc.Seq, -1, starts, table.StopCodons, minLen, orfs)
+				}
+				if len(orfs) == 0 {
+					continue
+				}
+
+				if all {
+					sort.Slice(orfs, func(i, j int) bool {
+						if orfs[i].begin(len(record.Seq.Seq)) == orfs[j].begin(len(record.Seq.Seq)) {
+							return orfs[i].frame > orfs[j].frame
+						}
+						return orfs[i].begin(len(record.Seq.Seq)) < orfs[j].begin(len(record.Seq.Seq))
+					})
+				} else {
+					sort.SliceStable(orfs, func(i, j int) bool {
+						return orfs[i].end-orfs[i].start > orfs[j].end-orfs[j].start
+					})
+					orfs = orfs[:1]
+				}
+
+				for i, o := range orfs {
+					if o.frame > 0 {
+						s = record.Seq.Seq[o.start:o.end]
+						strand = "+"
+					} else {
+						s = rc.Seq[o.start:o.end]
+						strand = "-"
+					}
+					begin = o.begin(len(record.Seq.Seq))
+					end = begin + o.end - o.start - 1
+
+					fmt.Fprintf(outfh, ">%s_orf%d frame=%+d strand=%s begin=%d end=%d len=%d",
+						record.ID, i+1, o.frame, strand, begin, end, o.end-o.start)
+					if len(record.Desc) > 0 {
+						outfh.WriteString(" ")
+						outfh.Write(record.Desc)
+					}
+					outfh.WriteString("\n")
+
+					if protein {
+						prot, err = table.Translate(s, 1, false, false, true, true)
+						checkError(err)
+						outfh.Write(byteutil.WrapByteSlice(prot, lineWidth))
+					} else {
+						outfh.Write(byteutil.WrapByteSlice(s, lineWidth))
+					}
+					outfh.WriteString("\n")
+				}
+			}
+			fastxReader.Close()
+		}
+	},
+}
+
+// orf is an open reading frame, start and end (exclusive) are 0-based
+// positions on the strand of the frame.
+type orf struct {
+	frame      int
+	start, end int
+}
+
+// begin returns the 1-based start position on the positive strand.
+func (o orf) begin(seqLen int) int {
+	if o.frame > 0 {
+		return o.start + 1
+	}
+	return seqLen - o.end + 1
+}
+
+// findORFs finds ORFs in the three frames of a sequence, sign is 1
+// for the positive strand and -1 for the negative strand.
+func findORFs(s []byte, sign int, starts, stops map[string]struct{}, minLen int, orfs []orf) []orf {
+	codon := make([]byte, 3)
+	var start, i, j int
+	var ok bool
+	for f := 0; f < 3; f++ {
+		start = -1
+		for i = f; i+3 <= len(s); i += 3 {
+			for j = 0; j < 3; j++ {
+				codon[j] = s[i+j] & 0xDF // upper case
+				if codon[j] == 'U' {
+					codon[j] = 'T'
+				}
+			}
+
+			if _, ok = stops[string(codon)]; ok {
+				if start >= 0 && i+3-start >= minLen {
+					orfs = append(orfs, orf{frame: sign * (f + 1), start: start, end: i + 3})
+				}
+				start = -1
+				continue
+			}
+			if start < 0 {
+				if _, ok = starts[string(codon)]; ok {
+					start = i
+				}
+			}
+		}
+	}
+	return orfs
+}
+
+func init() {
+	RootCmd.AddCommand(orfCmd)
+
+	orfCmd.Flags().IntP("transl-table", "T", 1, `translate table/genetic code, type 'seqkit translate --help' for more details`)
+	orfCmd.Flags().IntP("min-len", "m", 90, "minimum ORF length (nt, including the stop codon)")
+	orfCmd.Flags().BoolP("both-strands", "b", false, "scan all six frames of both strands")
+	orfCmd.Flags().BoolP("all", "a", false, "output all ORFs not shorter than -m/--min-len, instead of the longest one")
+	orfCmd.Flags().BoolP("alt-starts", "s", false, "also use alternative start codons of the translate table, rather than ATG only")
+	orfCmd.Flags().BoolP("protein", "p", false, "output protein sequences instead of nucleotide sequences")
+}
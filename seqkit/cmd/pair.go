@@ -27,6 +27,7 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"strings"
 
 	"github.com/cespare/xxhash/v2"
@@ -57,6 +58,12 @@ Attention:
 4. Paired gzipped files may be slightly larger than original files, because
    of using a different gzip package/library, don't worry.
 
+5. Use --check-only for a dry-run report of the pairing status, including
+   numbers of paired reads and reads only in read1 or read2, and the first
+   --max-report IDs of unpaired reads. No reads are written, and the program
+   exits with a non-zero status if any read is unpaired, which is handy for CI.
+   Only IDs of unpaired or out-of-order reads are kept in memory.
+
 Tips:
 1. Support for '/1 'and '/2' tags for paired read files generated by platforms like MGI.
    You can simply specify the regular expression for extracting sequence IDs:
@@ -65,6 +72,7 @@ Tips:
 `,
 	Run: func(cmd *cobra.Command, args []string) {
 		config := getConfigs(cmd)
+		bench := getBenchmarkStats(cmd)
 		alphabet := config.Alphabet
 		idRegexp := config.IDRegexp
 		lineWidth := 0
@@ -84,6 +92,24 @@ Tips:
 		if read1 == read2 {
 			checkError(fmt.Errorf("values of flag -1/--read1 and -2/--read2 can not be the same"))
 		}
+		bench.addInputs(read1, read2)
+
+		if getFlagBool(cmd, "check-only") {
+			maxReport := getFlagNonNegativeInt(cmd, "max-report")
+
+			outfh, err := xopen.Wopen(config.OutFile)
+			checkError(err)
+
+			result, err := pairCheck(alphabet, idRegexp, read1, read2, bench)
+			checkError(err)
+			result.write(outfh, read1, read2, maxReport)
+			outfh.Close()
+
+			if result.only1 > 0 || result.only2 > 0 {
+				os.Exit(1)
+			}
+			return
+		}
 
 		outdir := getFlagString(cmd, "out-dir")
 		force := getFlagBool(cmd, "force")
@@ -157,6 +183,7 @@ Tips:
 		checkError(errors.Wrap(err, read1))
 		record2, err = reader2.Read()
 		checkError(errors.Wrap(err, read2))
+		bench.addRecords(2)
 
 		// require fastq
 		// if !reader1.IsFastq || !reader2.IsFastq {
@@ -198,6 +225,7 @@ Tips:
 							break
 						}
 					}
+					bench.addRecord()
 				}
 
 				// new read2
@@ -212,6 +240,7 @@ Tips:
 							break
 						}
 					}
+					bench.addRecord()
 				}
 
 				continue
@@ -241,6 +270,7 @@ Tips:
 						break
 					}
 				}
+				bench.addRecord()
 			}
 
 			// ---
@@ -269,6 +299,7 @@ Tips:
 						break
 					}
 				}
+				bench.addRecord()
 			}
 		}
 
@@ -375,4 +406,162 @@ func init() {
 	pairCmd.Flags().StringP("out-dir", "O", "", "output directory")
 	pairCmd.Flags().BoolP("force", "f", false, "overwrite output directory")
 	pairCmd.Flags().BoolP("save-unpaired", "u", false, "save unpaired reads if there are")
+	pairCmd.Flags().BoolP("check-only", "", false, "only report pairing inconsistencies without writing reads, and exit with a non-zero status if any")
+	pairCmd.Flags().IntP("max-report", "", 10, "maximum number of IDs of unpaired reads to report in each file with --check-only")
+}
+
+// pairCheckRead is an unpaired read by now, with its index in the file.
+type pairCheckRead struct {
+	idx uint64
+	id  string
+}
+
+// pairCheckResult is the result of checking pairing status of two files.
+type pairCheckResult struct {
+	paired     uint64
+	outOfOrder uint64 // paired reads not in the same positions of the two files
+	only1      uint64
+	only2      uint64
+
+	unpaired1 []pairCheckRead
+	unpaired2 []pairCheckRead
+}
+
+// pairCheck streams the two files with the same hash-join of pairing,
+// but only saves IDs of reads not paired yet.
+func pairCheck(alphabet *seq.Alphabet, idRegexp string, read1, read2 string, bench *benchmarkStats) (*pairCheckResult, error) {
+	reader1, err := fastx.NewReader(alphabet, read1, idRegexp)
+	if err != nil {
+		return nil, errors.Wrap(err, read1)
+	}
+	defer reader1.Close()
+	reader2, err := fastx.NewReader(alphabet, read2, idRegexp)
+	if err != nil {
+		return nil, errors.Wrap(err, read2)
+	}
+	defer reader2.Close()
+
+	result := &pairCheckResult{}
+
+	m1 := make(map[uint64]pairCheckRead, 1024)
+	m2 := make(map[uint64]pairCheckRead, 1024)
+
+	var record1, record2 *fastx.Record
+	var eof1, eof2 bool
+	var i1, i2 uint64
+	var h uint64
+	var ok bool
+
+	read := func(reader *fastx.Reader, file string, eof *bool) (*fastx.Record, error) {
+		record, err := reader.Read()
+		if err != nil {
+			if err == io.EOF {
+				*eof = true
+				return nil, nil
+			}
+			return nil, errors.Wrap(err, file)
+		}
+		bench.addRecord()
+		return record, nil
+	}
+
+	if record1, err = read(reader1, read1, &eof1); err != nil {
+		return nil, err
+	}
+	if record2, err = read(reader2, read2, &eof2); err != nil {
+		return nil, err
+	}
+
+	for !eof1 || !eof2 {
+		if !eof1 && !eof2 && bytes.Equal(record1.ID, record2.ID) { // same ID
+			result.paired++
+			i1++
+			i2++
+
+			if record1, err = read(reader1, read1, &eof1); err != nil {
+				return nil, err
+			}
+			if record2, err = read(reader2, read2, &eof2); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if !eof1 {
+			i1++
+			h = xxhash.Sum64(record1.ID)
+			if _, ok = m2[h]; ok {
+				result.paired++
+				result.outOfOrder++
+				delete(m2, h)
+			} else {
+				m1[h] = pairCheckRead{idx: i1, id: string(record1.ID)}
+			}
+
+			if record1, err = read(reader1, read1, &eof1); err != nil {
+				return nil, err
+			}
+		}
+
+		if !eof2 {
+			i2++
+			h = xxhash.Sum64(record2.ID)
+			if _, ok = m1[h]; ok {
+				result.paired++
+				result.outOfOrder++
+				delete(m1, h)
+			} else {
+				m2[h] = pairCheckRead{idx: i2, id: string(record2.ID)}
+			}
+
+			if record2, err = read(reader2, read2, &eof2); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// left reads
+	for h, r := range m1 {
+		if _, ok = m2[h]; ok {
+			result.paired++
+			result.outOfOrder++
+			delete(m2, h)
+			continue
+		}
+		result.unpaired1 = append(result.unpaired1, r)
+	}
+	for _, r := range m2 {
+		result.unpaired2 = append(result.unpaired2, r)
+	}
+	result.only1 = uint64(len(result.unpaired1))
+	result.only2 = uint64(len(result.unpaired2))
+
+	sort.Slice(result.unpaired1, func(i, j int) bool { return result.unpaired1[i].idx < result.unpaired1[j].idx })
+	sort.Slice(result.unpaired2, func(i, j int) bool { return result.unpaired2[i].idx < result.unpaired2[j].idx })
+
+	return result, nil
+}
+
+func (r *pairCheckResult) write(outfh *xopen.Writer, read1, read2 string, maxReport int) {
+	fmt.Fprintf(outfh, "paired reads: %d\n", r.paired)
+	fmt.Fprintf(outfh, "paired reads not in the same order: %d\n", r.outOfOrder)
+	fmt.Fprintf(outfh, "reads only in %s: %d\n", read1, r.only1)
+	fmt.Fprintf(outfh, "reads only in %s: %d\n", read2, r.only2)
+
+	for _, u := range []struct {
+		file  string
+		reads []pairCheckRead
+	}{{read1, r.unpaired1}, {read2, r.unpaired2}} {
+		if maxReport == 0 || len(u.reads) == 0 {
+			continue
+		}
+		reads := u.reads
+		if len(reads) > maxReport {
+			reads = reads[:maxReport]
+		}
+		fmt.Fprintf(outfh, "first %d IDs only in %s:\n", len(reads), u.file)
+		for _, read := range reads {
+			fmt.Fprintf(outfh, "  #%d\t%s\n", read.idx, read.id)
+		}
+	}
 }
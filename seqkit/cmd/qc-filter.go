@@ -0,0 +1,305 @@
+// Copyright © 2016-2024 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
+
+	"github.com/shenwei356/bio/seq"
+	"github.com/shenwei356/bio/seqio/fastx"
+	"github.com/shenwei356/xopen"
+	"github.com/spf13/cobra"
+)
+
+// qcFilterCmd represents the qc-filter command
+var qcFilterCmd = &cobra.Command{
+	GroupID: "set",
+
+	Use:   "qc-filter",
+	Short: "filter reads by length, N content and quality, with a summary of each gate",
+	Long: `filter reads by length, N content and quality, with a summary of each gate
+
+Gates are evaluated in the fixed order below, and a record failing any gate
+is dropped. Each dropped record is counted once, for the first gate it fails.
+Only given gates are applied.
+  1. -m/--min-len       the minimum sequence length.
+  2. -M/--max-len       the maximum sequence length.
+  3. -n/--max-n-frac    the maximum fraction of N bases (case-insensitive).
+  4. -Q/--min-mean-qual the minimum average quality (only for FASTQ), which
+                        is computed in the same way as "seqkit seq -Q",
+                        i.e., from the mean error probability.
+
+A summary with the numbers of records dropped by each gate is reported
+unless --quiet is given.
+
+Filtering read pairs (-1/--read1 and -2/--read2):
+  1. A pair is dropped if either mate fails any gate, so the pairs are kept
+     synchronized. In the summary, a dropped pair is counted for the first
+     gate failed by either mate.
+  2. Reads in the two files should be paired in the same order, which is
+     checked by their IDs, with the mate suffixes '/1' and '/2' ignored.
+     Use "seqkit pair" first if not.
+  3. If the flag -O/--out-dir is not given, the output will be saved in the
+     same directory of input, with the suffix "filtered", e.g.,
+     read_1.filtered.fq.gz. Otherwise, names are kept untouched in the given
+     output directory. -o/--out-file is not supported.
+
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		config := getConfigs(cmd)
+		bench := getBenchmarkStats(cmd)
+		alphabet := config.Alphabet
+		idRegexp := config.IDRegexp
+		lineWidth := config.LineWidth
+		outFile := config.OutFile
+		quiet := config.Quiet
+		seq.AlphabetGuessSeqLengthThreshold = config.AlphabetGuessSeqLength
+		seq.ValidateSeq = false
+		runtime.GOMAXPROCS(config.Threads)
+
+		gates := &qcFilterGates{
+			minLen:      getFlagInt(cmd, "min-len"),
+			maxLen:      getFlagInt(cmd, "max-len"),
+			maxNFrac:    getFlagFloat64(cmd, "max-n-frac"),
+			minMeanQual: getFlagFloat64(cmd, "min-mean-qual"),
+			qBase:       getFlagPositiveInt(cmd, "qual-ascii-base"),
+		}
+		if gates.minLen >= 0 && gates.maxLen >= 0 && gates.minLen > gates.maxLen {
+			checkError(fmt.Errorf("value of flag -m (--min-len) should be <= value of flag -M (--max-len)"))
+		}
+		if gates.maxNFrac > 1 {
+			checkError(fmt.Errorf("value of flag -n (--max-n-frac) should be in range of [0, 1]"))
+		}
+		if gates.minLen < 0 && gates.maxLen < 0 && gates.maxNFrac < 0 && gates.minMeanQual < 0 {
+			checkError(fmt.Errorf("at least one of flags -m (--min-len), -M (--max-len), -n (--max-n-frac) and -Q (--min-mean-qual) needed"))
+		}
+
+		read1 := getFlagString(cmd, "read1")
+		read2 := getFlagString(cmd, "read2")
+		paired := read1 != "" || read2 != ""
+		if paired {
+			if read1 == "" || read2 == "" {
+				checkError(fmt.Errorf("flag -1/--read1 and -2/--read2 needed"))
+			}
+			if read1 == read2 {
+				checkError(fmt.Errorf("values of flag -1/--read1 and -2/--read2 can not be the same"))
+			}
+			if len(args) > 0 {
+				checkError(fmt.Errorf("no positional arguments are allowed for paired reads: %s", strings.Join(args, " ")))
+			}
+			if outFile != "-" {
+				checkError(fmt.Errorf("flag -o/--out-file is not supported for paired reads, please use -O/--out-dir"))
+			}
+		}
+
+		var record *fastx.Record
+		var gate, n int
+		var checkFormat bool
+		dropped := make([]int, len(qcFilterGateNames))
+
+		if paired {
+			outdir := getFlagString(cmd, "out-dir")
+			outFiles, err := pairedOutFiles(read1, read2, outdir, "filtered")
+			checkError(err)
+			outfh1, err := xopen.Wopen(outFiles[0])
+			checkError(err)
+			defer outfh1.Close()
+			outfh2, err := xopen.Wopen(outFiles[1])
+			checkError(err)
+			defer outfh2.Close()
+
+			reader, err := newPairedReader(alphabet, read1, read2, idRegexp, bench)
+			checkError(err)
+
+			var record1, record2 *fastx.Record
+			var gate2 int
+			checkFormat = true
+			for {
+				record1, record2, err = reader.Read()
+				if err != nil {
+					if err == io.EOF {
+						break
+					}
+					checkError(err)
+				}
+				if checkFormat {
+					gates.checkFormat(reader.reader1.IsFastq)
+					gates.checkFormat(reader.reader2.IsFastq)
+					if reader.IsFastq() {
+						config.LineWidth = 0
+						fastx.ForcelyOutputFastq = true
+					}
+					checkFormat = false
+				}
+				n++
+
+				gate = gates.check(record1)
+				if gate2 = gates.check(record2); gate < 0 || (gate2 >= 0 && gate2 < gate) {
+					gate = gate2
+				}
+				if gate >= 0 {
+					dropped[gate]++
+					continue
+				}
+
+				record1.FormatToWriter(outfh1, config.LineWidth)
+				record2.FormatToWriter(outfh2, config.LineWidth)
+			}
+			reader.Close()
+		} else {
+			files := getFileListFromArgsAndFile(cmd, args, true, "infile-list", true)
+			bench.addInputs(files...)
+
+			outfh, err := xopen.Wopen(outFile)
+			checkError(err)
+			defer outfh.Close()
+
+			for _, file := range files {
+				fastxReader, err := fastx.NewReader(alphabet, file, idRegexp)
+				checkError(err)
+
+				checkFormat = true
+				for {
+					record, err = fastxReader.Read()
+					if err != nil {
+						if err == io.EOF {
+							break
+						}
+						checkError(err)
+						break
+					}
+					bench.addRecord()
+					if checkFormat {
+						gates.checkFormat(fastxReader.IsFastq)
+						if fastxReader.IsFastq {
+							config.LineWidth = 0
+							fastx.ForcelyOutputFastq = true
+						}
+						checkFormat = false
+					}
+					n++
+
+					if gate = gates.check(record); gate >= 0 {
+						dropped[gate]++
+						continue
+					}
+
+					record.FormatToWriter(outfh, config.LineWidth)
+				}
+				fastxReader.Close()
+
+				config.LineWidth = lineWidth
+			}
+		}
+
+		if !quiet {
+			unit := "records"
+			if paired {
+				unit = "read pairs"
+			}
+			var nDropped int
+			for _, c := range dropped {
+				nDropped += c
+			}
+			log.Infof("%d %s processed, %d passed, %d dropped", n, unit, n-nDropped, nDropped)
+			for i, name := range qcFilterGateNames {
+				if gates.enabled(i) {
+					log.Infof("  dropped by %s: %d", name, dropped[i])
+				}
+			}
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(qcFilterCmd)
+
+	qcFilterCmd.Flags().IntP("min-len", "m", -1, "minimum sequence length (-1 for no limit)")
+	qcFilterCmd.Flags().IntP("max-len", "M", -1, "maximum sequence length (-1 for no limit)")
+	qcFilterCmd.Flags().Float64P("max-n-frac", "n", -1, "maximum fraction of N bases, in range of [0, 1] (-1 for no limit)")
+	qcFilterCmd.Flags().Float64P("min-mean-qual", "Q", -1, "minimum average quality, only for FASTQ (-1 for no limit)")
+	qcFilterCmd.Flags().IntP("qual-ascii-base", "b", 33, "ASCII BASE, 33 for Phred+33")
+
+	qcFilterCmd.Flags().StringP("read1", "1", "", "(gzipped) read1 file, for filtering read pairs")
+	qcFilterCmd.Flags().StringP("read2", "2", "", "(gzipped) read2 file, for filtering read pairs")
+	qcFilterCmd.Flags().StringP("out-dir", "O", "", "output directory for paired reads")
+}
+
+// names of gates, in the order of evaluation
+var qcFilterGateNames = []string{"min-len", "max-len", "max-n-frac", "min-mean-qual"}
+
+// qcFilterGates contains thresholds of gates, negative values for disabled gates.
+type qcFilterGates struct {
+	minLen      int
+	maxLen      int
+	maxNFrac    float64
+	minMeanQual float64
+	qBase       int
+}
+
+func (g *qcFilterGates) enabled(i int) bool {
+	switch i {
+	case 0:
+		return g.minLen >= 0
+	case 1:
+		return g.maxLen >= 0
+	case 2:
+		return g.maxNFrac >= 0
+	case 3:
+		return g.minMeanQual >= 0
+	}
+	return false
+}
+
+func (g *qcFilterGates) checkFormat(isFastq bool) {
+	if g.minMeanQual >= 0 && !isFastq {
+		checkError(fmt.Errorf("flag -Q (--min-mean-qual) only works for FASTQ format"))
+	}
+}
+
+// check returns the index of the first gate the record fails, or -1 if it passes all gates.
+func (g *qcFilterGates) check(record *fastx.Record) int {
+	L := len(record.Seq.Seq)
+	if g.minLen >= 0 && L < g.minLen {
+		return 0
+	}
+	if g.maxLen >= 0 && L > g.maxLen {
+		return 1
+	}
+	if g.maxNFrac >= 0 && L > 0 {
+		var nN int
+		for _, b := range record.Seq.Seq {
+			if b == 'N' || b == 'n' {
+				nN++
+			}
+		}
+		if float64(nN)/float64(L) > g.maxNFrac {
+			return 2
+		}
+	}
+	if g.minMeanQual >= 0 && record.Seq.AvgQual(g.qBase) < g.minMeanQual {
+		return 3
+	}
+	return -1
+}
@@ -52,6 +52,9 @@ Examples:
       seqkit range -r 10:100
       seqkit range -r -100:-10
 
+Records are read directly if the record-offset index file (<input>.skidx)
+created by "seqkit index build" exists and matches the input file.
+
 `,
 	Run: func(cmd *cobra.Command, args []string) {
 		config := getConfigs(cmd)
@@ -90,7 +93,9 @@ Examples:
 		if start > 0 && end < 0 && end != -1 {
 			checkError(fmt.Errorf("not supported range: %d:%d, the end needs to be -1 when start > 0 and end < 0", start, end))
 		}
+		bench := getBenchmarkStats(cmd)
 		files := getFileListFromArgsAndFile(cmd, args, true, "infile-list", true)
+		bench.addInputs(files...)
 
 		outfh, err := xopen.Wopen(outFile)
 		checkError(err)
@@ -118,6 +123,50 @@ Examples:
 
 		var record *fastx.Record
 		for _, file := range files {
+			// records in the range could be read directly with the index (seqkit index)
+			if idx := loadSeqIndex(file, config.Quiet); idx != nil {
+				if idx.fastq {
+					config.LineWidth = 0
+					fastx.ForcelyOutputFastq = true
+				}
+
+				n = len(idx.offsets)
+				var s, e int // 1-based
+				if rangePP {
+					s, e = start, end
+				} else if rangePN {
+					s, e = start, n
+				} else {
+					s, e = n+start+1, n+end+1
+					if s < 1 {
+						s = 1
+					}
+				}
+				if e > n {
+					e = n
+				}
+
+				if s <= e {
+					idxReader, err := newSeqIndexReader(file, idx, alphabet, idRegexp)
+					checkError(err)
+					fastxReader, err := idxReader.seek(idx.offsets[s-1])
+					checkError(err)
+					for i := s; i <= e; i++ {
+						record, err = fastxReader.Read()
+						if err != nil {
+							checkError(fmt.Errorf("%s: failed to read record via the index, please rebuild it: %s", file, err))
+						}
+						bench.addRecord()
+						record.FormatToWriter(outfh, config.LineWidth)
+					}
+					fastxReader.Close()
+					checkError(idxReader.Close())
+				}
+
+				config.LineWidth = lineWidth
+				continue
+			}
+
 			fastxReader, err := fastx.NewReader(alphabet, file, idRegexp)
 			checkError(err)
 
@@ -136,6 +185,7 @@ Examples:
 					checkError(err)
 					break
 				}
+				bench.addRecord()
 
 				if fastxReader.IsFastq {
 					config.LineWidth = 0
@@ -0,0 +1,398 @@
+// Copyright © 2016-2024 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"math/rand"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/shenwei356/bio/seq"
+	"github.com/shenwei356/bio/seqio/fastx"
+	"github.com/shenwei356/xopen"
+	"github.com/spf13/cobra"
+)
+
+// recodeCmd represents the recode command
+var recodeCmd = &cobra.Command{
+	GroupID: "edit",
+
+	Use:     "recode",
+	Aliases: []string{"codon-optimize"},
+	Short:   "recode CDS or protein sequences with a codon usage table",
+	Long: `recode CDS or protein sequences with a codon usage table
+
+Nucleotide sequences are treated as CDSs, and translated with the genetic code
+given by -T/--transl-table, then every amino acid (including the stop "*")
+is recoded with a synonymous codon in the usage table, so the protein is kept.
+Protein sequences are recoded directly.
+
+Usage table:
+  1. A tab-delimited file, with codons in the first column and usage values
+     (e.g., frequencies, per-thousand values, or counts) in the last column.
+     Extra columns, e.g., amino acids, are ignored, as amino acids are decided
+     by the genetic code. Blank lines and lines starting with "#" are ignored.
+  2. Only relative values of codons of the same amino acid matter. Codons
+     with a value of 0 are not used, and an error is reported if no codons
+     are available for an amino acid.
+
+Choosing codons:
+  1. By default, the codon with the highest usage is chosen for every amino
+     acid (ties are broken by the lexical order of codons).
+  2. With --sample, codons are randomly chosen by their usage frequencies,
+     seeded by -s/--rand-seed.
+  3. With --avoid-sites, e.g., --avoid-sites GAATTC,GGATCC, alternative
+     synonymous codons are chosen to avoid the sites on both strands, with
+     backtracking to previous codons when needed, while preferring codons
+     with higher usage (or sampled orders with --sample). Sites spanning
+     adjacent codons are also avoided. If the sites can not be avoided in a
+     record, e.g., "TGG" for "W", or the backtracking exceeds the maximum
+     number of steps (--max-steps), the record is recoded ignoring the sites,
+     with a warning.
+
+Attention:
+  1. The alphabet of sequences is guessed, please use "-t protein" for short
+     protein sequences only containing letters like "ACGT".
+  2. Lengths of CDSs should be multiples of 3, codons containing ambiguous
+     bases are not supported, and neither are unknown amino acids like "X".
+  3. Output sequences are DNA.
+
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		config := getConfigs(cmd)
+		alphabet := config.Alphabet
+		idRegexp := config.IDRegexp
+		outFile := config.OutFile
+		quiet := config.Quiet
+		seq.AlphabetGuessSeqLengthThreshold = config.AlphabetGuessSeqLength
+		seq.ValidateSeq = false
+		runtime.GOMAXPROCS(config.Threads)
+
+		usageFile := getFlagString(cmd, "usage-table")
+		if usageFile == "" {
+			checkError(fmt.Errorf("flag -u/--usage-table needed"))
+		}
+		translTable := getFlagPositiveInt(cmd, "transl-table")
+		table, ok := seq.CodonTables[translTable]
+		if !ok {
+			checkError(fmt.Errorf("invalid translate table: %d", translTable))
+		}
+		sampling := getFlagBool(cmd, "sample")
+		seed := getFlagInt64(cmd, "rand-seed")
+		maxSteps := getFlagPositiveInt(cmd, "max-steps")
+
+		var sites [][]byte
+		for _, site := range getFlagStringSlice(cmd, "avoid-sites") {
+			site = strings.ToUpper(strings.TrimSpace(site))
+			if site == "" {
+				continue
+			}
+			for _, b := range []byte(site) {
+				if !(b == 'A' || b == 'C' || b == 'G' || b == 'T') {
+					checkError(fmt.Errorf("invalid site in --avoid-sites, only A, C, G and T are allowed: %s", site))
+				}
+			}
+			s, err := seq.NewSeqWithoutValidation(seq.DNA, []byte(site))
+			checkError(err)
+			sites = append(sites, s.Seq)
+			if rc := s.RevCom().Seq; !bytes.Equal(rc, s.Seq) {
+				sites = append(sites, rc)
+			}
+		}
+
+		usage, err := readCodonUsageTable(usageFile, table)
+		checkError(err)
+
+		bench := getBenchmarkStats(cmd)
+		files := getFileListFromArgsAndFile(cmd, args, true, "infile-list", true)
+		bench.addInputs(files...)
+
+		outfh, err := xopen.Wopen(outFile)
+		checkError(err)
+		defer outfh.Close()
+
+		recoder := &codonRecoder{
+			usage:    usage,
+			sampling: sampling,
+			rand:     rand.New(rand.NewSource(seed)),
+			sites:    sites,
+			maxSteps: maxSteps,
+		}
+
+		var record *fastx.Record
+		var protein, cds []byte
+		var avoided bool
+		var n, nFailed int
+		for _, file := range files {
+			fastxReader, err := fastx.NewReader(alphabet, file, idRegexp)
+			checkError(err)
+			for {
+				record, err = fastxReader.Read()
+				if err != nil {
+					if err == io.EOF {
+						break
+					}
+					checkError(err)
+					break
+				}
+				bench.addRecord()
+
+				if record.Seq.Alphabet == seq.Protein {
+					protein = bytes.ToUpper(record.Seq.Seq)
+				} else {
+					if len(record.Seq.Seq)%3 != 0 {
+						checkError(fmt.Errorf("the length of CDS (%d) is not a multiple of 3: %s", len(record.Seq.Seq), record.ID))
+					}
+					protein, err = table.Translate(record.Seq.Seq, 1, false, false, false, false)
+					if err != nil {
+						checkError(fmt.Errorf("failed to translate CDS %s: %s", record.ID, err))
+					}
+				}
+
+				cds, avoided, err = recoder.Recode(protein)
+				if err != nil {
+					checkError(fmt.Errorf("%s: %s", record.ID, err))
+				}
+				if !avoided {
+					nFailed++
+					if !quiet {
+						log.Warningf("sites could not be avoided in %s", record.ID)
+					}
+				}
+				n++
+
+				record.Seq.Seq = cds
+				record.Seq.Qual = nil
+				record.FormatToWriter(outfh, config.LineWidth)
+			}
+			fastxReader.Close()
+		}
+
+		if !quiet {
+			log.Infof("%d sequences recoded", n)
+			if len(sites) > 0 && nFailed > 0 {
+				log.Warningf("sites could not be avoided in %d sequences", nFailed)
+			}
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(recodeCmd)
+
+	recodeCmd.Flags().StringP("usage-table", "u", "", "tab-delimited codon usage table, with codons in the first column and usage values in the last column")
+	recodeCmd.Flags().IntP("transl-table", "T", 1, `translate table/genetic code, type 'seqkit translate --help' for more details`)
+	recodeCmd.Flags().BoolP("sample", "", false, "choose codons randomly by usage frequencies, instead of the codon with the highest usage")
+	recodeCmd.Flags().Int64P("rand-seed", "s", 11, "random seed for --sample")
+	recodeCmd.Flags().StringSliceP("avoid-sites", "", []string{}, "sites to avoid on both strands by choosing alternative synonymous codons, e.g., GAATTC,GGATCC")
+	recodeCmd.Flags().IntP("max-steps", "", 1000000, "maximum number of steps of backtracking when avoiding sites in a sequence")
+}
+
+// codonUsage is a codon and its usage value.
+type codonUsage struct {
+	codon []byte
+	value float64
+}
+
+// readCodonUsageTable reads a codon usage table, and returns codons of every
+// amino acid, sorted by usage values in descending order.
+func readCodonUsageTable(file string, table *seq.CodonTable) (map[byte][]codonUsage, error) {
+	fh, err := xopen.Ropen(file)
+	if err != nil {
+		return nil, fmt.Errorf("read codon usage table %s: %s", file, err)
+	}
+	defer fh.Close()
+
+	usage := make(map[byte][]codonUsage, 21)
+	seen := make(map[string]struct{}, 64)
+	scanner := bufio.NewScanner(fh)
+	var line, codon string
+	var items []string
+	var value float64
+	var aa byte
+	var i int
+	for scanner.Scan() {
+		i++
+		line = strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		items = strings.Split(line, "\t")
+		if len(items) < 2 {
+			return nil, fmt.Errorf("line %d of codon usage table: at least two columns expected: %s", i, line)
+		}
+		codon = strings.ReplaceAll(strings.ToUpper(strings.TrimSpace(items[0])), "U", "T")
+		if len(codon) != 3 || strings.Trim(codon, "ACGT") != "" {
+			return nil, fmt.Errorf("line %d of codon usage table: invalid codon: %s", i, items[0])
+		}
+		if _, ok := seen[codon]; ok {
+			return nil, fmt.Errorf("line %d of codon usage table: duplicated codon: %s", i, items[0])
+		}
+		seen[codon] = struct{}{}
+		value, err = strconv.ParseFloat(strings.TrimSpace(items[len(items)-1]), 64)
+		if err != nil || value < 0 {
+			return nil, fmt.Errorf("line %d of codon usage table: invalid usage value: %s", i, items[len(items)-1])
+		}
+		if value == 0 {
+			continue
+		}
+		aa, err = table.Get2(codon, false)
+		if err != nil {
+			return nil, fmt.Errorf("line %d of codon usage table: %s", i, err)
+		}
+		usage[aa] = append(usage[aa], codonUsage{codon: []byte(codon), value: value})
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read codon usage table %s: %s", file, err)
+	}
+	if len(usage) == 0 {
+		return nil, fmt.Errorf("no codons found in codon usage table: %s", file)
+	}
+
+	for _, codons := range usage {
+		sort.Slice(codons, func(i, j int) bool {
+			if codons[i].value == codons[j].value {
+				return bytes.Compare(codons[i].codon, codons[j].codon) < 0
+			}
+			return codons[i].value > codons[j].value
+		})
+	}
+	return usage, nil
+}
+
+// codonRecoder recodes protein sequences with codons in a usage table.
+type codonRecoder struct {
+	usage    map[byte][]codonUsage
+	sampling bool
+	rand     *rand.Rand
+	sites    [][]byte // sites and their reverse complements
+	maxSteps int
+}
+
+// candidates returns codons of an amino acid in the order of preference.
+func (r *codonRecoder) candidates(aa byte) []codonUsage {
+	codons := r.usage[aa]
+	if !r.sampling || len(codons) == 1 {
+		return codons
+	}
+
+	// weighted sampling without replacement
+	pool := make([]codonUsage, len(codons))
+	copy(pool, codons)
+	list := make([]codonUsage, 0, len(codons))
+	var sum, v float64
+	var i int
+	for len(pool) > 0 {
+		sum = 0
+		for _, c := range pool {
+			sum += c.value
+		}
+		v = r.rand.Float64() * sum
+		for i = 0; i < len(pool)-1; i++ {
+			v -= pool[i].value
+			if v < 0 {
+				break
+			}
+		}
+		list = append(list, pool[i])
+		pool = append(pool[:i], pool[i+1:]...)
+	}
+	return list
+}
+
+// hasSiteEndingIn checks if any site ends in the range [start, len(s)) of s.
+func (r *codonRecoder) hasSiteEndingIn(s []byte, start int) bool {
+	var b int
+	for _, site := range r.sites {
+		b = start - len(site) + 1
+		if b < 0 {
+			b = 0
+		}
+		if bytes.Contains(s[b:], site) {
+			return true
+		}
+	}
+	return false
+}
+
+// Recode recodes a protein sequence. It returns false if sites could not be avoided.
+func (r *codonRecoder) Recode(protein []byte) ([]byte, bool, error) {
+	lists := make([][]codonUsage, len(protein))
+	for i, aa := range protein {
+		if _, ok := r.usage[aa]; !ok {
+			return nil, false, fmt.Errorf("no codons in the usage table for the amino acid at position %d: %c", i+1, aa)
+		}
+		lists[i] = r.candidates(aa)
+	}
+
+	cds := make([]byte, 0, len(protein)*3)
+	if len(r.sites) == 0 {
+		for _, list := range lists {
+			cds = append(cds, list[0].codon...)
+		}
+		return cds, true, nil
+	}
+
+	// depth-first search with the index of the candidate codon of every position
+	idx := make([]int, len(protein))
+	var i, steps int
+	for i < len(protein) {
+		if idx[i] == len(lists[i]) { // all candidates failed, backtrack
+			idx[i] = 0
+			i--
+			if i < 0 {
+				break
+			}
+			cds = cds[:i*3]
+			idx[i]++
+			continue
+		}
+
+		steps++
+		if steps > r.maxSteps {
+			break
+		}
+
+		cds = append(cds, lists[i][idx[i]].codon...)
+		if r.hasSiteEndingIn(cds, i*3) {
+			cds = cds[:i*3]
+			idx[i]++
+			continue
+		}
+		i++
+	}
+	if i == len(protein) {
+		return cds, true, nil
+	}
+
+	// failed, ignoring the sites
+	cds = cds[:0]
+	for _, list := range lists {
+		cds = append(cds, list[0].codon...)
+	}
+	return cds, false, nil
+}
@@ -72,7 +72,9 @@ Example:
 		seq.ValidateSeq = false
 		runtime.GOMAXPROCS(config.Threads)
 
+		bench := getBenchmarkStats(cmd)
 		files := getFileListFromArgsAndFile(cmd, args, true, "infile-list", true)
+		bench.addInputs(files...)
 
 		byName := getFlagBool(cmd, "by-name")
 		mOutputs := getFlagBool(cmd, "multiple-outfiles")
@@ -146,6 +148,7 @@ Example:
 						checkError(err)
 						break
 					}
+					bench.addRecord()
 					if fastxReader.IsFastq {
 						config.LineWidth = 0
 						fastx.ForcelyOutputFastq = true
@@ -24,8 +24,10 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"math/rand"
 	"regexp"
 	"runtime"
+	"strconv"
 	"strings"
 
 	"github.com/cespare/xxhash/v2"
@@ -61,8 +63,14 @@ more on: http://bioinf.shenwei.me/seqkit/usage/#replace
 Special replacement symbols (only for replacing name not sequence):
 
     {nr}    Record number, starting from 1
+    {rand:N} Random alphanumeric string of N characters ([0-9A-Za-z]),
+            seeded by --rand-seed. Random strings are unique in a run,
+            a new one is generated at most 100 times on collision, e.g.,
+              seqkit replace -p '.+' -r 'read_{rand:8}'
     {kv}    Corresponding value of the key (captured variable $n) by key-value file,
             n can be specified by flag -I (--key-capt-idx) (default: 1)
+    {kv:NAME} Value of the column NAME of the key in a key-value file with
+            multiple columns, see details below.
             
 Special cases:
   1. If replacements contain '$', 
@@ -71,6 +79,58 @@ Special cases:
     b). If not, use '$$':
             -r 'xxx$$xx'
 
+Using multiple columns of key-value files ({kv:NAME}):
+  1. When '{kv:NAME}' is used in -r, the first line of the key-value file
+     is treated as the header line of column names, and values of all columns
+     are loaded, e.g., for a file with the header line "id species strain":
+       seqkit replace -p '^(\S+)' -r '{kv:species} strain {kv:strain}' -k kv.tsv
+     '{kv}' represents the second column in this case.
+  2. For keys not found, the whole replacement follows -U/--keep-untouch,
+     -K/--keep-key and -m/--key-miss-repl as '{kv}' does.
+  3. For keys found but with missing columns (rows with fewer columns),
+     -K/--keep-key and -m/--key-miss-repl apply to each missing field,
+     while -U/--keep-untouch leaves the name unchanged.
+
+Matching multiple times or none (only for replacing name):
+  1. All non-overlapping matches of -p in a name are replaced, except when
+     using '{kv}', where multiple matches are treated as an error.
+  2. Names not matched by -p are left unchanged by default. Flag --if-miss
+     sets a template to replace the whole name of these records. It supports
+     '{nr}', and '$0' (or '${0}') represents the original name, while other
+     capture variables are empty as there's no match. e.g.,
+       seqkit replace -p '^(\w+)_ok' -r '$1' --if-miss 'unmatched_{nr} $0'
+
+Replacing sequences (-s/--by-seq):
+  1. Capture variables also work, e.g., masking the motif between two
+     anchors with N:
+       seqkit replace -s -p '(ACGT)TTTT(ACGT)' -r '${1}NNNN${2}'
+  2. For FASTQ, qualities are kept only when the substitution does not
+     change the sequence length, otherwise an error is reported.
+  3. For FASTA, a warning is shown if sequence lengths are changed.
+
+Normalizing names (--normalize-id):
+  1. Leading and trailing whitespace of names are trimmed, and internal runs
+     of whitespace (spaces and tabs) are collapsed into single spaces.
+  2. Flag --id-case changes the case of the ID, i.e., the first word of the
+     normalized name, to "upper" or "lower". Descriptions are not changed.
+  3. Normalization is performed before replacing with -p/-r, so they can be
+     combined, e.g., adding a prefix to normalized names:
+       seqkit replace --normalize-id --id-case upper -p '^' -r 'sample1_'
+     Flag -p (--pattern) is optional with --normalize-id.
+
+Extracting IDs by positions (--id-range):
+  1. The ID is replaced with the substring of the given range (1-based,
+     end included), while the description is kept, e.g., "1:8" for the
+     first 8 characters, and "-6:-1" for the last 6 ones. Negative positions
+     count from the end as in other commands, like "seqkit subseq -r".
+  2. Out-of-range positions are clamped to the ID, e.g., "1:20" for an ID
+     of 12 characters returns the whole ID. IDs with empty ranges, e.g., "15:20"
+     for the ID above, are kept unchanged, with a warning of the number.
+  3. It is performed after --normalize-id and before replacing with -p/-r,
+     so prefixes or suffixes can be added, e.g.,
+       seqkit replace --id-range 1:8 -p '^' -r 'S1_'
+     Flag -p (--pattern) is optional with --id-range.
+
 Filtering records to edit:
   You can use flags similar to those in "seqkit grep" to choose partly records to edit.
 
@@ -94,14 +154,62 @@ Filtering records to edit:
 		keepUntouch := getFlagBool(cmd, "keep-untouch")
 		keyCaptIdx := getFlagPositiveInt(cmd, "key-capt-idx")
 		keyMissRepl := getFlagString(cmd, "key-miss-repl")
+		ifMissTemplate := getFlagString(cmd, "if-miss")
+		ifMiss := cmd.Flags().Lookup("if-miss").Changed
+		randSeed := getFlagInt64(cmd, "rand-seed")
 
 		bySeq := getFlagBool(cmd, "by-seq")
 		// byName := getFlagBool(cmd, "by-name")
 		ignoreCase := getFlagBool(cmd, "ignore-case")
 
-		if pattern == "" {
+		normalizeID := getFlagBool(cmd, "normalize-id")
+		idCase := getFlagString(cmd, "id-case")
+		if idCase != "" {
+			if !normalizeID {
+				checkError(fmt.Errorf("flag --id-case should be used along with --normalize-id"))
+			}
+			if idCase != "upper" && idCase != "lower" {
+				checkError(fmt.Errorf(`invalid value of flag --id-case: %s, available: "upper", "lower"`, idCase))
+			}
+		}
+		if normalizeID && bySeq {
+			checkError(fmt.Errorf("flag --normalize-id is not compatible with -s (--by-seq)"))
+		}
+
+		idRange := getFlagString(cmd, "id-range")
+		var idStart, idEnd int
+		if idRange != "" {
+			if bySeq {
+				checkError(fmt.Errorf("flag --id-range is not compatible with -s (--by-seq)"))
+			}
+			if !reRegion.MatchString(idRange) {
+				checkError(fmt.Errorf(`invalid value of flag --id-range: %s, e.g., 1:8 or -6:-1`, idRange))
+			}
+			r := strings.Split(idRange, ":")
+			var err error
+			idStart, err = strconv.Atoi(r[0])
+			checkError(err)
+			idEnd, err = strconv.Atoi(r[1])
+			checkError(err)
+			if idStart == 0 || idEnd == 0 {
+				checkError(fmt.Errorf("both begin and end in --id-range should not be 0"))
+			}
+			if idStart > 0 && idEnd > 0 && idStart > idEnd {
+				checkError(fmt.Errorf("begin should not be larger than end in --id-range: %s", idRange))
+			}
+		}
+
+		if pattern == "" && !normalizeID && idRange == "" {
 			checkError(fmt.Errorf("flags -p (--pattern) needed"))
 		}
+		if ifMiss {
+			if bySeq {
+				checkError(fmt.Errorf("flag --if-miss is only for replacing sequence name, not compatible with -s (--by-seq)"))
+			}
+			if reKV.MatchString(ifMissTemplate) || reKVField.MatchString(ifMissTemplate) {
+				checkError(fmt.Errorf(`replacement symbol "{kv}"/"{KV}" is not supported in value of flag --if-miss`))
+			}
+		}
 
 		// check pattern with unquoted comma
 		if reUnquotedComma.MatchString(pattern) {
@@ -123,7 +231,7 @@ Filtering records to edit:
 			if len(replacement) == 0 {
 				checkError(fmt.Errorf("flag -r (--replacement) needed when given flag -k (--kv-file)"))
 			}
-			if !reKV.Match(replacement) {
+			if !reKV.Match(replacement) && !reKVField.Match(replacement) {
 				checkError(fmt.Errorf(`replacement symbol "{kv}"/"{KV}" not found in value of flag -r (--replacement) when flag -k (--kv-file) given`))
 			}
 		}
@@ -132,9 +240,31 @@ Filtering records to edit:
 			replaceWithNR = true
 		}
 
+		ifMissReplacement := []byte(ifMissTemplate)
+		var ifMissWithNR bool
+		if reNR.Match(ifMissReplacement) {
+			ifMissWithNR = true
+		}
+
+		replaceWithRand := reRand.Match(replacement)
+		ifMissWithRand := reRand.Match(ifMissReplacement)
+		if replaceWithRand && bySeq {
+			checkError(fmt.Errorf(`replacement symbol "{rand:N}" is only for replacing sequence name, not compatible with -s (--by-seq)`))
+		}
+		var randIDs *randIDGenerator
+		if replaceWithRand || ifMissWithRand {
+			checkError(checkRandTokens(replacement))
+			checkError(checkRandTokens(ifMissReplacement))
+			randIDs = newRandIDGenerator(randSeed)
+		}
+
 		var replaceWithKV bool
 		var kvs map[string]string
-		if reKV.Match(replacement) {
+		// for {kv:NAME}
+		replaceWithKVFields := reKVField.Match(replacement)
+		var kvNames []string
+		var kvRows map[string]map[string]string
+		if reKV.Match(replacement) || replaceWithKVFields {
 			replaceWithKV = true
 			if !regexp.MustCompile(`\(.+\)`).MatchString(pattern) {
 				checkError(fmt.Errorf(`value of -p (--pattern) must contains "(" and ")" to capture data which is used specify the KEY`))
@@ -148,15 +278,38 @@ Filtering records to edit:
 			if !quiet {
 				log.Infof("read key-value file: %s", kvFile)
 			}
-			kvs, err = readKVs(kvFile, ignoreCase)
-			if err != nil {
-				checkError(fmt.Errorf("read key-value file: %s", err))
-			}
-			if len(kvs) == 0 {
-				checkError(fmt.Errorf("no valid data in key-value file: %s", kvFile))
-			}
-			if !quiet {
-				log.Infof("%d pairs of key-value loaded", len(kvs))
+			if replaceWithKVFields {
+				kvNames, kvRows, err = readKVTable(kvFile, ignoreCase)
+				if err != nil {
+					checkError(fmt.Errorf("read key-value file: %s", err))
+				}
+				if len(kvRows) == 0 {
+					checkError(fmt.Errorf("no valid data in key-value file: %s", kvFile))
+				}
+				columns := make(map[string]struct{}, len(kvNames))
+				for _, name := range kvNames[1:] {
+					columns[name] = struct{}{}
+				}
+				for _, m := range reKVField.FindAllSubmatch(replacement, -1) {
+					if _, ok := columns[string(m[2])]; !ok {
+						checkError(fmt.Errorf("column %s not found in the header line of key-value file: %s. available: %s",
+							m[2], kvFile, strings.Join(kvNames[1:], ", ")))
+					}
+				}
+				if !quiet {
+					log.Infof("%d keys with %d columns of values loaded", len(kvRows), len(kvNames)-1)
+				}
+			} else {
+				kvs, err = readKVs(kvFile, ignoreCase)
+				if err != nil {
+					checkError(fmt.Errorf("read key-value file: %s", err))
+				}
+				if len(kvs) == 0 {
+					checkError(fmt.Errorf("no valid data in key-value file: %s", kvFile))
+				}
+				if !quiet {
+					log.Infof("%d pairs of key-value loaded", len(kvs))
+				}
 			}
 		}
 
@@ -305,7 +458,9 @@ Filtering records to edit:
 
 		// -------------------
 
+		bench := getBenchmarkStats(cmd)
 		files := getFileListFromArgsAndFile(cmd, args, true, "infile-list", true)
+		bench.addInputs(files...)
 
 		outfh, err := xopen.Wopen(outFile)
 		checkError(err)
@@ -316,6 +471,7 @@ Filtering records to edit:
 		var found [][]byte
 		var k, v string
 		var ok bool
+		var kvRow map[string]string
 		var doNotChange bool
 		var record *fastx.Record
 		nrFormat := fmt.Sprintf("%%0%dd", nrWidth)
@@ -327,6 +483,15 @@ Filtering records to edit:
 		var k2 []byte
 		var re *regexp.Regexp
 		var h uint64
+		var newSeq []byte
+		var nLenChanged int
+		var id, newID []byte
+		var nEmptyRange int
+		var idRe *regexp.Regexp
+		if idRange != "" && normalizeID {
+			idRe, err = regexp.Compile(idRegexp)
+			checkError(err)
+		}
 
 		for _, file := range files {
 			fastxReader, err := fastx.NewReader(alphabet, file, idRegexp)
@@ -341,6 +506,7 @@ Filtering records to edit:
 					checkError(err)
 					break
 				}
+				bench.addRecord()
 				if fastxReader.IsFastq {
 					config.LineWidth = 0
 					fastx.ForcelyOutputFastq = true
@@ -415,11 +581,45 @@ Filtering records to edit:
 
 				// edit
 
+				if normalizeID {
+					record.Name = replaceNormalizeName(record.Name, idCase)
+				}
+
+				if idRange != "" {
+					id = record.ID
+					if normalizeID { // the ID might be changed
+						id = fastx.ParseHeadID(idRe, record.Name)
+					}
+					if newID, ok = replaceIDRange(id, idStart, idEnd); ok {
+						record.Name = bytes.Replace(record.Name, id, newID, 1)
+					} else {
+						nEmptyRange++
+					}
+				}
+
+				if pattern == "" {
+					record.FormatToWriter(outfh, config.LineWidth)
+					continue
+				}
+
 				if bySeq {
-					if fastxReader.IsFastq {
-						checkError(fmt.Errorf("editing FASTQ is not supported"))
+					newSeq = patternRegexp.ReplaceAll(record.Seq.Seq, replacement)
+					if len(newSeq) != len(record.Seq.Seq) {
+						if fastxReader.IsFastq {
+							checkError(fmt.Errorf("the sequence length of FASTQ record changed after replacement, qualities can not be kept: %s", record.ID))
+						}
+						nLenChanged++
 					}
-					record.Seq.Seq = patternRegexp.ReplaceAll(record.Seq.Seq, replacement)
+					record.Seq.Seq = newSeq
+				} else if ifMiss && !patternRegexp.Match(record.Name) {
+					r = ifMissReplacement
+					if ifMissWithNR {
+						r = reNR.ReplaceAll(r, []byte(fmt.Sprintf(nrFormat, nr)))
+					}
+					if ifMissWithRand {
+						r = randIDs.replaceAll(r)
+					}
+					record.Name = reWholeName.ReplaceAll(record.Name, r)
 				} else {
 					doNotChange = false
 
@@ -444,7 +644,24 @@ Filtering records to edit:
 							if ignoreCase {
 								k = strings.ToLower(k)
 							}
-							if v, ok = kvs[k]; ok {
+							if replaceWithKVFields {
+								if kvRow, ok = kvRows[k]; ok {
+									if keepKey {
+										r, ok = replaceKVFields(r, kvRow, kvNames[1], found[keyCaptIdx])
+									} else {
+										r, ok = replaceKVFields(r, kvRow, kvNames[1], []byte(keyMissRepl))
+									}
+									if !ok && keepUntouch {
+										doNotChange = true
+									}
+								} else if keepUntouch {
+									doNotChange = true
+								} else if keepKey {
+									r = reKVField.ReplaceAll(reKV.ReplaceAll(r, found[keyCaptIdx]), found[keyCaptIdx])
+								} else {
+									r = reKVField.ReplaceAll(reKV.ReplaceAll(r, []byte(keyMissRepl)), []byte(keyMissRepl))
+								}
+							} else if v, ok = kvs[k]; ok {
 								r = reKV.ReplaceAll(r, []byte(v))
 							} else if keepUntouch {
 								doNotChange = true
@@ -459,7 +676,11 @@ Filtering records to edit:
 					}
 
 					if !doNotChange {
-						record.Name = patternRegexp.ReplaceAll(record.Name, r)
+						if replaceWithRand {
+							record.Name = randIDs.replaceAllRegexp(patternRegexp, record.Name, r)
+						} else {
+							record.Name = patternRegexp.ReplaceAll(record.Name, r)
+						}
 					}
 				}
 
@@ -473,6 +694,12 @@ Filtering records to edit:
 		if !config.Quiet && useFilter {
 			log.Infof("%d records matched by the filter", count)
 		}
+		if !config.Quiet && nEmptyRange > 0 {
+			log.Warningf("IDs of %d records kept unchanged for empty ranges of --id-range", nEmptyRange)
+		}
+		if !config.Quiet && nLenChanged > 0 {
+			log.Warningf("sequence lengths of %d records changed after replacement", nLenChanged)
+		}
 	},
 }
 
@@ -483,11 +710,11 @@ func init() {
 		"replacement. supporting capture variables. "+
 			" e.g. $1 represents the text of the first submatch. "+
 			"ATTENTION: for *nix OS, use SINGLE quote NOT double quotes or "+
-			`use the \ escape character. Record number is also supported by "{nr}".`+
+			`use the \ escape character. Record number is also supported by "{nr}", and random string by "{rand:N}".`+
 			`use ${1} instead of $1 when {kv} given!`)
 	replaceCmd.Flags().IntP("nr-width", "", 1, `minimum width for {nr} in flag -r/--replacement. e.g., formatting "1" to "001" by --nr-width 3`)
 	// replaceCmd.Flags().BoolP("by-name", "n", false, "replace full name instead of just id")
-	replaceCmd.Flags().BoolP("by-seq", "s", false, "replace seq (only FASTA)")
+	replaceCmd.Flags().BoolP("by-seq", "s", false, "replace seq. For FASTQ, the replacement should not change the sequence length")
 	replaceCmd.Flags().BoolP("ignore-case", "i", false, "ignore case")
 	replaceCmd.Flags().StringP("kv-file", "k", "",
 		`tab-delimited key-value file for replacing key with value when using "{kv}" in -r (--replacement) (only for sequence name)`)
@@ -495,6 +722,11 @@ func init() {
 	replaceCmd.Flags().BoolP("keep-key", "K", false, "keep the key as value when no value found for the key (only for sequence name)")
 	replaceCmd.Flags().IntP("key-capt-idx", "I", 1, "capture variable index of key (1-based)")
 	replaceCmd.Flags().StringP("key-miss-repl", "m", "", "replacement for key with no corresponding value")
+	replaceCmd.Flags().Int64P("rand-seed", "", 11, `random seed for "{rand:N}"`)
+	replaceCmd.Flags().BoolP("normalize-id", "", false, "trim whitespace of names and collapse internal runs of whitespace into single spaces, before replacing")
+	replaceCmd.Flags().StringP("id-case", "", "", `change the case of IDs when using --normalize-id: "upper" or "lower"`)
+	replaceCmd.Flags().StringP("id-range", "", "", `replace the ID with the substring of the given range (1-based, end included), e.g., 1:8 for the first 8 characters, -6:-1 for the last 6 ones`)
+	replaceCmd.Flags().StringP("if-miss", "", "", `replacement template for the whole name of records not matched by -p (--pattern), supporting "{nr}" and "$0" for the original name (only for sequence name)`)
 
 	replaceCmd.Flags().StringSliceP("f-pattern", "", []string{""}, `[target filter] search pattern (multiple values supported. Attention: use double quotation marks for patterns containing comma, e.g., -p '"A{2,}"')`)
 	replaceCmd.Flags().StringP("f-pattern-file", "", "", "[target filter] pattern file (one record per line)")
@@ -506,5 +738,126 @@ func init() {
 	replaceCmd.Flags().BoolP("f-only-positive-strand", "", false, "[target filter] only search on positive strand")
 }
 
+// replaceNormalizeName trims whitespace of a name, collapses internal runs of
+// whitespace into single spaces, and changes the case of the first word (ID).
+func replaceNormalizeName(name []byte, idCase string) []byte {
+	fields := bytes.Fields(name)
+	if len(fields) == 0 {
+		return name[:0]
+	}
+	switch idCase {
+	case "upper":
+		fields[0] = bytes.ToUpper(fields[0])
+	case "lower":
+		fields[0] = bytes.ToLower(fields[0])
+	}
+	return bytes.Join(fields, []byte{' '})
+}
+
+// replaceIDRange returns the substring of an ID in a range, with out-of-range
+// positions clamped. It returns false for an empty range.
+func replaceIDRange(id []byte, start, end int) ([]byte, bool) {
+	if start < 0 && -start > len(id) {
+		start = 1
+	}
+	s, e, ok := seq.SubLocation(len(id), start, end)
+	if !ok || s > e {
+		return id, false
+	}
+	return id[s-1 : e], true
+}
+
 var reNR = regexp.MustCompile(`\{(NR|nr)\}`)
 var reKV = regexp.MustCompile(`\{(KV|kv)\}`)
+var reKVField = regexp.MustCompile(`\{(KV|kv):([^{}]+)\}`)
+var reWholeName = regexp.MustCompile(`(?s)^.*$`)
+var reRand = regexp.MustCompile(`\{(RAND|rand):(\d+)\}`)
+
+// replaceKVFields replaces "{kv:NAME}" with values of columns in a row of a
+// key-value file, and "{kv}" with the value of the first value column.
+// Missing values are replaced with fill, and false is returned in this case.
+func replaceKVFields(r []byte, row map[string]string, first string, fill []byte) ([]byte, bool) {
+	complete := true
+	value := func(name string) []byte {
+		if v, ok := row[name]; ok {
+			return []byte(v)
+		}
+		complete = false
+		return fill
+	}
+	r = reKVField.ReplaceAllFunc(r, func(m []byte) []byte {
+		return value(string(reKVField.FindSubmatch(m)[2]))
+	})
+	if reKV.Match(r) {
+		r = reKV.ReplaceAll(r, value(first))
+	}
+	return r, complete
+}
+
+// randMaxAttempts is the maximum number of attempts to generate
+// an unused random string for "{rand:N}".
+const randMaxAttempts = 100
+
+const randAlphanumeric = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+func checkRandTokens(template []byte) error {
+	for _, m := range reRand.FindAllSubmatch(template, -1) {
+		n, err := strconv.Atoi(string(m[2]))
+		if err != nil || n <= 0 {
+			return fmt.Errorf(`length of random string should be positive: %s`, m[0])
+		}
+	}
+	return nil
+}
+
+// randIDGenerator generates unique random alphanumeric strings.
+type randIDGenerator struct {
+	rnd  *rand.Rand
+	used map[string]struct{}
+}
+
+func newRandIDGenerator(seed int64) *randIDGenerator {
+	return &randIDGenerator{
+		rnd:  rand.New(rand.NewSource(seed)),
+		used: make(map[string]struct{}, 1024),
+	}
+}
+
+// next returns a random string of n characters not returned before.
+func (g *randIDGenerator) next(n int) (string, error) {
+	buf := make([]byte, n)
+	for i := 0; i < randMaxAttempts; i++ {
+		for j := range buf {
+			buf[j] = randAlphanumeric[g.rnd.Intn(len(randAlphanumeric))]
+		}
+		if _, ok := g.used[string(buf)]; !ok {
+			g.used[string(buf)] = struct{}{}
+			return string(buf), nil
+		}
+	}
+	return "", fmt.Errorf(`failed to generate a unique random string of %d characters after %d attempts, please use a longer length in "{rand:N}"`, n, randMaxAttempts)
+}
+
+// replaceAll replaces every "{rand:N}" in the template with a new random string.
+func (g *randIDGenerator) replaceAll(template []byte) []byte {
+	return reRand.ReplaceAllFunc(template, func(m []byte) []byte {
+		n, _ := strconv.Atoi(string(reRand.FindSubmatch(m)[2]))
+		s, err := g.next(n)
+		checkError(err)
+		return []byte(s)
+	})
+}
+
+// replaceAllRegexp replaces matches of re in src with the template, like
+// re.ReplaceAll(). Capture groups like "$1" are expanded before "{rand:N}"
+// is substituted, so "$1{rand:4}" is not read as a group named "1aB3x".
+func (g *randIDGenerator) replaceAllRegexp(re *regexp.Regexp, src, template []byte) []byte {
+	dst := make([]byte, 0, len(src)+len(template))
+	var last int
+	for _, loc := range re.FindAllSubmatchIndex(src, -1) {
+		dst = append(dst, src[last:loc[0]]...)
+		dst = append(dst, g.replaceAll(re.Expand(nil, template, src, loc))...)
+		last = loc[1]
+	}
+	return append(dst, src[last:]...)
+}
@@ -0,0 +1,254 @@
+// Copyright © 2016-2024 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+
+	"github.com/biogo/hts/sam"
+	"github.com/shenwei356/bio/seq"
+	"github.com/shenwei356/xopen"
+	"github.com/spf13/cobra"
+)
+
+// revcompBamCmd represents the revcomp-bam command
+var revcompBamCmd = &cobra.Command{
+	GroupID: "bam",
+
+	Use:   "revcomp-bam",
+	Short: "export reads of BAM files as FASTQ in their original orientation",
+	Long: `export reads of BAM files as FASTQ in their original orientation
+
+Sequences of alignments on the reverse strand (flag 0x10) are stored
+reverse-complemented in BAM files. This command restores the original
+reads: the sequences are reverse-complemented and the qualities are
+reversed for these alignments, and other reads are outputted unchanged.
+
+Attention:
+  1. Only primary alignments and unmapped reads are outputted, secondary
+     (0x100) and supplementary (0x800) alignments are skipped.
+  2. Soft-clipped bases are kept in the sequences, so the whole reads are
+     restored. Hard-clipped bases are absent in BAM records, these reads can
+     not be fully reconstructed and are skipped by default. Use
+     "-H/--hard-clipped keep" to output the remaining parts.
+  3. Reads without qualities ("*") are given the Phred quality of
+     --default-qual.
+  4. Values of BAM tags given by -T/--tags are appended to the headers in
+     the SAM format, separated by tabs, e.g., "read1	BC:Z:ACGT	RG:Z:grp1".
+     Use "-T '*'" for all tags. Values are copied as they are, tags relative
+     to the reference strand (e.g., MD) are not reversed.
+  5. Use "-P/--pair-suffix" to append "/1" and "/2" to names of read 1
+     (0x40) and read 2 (0x80).
+
+Examples:
+  1. Exporting reads with the cell barcode and UMI tags.
+       seqkit revcomp-bam aln.bam -T CB,UB -o reads.fq.gz
+  2. Exporting read 1 and read 2 of paired-end reads.
+       samtools view -b -f 0x40 aln.bam | seqkit revcomp-bam -P -o r1.fq.gz
+       samtools view -b -f 0x80 aln.bam | seqkit revcomp-bam -P -o r2.fq.gz
+
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		config := getConfigs(cmd)
+		outFile := config.OutFile
+		quiet := config.Quiet
+		seq.ValidateSeq = false
+		runtime.GOMAXPROCS(config.Threads)
+
+		tagNames := getFlagStringSlice(cmd, "tags")
+		hardClipped := getFlagString(cmd, "hard-clipped")
+		defaultQual := getFlagNonNegativeInt(cmd, "default-qual")
+		pairSuffix := getFlagBool(cmd, "pair-suffix")
+
+		var keepHardClipped bool
+		switch hardClipped {
+		case "drop":
+		case "keep":
+			keepHardClipped = true
+		default:
+			checkError(fmt.Errorf(`invalid value of flag -H (--hard-clipped): %s, available: "drop", "keep"`, hardClipped))
+		}
+		if defaultQual > 93 {
+			checkError(fmt.Errorf("value of flag --default-qual should be in range of [0, 93]: %d", defaultQual))
+		}
+
+		var allTags bool
+		tags := make([][]byte, 0, len(tagNames))
+		for _, t := range tagNames {
+			if t == "*" {
+				allTags = true
+				continue
+			}
+			if len(t) != 2 {
+				checkError(fmt.Errorf("invalid tag of flag -T (--tags): %s, tags should be of two characters, e.g., BC", t))
+			}
+			tags = append(tags, []byte(t))
+		}
+
+		bench := getBenchmarkStats(cmd)
+		files := getFileListFromArgsAndFile(cmd, args, true, "infile-list", true)
+		bench.addInputs(files...)
+
+		outfh, err := xopen.Wopen(outFile)
+		checkError(err)
+		defer outfh.Close()
+
+		var r *sam.Record
+		var s, q []byte
+		var aux sam.Aux
+		var ok bool
+		var reversed bool
+		var nReads, nReversed, nSkipped, nHardClipped int
+		for _, file := range files {
+			bamReader := NewBamReader(file, config.Threads)
+			for {
+				r, err = bamReader.Read()
+				if err != nil {
+					if err == io.EOF {
+						break
+					}
+					checkError(err)
+				}
+				bench.addRecord()
+
+				if r.Flags&(sam.Secondary|sam.Supplementary) != 0 {
+					nSkipped++
+					continue
+				}
+
+				if bamHardClipped(r) {
+					nHardClipped++
+					if !keepHardClipped {
+						continue
+					}
+				}
+
+				s, q, reversed = revcompBamRead(r, defaultQual)
+				if reversed {
+					nReversed++
+				}
+
+				outfh.WriteByte('@')
+				outfh.WriteString(r.Name)
+				if pairSuffix {
+					if r.Flags&sam.Read1 != 0 {
+						outfh.WriteString("/1")
+					} else if r.Flags&sam.Read2 != 0 {
+						outfh.WriteString("/2")
+					}
+				}
+				if allTags {
+					for _, aux = range r.AuxFields {
+						outfh.WriteByte('\t')
+						outfh.WriteString(aux.String())
+					}
+				} else {
+					for _, t := range tags {
+						if aux, ok = r.Tag(t); ok {
+							outfh.WriteByte('\t')
+							outfh.WriteString(aux.String())
+						}
+					}
+				}
+				outfh.WriteByte('\n')
+				outfh.Write(s)
+				outfh.WriteString("\n+\n")
+				outfh.Write(q)
+				outfh.WriteByte('\n')
+				nReads++
+			}
+			checkError(bamReader.Close())
+		}
+
+		if !quiet {
+			log.Infof("%d reads outputted, %d of them reverse-complemented", nReads, nReversed)
+			if nSkipped > 0 {
+				log.Infof("%d secondary/supplementary alignments skipped", nSkipped)
+			}
+			if nHardClipped > 0 {
+				if keepHardClipped {
+					log.Warningf("%d hard-clipped reads outputted, which are not complete", nHardClipped)
+				} else {
+					log.Warningf("%d hard-clipped reads skipped", nHardClipped)
+				}
+			}
+		}
+	},
+}
+
+// bamHardClipped checks if an alignment contains hard-clipped bases.
+func bamHardClipped(r *sam.Record) bool {
+	for _, c := range r.Cigar {
+		if c.Type() == sam.CigarHardClipped {
+			return true
+		}
+	}
+	return false
+}
+
+// revcompBamRead returns the sequence and Phred+33 qualities of a read in its
+// original orientation. Reads without qualities are given defaultQual.
+func revcompBamRead(r *sam.Record, defaultQual int) (s, q []byte, reversed bool) {
+	s = r.Seq.Expand()
+	q = make([]byte, len(s))
+	if len(r.Qual) == len(s) && (len(s) == 0 || r.Qual[0] != 0xff) {
+		for i := range r.Qual {
+			q[i] = r.Qual[i] + 33
+		}
+	} else {
+		for i := range q {
+			q[i] = byte(defaultQual + 33)
+		}
+	}
+
+	if r.Flags&sam.Reverse != 0 {
+		revcompBamReverse(s, q)
+		reversed = true
+	}
+	return s, q, reversed
+}
+
+// revcompBamReverse reverse-complements a sequence and reverses the
+// qualities, in place.
+func revcompBamReverse(s, q []byte) {
+	for i, j := 0, len(s)-1; i < j; i, j = i+1, j-1 {
+		s[i], s[j] = s[j], s[i]
+		q[i], q[j] = q[j], q[i]
+	}
+	var b byte
+	var err error
+	for i := range s {
+		if b, err = seq.DNAredundant.PairLetter(s[i]); err == nil {
+			s[i] = b
+		}
+	}
+}
+
+func init() {
+	RootCmd.AddCommand(revcompBamCmd)
+
+	revcompBamCmd.Flags().StringSliceP("tags", "T", []string{}, `BAM tags appended to headers, e.g., -T CB,UB. Use "*" for all tags`)
+	revcompBamCmd.Flags().StringP("hard-clipped", "H", "drop", `policy for hard-clipped reads which can not be fully reconstructed: "drop" or "keep"`)
+	revcompBamCmd.Flags().IntP("default-qual", "", 1, "Phred quality for reads without qualities")
+	revcompBamCmd.Flags().BoolP("pair-suffix", "P", false, `append "/1" and "/2" to names of read 1 and read 2`)
+}
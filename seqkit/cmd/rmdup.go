@@ -21,11 +21,15 @@
 package cmd
 
 import (
+	"bufio"
 	"bytes"
 	"fmt"
 	"io"
+	"os"
+	"regexp"
 	"runtime"
 	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/cespare/xxhash/v2"
@@ -48,10 +52,67 @@ Attention:
      compared. Switch on -P/--only-positive-strand for considering the
      positive strand only.
   2. Only the first record is saved for duplicates.
-     
+  3. Only 64-bit hash values of IDs/names/sequences are kept in memory,
+     which costs about 40 bytes per unique record. For very large files,
+     use --max-mem to cap the memory: when the number of hash values
+     exceeds the cap, they are sorted and spilled to a temporary file
+     in $TMPDIR (or --tmp-dir), along with a Bloom filter (about 10 bits
+     per value) kept in memory for fast lookup, which is also counted in
+     the cap. Hash values found in the
+     Bloom filters are verified in the temporary files, so no false
+     duplicates are introduced. Temporary files are removed on exit.
+     IDs of duplicated records for -D/--dup-num-file are still kept in
+     memory, and IDs of other records are also spilled to disk.
+
+Removing duplicated read pairs (-1/--read1 and -2/--read2):
+  1. Read pairs with both mates identical to those of a previous pair
+     (PCR/optical duplicates) are removed, i.e., the key is the concatenation
+     of sequences of R1 and R2. Mates are compared as they are, i.e., only
+     the positive strand. --prefix-len limits the comparison to the first N bases
+     of each mate, which is faster but may collapse more pairs.
+  2. Reads in the two files should be paired in the same order, which is
+     checked by their IDs, with the mate suffixes '/1' and '/2' ignored.
+     Use "seqkit pair" first if not.
+  3. If the flag -O/--out-dir is not given, the output will be saved in the
+     same directory of input, with the suffix "rmdup", e.g., read_1.rmdup.fq.gz.
+     Otherwise, names are kept untouched in the given output directory.
+     -o/--out-file is not supported.
+  4. -D/--dup-num-file lists IDs of collapsed pairs (IDs of read1).
+     -d/--dup-seqs-file, -n/--by-name are not supported.
+
+Dereplication with abundances (--size-out):
+  1. Flag --size-out appends the number of records of each group of duplicates
+     to the ID of the representative (the first record), following the
+     USEARCH/VSEARCH convention, e.g., "seq1;size=10". Existing ";size=N"
+     annotations are replaced. It works with -s/--by-seq and considers both
+     strands unless -P/--only-positive-strand is given.
+  2. --sort-by-size outputs representatives in decreasing order of sizes,
+     records with the same size are kept in the input order.
+  3. --cluster-file saves the members of each group in two columns:
+     ID of the representative, and ID of the member (including itself).
+  4. Representatives are kept in memory and outputted after all records are
+     read, so --max-mem and read pairs are not supported.
+
+Removing adjacent duplicates of sorted input (--sorted):
+  1. For input already sorted by IDs/names/sequences in lexicographic order
+     (ascending or descending), e.g., by "seqkit sort" without -N, flag
+     --sorted collapses adjacent records with the same key by comparing each
+     record with the previous one, without keeping any hash values, so the
+     memory is constant.
+  2. An error is reported if the input is detected not sorted, i.e.,
+     a record is found in the opposite order to that of previous ones.
+     Multiple input files are treated as one sorted stream.
+  3. Only the positive strand is compared for -s/--by-seq, as reverse
+     complement sequences are not adjacent in sorted input.
+  4. -d/--dup-seqs-file, --size-out and --cluster-file are supported.
+     -D/--dup-num-file lists duplicates in the input order instead of the
+     decreasing order of numbers. --max-mem, --sort-by-size and read pairs
+     are not supported.
+
 `,
 	Run: func(cmd *cobra.Command, args []string) {
 		config := getConfigs(cmd)
+		bench := getBenchmarkStats(cmd)
 		alphabet := config.Alphabet
 		idRegexp := config.IDRegexp
 		lineWidth := config.LineWidth
@@ -67,9 +128,30 @@ Attention:
 		dupFile := getFlagString(cmd, "dup-seqs-file")
 		numFile := getFlagString(cmd, "dup-num-file")
 
+		maxMem, err := ParseByteSize(getFlagString(cmd, "max-mem"))
+		if err != nil {
+			checkError(fmt.Errorf("invalid value of flag --max-mem: %s", err))
+		}
+		tmpDir := getFlagString(cmd, "tmp-dir")
+
+		sizeOut := getFlagBool(cmd, "size-out")
+		sortBySize := getFlagBool(cmd, "sort-by-size")
+		clusterFile := getFlagString(cmd, "cluster-file")
+		if (sortBySize || clusterFile != "") && !sizeOut {
+			checkError(fmt.Errorf("flag --size-out needed when using --sort-by-size or --cluster-file"))
+		}
+		if sizeOut && maxMem > 0 {
+			checkError(fmt.Errorf("flag --size-out is not compatible with --max-mem"))
+		}
+
 		saveDupFile := dupFile != ""
 		saveNumFile := numFile != ""
 
+		sorted := getFlagBool(cmd, "sorted")
+		if sorted && (maxMem > 0 || sortBySize) {
+			checkError(fmt.Errorf("flag --sorted is not compatible with --max-mem and --sort-by-size"))
+		}
+
 		// revcom := getFlagBool(cmd, "consider-revcom")
 		revcom := !getFlagBool(cmd, "only-positive-strand")
 
@@ -77,15 +159,62 @@ Attention:
 			checkError(fmt.Errorf("only one/none of the flags -s (--by-seq) and -n (--by-name) is allowed"))
 		}
 
+		read1 := getFlagString(cmd, "read1")
+		read2 := getFlagString(cmd, "read2")
+		paired := read1 != "" || read2 != ""
+		prefixLen := getFlagNonNegativeInt(cmd, "prefix-len")
+		if paired {
+			if read1 == "" || read2 == "" {
+				checkError(fmt.Errorf("flag -1/--read1 and -2/--read2 needed"))
+			}
+			if read1 == read2 {
+				checkError(fmt.Errorf("values of flag -1/--read1 and -2/--read2 can not be the same"))
+			}
+			if len(args) > 0 {
+				checkError(fmt.Errorf("no positional arguments are allowed for paired reads: %s", strings.Join(args, " ")))
+			}
+			if outFile != "-" {
+				checkError(fmt.Errorf("flag -o/--out-file is not supported for paired reads, please use -O/--out-dir"))
+			}
+			if byName || saveDupFile {
+				checkError(fmt.Errorf("flag -n (--by-name) and -d (--dup-seqs-file) are not supported for paired reads"))
+			}
+			if sizeOut {
+				checkError(fmt.Errorf("flag --size-out is not supported for paired reads"))
+			}
+			if sorted {
+				checkError(fmt.Errorf("flag --sorted is not supported for paired reads"))
+			}
+			bySeq = true
+			revcom = false
+		} else if prefixLen > 0 {
+			checkError(fmt.Errorf("flag --prefix-len only works with -1/--read1 and -2/--read2"))
+		}
+
 		if !revcom && !bySeq {
 			checkError(fmt.Errorf("flag -s (--by-seq) needed when using -P (--only-positive-strand)"))
 		}
 
-		files := getFileListFromArgsAndFile(cmd, args, true, "infile-list", true)
+		var files []string
+		var outfh, outfh1, outfh2 *xopen.Writer
+		if paired {
+			outdir := getFlagString(cmd, "out-dir")
+			outFiles, err := pairedOutFiles(read1, read2, outdir, "rmdup")
+			checkError(err)
+			outfh1, err = xopen.Wopen(outFiles[0])
+			checkError(err)
+			defer outfh1.Close()
+			outfh2, err = xopen.Wopen(outFiles[1])
+			checkError(err)
+			defer outfh2.Close()
+		} else {
+			files = getFileListFromArgsAndFile(cmd, args, true, "infile-list", true)
+			bench.addInputs(files...)
 
-		outfh, err := xopen.Wopen(outFile)
-		checkError(err)
-		defer outfh.Close()
+			outfh, err = xopen.Wopen(outFile)
+			checkError(err)
+			defer outfh.Close()
+		}
 
 		var outfhDup *xopen.Writer
 		if saveDupFile {
@@ -94,12 +223,231 @@ Attention:
 			defer outfhDup.Close()
 		}
 
-		counter := make(map[uint64]int)
+		if sorted {
+			if bySeq && revcom && !quiet {
+				log.Infof("only the positive strand is compared for --sorted")
+			}
+			var outfhNum, outfhCluster *xopen.Writer
+			if saveNumFile {
+				outfhNum, err = xopen.Wopen(numFile)
+				checkError(err)
+				defer outfhNum.Close()
+			}
+			if clusterFile != "" {
+				outfhCluster, err = xopen.Wopen(clusterFile)
+				checkError(err)
+				defer outfhCluster.Close()
+			}
+			keepIDs := saveNumFile || clusterFile != ""
+
+			var record, rep *fastx.Record // rep is the representative for --size-out
+			var key, prev []byte
+			var order, c int // order: 1 for ascending, -1 for descending, 0 for unknown
+			var size, removed int
+			var ids []string // IDs of the current group
+			flush := func() {
+				if size == 0 {
+					return
+				}
+				if clusterFile != "" {
+					for _, id := range ids {
+						outfhCluster.WriteString(ids[0] + "\t" + id + "\n")
+					}
+				}
+				if saveNumFile && size > 1 {
+					outfhNum.WriteString(fmt.Sprintf("%d\t%s\n", len(ids), strings.Join(ids, ", ")))
+				}
+				if sizeOut {
+					rmdupSizeAnnotate(rep, size)
+					rep.FormatToWriter(outfh, config.LineWidth)
+				}
+			}
+			for _, file := range files {
+				fastxReader, err := fastx.NewReader(alphabet, file, idRegexp)
+				checkError(err)
+				for {
+					record, err = fastxReader.Read()
+					if err != nil {
+						if err == io.EOF {
+							break
+						}
+						checkError(err)
+						break
+					}
+					bench.addRecord()
+					if fastxReader.IsFastq {
+						config.LineWidth = 0
+						fastx.ForcelyOutputFastq = true
+					}
+
+					if bySeq {
+						key = record.Seq.Seq
+					} else if byName {
+						key = record.Name
+					} else {
+						key = record.ID
+					}
+					if ignoreCase {
+						key = bytes.ToLower(key)
+					}
+
+					if size > 0 {
+						c = bytes.Compare(key, prev)
+						if c == 0 { // duplicated
+							removed++
+							size++
+							if saveDupFile {
+								outfhDup.Write(record.Format(config.LineWidth))
+							}
+							if keepIDs {
+								ids = append(ids, string(record.ID))
+							}
+							continue
+						}
+						if order == 0 {
+							order = c
+						} else if c != order {
+							checkError(fmt.Errorf("input is not sorted, found at record: %s, please sort it with \"seqkit sort\" first", record.ID))
+						}
+						flush()
+					}
+
+					// a new group
+					prev = append(prev[:0], key...)
+					size = 1
+					if keepIDs {
+						ids = append(ids[:0], string(record.ID))
+					}
+					if sizeOut {
+						rep = record.Clone()
+					} else {
+						record.FormatToWriter(outfh, config.LineWidth)
+					}
+				}
+				fastxReader.Close()
+			}
+			flush()
+
+			if !quiet {
+				log.Infof("%d duplicated records removed", removed)
+			}
+			return
+		}
+
+		counter, err := newSpillHashSet(maxMem, tmpDir)
+		checkError(err)
+		defer counter.Close()
+
 		names := make(map[uint64][]string)
 
+		// for -D/--dup-num-file when hash values are spilled to disk:
+		// names of records which have been spilled are saved to keptFile,
+		// and names of duplicated records are kept in dupNames.
+		dupNames := make(map[uint64][]string)
+		var keptFile *bufio.Writer
+		var keptFileName string
+		missingFirst := make(map[uint64]struct{})
+		if saveNumFile && maxMem > 0 {
+			keptFileName, keptFile, err = counter.CreateFile("names")
+			checkError(err)
+			counter.OnSpill = func() {
+				for h, l := range names {
+					if len(l) > 1 {
+						dupNames[h] = l
+					} else {
+						fmt.Fprintf(keptFile, "%d\t%s\n", h, l[0])
+					}
+				}
+				names = make(map[uint64][]string)
+			}
+		}
+		addDupName := func(h uint64, name string) {
+			if l, ok := names[h]; ok {
+				names[h] = append(l, name)
+			} else if l, ok = dupNames[h]; ok {
+				dupNames[h] = append(l, name)
+			} else { // the first one has been spilled to disk
+				dupNames[h] = []string{"", name}
+				missingFirst[h] = struct{}{}
+			}
+		}
+
 		var subject uint64
 		var removed int
 		var record *fastx.Record
+
+		// for --size-out, representatives are kept and outputted at the end
+		var reps []*fastx.Record
+		var sizes []int
+		var members [][]string
+		repIdx := make(map[uint64]int)
+		var isFastq bool
+		addToGroup := func(h uint64, record *fastx.Record) {
+			i := repIdx[h]
+			sizes[i]++
+			if clusterFile != "" {
+				members[i] = append(members[i], string(record.ID))
+			}
+		}
+
+		if paired {
+			reader, err := newPairedReader(alphabet, read1, read2, idRegexp, bench)
+			checkError(err)
+
+			digest := xxhash.New()
+			var record1, record2 *fastx.Record
+			var s1, s2 []byte
+			sep := []byte{'\n'}
+			for {
+				record1, record2, err = reader.Read()
+				if err != nil {
+					if err == io.EOF {
+						break
+					}
+					checkError(err)
+				}
+				if reader.IsFastq() {
+					config.LineWidth = 0
+					fastx.ForcelyOutputFastq = true
+				}
+
+				s1, s2 = record1.Seq.Seq, record2.Seq.Seq
+				if prefixLen > 0 {
+					if len(s1) > prefixLen {
+						s1 = s1[:prefixLen]
+					}
+					if len(s2) > prefixLen {
+						s2 = s2[:prefixLen]
+					}
+				}
+				if ignoreCase {
+					s1, s2 = bytes.ToLower(s1), bytes.ToLower(s2)
+				}
+				digest.Reset()
+				digest.Write(s1)
+				digest.Write(sep)
+				digest.Write(s2)
+				subject = digest.Sum64()
+
+				if counter.Has(subject) { // duplicated
+					removed++
+					if saveNumFile {
+						addDupName(subject, string(record1.ID))
+					}
+					continue
+				}
+
+				record1.FormatToWriter(outfh1, config.LineWidth)
+				record2.FormatToWriter(outfh2, config.LineWidth)
+
+				if saveNumFile {
+					names[subject] = []string{string(record1.ID)}
+				}
+				checkError(counter.Add(subject))
+			}
+			reader.Close()
+		}
+
 		for _, file := range files {
 			fastxReader, err := fastx.NewReader(alphabet, file, idRegexp)
 			checkError(err)
@@ -112,9 +460,11 @@ Attention:
 					checkError(err)
 					break
 				}
+				bench.addRecord()
 				if fastxReader.IsFastq {
 					config.LineWidth = 0
 					fastx.ForcelyOutputFastq = true
+					isFastq = true
 				}
 
 				if bySeq {
@@ -137,14 +487,16 @@ Attention:
 					}
 				}
 
-				if _, ok := counter[subject]; ok { // duplicated
-					counter[subject]++
+				if counter.Has(subject) { // duplicated
 					removed++
 					if saveDupFile {
 						outfhDup.Write(record.Format(config.LineWidth))
 					}
 					if saveNumFile {
-						names[subject] = append(names[subject], string(record.ID))
+						addDupName(subject, string(record.ID))
+					}
+					if sizeOut {
+						addToGroup(subject, record)
 					}
 
 					continue
@@ -157,37 +509,93 @@ Attention:
 						subject = xxhash.Sum64(record.Seq.RevCom().Seq)
 					}
 
-					if _, ok := counter[subject]; ok { // duplicated
-						counter[subject]++
+					if counter.Has(subject) { // duplicated
 						removed++
 						if saveDupFile {
 							outfhDup.Write(record.Format(config.LineWidth))
 						}
 						if saveNumFile {
-							names[subject] = append(names[subject], string(record.ID))
+							addDupName(subject, string(record.ID))
+						}
+						if sizeOut {
+							addToGroup(subject, record)
 						}
 						continue
 					}
 				}
 
-				record.FormatToWriter(outfh, config.LineWidth)
-				counter[subject]++
+				if sizeOut {
+					repIdx[subject] = len(reps)
+					reps = append(reps, record.Clone())
+					sizes = append(sizes, 1)
+					if clusterFile != "" {
+						members = append(members, []string{string(record.ID)})
+					}
+				} else {
+					record.FormatToWriter(outfh, config.LineWidth)
+				}
 
 				if saveNumFile {
 					names[subject] = []string{string(record.ID)}
 				}
+				checkError(counter.Add(subject))
 			}
 			fastxReader.Close()
 
 			config.LineWidth = lineWidth
 		}
 
+		if sizeOut {
+			order := make([]int, len(reps))
+			for i := range order {
+				order[i] = i
+			}
+			if sortBySize {
+				sort.SliceStable(order, func(i, j int) bool { return sizes[order[i]] > sizes[order[j]] })
+			}
+
+			var outfhCluster *xopen.Writer
+			if clusterFile != "" {
+				outfhCluster, err = xopen.Wopen(clusterFile)
+				checkError(err)
+				defer outfhCluster.Close()
+			}
+			if isFastq {
+				config.LineWidth = 0
+			}
+			var repID string
+			for _, i := range order {
+				record = reps[i]
+				if clusterFile != "" {
+					repID = members[i][0]
+					for _, m := range members[i] {
+						outfhCluster.WriteString(repID + "\t" + m + "\n")
+					}
+				}
+
+				rmdupSizeAnnotate(record, sizes[i])
+				record.FormatToWriter(outfh, config.LineWidth)
+			}
+			config.LineWidth = lineWidth
+		}
+
 		var outfhNum *xopen.Writer
 		if saveNumFile {
 			outfhNum, err = xopen.Wopen(numFile)
 			checkError(err)
 			defer outfhNum.Close()
 		}
+		if !quiet && counter.Spilled() > 0 {
+			log.Infof("%d hash values were spilled to %d temporary file(s)", counter.Spilled(), counter.Runs())
+		}
+
+		if keptFile != nil {
+			checkError(keptFile.Flush())
+			if len(missingFirst) > 0 {
+				checkError(fillFirstNames(keptFileName, dupNames, missingFirst))
+			}
+		}
+
 		if removed > 0 {
 			list := new(listOfStringSlice)
 			for _, l := range names {
@@ -195,6 +603,9 @@ Attention:
 					list.data = append(list.data, l)
 				}
 			}
+			for _, l := range dupNames {
+				list.data = append(list.data, l)
+			}
 			sort.Sort(list)
 			for _, l := range list.data {
 				outfhNum.WriteString(fmt.Sprintf("%d\t%s\n", len(l), strings.Join(l, ", ")))
@@ -202,7 +613,11 @@ Attention:
 		}
 
 		if !quiet {
-			log.Infof("%d duplicated records removed", removed)
+			if paired {
+				log.Infof("%d duplicated read pairs removed", removed)
+			} else {
+				log.Infof("%d duplicated records removed", removed)
+			}
 		}
 	},
 }
@@ -217,6 +632,34 @@ func init() {
 	rmdupCmd.Flags().StringP("dup-num-file", "D", "", "file to save numbers and ID lists of duplicated seqs")
 	// rmdupCmd.Flags().BoolP("consider-revcom", "r", false, "considering the reverse compelment sequence")
 	rmdupCmd.Flags().BoolP("only-positive-strand", "P", false, "only considering positive strand when comparing by sequence")
+	rmdupCmd.Flags().StringP("max-mem", "", "", `approximate maximum memory for hash values, supported units: K, M, G. e.g., 4G. hash values exceeding the cap are spilled to temporary files`)
+	rmdupCmd.Flags().StringP("tmp-dir", "", os.TempDir(), `directory for temporary files, the default value is $TMPDIR`)
+
+	rmdupCmd.Flags().BoolP("size-out", "", false, `append ";size=N" to IDs of representatives, with N being the number of duplicates (including itself)`)
+	rmdupCmd.Flags().BoolP("sort-by-size", "", false, "output representatives in decreasing order of sizes, only for --size-out")
+	rmdupCmd.Flags().StringP("cluster-file", "", "", "file to save IDs of representatives and their members, only for --size-out")
+
+	rmdupCmd.Flags().BoolP("sorted", "", false, `input is sorted, only remove adjacent duplicates with constant memory. type "seqkit rmdup -h" for details`)
+
+	rmdupCmd.Flags().StringP("read1", "1", "", "(gzipped) read1 file, for removing duplicated read pairs")
+	rmdupCmd.Flags().StringP("read2", "2", "", "(gzipped) read2 file, for removing duplicated read pairs")
+	rmdupCmd.Flags().StringP("out-dir", "O", "", "output directory for paired reads")
+	rmdupCmd.Flags().IntP("prefix-len", "", 0, "only compare the first N bases of each mate of read pairs, 0 for whole sequences")
+}
+
+var reRmdupSize = regexp.MustCompile(`;size=\d+`)
+
+// rmdupSizeAnnotate appends ";size=N" to the ID of a record, existing annotations
+// are removed.
+func rmdupSizeAnnotate(record *fastx.Record, size int) {
+	id := bytes.TrimRight(reRmdupSize.ReplaceAll(record.ID, nil), ";")
+	id = []byte(fmt.Sprintf("%s;size=%d", id, size))
+	if i := bytes.Index(record.Name, record.ID); i >= 0 {
+		record.Name = bytes.Join([][]byte{record.Name[:i], id, record.Name[i+len(record.ID):]}, nil)
+	} else {
+		record.Name = id
+	}
+	record.ID = id
 }
 
 type listOfStringSlice struct {
@@ -226,3 +669,35 @@ type listOfStringSlice struct {
 func (l listOfStringSlice) Len() int           { return len(l.data) }
 func (l listOfStringSlice) Less(i, j int) bool { return len(l.data[i]) > len(l.data[j]) }
 func (l listOfStringSlice) Swap(i, j int)      { l.data[i], l.data[j] = l.data[j], l.data[i] }
+
+// fillFirstNames reads names of records spilled to disk, and fills in the first
+// names of duplicated records.
+func fillFirstNames(file string, dupNames map[uint64][]string, missing map[uint64]struct{}) error {
+	fh, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+
+	scanner := bufio.NewScanner(fh)
+	scanner.Buffer(make([]byte, 0, 1<<20), 1<<30)
+	var line string
+	var i int
+	var h uint64
+	for scanner.Scan() {
+		line = scanner.Text()
+		i = strings.IndexByte(line, '\t')
+		if i < 0 {
+			continue
+		}
+		h, err = strconv.ParseUint(line[:i], 10, 64)
+		if err != nil {
+			return err
+		}
+		if _, ok := missing[h]; ok {
+			dupNames[h][0] = line[i+1:]
+			delete(missing, h)
+		}
+	}
+	return scanner.Err()
+}
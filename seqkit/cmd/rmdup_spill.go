@@ -0,0 +1,269 @@
+// Copyright © 2016-2024 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// rmdupBytesPerHash is the estimated memory of a hash value in a Go map.
+const rmdupBytesPerHash = 40
+
+// bloomBitsPerItem and bloomHashes give a false positive rate of about 1%.
+const bloomBitsPerItem = 10
+const bloomHashes = 7
+
+// rmdupMinItems is the minimum number of hash values kept in memory,
+// even if Bloom filters take most of the memory cap.
+const rmdupMinItems = 1024
+
+// spillHashSet is a set of uint64 hash values with a cap of memory, including
+// hash values in memory and Bloom filters of spilled ones.
+// When the cap is reached, values are sorted and written to a temporary file,
+// with a Bloom filter kept in memory to avoid unnecessary disk lookups.
+type spillHashSet struct {
+	maxMem   int64 // 0 for no limit
+	maxItems int   // the current cap of the number of values in memory
+	tmpDir   string
+
+	mem        map[uint64]struct{}
+	dir        string
+	runs       []*hashRun
+	files      []*os.File // files created by CreateFile
+	bloomBytes int64
+
+	spilled int
+
+	// OnSpill is called before values in memory are spilled.
+	OnSpill func()
+
+	mu     sync.Mutex
+	closed bool
+}
+
+// hashRun is a sorted file of hash values, with a Bloom filter.
+type hashRun struct {
+	fh    *os.File
+	n     int64
+	bits  []uint64
+	nbits uint64
+	buf   []byte
+}
+
+func newSpillHashSet(maxMem int64, tmpDir string) (*spillHashSet, error) {
+	if maxMem < 0 {
+		return nil, fmt.Errorf("the maximum memory should not be negative: %d", maxMem)
+	}
+	s := &spillHashSet{
+		maxMem: maxMem,
+		tmpDir: tmpDir,
+		mem:    make(map[uint64]struct{}, 1024),
+	}
+	s.updateMaxItems()
+	return s, nil
+}
+
+// updateMaxItems computes the cap of the number of values in memory from
+// the memory cap and the memory occupied by Bloom filters.
+func (s *spillHashSet) updateMaxItems() {
+	if s.maxMem == 0 {
+		return
+	}
+	s.maxItems = int((s.maxMem - s.bloomBytes) / rmdupBytesPerHash)
+	if s.maxItems < rmdupMinItems {
+		s.maxItems = rmdupMinItems
+	}
+}
+
+// Has checks if a hash value exists.
+func (s *spillHashSet) Has(h uint64) bool {
+	if _, ok := s.mem[h]; ok {
+		return true
+	}
+	for _, r := range s.runs {
+		if r.has(h) {
+			return true
+		}
+	}
+	return false
+}
+
+// Add adds a hash value, and spills values to disk if the cap is reached.
+func (s *spillHashSet) Add(h uint64) error {
+	s.mem[h] = struct{}{}
+	if s.maxItems > 0 && len(s.mem) >= s.maxItems {
+		return s.spill()
+	}
+	return nil
+}
+
+// Spilled returns the number of values spilled to disk.
+func (s *spillHashSet) Spilled() int { return s.spilled }
+
+// Runs returns the number of temporary files of hash values.
+func (s *spillHashSet) Runs() int { return len(s.runs) }
+
+func (s *spillHashSet) ensureDir() error {
+	if s.dir != "" {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	dir, err := os.MkdirTemp(s.tmpDir, "seqkit-rmdup-")
+	if err != nil {
+		return err
+	}
+	s.dir = dir
+	addCleanup(func() { s.Close() })
+	return nil
+}
+
+// CreateFile creates a temporary file which will be removed in Close.
+func (s *spillHashSet) CreateFile(name string) (string, *bufio.Writer, error) {
+	if err := s.ensureDir(); err != nil {
+		return "", nil, err
+	}
+	file := filepath.Join(s.dir, name)
+	fh, err := os.Create(file)
+	if err != nil {
+		return "", nil, err
+	}
+	s.files = append(s.files, fh)
+	return file, bufio.NewWriterSize(fh, os.Getpagesize()*64), nil
+}
+
+func (s *spillHashSet) spill() error {
+	if s.OnSpill != nil {
+		s.OnSpill()
+	}
+	if err := s.ensureDir(); err != nil {
+		return err
+	}
+
+	hashes := make([]uint64, 0, len(s.mem))
+	for h := range s.mem {
+		hashes = append(hashes, h)
+	}
+	sort.Slice(hashes, func(i, j int) bool { return hashes[i] < hashes[j] })
+
+	fh, err := os.Create(filepath.Join(s.dir, fmt.Sprintf("hashes.%d", len(s.runs))))
+	if err != nil {
+		return err
+	}
+
+	nbits := uint64(len(hashes)) * bloomBitsPerItem
+	r := &hashRun{
+		fh:    fh,
+		n:     int64(len(hashes)),
+		bits:  make([]uint64, (nbits+63)/64),
+		nbits: nbits,
+		buf:   make([]byte, 8),
+	}
+
+	w := bufio.NewWriterSize(fh, os.Getpagesize()*64)
+	buf := make([]byte, 8)
+	for _, h := range hashes {
+		binary.LittleEndian.PutUint64(buf, h)
+		if _, err = w.Write(buf); err != nil {
+			return err
+		}
+		r.bloomAdd(h)
+	}
+	if err = w.Flush(); err != nil {
+		return err
+	}
+
+	s.runs = append(s.runs, r)
+	s.spilled += len(hashes)
+	s.bloomBytes += int64(len(r.bits)) * 8
+	s.updateMaxItems()
+	s.mem = make(map[uint64]struct{}, 1024)
+	return nil
+}
+
+// Close closes and removes all temporary files.
+func (s *spillHashSet) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	for _, r := range s.runs {
+		r.fh.Close()
+	}
+	for _, fh := range s.files {
+		fh.Close()
+	}
+	if s.dir != "" {
+		return os.RemoveAll(s.dir)
+	}
+	return nil
+}
+
+// the two hash functions for double hashing.
+func bloomLocation(h uint64, i int, nbits uint64) uint64 {
+	h2 := (h>>33 | h<<31) * 0x9e3779b97f4a7c15
+	return (h + uint64(i)*(h2|1)) % nbits
+}
+
+func (r *hashRun) bloomAdd(h uint64) {
+	var loc uint64
+	for i := 0; i < bloomHashes; i++ {
+		loc = bloomLocation(h, i, r.nbits)
+		r.bits[loc>>6] |= 1 << (loc & 63)
+	}
+}
+
+func (r *hashRun) has(h uint64) bool {
+	var loc uint64
+	for i := 0; i < bloomHashes; i++ {
+		loc = bloomLocation(h, i, r.nbits)
+		if r.bits[loc>>6]&(1<<(loc&63)) == 0 {
+			return false
+		}
+	}
+
+	// binary search in the file
+	var lo, hi, mid int64 = 0, r.n - 1, 0
+	var v uint64
+	for lo <= hi {
+		mid = (lo + hi) >> 1
+		if _, err := r.fh.ReadAt(r.buf, mid<<3); err != nil {
+			checkError(fmt.Errorf("failed to read temporary file %s: %s", r.fh.Name(), err))
+		}
+		v = binary.LittleEndian.Uint64(r.buf)
+		if v == h {
+			return true
+		} else if v < h {
+			lo = mid + 1
+		} else {
+			hi = mid - 1
+		}
+	}
+	return false
+}
@@ -64,7 +64,25 @@ Compression level:
   zstd     1-4     2        roughly equals to zstd 1, 3, 7, 11, respectively.
   bzip     1-9     6        https://github.com/dsnet/compress
 
+Benchmark mode (--benchmark):
+  The elapsed time, number of records processed, sizes of input and output
+  files, and throughput are printed to stderr on completion, unless --quiet
+  is given. Output is not changed.
+  1. Records are counted by commands while reading the input, a read pair
+     is counted as two records. Records read again in a second pass, e.g.,
+     by "seqkit common", are not counted twice. Note that some commands,
+     e.g., "seqkit head", may stop before reading the whole input.
+  2. Input size is the size of input files on disk (compressed size for
+     compressed files), output size is that of the file given by -o/--out-file.
+     The throughput is the number or size divided by the elapsed time.
+  3. "NA" is reported for stdin/stdout and other inputs/outputs that are
+     not regular files, and for records of commands not processing records
+     one by one, e.g., "seqkit faidx" and "seqkit bam".
+
 `, VERSION),
+
+	PersistentPreRun:  benchmarkStart,
+	PersistentPostRun: benchmarkReport,
 }
 
 // Execute adds all child commands to the root command sets flags appropriately.
@@ -135,6 +153,7 @@ func init() {
 	RootCmd.PersistentFlags().BoolP("quiet", "", false, "be quiet and do not show extra information")
 	RootCmd.PersistentFlags().IntP("alphabet-guess-seq-length", "", 10000, "length of sequence prefix of the first FASTA record based on which seqkit guesses the sequence type (0 for whole seq)")
 	RootCmd.PersistentFlags().StringP("infile-list", "X", "", "file of input files list (one file per line), if given, they are appended to files from cli arguments")
+	RootCmd.PersistentFlags().BoolP("benchmark", "", false, `report elapsed time, records processed, input/output bytes and throughput to stderr on completion. type "seqkit -h" for details`)
 	RootCmd.PersistentFlags().IntP("compress-level", "", -1, `compression level for gzip, zstd, xz and bzip2. type "seqkit -h" for the range and default value for each format`)
 
 	RootCmd.CompletionOptions.DisableDefaultCmd = true
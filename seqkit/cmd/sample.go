@@ -21,10 +21,21 @@
 package cmd
 
 import (
+	"bufio"
+	"bytes"
 	"fmt"
 	"io"
 	"math/rand"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
 	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/shenwei356/bio/seq"
 	"github.com/shenwei356/bio/seqio/fastx"
@@ -44,12 +55,82 @@ Attention:
 1. Do not use '-n' on large FASTQ files, it loads all seqs into memory!
    use 'seqkit sample -p 0.1 seqs.fq.gz | seqkit head -n N' instead!
 
+Sampling the first N records of every group (--per-group N):
+  1. Groups are captured from sequence headers by --group-regexp, e.g.,
+     '^(\S+?)_\d+' for IDs like "sampleA_1". Records not matching the
+     regular expression are skipped. No random sampling is performed.
+  2. Use --groups to only output records of given groups.
+  3. With --early-stop and --groups, reading stops once all given groups
+     are satisfied, i.e., having N records. Groups with fewer than N records
+     are only complete at the end of the file, and the whole file may be
+     scanned. If the input is sorted by group (records of a group are
+     adjacent), use --sorted to also consider a group complete when the next
+     group starts, so reading stops right after the last given group.
+     If a group reappears with --sorted, the input is not sorted, and the
+     shortcut is disabled with a warning.
+
+Sampling by per-record probabilities (--prob-file FILE):
+  1. FILE is a tab-delimited file of sequence IDs and probabilities in the
+     range of [0, 1], e.g., "read_1<TAB>0.3". Blank lines and lines starting
+     with "#" are ignored. Malformed lines are reported with line numbers.
+  2. Each record is kept with the probability of its ID, or the value of
+     --default-prob if the ID is absent in the file (default 0, discarding).
+  3. Records are streamed in one pass, seeded by -s/--rand-seed.
+
+Partitioning records into K folds (--folds K), e.g., for cross-validation:
+  1. Every record is assigned to one of K files, so the files are disjoint
+     and together contain all records. Records are assigned in consecutive
+     blocks of K records, with the folds randomly permuted in each block,
+     so fold sizes differ by at most one. Assignment is seeded by
+     -s/--rand-seed, the same seed gives the same partition.
+  2. Output files are named <out-prefix>_<i><ext>, where i is 1-based and
+     ext is the extension of the input file, e.g., "fold_1.fq.gz".
+     For stdin, the extension is ".fasta" or ".fastq".
+  3. For paired-end reads, use --read1 and --read2. Pairs are kept together
+     in outputs of <out-prefix>_<i>_R1<ext> and <out-prefix>_<i>_R2<ext>.
+     Reads in the two files must be in the same order with the same IDs,
+     where the mate suffixes '/1' and '/2' are ignored. Positional arguments
+     are not allowed.
+  4. Records are streamed in one pass, and -o/--out-file is ignored.
+
+Sampling to an approximate output size (--target-size SIZE), e.g., 100M:
+  1. The size refers to the uncompressed output stream, i.e., sampled records
+     formatted with -w/--line-width, before being compressed according to
+     the suffix of -o/--out-file.
+  2. Records are sampled by the proportion of the target size to the total
+     size of the input. The total size is the file size for plain files,
+     while compressed files (.gz, .xz, .zst, .bz2) are read twice, with records
+     counted in the first pass. Stdin is not supported.
+  3. The achieved size is reported unless --quiet is given. It is close to,
+     but not exactly the target, and the whole input is outputted if the
+     target size is larger than the input.
+
+Time-bounded sampling (--duration), e.g., for live streams:
+  1. Records are read and sampled until the wall-clock duration elapses,
+     the end of the input, or receiving SIGINT (Ctrl-C) or SIGTERM, whichever
+     comes first. The duration is in the format of Go, e.g., 30s, 5m, 1h30m.
+  2. With -n/--number N, a reservoir of N records is kept in memory, so every
+     record read has the same probability of being kept, and the reservoir
+     is outputted on stopping, in the input order. With -p/--proportion,
+     sampled records are outputted immediately.
+  3. Only complete records are outputted, the record being read on stopping
+     is discarded. -2/--two-pass is not supported.
+
 `,
 	Run: func(cmd *cobra.Command, args []string) {
 		if len(args) > 1 {
 			checkError(fmt.Errorf("no more than one file needed (%d)", len(args)))
 		}
 
+		// signals are caught before any I/O, so that SIGINT/SIGTERM received
+		// before the first record stops sampling instead of killing the process
+		var sigChan chan os.Signal
+		if getFlagString(cmd, "duration") != "" {
+			sigChan = make(chan os.Signal, 1)
+			signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+			defer signal.Stop(sigChan)
+		}
+
 		config := getConfigs(cmd)
 		alphabet := config.Alphabet
 		idRegexp := config.IDRegexp
@@ -60,6 +141,7 @@ Attention:
 		seq.ValidateSeq = false
 		runtime.GOMAXPROCS(config.Threads)
 
+		bench := getBenchmarkStats(cmd)
 		files := getFileListFromArgsAndFile(cmd, args, true, "infile-list", true)
 
 		seed := getFlagInt64(cmd, "rand-seed")
@@ -69,6 +151,183 @@ Attention:
 
 		file := files[0]
 
+		perGroup := getFlagNonNegativeInt(cmd, "per-group")
+
+		durationS := getFlagString(cmd, "duration")
+		if durationS != "" && (getFlagNonNegativeInt(cmd, "folds") > 0 || getFlagString(cmd, "prob-file") != "" ||
+			perGroup > 0 || getFlagString(cmd, "target-size") != "") {
+			checkError(fmt.Errorf("flag --duration is not compatible with --folds, --prob-file, --per-group and --target-size"))
+		}
+
+		folds := getFlagNonNegativeInt(cmd, "folds")
+		read1 := getFlagString(cmd, "read1")
+		read2 := getFlagString(cmd, "read2")
+		if (read1 != "") != (read2 != "") {
+			checkError(fmt.Errorf("flags --read1 and --read2 should be given together"))
+		}
+		if read1 != "" && folds == 0 {
+			checkError(fmt.Errorf("flags --read1 and --read2 are only supported with --folds"))
+		}
+		if read1 != "" && len(args) > 0 {
+			checkError(fmt.Errorf("no positional arguments are allowed for paired reads: %s", strings.Join(args, " ")))
+		}
+		if folds > 0 {
+			if number > 0 || proportion > 0 || twoPass || perGroup > 0 || getFlagString(cmd, "prob-file") != "" {
+				checkError(fmt.Errorf("flag --folds is not compatible with -n (--number), -p (--proportion), -2 (--two-pass), --per-group and --prob-file"))
+			}
+			if folds < 2 {
+				checkError(fmt.Errorf("value of --folds should be at least 2: %d", folds))
+			}
+			outPrefix := getFlagString(cmd, "out-prefix")
+			if outPrefix == "" {
+				checkError(fmt.Errorf("value of --out-prefix should not be empty"))
+			}
+
+			inputs := []string{file}
+			if read1 != "" {
+				inputs = []string{read1, read2}
+			}
+			bench.addInputs(inputs...)
+			counts := sampleFolds(inputs, alphabet, idRegexp, config.LineWidth, folds, outPrefix, seed, bench)
+
+			if !quiet {
+				var total int64
+				for i, c := range counts {
+					log.Infof("fold %d: %d records", i+1, c)
+					total += c
+				}
+				if read1 != "" {
+					log.Infof("%d read pairs partitioned into %d folds", total, folds)
+				} else {
+					log.Infof("%d sequences partitioned into %d folds", total, folds)
+				}
+			}
+			return
+		}
+
+		bench.addInputs(file)
+
+		probFile := getFlagString(cmd, "prob-file")
+		if probFile != "" {
+			if number > 0 || proportion > 0 || twoPass || perGroup > 0 {
+				checkError(fmt.Errorf("flag --prob-file is not compatible with -n (--number), -p (--proportion), -2 (--two-pass) and --per-group"))
+			}
+			defaultProb := getFlagFloat64(cmd, "default-prob")
+			if !(defaultProb >= 0 && defaultProb <= 1) {
+				checkError(fmt.Errorf("value of --default-prob (%f) should be in range of [0, 1]", defaultProb))
+			}
+
+			probs, err := readSampleProbs(probFile)
+			checkError(err)
+			if !quiet {
+				log.Infof("%d probabilities loaded from %s", len(probs), probFile)
+			}
+
+			outfh, err := xopen.Wopen(outFile)
+			checkError(err)
+			defer outfh.Close()
+
+			rand.Seed(seed)
+
+			var n, total int64
+			var record *fastx.Record
+			var prob float64
+			var ok bool
+			fastxReader, err := fastx.NewReader(alphabet, file, idRegexp)
+			checkError(err)
+			for {
+				record, err = fastxReader.Read()
+				if err != nil {
+					if err == io.EOF {
+						break
+					}
+					checkError(err)
+					break
+				}
+				bench.addRecord()
+				if fastxReader.IsFastq {
+					config.LineWidth = 0
+					fastx.ForcelyOutputFastq = true
+				}
+				total++
+
+				if prob, ok = probs[string(record.ID)]; !ok {
+					prob = defaultProb
+				}
+				if rand.Float64() < prob {
+					n++
+					record.FormatToWriter(outfh, config.LineWidth)
+				}
+			}
+			fastxReader.Close()
+
+			if !quiet {
+				log.Infof("%d of %d sequences outputted", n, total)
+			}
+			return
+		}
+
+		if perGroup > 0 {
+			if number > 0 || proportion > 0 || twoPass {
+				checkError(fmt.Errorf("flag --per-group is not compatible with -n (--number), -p (--proportion) and -2 (--two-pass)"))
+			}
+			groupRegexp := getFlagString(cmd, "group-regexp")
+			if groupRegexp == "" {
+				checkError(fmt.Errorf("flag --group-regexp needed when using --per-group"))
+			}
+			if !regexp.MustCompile(`\(.+\)`).MatchString(groupRegexp) {
+				checkError(fmt.Errorf(`value of flag --group-regexp must contain "(" and ")" to capture the group`))
+			}
+			reGroup, err := regexp.Compile(groupRegexp)
+			checkError(err)
+
+			groups := getFlagStringSlice(cmd, "groups")
+			earlyStop := getFlagBool(cmd, "early-stop")
+			if earlyStop && len(groups) == 0 {
+				checkError(fmt.Errorf("flag --groups needed when using --early-stop"))
+			}
+			sortedByGroup := getFlagBool(cmd, "sorted")
+			if sortedByGroup && !earlyStop {
+				checkError(fmt.Errorf("flag --early-stop needed when using --sorted"))
+			}
+
+			outfh, err := xopen.Wopen(outFile)
+			checkError(err)
+			defer outfh.Close()
+
+			n := samplePerGroup(outfh, file, alphabet, idRegexp, config.LineWidth, !quiet,
+				reGroup, perGroup, groups, earlyStop, sortedByGroup, bench)
+
+			if !quiet {
+				log.Infof("%d sequences outputted", n)
+			}
+			return
+		}
+
+		targetSizeS := getFlagString(cmd, "target-size")
+		if targetSizeS != "" {
+			if number > 0 || proportion > 0 || twoPass {
+				checkError(fmt.Errorf("flag --target-size is not compatible with -n (--number), -p (--proportion) and -2 (--two-pass)"))
+			}
+			targetSize, err := ParseByteSize(targetSizeS)
+			if err != nil || targetSize <= 0 {
+				checkError(fmt.Errorf("invalid value of flag --target-size, a positive size expected: %s", targetSizeS))
+			}
+			if isStdin(file) {
+				checkError(fmt.Errorf("flag --target-size does not support reading from stdin"))
+			}
+
+			outfh, err := xopen.Wopen(outFile)
+			checkError(err)
+			defer outfh.Close()
+
+			n, size := sampleToSize(outfh, file, alphabet, idRegexp, config.LineWidth, targetSize, seed, quiet, bench)
+			if !quiet {
+				log.Infof("%d sequences outputted, with %d bytes (target: %d bytes)", n, size, targetSize)
+			}
+			return
+		}
+
 		if twoPass && isStdin(file) {
 			checkError(fmt.Errorf("two-pass mode (-2) will failed when reading from stdin. please disable flag: -2"))
 		}
@@ -84,6 +343,28 @@ Attention:
 			checkError(fmt.Errorf("value of -p (--proportion) (%f) should be in range of (0, 1]", proportion))
 		}
 
+		if durationS != "" {
+			if twoPass {
+				checkError(fmt.Errorf("flag --duration is not compatible with -2 (--two-pass)"))
+			}
+			duration, err := time.ParseDuration(durationS)
+			if err != nil || duration <= 0 {
+				checkError(fmt.Errorf("invalid value of flag --duration, a positive duration expected, e.g., 30s: %s", durationS))
+			}
+
+			outfh, err := xopen.Wopen(outFile)
+			checkError(err)
+			defer outfh.Close()
+
+			n, total, reason, err := sampleForDuration(outfh, file, alphabet, idRegexp, config.LineWidth,
+				number, proportion, duration, seed, sigChan, bench)
+			checkError(err)
+			if !quiet {
+				log.Infof("%d of %d sequences outputted, stopped by %s", n, total, reason)
+			}
+			return
+		}
+
 		outfh, err := xopen.Wopen(outFile)
 		checkError(err)
 		defer outfh.Close()
@@ -128,6 +409,7 @@ Attention:
 						checkError(err)
 						break
 					}
+					bench.addRecord()
 					if fastxReader.IsFastq {
 						config.LineWidth = 0
 						fastx.ForcelyOutputFastq = true
@@ -149,6 +431,7 @@ Attention:
 				}
 				records, err := fastx.GetSeqs(file, alphabet, config.Threads, 10, idRegexp)
 				checkError(err)
+				bench.addRecords(int64(len(records)))
 
 				if len(records) > 0 && len(records[0].Seq.Qual) > 0 {
 					config.LineWidth = 0
@@ -183,6 +466,7 @@ Attention:
 					checkError(err)
 					break
 				}
+				bench.addRecord()
 				if fastxReader.IsFastq {
 					config.LineWidth = 0
 					fastx.ForcelyOutputFastq = true
@@ -210,4 +494,475 @@ func init() {
 	sampleCmd.Flags().Int64P("number", "n", 0, "sample by number (result may not exactly match), DO NOT use on large FASTQ files.")
 	sampleCmd.Flags().Float64P("proportion", "p", 0, "sample by proportion")
 	sampleCmd.Flags().BoolP("two-pass", "2", false, "2-pass mode read files twice to lower memory usage. Not allowed when reading from stdin")
+	sampleCmd.Flags().IntP("per-group", "", 0, "output the first N records of every group captured by --group-regexp")
+	sampleCmd.Flags().StringP("group-regexp", "", "", `regular expression for capturing groups from sequence headers, e.g., '^(\S+?)_\d+'`)
+	sampleCmd.Flags().StringSliceP("groups", "", []string{}, "only output records of these groups, multiple values supported, e.g., --groups A,B")
+	sampleCmd.Flags().StringP("prob-file", "", "", "tab-delimited file of sequence IDs and probabilities for keeping each record")
+	sampleCmd.Flags().Float64P("default-prob", "", 0, "probability for records whose IDs are not in the file given by --prob-file")
+	sampleCmd.Flags().StringP("target-size", "", "", `sample to approximately this size of the uncompressed output, supported units: K, M, G. e.g., 100M`)
+	sampleCmd.Flags().IntP("folds", "", 0, "partition records randomly into K disjoint files of nearly equal sizes")
+	sampleCmd.Flags().StringP("out-prefix", "", "fold", "prefix of output files for --folds, which could contain a directory")
+	sampleCmd.Flags().StringP("read1", "", "", "(gzipped) read1 file, for --folds only")
+	sampleCmd.Flags().StringP("read2", "", "", "(gzipped) read2 file, for --folds only")
+	sampleCmd.Flags().StringP("duration", "", "", `keep reading and sampling for this wall-clock duration and then stop, e.g., 30s. type "seqkit sample -h" for details`)
+	sampleCmd.Flags().BoolP("early-stop", "", false, "stop reading once all groups given by --groups are complete, best for input sorted by group")
+	sampleCmd.Flags().BoolP("sorted", "", false, "input is sorted by group, a group is complete when the next group starts, for --early-stop")
+}
+
+// samplePerGroup outputs the first N records of every group, and returns the number of outputted records.
+func samplePerGroup(outfh *xopen.Writer, file string, alphabet *seq.Alphabet, idRegexp string, lineWidth int, verbose bool,
+	reGroup *regexp.Regexp, perGroup int, groups []string, earlyStop bool, sortedByGroup bool, bench *benchmarkStats) int64 {
+
+	counts := make(map[string]int, 1024)
+
+	// groups to output
+	wanted := make(map[string]bool, len(groups))
+	for _, g := range groups {
+		wanted[g] = true
+	}
+	filter := len(wanted) > 0
+	pending := len(wanted) // wanted groups which are not complete yet
+
+	var sorted = sortedByGroup
+	var current string
+	var closed = make(map[string]bool, 1024) // groups which have ended
+
+	complete := func(g string) {
+		if wanted[g] {
+			wanted[g] = false
+			pending--
+		}
+	}
+
+	fastxReader, err := fastx.NewReader(alphabet, file, idRegexp)
+	checkError(err)
+	defer fastxReader.Close()
+
+	var n int64
+	var record *fastx.Record
+	var found [][]byte
+	var g string
+	var c int
+	var unmatched int
+	for {
+		record, err = fastxReader.Read()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			checkError(err)
+			break
+		}
+		bench.addRecord()
+		if fastxReader.IsFastq {
+			lineWidth = 0
+			fastx.ForcelyOutputFastq = true
+		}
+
+		found = reGroup.FindSubmatch(record.Name)
+		if found == nil {
+			unmatched++
+			continue
+		}
+		g = string(found[1])
+
+		if g != current {
+			if sorted {
+				if closed[g] {
+					sorted = false
+					if verbose {
+						log.Warningf("input is not sorted by group (group '%s' reappears), groups are only complete when satisfied", g)
+					}
+				} else if current != "" {
+					closed[current] = true
+					complete(current)
+				}
+			}
+			current = g
+
+			if earlyStop && pending == 0 {
+				break
+			}
+		}
+
+		if filter {
+			if _, ok := wanted[g]; !ok {
+				continue
+			}
+		}
+
+		c = counts[g]
+		if c >= perGroup {
+			continue
+		}
+		c++
+		counts[g] = c
+
+		record.FormatToWriter(outfh, lineWidth)
+		n++
+
+		if c == perGroup {
+			complete(g)
+			if earlyStop && pending == 0 {
+				break
+			}
+		}
+	}
+
+	if unmatched > 0 && verbose {
+		log.Warningf("%d records not matching the group regular expression were skipped", unmatched)
+	}
+	return n
+}
+
+// sampleFolds partitions records of one file, or read pairs of two files,
+// into K files, and returns the numbers of records in each fold.
+func sampleFolds(files []string, alphabet *seq.Alphabet, idRegexp string, lineWidth int,
+	folds int, outPrefix string, seed int64, bench *benchmarkStats) []int64 {
+
+	paired := len(files) == 2
+	readers := make([]*fastx.Reader, len(files))
+	var err error
+	for i, file := range files {
+		readers[i], err = fastx.NewReader(alphabet, file, idRegexp)
+		checkError(err)
+		defer readers[i].Close()
+	}
+
+	if dir := filepath.Dir(outPrefix); dir != "." {
+		checkError(os.MkdirAll(dir, 0755))
+	}
+
+	// outfhs[fold][mate]
+	outfhs := make([][]*xopen.Writer, folds)
+	openOutputs := func(isFastq bool) {
+		var ext string
+		if isStdin(files[0]) {
+			if isFastq {
+				ext = suffixFQ
+			} else {
+				ext = suffixFA
+			}
+		} else {
+			_, ext = filepathTrimExtension(files[0])
+		}
+		for i := range outfhs {
+			outfhs[i] = make([]*xopen.Writer, len(files))
+			for j := range files {
+				var outfile string
+				if paired {
+					outfile = fmt.Sprintf("%s_%d_R%d%s", outPrefix, i+1, j+1, ext)
+				} else {
+					outfile = fmt.Sprintf("%s_%d%s", outPrefix, i+1, ext)
+				}
+				outfhs[i][j], err = xopen.Wopen(outfile)
+				checkError(err)
+			}
+		}
+	}
+
+	r := rand.New(rand.NewSource(seed))
+	perm := make([]int, folds)
+	counts := make([]int64, folds)
+	records := make([]*fastx.Record, len(files))
+	var i, j, k int
+	var n int64
+	for {
+		for j = range readers {
+			records[j], err = readers[j].Read()
+			if err != nil {
+				if err != io.EOF {
+					checkError(err)
+				}
+				records[j] = nil
+			}
+		}
+		if records[0] == nil {
+			if paired && records[1] != nil {
+				checkError(fmt.Errorf("%s has more reads than %s", files[1], files[0]))
+			}
+			break
+		}
+		if paired {
+			if records[1] == nil {
+				checkError(fmt.Errorf("%s has more reads than %s", files[0], files[1]))
+			}
+			if !bytes.Equal(mateBaseName(records[0].ID), mateBaseName(records[1].ID)) {
+				checkError(fmt.Errorf("IDs of read pair %d not matched: %s, %s", n+1, records[0].ID, records[1].ID))
+			}
+		}
+		bench.addRecords(int64(len(records)))
+
+		if n == 0 {
+			if readers[0].IsFastq {
+				lineWidth = 0
+				fastx.ForcelyOutputFastq = true
+			}
+			openOutputs(readers[0].IsFastq)
+		}
+
+		// a new block of K records
+		k = int(n % int64(folds))
+		if k == 0 {
+			for i = range perm {
+				perm[i] = i
+			}
+			r.Shuffle(folds, func(a, b int) { perm[a], perm[b] = perm[b], perm[a] })
+		}
+		i = perm[k]
+
+		for j = range records {
+			records[j].FormatToWriter(outfhs[i][j], lineWidth)
+		}
+		counts[i]++
+		n++
+	}
+
+	if n == 0 {
+		openOutputs(false)
+	}
+	for i = range outfhs {
+		for j = range outfhs[i] {
+			checkError(outfhs[i][j].Close())
+		}
+	}
+	return counts
+}
+
+// readSampleProbs reads a tab-delimited file of sequence IDs and probabilities.
+func readSampleProbs(file string) (map[string]float64, error) {
+	fh, err := xopen.Ropen(file)
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+
+	probs := make(map[string]float64, 1024)
+	scanner := bufio.NewScanner(fh)
+	scanner.Buffer(make([]byte, 0, 64<<10), 1<<26)
+	var line string
+	var items []string
+	var prob float64
+	var i int
+	for scanner.Scan() {
+		i++
+		line = strings.TrimRight(scanner.Text(), "\r")
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		items = strings.Split(line, "\t")
+		if len(items) < 2 {
+			return nil, fmt.Errorf("%s: line %d: two tab-delimited columns expected: %s", file, i, line)
+		}
+		prob, err = strconv.ParseFloat(strings.TrimSpace(items[1]), 64)
+		if err != nil || !(prob >= 0 && prob <= 1) {
+			return nil, fmt.Errorf("%s: line %d: invalid probability, a number in range of [0, 1] expected: %s", file, i, items[1])
+		}
+		if _, ok := probs[items[0]]; ok {
+			return nil, fmt.Errorf("%s: line %d: duplicated sequence ID: %s", file, i, items[0])
+		}
+		probs[items[0]] = prob
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+	return probs, nil
+}
+
+// sampleRecordSize returns the size of a record formatted with the line width.
+func sampleRecordSize(record *fastx.Record, lineWidth int, isFastq bool) int64 {
+	l := len(record.Seq.Seq)
+	if isFastq {
+		return int64(len(record.Name) + 2*l + 6) // "@", "+" and four "\n"
+	}
+	lines := 1
+	if lineWidth > 0 && l > 0 {
+		lines = (l + lineWidth - 1) / lineWidth
+	}
+	return int64(len(record.Name) + l + lines + 2) // ">" and "\n"s
+}
+
+// sampleToSize samples records to approximately the target size of output,
+// and returns the number and total size of outputted records.
+func sampleToSize(outfh *xopen.Writer, file string, alphabet *seq.Alphabet, idRegexp string,
+	lineWidth int, targetSize int64, seed int64, quiet bool, bench *benchmarkStats) (int64, int64) {
+
+	var total int64
+	var err error
+	var record *fastx.Record
+	var fastxReader *fastx.Reader
+	var compressed bool
+	lower := strings.ToLower(file)
+	for _, suffix := range []string{".gz", ".xz", ".zst", ".bz2"} {
+		if strings.HasSuffix(lower, suffix) {
+			compressed = true
+			break
+		}
+	}
+
+	if compressed {
+		if !quiet {
+			log.Info("first pass: counting the size of the compressed input")
+		}
+		fastxReader, err = fastx.NewReader(alphabet, file, idRegexp)
+		checkError(err)
+		w := lineWidth
+		for {
+			record, err = fastxReader.Read()
+			if err != nil {
+				if err == io.EOF {
+					break
+				}
+				checkError(err)
+				break
+			}
+			if fastxReader.IsFastq {
+				w = 0
+			}
+			total += sampleRecordSize(record, w, fastxReader.IsFastq)
+		}
+		fastxReader.Close()
+	} else {
+		fi, err := os.Stat(file)
+		checkError(err)
+		total = fi.Size()
+	}
+
+	proportion := 1.0
+	if total > targetSize {
+		proportion = float64(targetSize) / float64(total)
+	}
+	if !quiet {
+		log.Infof("input size: %d bytes, sample by proportion: %f", total, proportion)
+	}
+
+	r := rand.New(rand.NewSource(seed))
+	var n, size int64
+	fastxReader, err = fastx.NewReader(alphabet, file, idRegexp)
+	checkError(err)
+	for {
+		record, err = fastxReader.Read()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			checkError(err)
+			break
+		}
+		bench.addRecord()
+		if fastxReader.IsFastq {
+			lineWidth = 0
+			fastx.ForcelyOutputFastq = true
+		}
+
+		if r.Float64() <= proportion {
+			n++
+			size += sampleRecordSize(record, lineWidth, fastxReader.IsFastq)
+			record.FormatToWriter(outfh, lineWidth)
+		}
+	}
+	fastxReader.Close()
+
+	return n, size
+}
+
+// sampleRead is a record or an error sent by the reading goroutine.
+type sampleRead struct {
+	record  *fastx.Record
+	isFastq bool
+	err     error
+}
+
+// sampleForDuration samples records by number (reservoir sampling) or
+// proportion, until the duration elapses, the end of input, or receiving
+// SIGINT/SIGTERM from sigChan. It returns the numbers of outputted and read records,
+// and the reason of stopping.
+func sampleForDuration(outfh *xopen.Writer, file string, alphabet *seq.Alphabet, idRegexp string,
+	lineWidth int, number int64, proportion float64, duration time.Duration, seed int64,
+	sigChan <-chan os.Signal, bench *benchmarkStats) (int64, int64, string, error) {
+
+	fastxReader, err := fastx.NewReader(alphabet, file, idRegexp)
+	if err != nil {
+		return 0, 0, "", err
+	}
+
+	// records are read in another goroutine, as reading could be blocked
+	// by live streams. The goroutine is abandoned on stopping.
+	ch := make(chan sampleRead, 64)
+	go func() {
+		for {
+			record, err := fastxReader.Read()
+			if err != nil {
+				ch <- sampleRead{err: err}
+				return
+			}
+			bench.addRecord()
+			ch <- sampleRead{record: record.Clone(), isFastq: fastxReader.IsFastq}
+		}
+	}()
+
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+
+	r := rand.New(rand.NewSource(seed))
+
+	// reservoir for sampling by number
+	type indexedRecord struct {
+		idx    int64
+		record *fastx.Record
+	}
+	var reservoir []indexedRecord
+	if number > 0 {
+		reservoir = make([]indexedRecord, 0, number)
+	}
+
+	var n, total, j int64
+	var reason string
+	var read sampleRead
+LOOP:
+	for {
+		select {
+		case <-timer.C:
+			reason = "timeout"
+			break LOOP
+		case <-sigChan:
+			reason = "signal"
+			break LOOP
+		case read = <-ch:
+		}
+
+		if read.err != nil {
+			if read.err == io.EOF {
+				reason = "end of input"
+				fastxReader.Close()
+				break LOOP
+			}
+			return n, total, "", read.err
+		}
+		if read.isFastq {
+			lineWidth = 0
+			fastx.ForcelyOutputFastq = true
+		}
+
+		if number > 0 {
+			if total < number {
+				reservoir = append(reservoir, indexedRecord{total, read.record})
+			} else if j = r.Int63n(total + 1); j < number {
+				reservoir[j] = indexedRecord{total, read.record}
+			}
+		} else if r.Float64() <= proportion {
+			n++
+			read.record.FormatToWriter(outfh, lineWidth)
+		}
+		total++
+	}
+
+	if number > 0 {
+		sort.Slice(reservoir, func(i, j int) bool { return reservoir[i].idx < reservoir[j].idx })
+		for _, ir := range reservoir {
+			ir.record.FormatToWriter(outfh, lineWidth)
+		}
+		n = int64(len(reservoir))
+	}
+	return n, total, reason, nil
 }
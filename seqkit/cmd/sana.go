@@ -87,7 +87,9 @@ Sana currently supports this FASTQ dialect:
 		allowGaps := getFlagBool(cmd, "allow-gaps")
 		runtime.GOMAXPROCS(config.Threads)
 
+		bench := getBenchmarkStats(cmd)
 		files := getFileListFromArgsAndFile(cmd, args, true, "infile-list", true)
+		bench.addInputs(files...)
 
 		outfh, err := xopen.Wopen(outFile)
 		checkError(err)
@@ -120,6 +122,7 @@ Sana currently supports this FASTQ dialect:
 				switch rawSeq.Err {
 				case nil:
 					pass++
+					bench.addRecord()
 					outfh.WriteString(rawSeq.Format(outFmt) + "\n")
 				default:
 					fail++
@@ -0,0 +1,268 @@
+// Copyright © 2016-2024 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
+
+	"github.com/shenwei356/bio/seq"
+	"github.com/shenwei356/bio/seqio/fastx"
+	"github.com/shenwei356/xopen"
+	"github.com/spf13/cobra"
+)
+
+// sanitizeIDCmd represents the sanitize-id command
+var sanitizeIDCmd = &cobra.Command{
+	GroupID: "edit",
+
+	Use:     "sanitize-id",
+	Aliases: []string{"fix-id"},
+	Short:   "sanitize sequence IDs for compatibility with other tools",
+	Long: `sanitize sequence IDs for compatibility with other tools
+
+Steps for each record:
+  1. Characters in -c/--chars, spaces, non-printable and non-ASCII characters
+     in the ID are replaced with -r/--replacement.
+  2. IDs longer than -l/--max-len are truncated.
+  3. Duplicated IDs are made unique by appending "_N" (separator -s/--separator),
+     N starts from 2. The suffix is counted in -l/--max-len.
+
+Attention:
+  1. The description (text after the first space or tab in the header) is kept
+     untouched, and the new ID replaces the first word of the header.
+  2. With --id-regexp or --id-ncbi, only the parsed ID (e.g., the accession
+     "NC_002516.2" of ">gi|110645304|ref|NC_002516.2| Pseud...") is sanitized
+     and kept, other parts of the first word are removed. The first word is
+     sanitized if the regular expression does not match.
+  3. Use -m/--mapping to save a tab-delimited mapping file of the original
+     first word of the header and the new ID. Original IDs can be restored with
+     "seqkit replace", using a key-value file with the two columns swapped:
+         awk -F '\t' '{print $2"\t"$1}' mapping.tsv > new2old.tsv
+         seqkit replace -p '^(\S+)' -r '{kv}' -k new2old.tsv new.fa
+
+Example:
+
+    $ echo -e ">sp|P69905|HBA_HUMAN Hemoglobin\nACGT" \
+        | seqkit sanitize-id
+    >sp_P69905_HBA_HUMAN Hemoglobin
+    ACGT
+
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		config := getConfigs(cmd)
+		alphabet := config.Alphabet
+		idRegexp := config.IDRegexp
+		lineWidth := config.LineWidth
+		outFile := config.OutFile
+		quiet := config.Quiet
+		seq.AlphabetGuessSeqLengthThreshold = config.AlphabetGuessSeqLength
+		seq.ValidateSeq = false
+		runtime.GOMAXPROCS(config.Threads)
+
+		chars := getFlagString(cmd, "chars")
+		replacement := getFlagString(cmd, "replacement")
+		maxLen := getFlagNonNegativeInt(cmd, "max-len")
+		separator := getFlagString(cmd, "separator")
+		mappingFile := getFlagString(cmd, "mapping")
+
+		for _, c := range chars {
+			if c > 127 {
+				checkError(fmt.Errorf("value of -c (--chars) contains non-ASCII characters"))
+			}
+		}
+		if replacement != "" && sanitizeIDNeedReplace(replacement, chars) {
+			checkError(fmt.Errorf("value of -r (--replacement) should not contain characters to replace: %s", replacement))
+		}
+		if sanitizeIDNeedReplace(separator, chars) {
+			checkError(fmt.Errorf("value of -s (--separator) should not contain characters to replace: %s", separator))
+		}
+
+		bench := getBenchmarkStats(cmd)
+		files := getFileListFromArgsAndFile(cmd, args, true, "infile-list", true)
+		bench.addInputs(files...)
+
+		outfh, err := xopen.Wopen(outFile)
+		checkError(err)
+		defer outfh.Close()
+
+		var mapfh *xopen.Writer
+		if mappingFile != "" {
+			mapfh, err = xopen.Wopen(mappingFile)
+			checkError(err)
+			defer mapfh.Close()
+		}
+
+		s := &idSanitizer{
+			chars:       []byte(chars),
+			replacement: []byte(replacement),
+			maxLen:      maxLen,
+			separator:   separator,
+			used:        make(map[string]struct{}, 1024),
+			counts:      make(map[string]int, 1024),
+		}
+
+		var record *fastx.Record
+		var id, word, desc []byte
+		var newID string
+		var i int
+		var nChanged, n int
+		for _, file := range files {
+			fastxReader, err := fastx.NewReader(alphabet, file, idRegexp)
+			checkError(err)
+
+			for {
+				record, err = fastxReader.Read()
+				if err != nil {
+					if err == io.EOF {
+						break
+					}
+					checkError(err)
+					break
+				}
+				bench.addRecord()
+				if fastxReader.IsFastq {
+					config.LineWidth = 0
+					fastx.ForcelyOutputFastq = true
+				}
+				n++
+
+				// the first word and the description
+				word, desc = record.Name, nil
+				if i = bytes.IndexAny(record.Name, " \t"); i >= 0 {
+					word = record.Name[:i]
+					desc = bytes.TrimLeft(record.Name[i:], " \t")
+				}
+
+				// the whole header is returned as the ID when --id-regexp does not match
+				id = record.ID
+				if bytes.IndexAny(id, " \t") >= 0 {
+					id = word
+				}
+
+				newID = s.Sanitize(id)
+				if newID != string(word) {
+					nChanged++
+				}
+
+				if mapfh != nil {
+					fmt.Fprintf(mapfh, "%s\t%s\n", word, newID)
+				}
+
+				if len(desc) > 0 {
+					record.Name = []byte(newID + " " + string(desc))
+				} else {
+					record.Name = []byte(newID)
+				}
+
+				record.FormatToWriter(outfh, config.LineWidth)
+			}
+			fastxReader.Close()
+
+			config.LineWidth = lineWidth
+		}
+
+		if !quiet {
+			log.Infof("%d of %d sequence IDs changed", nChanged, n)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(sanitizeIDCmd)
+
+	sanitizeIDCmd.Flags().StringP("chars", "c", "|,;:=/\\()[]{}<>'\"`*?!&$#@%^+~", "characters to replace, besides spaces, non-printable and non-ASCII characters")
+	sanitizeIDCmd.Flags().StringP("replacement", "r", "_", "replacement of disallowed characters, empty for removing them")
+	sanitizeIDCmd.Flags().IntP("max-len", "l", 0, "maximum length of IDs, including the suffix for duplicates. 0 for no limit")
+	sanitizeIDCmd.Flags().StringP("separator", "s", "_", "separator between the ID and the counter of duplicated IDs")
+	sanitizeIDCmd.Flags().StringP("mapping", "m", "", "save a tab-delimited mapping file of original first words of headers and new IDs")
+}
+
+// idSanitizer replaces disallowed characters in IDs, truncates them,
+// and makes them unique.
+type idSanitizer struct {
+	chars       []byte
+	replacement []byte
+	maxLen      int
+	separator   string
+
+	used   map[string]struct{} // outputted IDs
+	counts map[string]int      // the last number used for duplicates of an ID
+}
+
+func sanitizeIDNeedReplace(s string, chars string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] <= ' ' || s[i] >= 127 || strings.IndexByte(chars, s[i]) >= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Sanitize returns a new ID which is unique among all returned IDs.
+func (s *idSanitizer) Sanitize(id []byte) string {
+	buf := make([]byte, 0, len(id))
+	for _, b := range id {
+		if b <= ' ' || b >= 127 || bytes.IndexByte(s.chars, b) >= 0 {
+			buf = append(buf, s.replacement...)
+			continue
+		}
+		buf = append(buf, b)
+	}
+	if s.maxLen > 0 && len(buf) > s.maxLen {
+		buf = buf[:s.maxLen]
+	}
+
+	newID := string(buf)
+	if _, ok := s.used[newID]; !ok {
+		s.used[newID] = struct{}{}
+		return newID
+	}
+
+	// duplicated
+	base := newID
+	n, ok := s.counts[base]
+	if !ok {
+		n = 1
+	}
+	var suffix string
+	for {
+		n++
+		suffix = fmt.Sprintf("%s%d", s.separator, n)
+		if s.maxLen > 0 && len(base)+len(suffix) > s.maxLen {
+			if len(suffix) >= s.maxLen {
+				checkError(fmt.Errorf("the value of -l (--max-len) is too small to make ID unique: %s", base))
+			}
+			newID = base[:s.maxLen-len(suffix)] + suffix
+		} else {
+			newID = base + suffix
+		}
+		if _, ok = s.used[newID]; !ok {
+			break
+		}
+	}
+	s.counts[base] = n
+	s.used[newID] = struct{}{}
+	return newID
+}
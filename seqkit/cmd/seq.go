@@ -23,6 +23,8 @@ package cmd
 import (
 	"bufio"
 	"bytes"
+	"crypto/md5"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"os"
@@ -35,6 +37,7 @@ import (
 	gzip "github.com/klauspost/pgzip"
 	"github.com/shenwei356/bio/seq"
 	"github.com/shenwei356/bio/seqio/fastx"
+	"github.com/shenwei356/xopen"
 	"github.com/spf13/cobra"
 
 	"github.com/klauspost/compress/zstd"
@@ -49,9 +52,80 @@ var seqCmd = &cobra.Command{
 	Short: "transform sequences (extract ID, filter by length, remove gaps, reverse complement...)",
 	Long: `transform sequences (extract ID, filter by length, remove gaps, reverse complement...)
 
+Attention:
+  1. Flag -i/--only-id truncates sequence headers to IDs parsed by --id-regexp
+     or --id-ncbi, e.g., ">gi|110645304|ref|NC_002516.2| Pseud..." becomes
+     ">NC_002516.2" with --id-ncbi. For headers not matching the regular
+     expression, the first whitespace-delimited word is used instead, so
+     outputted headers never contain spaces.
+  2. Flag --validate-lengths only checks if the lengths of sequences and
+     qualities are equal in FASTQ files, without any transformation.
+     Records with unequal lengths are outputted in a TSV format
+     (file, index, id, seq_len, qual_len), where index is the 1-based record
+     index in each file, and the program exits with a non-zero status.
+     Only 4-line FASTQ records (one line for each sequence and quality)
+     are supported, use "seqkit sana" for sanitizing broken files.
+  3. Flag --concat-all concatenates all records into a single one with the ID
+     given by --concat-id, separated by --spacer copies of --spacer-char.
+     For FASTQ, --spacer-qual is needed for qualities of spacers.
+     Use --concat-bed to save positions of original records (BED, 0-based).
+     Only -g, -m, -M, -Q, -R, -r, -p, -l and -u are supported in this mode.
+  4. Flags --dna2rna and --rna2dna convert T to U and U to T respectively,
+     with the case preserved. The conversion is skipped with a warning if
+     the sequence type (given by -t/--seq-type or guessed from the first
+     sequences) is already the target one, or is protein.
+  5. Flag --both-strands outputs each record twice: the original one and its
+     reverse complement, the ID of which is appended with --rc-suffix
+     (default "_rc"). Qualities are reversed for FASTQ, and protein sequences
+     are not supported. Filters (-m, -M, -Q, -R) and -g are applied before,
+     so both records are kept or discarded together, and other flags like
+     -i, -s, -u and -w apply to both records.
+  6. Flag --max-bases stops after the cumulative length of output sequences
+     reaches the given size (units K, M, G in 1024-based), the current record is
+     always completed. Only sequences of records passing the filters (-m, -M,
+     -Q, -R) are counted, after removing gaps with -g. With --both-strands,
+     both records are counted.
+  7. Flag --complement-only-iupac complements sequences with the IUPAC
+     nucleotide table regardless of the sequence type, i.e., A<->T, C<->G,
+     R<->Y, K<->M, B<->V, D<->H, while S, W and N are unchanged, and U is
+     complemented to A. Sequences containing U but no T are treated as RNA,
+     where A is complemented to U. The case is preserved, and other
+     letters like gaps are kept. It is useful for sequences mixing T and U,
+     which might be guessed as protein/unlimit ones and left unchanged by
+     -p/--complement. It implies -p, and gives reverse complement
+     sequences with -r/--reverse.
+  8. Flag --max-n-frac drops records with the fraction of N bases
+     (case-insensitive) greater than the given value, and --drop-ambiguous
+     drops records containing any ambiguous bases, i.e., IUPAC codes other
+     than A, C, G, T and U: R, Y, S, W, K, M, B, D, H, V and N. Both are
+     applied after removing gaps with -g, along with the length filters, and
+     only work for DNA/RNA sequences. Numbers of dropped records are reported
+     unless -q/--quiet is given.
+  9. Flag --verify-hash verifies sequences against expected MD5 hashes in a
+     tab-delimited file, with sequence IDs in the first column and hashes in
+     the last one, e.g., the output of "seqkit fx2tab -n -i -s". Sequences
+     are converted to lower case before hashing unless --hash-case-sensitive
+     is given, the same as "seqkit fx2tab -s". Blank lines and lines starting
+     with "#" are ignored. Problems are outputted in a TSV format (file, id,
+     status, expected, observed), where status is one of "mismatch",
+     "missing" (in the hash file but not in the input) and "extra" (in the
+     input but not in the hash file), and the program exits with a non-zero
+     status. Use --max-report to cap the output.
+  10. Paired-end reads given by -1/--read1 and -2/--read2 are reversed and/or
+     complemented (-r, -p, --complement-only-iupac) mate by mate, and written
+     to two files in sync. Headers, including '/1' and '/2' suffixes, are kept
+     untouched, and qualities are reversed along with sequences. The two files
+     should have the same number of reads in the same order, which is checked
+     by IDs with the '/1' and '/2' suffixes ignored. If -O/--out-dir is not
+     given, outputs are saved in the directory of the input with the suffix
+     "seq", e.g., read_1.seq.fq.gz, otherwise names are kept untouched in the
+     given directory. -o/--out-file and other flags for transformation or
+     filtering are not supported in this mode.
+
 `,
 	Run: func(cmd *cobra.Command, args []string) {
 		config := getConfigs(cmd)
+		bench := getBenchmarkStats(cmd)
 		alphabet := config.Alphabet
 		idRegexp := config.IDRegexp
 		lineWidth := config.LineWidth
@@ -63,6 +137,10 @@ var seqCmd = &cobra.Command{
 
 		reverse := getFlagBool(cmd, "reverse")
 		complement := getFlagBool(cmd, "complement")
+		complementIUPAC := getFlagBool(cmd, "complement-only-iupac")
+		if complementIUPAC {
+			complement = true
+		}
 		onlyName := getFlagBool(cmd, "name")
 		onlySeq := getFlagBool(cmd, "seq")
 		onlyQual := getFlagBool(cmd, "qual")
@@ -73,6 +151,14 @@ var seqCmd = &cobra.Command{
 		upperCase := getFlagBool(cmd, "upper-case")
 		dna2rna := getFlagBool(cmd, "dna2rna")
 		rna2dna := getFlagBool(cmd, "rna2dna")
+		if dna2rna && rna2dna {
+			checkError(fmt.Errorf("flags --dna2rna and --rna2dna are not compatible"))
+		}
+		bothStrands := getFlagBool(cmd, "both-strands")
+		suffix := []byte(getFlagString(cmd, "rc-suffix"))
+		if bothStrands && (reverse || complement) {
+			checkError(fmt.Errorf("flag --both-strands is not compatible with -r (--reverse) and -p (--complement)"))
+		}
 		color := getFlagBool(cmd, "color")
 		validateSeq := getFlagBool(cmd, "validate-seq")
 		minLen := getFlagInt(cmd, "min-len")
@@ -81,6 +167,47 @@ var seqCmd = &cobra.Command{
 		minQual := getFlagFloat64(cmd, "min-qual")
 		maxQual := getFlagFloat64(cmd, "max-qual")
 
+		maxBases, err := ParseByteSize(getFlagString(cmd, "max-bases"))
+		if err != nil {
+			checkError(fmt.Errorf("invalid value of flag --max-bases: %s", err))
+		}
+		limitBases := maxBases > 0
+
+		maxNFrac := getFlagFloat64(cmd, "max-n-frac")
+		dropAmbiguous := getFlagBool(cmd, "drop-ambiguous")
+		filterNFrac := maxNFrac >= 0
+		if filterNFrac && maxNFrac > 1 {
+			checkError(fmt.Errorf("value of flag --max-n-frac should be in range of [0, 1]"))
+		}
+		var nDroppedNFrac, nDroppedAmbiguous int
+		// ambiguityFiltered checks if a sequence should be dropped by --max-n-frac or --drop-ambiguous.
+		ambiguityFiltered := func(s []byte, alphabet *seq.Alphabet) bool {
+			if alphabet == seq.Protein {
+				checkError(fmt.Errorf("flags --max-n-frac and --drop-ambiguous only work for DNA/RNA sequences, protein sequences given"))
+			}
+			if dropAmbiguous && seqHasAmbiguousBases(s) {
+				nDroppedAmbiguous++
+				return true
+			}
+			if filterNFrac && len(s) > 0 &&
+				float64(bytes.Count(s, []byte{'N'})+bytes.Count(s, []byte{'n'}))/float64(len(s)) > maxNFrac {
+				nDroppedNFrac++
+				return true
+			}
+			return false
+		}
+		reportAmbiguityFiltered := func() {
+			if quiet {
+				return
+			}
+			if dropAmbiguous {
+				log.Infof("%d records with ambiguous bases dropped", nDroppedAmbiguous)
+			}
+			if filterNFrac {
+				log.Infof("%d records with N fractions > %v dropped", nDroppedNFrac, maxNFrac)
+			}
+		}
+
 		filterMinLen := minLen >= 0
 		filterMaxLen := maxLen >= 0
 		filterMinQual := minQual > 0
@@ -115,7 +242,7 @@ var seqCmd = &cobra.Command{
 		seq.ValidSeqThreads = config.Threads
 		seq.ComplementThreads = config.Threads
 
-		if complement && (alphabet == nil || alphabet == seq.Protein) {
+		if complement && !complementIUPAC && (alphabet == nil || alphabet == seq.Protein) {
 			log.Warningf("flag -t (--seq-type) (DNA/RNA) is recommended for computing complement sequences")
 		}
 
@@ -131,7 +258,340 @@ var seqCmd = &cobra.Command{
 			checkError(fmt.Errorf("could not give both flags -l (--lower-case) and -u (--upper-case)"))
 		}
 
+		read1 := getFlagString(cmd, "read1")
+		read2 := getFlagString(cmd, "read2")
+		if read1 != "" || read2 != "" {
+			if read1 == "" || read2 == "" {
+				checkError(fmt.Errorf("flag -1/--read1 and -2/--read2 needed"))
+			}
+			if read1 == read2 {
+				checkError(fmt.Errorf("values of flag -1/--read1 and -2/--read2 can not be the same"))
+			}
+			if len(args) > 0 {
+				checkError(fmt.Errorf("no positional arguments are allowed for paired reads: %s", strings.Join(args, " ")))
+			}
+			if outFile != "-" {
+				checkError(fmt.Errorf("flag -o/--out-file is not supported for paired reads, please use -O/--out-dir"))
+			}
+			if !reverse && !complement {
+				checkError(fmt.Errorf("flag -r/--reverse and/or -p/--complement needed for paired reads"))
+			}
+			if onlyName || onlySeq || onlyQual || onlyID || removeGaps || lowerCase || upperCase || dna2rna || rna2dna ||
+				bothStrands || color || limitBases || filterMinLen || filterMaxLen || filterMinQual || filterMaxQual ||
+				filterNFrac || dropAmbiguous || getFlagBool(cmd, "concat-all") {
+				checkError(fmt.Errorf("only -r, -p and --complement-only-iupac are supported for paired reads"))
+			}
+
+			outdir := getFlagString(cmd, "out-dir")
+			outFiles, err := pairedOutFiles(read1, read2, outdir, "seq")
+			checkError(err)
+			outfh1, err := xopen.Wopen(outFiles[0])
+			checkError(err)
+			defer outfh1.Close()
+			outfh2, err := xopen.Wopen(outFiles[1])
+			checkError(err)
+			defer outfh2.Close()
+
+			reader, err := newPairedReader(alphabet, read1, read2, idRegexp, bench)
+			checkError(err)
+
+			var record1, record2 *fastx.Record
+			var n int
+			for {
+				record1, record2, err = reader.Read()
+				if err != nil {
+					if err == io.EOF {
+						break
+					}
+					checkError(err)
+				}
+				if reader.IsFastq() {
+					config.LineWidth = 0
+					fastx.ForcelyOutputFastq = true
+				}
+
+				for _, record := range []*fastx.Record{record1, record2} {
+					if reverse {
+						record.Seq.ReverseInplace()
+					}
+					if complementIUPAC {
+						complementIUPACInplace(record.Seq.Seq)
+					} else if complement {
+						record.Seq.ComplementInplace()
+					}
+				}
+				record1.FormatToWriter(outfh1, config.LineWidth)
+				record2.FormatToWriter(outfh2, config.LineWidth)
+				n++
+			}
+			reader.Close()
+
+			if !quiet {
+				log.Infof("%d read pairs saved to %s and %s", n, outFiles[0], outFiles[1])
+			}
+			return
+		}
+
 		files := getFileListFromArgsAndFile(cmd, args, true, "infile-list", true)
+		bench.addInputs(files...)
+
+		if limitBases && (getFlagBool(cmd, "validate-lengths") || getFlagBool(cmd, "concat-all")) {
+			checkError(fmt.Errorf("flag --max-bases is not compatible with --validate-lengths and --concat-all"))
+		}
+
+		if getFlagBool(cmd, "validate-lengths") {
+			maxReport := getFlagNonNegativeInt(cmd, "max-report")
+
+			outfh, err := xopen.Wopen(outFile)
+			checkError(err)
+
+			outfh.WriteString("file\tindex\tid\tseq_len\tqual_len\n")
+			var n, nTotal int
+			for _, file := range files {
+				n, err = validateFastqLengths(outfh, file, maxReport, nTotal)
+				nTotal += n
+				if err != nil {
+					outfh.Close()
+					checkError(fmt.Errorf("%s: %s", file, err))
+				}
+			}
+			outfh.Close()
+
+			if nTotal > 0 {
+				log.Errorf("%d record(s) with unequal lengths of sequence and quality found", nTotal)
+				os.Exit(1)
+			}
+			if !quiet {
+				log.Infof("no records with unequal lengths of sequence and quality found")
+			}
+			return
+		}
+
+		if hashFile := getFlagString(cmd, "verify-hash"); hashFile != "" {
+			if limitBases || getFlagBool(cmd, "concat-all") {
+				checkError(fmt.Errorf("flag --verify-hash is not compatible with --max-bases and --concat-all"))
+			}
+			maxReport := getFlagNonNegativeInt(cmd, "max-report")
+			caseSensitive := getFlagBool(cmd, "hash-case-sensitive")
+
+			ids, expected, err := readSeqHashes(hashFile)
+			checkError(err)
+			if !quiet {
+				log.Infof("%d expected hashes loaded from %s", len(ids), hashFile)
+			}
+
+			outfh, err := xopen.Wopen(outFile)
+			checkError(err)
+
+			outfh.WriteString("file\tid\tstatus\texpected\tobserved\n")
+			var nMismatch, nMissing, nExtra, nReported, nChecked int
+			report := func(file, id, status, exp, obs string) {
+				nReported++
+				if maxReport > 0 && nReported > maxReport {
+					return
+				}
+				fmt.Fprintf(outfh, "%s\t%s\t%s\t%s\t%s\n", file, id, status, exp, obs)
+			}
+
+			seen := make(map[string]struct{}, len(ids))
+			var record *fastx.Record
+			var sum [md5.Size]byte
+			var id, obs, exp string
+			var ok bool
+			for _, file := range files {
+				fastxReader, err := fastx.NewReader(alphabet, file, idRegexp)
+				checkError(err)
+				for {
+					record, err = fastxReader.Read()
+					if err != nil {
+						if err == io.EOF {
+							break
+						}
+						checkError(err)
+						break
+					}
+					bench.addRecord()
+					nChecked++
+
+					if caseSensitive {
+						sum = md5.Sum(record.Seq.Seq)
+					} else {
+						sum = md5.Sum(bytes.ToLower(record.Seq.Seq))
+					}
+					obs = hex.EncodeToString(sum[:])
+
+					id = string(record.ID)
+					if exp, ok = expected[id]; !ok {
+						nExtra++
+						report(file, id, "extra", "-", obs)
+						continue
+					}
+					seen[id] = struct{}{}
+					if obs != exp {
+						nMismatch++
+						report(file, id, "mismatch", exp, obs)
+					}
+				}
+				fastxReader.Close()
+			}
+			for _, id = range ids {
+				if _, ok = seen[id]; !ok {
+					nMissing++
+					report(hashFile, id, "missing", expected[id], "-")
+				}
+			}
+			outfh.Close()
+
+			if nMismatch+nMissing+nExtra > 0 {
+				log.Errorf("%d record(s) checked: %d mismatch(es), %d missing, %d extra", nChecked, nMismatch, nMissing, nExtra)
+				os.Exit(1)
+			}
+			if !quiet {
+				log.Infof("%d record(s) checked, all hashes matched", nChecked)
+			}
+			return
+		}
+
+		if getFlagBool(cmd, "concat-all") {
+			if onlyName || onlySeq || onlyQual || onlyID || color || dna2rna || rna2dna || bothStrands {
+				checkError(fmt.Errorf("flags -n, -s, -q, -i, -k, --dna2rna, --rna2dna and --both-strands are not supported with --concat-all"))
+			}
+			concatID := getFlagString(cmd, "concat-id")
+			if concatID == "" {
+				checkError(fmt.Errorf("value of flag --concat-id should not be empty"))
+			}
+			spacer := getFlagNonNegativeInt(cmd, "spacer")
+			spacerChar := getFlagString(cmd, "spacer-char")
+			if len(spacerChar) != 1 {
+				checkError(fmt.Errorf("value of flag --spacer-char should be a single character"))
+			}
+			spacerQual := getFlagString(cmd, "spacer-qual")
+			if len(spacerQual) > 1 {
+				checkError(fmt.Errorf("value of flag --spacer-qual should be a single character"))
+			}
+			bedFile := getFlagString(cmd, "concat-bed")
+
+			var bedfh *xopen.Writer
+			var err error
+			if bedFile != "" {
+				bedfh, err = xopen.Wopen(bedFile)
+				checkError(err)
+				defer bedfh.Close()
+			}
+
+			var bufSeq, bufQual bytes.Buffer
+			var isFastq, first bool = false, true
+			var record *fastx.Record
+			var n int
+			var ab *seq.Alphabet
+			for _, file := range files {
+				fastxReader, err := fastx.NewReader(alphabet, file, idRegexp)
+				checkError(err)
+				for {
+					record, err = fastxReader.Read()
+					if err != nil {
+						if err == io.EOF {
+							break
+						}
+						checkError(err)
+						break
+					}
+					bench.addRecord()
+
+					if first {
+						ab = fastxReader.Alphabet()
+						isFastq = fastxReader.IsFastq
+						if isFastq && spacer > 0 && spacerQual == "" {
+							checkError(fmt.Errorf("flag --spacer-qual needed for FASTQ files when --spacer > 0"))
+						}
+						first = false
+					} else if fastxReader.IsFastq != isFastq {
+						checkError(fmt.Errorf("mixed FASTA and FASTQ records are not supported with --concat-all"))
+					}
+
+					if removeGaps {
+						record.Seq.RemoveGapsInplace(gapLetters)
+					}
+					if filterMinLen && len(record.Seq.Seq) < minLen {
+						continue
+					}
+					if filterMaxLen && len(record.Seq.Seq) > maxLen {
+						continue
+					}
+					if (dropAmbiguous || filterNFrac) && ambiguityFiltered(record.Seq.Seq, record.Seq.Alphabet) {
+						continue
+					}
+					if filterMinQual || filterMaxQual {
+						avgQual := record.Seq.AvgQual(qBase)
+						if filterMinQual && avgQual < minQual {
+							continue
+						}
+						if filterMaxQual && avgQual >= maxQual {
+							continue
+						}
+					}
+
+					if reverse {
+						record.Seq.ReverseInplace()
+					}
+					if complementIUPAC {
+						complementIUPACInplace(record.Seq.Seq)
+					} else if complement {
+						record.Seq.ComplementInplace()
+					}
+					if lowerCase {
+						record.Seq.Seq = bytes.ToLower(record.Seq.Seq)
+					} else if upperCase {
+						record.Seq.Seq = bytes.ToUpper(record.Seq.Seq)
+					}
+
+					if n > 0 && spacer > 0 {
+						bufSeq.Write(bytes.Repeat([]byte(spacerChar), spacer))
+						if isFastq {
+							bufQual.Write(bytes.Repeat([]byte(spacerQual), spacer))
+						}
+					}
+					if bedfh != nil {
+						fmt.Fprintf(bedfh, "%s\t%d\t%d\t%s\n", concatID, bufSeq.Len(), bufSeq.Len()+len(record.Seq.Seq), record.ID)
+					}
+					bufSeq.Write(record.Seq.Seq)
+					if isFastq {
+						bufQual.Write(record.Seq.Qual)
+					}
+					n++
+				}
+				fastxReader.Close()
+			}
+
+			outfh, err := xopen.Wopen(outFile)
+			checkError(err)
+			defer outfh.Close()
+
+			reportAmbiguityFiltered()
+			if n == 0 {
+				if !quiet {
+					log.Warningf("no records to concatenate")
+				}
+				return
+			}
+
+			var concated *fastx.Record
+			if isFastq {
+				concated, err = fastx.NewRecordWithQualWithoutValidation(ab, []byte{}, []byte(concatID), []byte{},
+					bufSeq.Bytes(), bufQual.Bytes())
+				lineWidth = 0
+				fastx.ForcelyOutputFastq = true
+			} else {
+				concated, err = fastx.NewRecordWithoutValidation(ab, []byte{}, []byte(concatID), []byte{}, bufSeq.Bytes())
+			}
+			checkError(err)
+			concated.FormatToWriter(outfh, lineWidth)
+
+			if !quiet {
+				log.Infof("%d records concatenated into %s (%d bp)", n, concatID, bufSeq.Len())
+			}
+			return
+		}
 
 		var seqCol *SeqColorizer
 		if color {
@@ -149,7 +609,6 @@ var seqCmd = &cobra.Command{
 			}
 		}
 		var outfh *os.File
-		var err error
 		if outFile == "-" {
 			outfh = os.Stdout
 		} else {
@@ -230,12 +689,24 @@ var seqCmd = &cobra.Command{
 		var isFastq bool
 		var printName, printSeq, printQual bool
 		var head []byte
+		var k int
+		var warnedIDFallback bool
 		var sequence *seq.Seq
 		var text []byte
 		var buffer *bytes.Buffer
 		var record *fastx.Record
+		records := make([]*fastx.Record, 2)
+		var nRecords int
+		var rc *fastx.Record
+		onceStrand := true
+		var nBases, nOutRecords int64
+		var reachedMaxBases bool
 
 		for _, file := range files {
+			if reachedMaxBases {
+				break
+			}
+
 			fastxReader, err := fastx.NewReader(alphabet, file, idRegexp)
 			checkError(err)
 
@@ -254,6 +725,7 @@ var seqCmd = &cobra.Command{
 					checkError(err)
 					break
 				}
+				bench.addRecord()
 
 				if checkSeqType {
 					isFastq = fastxReader.IsFastq
@@ -276,6 +748,10 @@ var seqCmd = &cobra.Command{
 					continue
 				}
 
+				if (dropAmbiguous || filterNFrac) && ambiguityFiltered(record.Seq.Seq, record.Seq.Alphabet) {
+					continue
+				}
+
 				if filterMinQual || filterMaxQual {
 					avgQual := record.Seq.AvgQual(qBase)
 					if filterMinQual && avgQual < minQual {
@@ -286,135 +762,192 @@ var seqCmd = &cobra.Command{
 					}
 				}
 
-				printName, printSeq = true, true
-				if onlyName && onlySeq {
-					printName, printSeq = true, true
-				} else if onlyName {
-					printName, printSeq, printQual = true, false, false
-				} else if onlySeq {
-					printName, printSeq, printQual = false, true, false
-				} else if onlyQual {
-					if !isFastq {
-						checkError(fmt.Errorf("FASTA format has no quality. So do not just use flag -q (--qual)"))
-					}
-					printName, printSeq, printQual = false, false, true
+				records[0] = record
+				nRecords = 1
+				if bothStrands {
+					if onceStrand {
+						if ab := fastxReader.Alphabet(); ab == seq.Protein {
+							checkError(fmt.Errorf("flag --both-strands does not support protein sequences"))
+						}
+						onceStrand = false
+					}
+					rc = record.Clone()
+					rc.Seq.RevComInplace()
+					rc.ID = append(rc.ID, suffix...)
+					rc.Name = bytes.Replace(rc.Name, record.ID, rc.ID, 1)
+					records[1] = rc
+					nRecords = 2
 				}
-				if printName {
-					if onlyID {
-						head = record.ID
-					} else {
-						head = record.Name
+
+				for _, record = range records[:nRecords] {
+					printName, printSeq = true, true
+					if onlyName && onlySeq {
+						printName, printSeq = true, true
+					} else if onlyName {
+						printName, printSeq, printQual = true, false, false
+					} else if onlySeq {
+						printName, printSeq, printQual = false, true, false
+					} else if onlyQual {
+						if !isFastq {
+							checkError(fmt.Errorf("FASTA format has no quality. So do not just use flag -q (--qual)"))
+						}
+						printName, printSeq, printQual = false, false, true
 					}
+					if printName {
+						if onlyID {
+							head = record.ID
+							// the ID regular expression does not match, and the whole header is returned
+							if k = bytes.IndexAny(head, " \t"); k >= 0 {
+								if !quiet && !warnedIDFallback {
+									log.Warningf("some headers do not match the ID regular expression, the first words are used as IDs, e.g., %s", head[:k])
+									warnedIDFallback = true
+								}
+								head = head[:k]
+							}
+						} else {
+							head = record.Name
+						}
 
-					if printSeq {
-						if isFastq {
-							outbw.Write(_mark_fastq)
-							outbw.Write(head)
-							outbw.Write(_mark_newline)
+						if printSeq {
+							if isFastq {
+								outbw.Write(_mark_fastq)
+								outbw.Write(head)
+								outbw.Write(_mark_newline)
+							} else {
+								outbw.Write(_mark_fasta)
+								outbw.Write(head)
+								outbw.Write(_mark_newline)
+							}
 						} else {
-							outbw.Write(_mark_fasta)
 							outbw.Write(head)
 							outbw.Write(_mark_newline)
 						}
-					} else {
-						outbw.Write(head)
-						outbw.Write(_mark_newline)
 					}
-				}
 
-				sequence = record.Seq
-				if reverse {
-					sequence = sequence.ReverseInplace()
-				}
-				if complement {
-					if !config.Quiet && record.Seq.Alphabet == seq.Protein || record.Seq.Alphabet == seq.Unlimit {
-						log.Warning("complement does no take effect on protein/unlimit sequence")
+					sequence = record.Seq
+					if reverse {
+						sequence = sequence.ReverseInplace()
+					}
+					if complementIUPAC {
+						complementIUPACInplace(sequence.Seq)
+					} else if complement {
+						if !config.Quiet && (record.Seq.Alphabet == seq.Protein || record.Seq.Alphabet == seq.Unlimit) {
+							log.Warning("complement does no take effect on protein/unlimit sequence")
+						}
+						sequence = sequence.ComplementInplace()
 					}
-					sequence = sequence.ComplementInplace()
-				}
 
-				if printSeq {
-					if dna2rna {
-						ab := fastxReader.Alphabet()
-						if ab == seq.RNA || ab == seq.RNAredundant {
-							if once {
-								log.Warningf("it's already RNA, no need to convert")
-								once = false
-							}
-						} else {
-							for i, b := range sequence.Seq {
-								switch b {
-								case 't':
-									sequence.Seq[i] = 'u'
-								case 'T':
-									sequence.Seq[i] = 'U'
+					if printSeq {
+						if dna2rna {
+							ab := fastxReader.Alphabet()
+							if ab == seq.RNA || ab == seq.RNAredundant {
+								if once {
+									if !quiet {
+										log.Warningf("it's already RNA, no need to convert")
+									}
+									once = false
+								}
+							} else if ab == seq.Protein {
+								if once {
+									if !quiet {
+										log.Warningf("it's protein, --dna2rna does not take effect")
+									}
+									once = false
+								}
+							} else {
+								for i, b := range sequence.Seq {
+									switch b {
+									case 't':
+										sequence.Seq[i] = 'u'
+									case 'T':
+										sequence.Seq[i] = 'U'
+									}
 								}
 							}
 						}
-					}
-					if rna2dna {
-						ab := fastxReader.Alphabet()
-						if ab == seq.DNA || ab == seq.DNAredundant {
-							if once {
-								log.Warningf("it's already DNA, no need to convert")
-								once = false
-							}
-						} else {
-							for i, b := range sequence.Seq {
-								switch b {
-								case 'u':
-									sequence.Seq[i] = 't'
-								case 'U':
-									sequence.Seq[i] = 'T'
+						if rna2dna {
+							ab := fastxReader.Alphabet()
+							if ab == seq.DNA || ab == seq.DNAredundant {
+								if once {
+									if !quiet {
+										log.Warningf("it's already DNA, no need to convert")
+									}
+									once = false
+								}
+							} else if ab == seq.Protein {
+								if once {
+									if !quiet {
+										log.Warningf("it's protein, --rna2dna does not take effect")
+									}
+									once = false
+								}
+							} else {
+								for i, b := range sequence.Seq {
+									switch b {
+									case 'u':
+										sequence.Seq[i] = 't'
+									case 'U':
+										sequence.Seq[i] = 'T'
+									}
 								}
 							}
 						}
-					}
-					if lowerCase {
-						sequence.Seq = bytes.ToLower(sequence.Seq)
-					} else if upperCase {
-						sequence.Seq = bytes.ToUpper(sequence.Seq)
-					}
+						if lowerCase {
+							sequence.Seq = bytes.ToLower(sequence.Seq)
+						} else if upperCase {
+							sequence.Seq = bytes.ToUpper(sequence.Seq)
+						}
 
-					if isFastq {
-						if color {
-							if sequence.Qual != nil {
-								outbw.Write(seqCol.ColorWithQuals(sequence.Seq, sequence.Qual))
+						if isFastq {
+							if color {
+								if sequence.Qual != nil {
+									outbw.Write(seqCol.ColorWithQuals(sequence.Seq, sequence.Qual))
+								} else {
+									outbw.Write(seqCol.Color(sequence.Seq))
+								}
 							} else {
-								outbw.Write(seqCol.Color(sequence.Seq))
+								outbw.Write(sequence.Seq)
 							}
 						} else {
-							outbw.Write(sequence.Seq)
-						}
-					} else {
-						text, buffer = wrapByteSlice(sequence.Seq, config.LineWidth, buffer)
+							text, buffer = wrapByteSlice(sequence.Seq, config.LineWidth, buffer)
 
-						if color {
-							if sequence.Qual != nil {
-								text = seqCol.ColorWithQuals(text, sequence.Qual)
-							} else {
-								text = seqCol.Color(text)
+							if color {
+								if sequence.Qual != nil {
+									text = seqCol.ColorWithQuals(text, sequence.Qual)
+								} else {
+									text = seqCol.Color(text)
+								}
 							}
+
+							outbw.Write(text)
 						}
 
-						outbw.Write(text)
+						outbw.Write(_mark_newline)
 					}
 
-					outbw.Write(_mark_newline)
-				}
+					if printQual {
+						if !onlyQual {
+							outbw.Write(_mark_plus_newline)
+						}
+
+						if color {
+							outbw.Write(seqCol.ColorQuals(sequence.Qual))
+						} else {
+							outbw.Write(sequence.Qual)
+						}
 
-				if printQual {
-					if !onlyQual {
-						outbw.Write(_mark_plus_newline)
+						outbw.Write(_mark_newline)
 					}
 
-					if color {
-						outbw.Write(seqCol.ColorQuals(sequence.Qual))
-					} else {
-						outbw.Write(sequence.Qual)
+					if limitBases {
+						nBases += int64(len(record.Seq.Seq))
+						nOutRecords++
 					}
+				}
 
-					outbw.Write(_mark_newline)
+				if limitBases && nBases >= maxBases {
+					reachedMaxBases = true
+					break
 				}
 			}
 			fastxReader.Close()
@@ -422,6 +955,10 @@ var seqCmd = &cobra.Command{
 			config.LineWidth = lineWidth
 		}
 
+		reportAmbiguityFiltered()
+		if limitBases && !quiet {
+			log.Infof("%d bases in %d records written", nBases, nOutRecords)
+		}
 	},
 }
 
@@ -432,26 +969,210 @@ func init() {
 
 	seqCmd.Flags().BoolP("reverse", "r", false, "reverse sequence")
 	seqCmd.Flags().BoolP("complement", "p", false, "complement sequence, flag '-v' is recommended to switch on")
+	seqCmd.Flags().BoolP("complement-only-iupac", "", false, "complement sequence with the IUPAC nucleotide table regardless of the sequence type, A is complemented to U for sequences with U but no T")
 	seqCmd.Flags().BoolP("name", "n", false, "only print names/sequence headers")
 	seqCmd.Flags().BoolP("seq", "s", false, "only print sequences")
 	seqCmd.Flags().BoolP("qual", "q", false, "only print qualities")
-	seqCmd.Flags().BoolP("only-id", "i", false, "print IDs instead of full headers")
+	seqCmd.Flags().BoolP("only-id", "i", false, "print IDs (parsed by --id-regexp or --id-ncbi) instead of full headers")
 	seqCmd.Flags().BoolP("remove-gaps", "g", false, `remove gaps letters set by -G/--gap-letters, e.g., spaces, tabs, and dashes (gaps "-" in aligned sequences)`)
 	seqCmd.Flags().StringP("gap-letters", "G", "- 	.", `gap letters to be removed with -g/--remove-gaps`)
 	seqCmd.Flags().BoolP("lower-case", "l", false, "print sequences in lower case")
 	seqCmd.Flags().BoolP("upper-case", "u", false, "print sequences in upper case")
-	seqCmd.Flags().BoolP("dna2rna", "", false, "DNA to RNA")
-	seqCmd.Flags().BoolP("rna2dna", "", false, "RNA to DNA")
+	seqCmd.Flags().BoolP("dna2rna", "", false, "DNA to RNA, converting T to U with the case preserved")
+	seqCmd.Flags().BoolP("rna2dna", "", false, "RNA to DNA, converting U to T with the case preserved")
+	seqCmd.Flags().BoolP("both-strands", "", false, "output each record and its reverse complement, with the ID of the latter appended with --rc-suffix")
+	seqCmd.Flags().StringP("rc-suffix", "", "_rc", "suffix appended to IDs of reverse complement records for --both-strands")
+	seqCmd.Flags().StringP("max-bases", "", "", `stop after outputting this number of bases in total, supported units: K, M, G. e.g., 100M`)
 	seqCmd.Flags().BoolP("color", "k", false, "colorize sequences - to be piped into \"less -R\"")
 	seqCmd.Flags().BoolP("validate-seq", "v", false, "validate bases according to the alphabet")
 	seqCmd.Flags().IntP("min-len", "m", -1, "only print sequences longer than or equal to the minimum length (-1 for no limit)")
 	seqCmd.Flags().IntP("max-len", "M", -1, "only print sequences shorter than or equal to the maximum length (-1 for no limit)")
 	seqCmd.Flags().IntP("qual-ascii-base", "b", 33, "ASCII BASE, 33 for Phred+33")
 	seqCmd.Flags().Float64P("min-qual", "Q", -1, "only print sequences with average quality greater or equal than this limit (-1 for no limit)")
+	seqCmd.Flags().BoolP("concat-all", "", false, "concatenate all records into a single one")
+	seqCmd.Flags().StringP("concat-id", "", "concatenated", "sequence ID of the concatenated record for --concat-all")
+	seqCmd.Flags().IntP("spacer", "", 0, "number of spacer characters between records for --concat-all")
+	seqCmd.Flags().StringP("spacer-char", "", "N", "spacer character for --concat-all")
+	seqCmd.Flags().StringP("spacer-qual", "", "", "quality character of spacers for FASTQ files with --concat-all, e.g., '!'")
+	seqCmd.Flags().StringP("concat-bed", "", "", "save positions of original records in the concatenated record to a BED file for --concat-all")
+	seqCmd.Flags().BoolP("validate-lengths", "", false, "only check if lengths of sequences and qualities are equal for 4-line FASTQ files, and report unequal records")
+	seqCmd.Flags().IntP("max-report", "", 0, "maximum number of records to report for --validate-lengths and --verify-hash (0 for no limit)")
 	seqCmd.Flags().Float64P("max-qual", "R", -1, "only print sequences with average quality less than this limit (-1 for no limit)")
+	seqCmd.Flags().Float64P("max-n-frac", "", -1, "only print sequences with the fraction of N bases not greater than this value, in range of [0, 1] (-1 for no limit)")
+	seqCmd.Flags().StringP("verify-hash", "", "", `verify sequences against expected MD5 hashes in a tab-delimited file (ID and hash), type "seqkit seq -h" for details`)
+	seqCmd.Flags().BoolP("hash-case-sensitive", "", false, "compute case sensitive sequence hashes for --verify-hash")
+	seqCmd.Flags().StringP("read1", "1", "", "(gzipped) read1 file, for reversing/complementing paired reads")
+	seqCmd.Flags().StringP("read2", "2", "", "(gzipped) read2 file, for reversing/complementing paired reads")
+	seqCmd.Flags().StringP("out-dir", "O", "", "output directory for paired reads")
+	seqCmd.Flags().BoolP("drop-ambiguous", "", false, "drop sequences containing ambiguous bases (IUPAC codes other than ACGTU)")
+}
+
+// iupacComplement is the complement table of IUPAC nucleotide codes.
+// Letters not in the table are kept.
+var iupacComplement [256]byte
+
+func init() {
+	for i := range iupacComplement {
+		iupacComplement[i] = byte(i)
+	}
+	pairs := []string{"AT", "CG", "RY", "KM", "BV", "DH", "SS", "WW", "NN"}
+	for _, p := range pairs {
+		iupacComplement[p[0]], iupacComplement[p[1]] = p[1], p[0]
+		iupacComplement[p[0]+32], iupacComplement[p[1]+32] = p[1]+32, p[0]+32
+	}
+	iupacComplement['U'], iupacComplement['u'] = 'A', 'a'
+
+	iupacComplementRNA = iupacComplement
+	iupacComplementRNA['A'], iupacComplementRNA['a'] = 'U', 'u'
+}
+
+// iupacComplementRNA is the complement table for RNA, where A is complemented to U.
+var iupacComplementRNA [256]byte
+
+// ambiguousBases marks IUPAC codes of ambiguous nucleotides.
+var ambiguousBases [256]bool
+
+func init() {
+	for _, b := range []byte("RYSWKMBDHVN") {
+		ambiguousBases[b] = true
+		ambiguousBases[b+32] = true
+	}
+}
+
+// seqHasAmbiguousBases checks if a sequence contains any ambiguous bases.
+func seqHasAmbiguousBases(s []byte) bool {
+	for _, b := range s {
+		if ambiguousBases[b] {
+			return true
+		}
+	}
+	return false
+}
+
+// complementIUPACInplace complements a nucleotide sequence in place, with the case preserved.
+// A sequence containing U but no T is treated as RNA, where A is complemented to U.
+func complementIUPACInplace(s []byte) {
+	table := &iupacComplement
+	if isRNALike(s) {
+		table = &iupacComplementRNA
+	}
+	for i, b := range s {
+		s[i] = table[b]
+	}
+}
+
+// isRNALike checks if a sequence contains U/u but no T/t.
+func isRNALike(s []byte) bool {
+	var hasU bool
+	for _, b := range s {
+		switch b {
+		case 'T', 't':
+			return false
+		case 'U', 'u':
+			hasU = true
+		}
+	}
+	return hasU
 }
 
 var _mark_fasta = []byte{'>'}
 var _mark_fastq = []byte{'@'}
 var _mark_plus_newline = []byte{'+', '\n'}
 var _mark_newline = []byte{'\n'}
+
+// readSeqHashes reads expected sequence hashes from a tab-delimited file,
+// with IDs in the first column and hashes in the last one.
+// IDs are returned in the order of the file.
+func readSeqHashes(file string) ([]string, map[string]string, error) {
+	fh, err := xopen.Ropen(file)
+	if err != nil {
+		return nil, nil, fmt.Errorf("read hash file %s: %s", file, err)
+	}
+	defer fh.Close()
+
+	ids := make([]string, 0, 1024)
+	hashes := make(map[string]string, 1024)
+	scanner := bufio.NewScanner(fh)
+	var line, hash string
+	var items []string
+	var lineNum int
+	for scanner.Scan() {
+		lineNum++
+		line = strings.TrimRight(scanner.Text(), "\r\n")
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		items = strings.Split(line, "\t")
+		if len(items) < 2 {
+			return nil, nil, fmt.Errorf("read hash file %s: at least 2 columns needed at line %d: %s", file, lineNum, line)
+		}
+		hash = strings.ToLower(strings.TrimSpace(items[len(items)-1]))
+		if h, ok := hashes[items[0]]; ok {
+			if h != hash {
+				return nil, nil, fmt.Errorf("read hash file %s: different hashes for duplicated ID: %s", file, items[0])
+			}
+			continue
+		}
+		ids = append(ids, items[0])
+		hashes[items[0]] = hash
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, nil, fmt.Errorf("read hash file %s: %s", file, err)
+	}
+	return ids, hashes, nil
+}
+
+// validateFastqLengths checks lengths of sequences and qualities of 4-line FASTQ records,
+// and writes records with unequal lengths to outfh. reported is the number of records
+// reported before, which is used to cap the output with maxReport.
+// It returns the number of records with unequal lengths.
+func validateFastqLengths(outfh *xopen.Writer, file string, maxReport int, reported int) (int, error) {
+	fh, err := xopen.Ropen(file)
+	if err != nil {
+		return 0, err
+	}
+	defer fh.Close()
+
+	reader := bufio.NewReaderSize(fh, bufSize)
+	var lines [4][]byte
+	var line []byte
+	var n, i, index, lineNum int
+	for {
+		for i = 0; i < 4; i++ {
+			line, err = reader.ReadBytes('\n')
+			if err != nil {
+				if err != io.EOF {
+					return n, err
+				}
+				if len(line) == 0 {
+					if i == 0 {
+						return n, nil
+					}
+					return n, fmt.Errorf("truncated record at line %d", lineNum)
+				}
+			}
+			lineNum++
+			lines[i] = append(lines[i][:0], bytes.TrimRight(line, "\r\n")...)
+		}
+		index++
+
+		if len(lines[0]) == 0 || lines[0][0] != '@' {
+			return n, fmt.Errorf("invalid FASTQ header at line %d: %s", lineNum-3, lines[0])
+		}
+		if len(lines[2]) == 0 || lines[2][0] != '+' {
+			return n, fmt.Errorf("invalid FASTQ separator at line %d: %s", lineNum-1, lines[2])
+		}
+
+		if len(lines[1]) == len(lines[3]) {
+			continue
+		}
+		n++
+		if maxReport > 0 && reported+n > maxReport {
+			continue
+		}
+		if i = bytes.IndexAny(lines[0], " \t"); i < 0 {
+			i = len(lines[0])
+		}
+		fmt.Fprintf(outfh, "%s\t%d\t%s\t%d\t%d\n", file, index, lines[0][1:i], len(lines[1]), len(lines[3]))
+	}
+}
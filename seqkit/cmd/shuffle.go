@@ -56,6 +56,18 @@ Attention:
   1. For the two-pass mode (-2/--two-pass), The flag -U/--update-faidx is recommended to
      ensure the .fai file matches the FASTA file.
 
+Window mode (-W/--window):
+  1. Records are streamed with a buffer of N records, for each new record,
+     a random one in the buffer is outputted and replaced by the new one.
+     The left records in the buffer are shuffled and outputted at the end.
+  2. Only N records are kept in memory, and FASTQ format is supported.
+  3. It's an approximate shuffle: a record is outputted at most N positions
+     earlier than its input position, while it could be delayed further,
+     though long delays are rare.
+     Use a window much larger than the correlation length of the input order,
+     e.g., records sorted by positions or grouped by samples.
+  4. Outputs are deterministic for the same input, -s/--rand-seed and -W/--window.
+
 
 
 `,
@@ -71,7 +83,9 @@ Attention:
 		fai.MapWholeFile = false
 		runtime.GOMAXPROCS(config.Threads)
 
+		bench := getBenchmarkStats(cmd)
 		files := getFileListFromArgsAndFile(cmd, args, true, "infile-list", true)
+		bench.addInputs(files...)
 
 		seed := getFlagInt64(cmd, "rand-seed")
 		twoPass := getFlagBool(cmd, "two-pass")
@@ -84,10 +98,68 @@ Attention:
 			checkError(fmt.Errorf("flag -U (--update-faidx) must be used with flag -2 (--two-pass)"))
 		}
 
+		window := getFlagNonNegativeInt(cmd, "window")
+		if window > 0 && twoPass {
+			checkError(fmt.Errorf("flag -W (--window) is not compatible with flag -2 (--two-pass)"))
+		}
+
 		index2name := make(map[int]string)
 		var record *fastx.Record
 		var err error
 
+		if window > 0 { // streaming shuffle with a window of records
+			outfh, err := xopen.Wopen(outFile)
+			checkError(err)
+			defer outfh.Close()
+
+			r := rand.New(rand.NewSource(seed))
+			buf := make([]*fastx.Record, 0, window)
+			var j int
+			var n int
+			for _, file := range files {
+				fastxReader, err := fastx.NewReader(alphabet, file, idRegexp)
+				checkError(err)
+				for {
+					record, err = fastxReader.Read()
+					if err != nil {
+						if err == io.EOF {
+							break
+						}
+						checkError(err)
+						break
+					}
+					bench.addRecord()
+					if fastxReader.IsFastq {
+						config.LineWidth = 0
+						fastx.ForcelyOutputFastq = true
+					}
+					n++
+
+					if len(buf) < window {
+						buf = append(buf, record.Clone())
+						continue
+					}
+
+					// output a random record in the window and replace it with the new one
+					j = r.Intn(window)
+					buf[j].FormatToWriter(outfh, config.LineWidth)
+					buf[j] = record.Clone()
+				}
+				fastxReader.Close()
+			}
+
+			// left records
+			r.Shuffle(len(buf), func(i, j int) { buf[i], buf[j] = buf[j], buf[i] })
+			for _, record = range buf {
+				record.FormatToWriter(outfh, config.LineWidth)
+			}
+
+			if !quiet {
+				log.Infof("%d sequences shuffled with a window of %d records", n, window)
+			}
+			return
+		}
+
 		if !twoPass { // read all records into memory
 			sequences := make(map[string]*fastx.Record)
 
@@ -107,6 +179,7 @@ Attention:
 						checkError(err)
 						break
 					}
+					bench.addRecord()
 					if fastxReader.IsFastq {
 						config.LineWidth = 0
 						fastx.ForcelyOutputFastq = true
@@ -258,5 +331,6 @@ func init() {
 	shuffleCmd.Flags().Int64P("rand-seed", "s", 23, "rand seed for shuffle")
 	shuffleCmd.Flags().BoolP("two-pass", "2", false, "two-pass mode read files twice to lower memory usage. (only for FASTA format)")
 	shuffleCmd.Flags().BoolP("keep-temp", "k", false, "keep temporary FASTA and .fai file when using 2-pass mode")
+	shuffleCmd.Flags().IntP("window", "W", 0, `shuffle in streaming mode with a buffer of this number of records, type "seqkit shuffle -h" for details`)
 	shuffleCmd.Flags().BoolP("update-faidx", "U", false, "update the fasta index file if it exists. Use this if you are not sure whether the fasta file changed")
 }
@@ -27,6 +27,7 @@ import (
 
 	"github.com/shenwei356/bio/seq"
 	"github.com/shenwei356/bio/seqio/fastx"
+	"github.com/shenwei356/util/byteutil"
 	"github.com/shenwei356/xopen"
 	"github.com/spf13/cobra"
 )
@@ -39,6 +40,18 @@ var slidingCmd = &cobra.Command{
 	Short: "extract subsequences in sliding windows",
 	Long: `extract subsequences in sliding windows
 
+Tabular output (-T/--tab):
+  One row per window is outputted instead of FASTA/Q records, with columns:
+    1. id     sequence ID
+    2. start  1-based start position of the window
+    3. end    1-based end position of the window (inclusive). For circular
+              genomes, it's wrapped to the start of the sequence for windows
+              crossing the origin, which can be longer than the sequence.
+    4. seq    subsequence of the window
+    5. qual   qualities of the window, only for FASTQ
+    6. GC     GC content (%) of the window, only with --gc. Same to
+              "seqkit fx2tab -g", ambiguous bases are counted in the denominator.
+
 `,
 	Run: func(cmd *cobra.Command, args []string) {
 		config := getConfigs(cmd)
@@ -50,7 +63,9 @@ var slidingCmd = &cobra.Command{
 		seq.ValidateSeq = false
 		runtime.GOMAXPROCS(config.BufferSize)
 
+		bench := getBenchmarkStats(cmd)
 		files := getFileListFromArgsAndFile(cmd, args, true, "infile-list", true)
+		bench.addInputs(files...)
 
 		greedy := getFlagBool(cmd, "greedy")
 		circular := getFlagBool(cmd, "circular-genome") || getFlagBool(cmd, "circular")
@@ -67,6 +82,13 @@ var slidingCmd = &cobra.Command{
 		}
 
 		suffix := getFlagString(cmd, "suffix")
+		tab := getFlagBool(cmd, "tab")
+		printGC := getFlagBool(cmd, "gc")
+		headerLine := getFlagBool(cmd, "header-line")
+		if !tab && (printGC || headerLine) {
+			checkError(fmt.Errorf("flags --gc and -H (--header-line) only work with -T (--tab)"))
+		}
+		gcLettersBytes := []byte{'g', 'c', 'G', 'C'}
 
 		outfh, err := xopen.Wopen(outFile)
 		checkError(err)
@@ -74,8 +96,11 @@ var slidingCmd = &cobra.Command{
 
 		var sequence, s, qual, q []byte
 		var r *fastx.Record
-		var originalLen, l, end, e int
+		var i, e int
+		var ok bool
+		var nextWindow func() (int, int, bool)
 		var record *fastx.Record
+		var headerPrinted bool
 		for _, file := range files {
 			fastxReader, err := fastx.NewReader(alphabet, file, idRegexp)
 			checkError(err)
@@ -88,44 +113,52 @@ var slidingCmd = &cobra.Command{
 					checkError(err)
 					break
 				}
+				bench.addRecord()
 				if fastxReader.IsFastq {
 					config.LineWidth = 0
 					fastx.ForcelyOutputFastq = true
 				}
 
-				originalLen = len(record.Seq.Seq)
 				sequence = record.Seq.Seq
 				qual = record.Seq.Qual
-				l = len(sequence)
-				end = l - 1
-				if end < 0 {
-					end = 0
+
+				if tab && headerLine && !headerPrinted {
+					outfh.WriteString("id\tstart\tend\tseq")
+					if fastxReader.IsFastq {
+						outfh.WriteString("\tqual")
+					}
+					if printGC {
+						outfh.WriteString("\tGC")
+					}
+					outfh.WriteString("\n")
+					headerPrinted = true
 				}
-				for i := 0; i <= end; i += step {
-					e = i + window
-					if e > originalLen {
-						if circular {
-							e = e - originalLen
-							s = sequence[i:]
-							s = append(s, sequence[0:e]...)
-							if len(qual) > 0 {
-								q = qual[i:]
-								q = append(q, qual[0:e]...)
-							}
-						} else if greedy {
-							s = sequence[i:]
-							if len(qual) > 0 {
-								q = qual[i:]
-							}
-							e = l
-						} else {
-							break
+
+				nextWindow = slidingWindows(len(sequence), window, step, circular, greedy)
+				for {
+					i, e, ok = nextWindow()
+					if !ok {
+						break
+					}
+
+					s = windowSlice(sequence, i, e)
+					if len(qual) > 0 {
+						q = windowSlice(qual, i, e)
+					}
+
+					e = circularEnd(e, len(sequence))
+
+					if tab {
+						fmt.Fprintf(outfh, "%s\t%d\t%d\t%s", record.ID, i+1, e, s)
+						if fastxReader.IsFastq {
+							outfh.WriteString("\t")
+							outfh.Write(q)
 						}
-					} else {
-						s = sequence[i : i+window]
-						if len(qual) > 0 {
-							q = qual[i : i+window]
+						if printGC {
+							fmt.Fprintf(outfh, "\t%.2f", float64(byteutil.CountBytes(s, gcLettersBytes))/float64(len(s))*100)
 						}
+						outfh.WriteString("\n")
+						continue
 					}
 
 					if len(qual) > 0 {
@@ -145,6 +178,59 @@ var slidingCmd = &cobra.Command{
 	},
 }
 
+// slidingWindows returns a function for iterating sliding windows of a sequence,
+// which returns 0-based start and end (exclusive) positions.
+// For circular genomes, the end of the window crossing the origin is greater than
+// the length, use circularEnd to wrap it.
+// If not circular and greedy is true, the last window shorter than the window size is also returned.
+func slidingWindows(length int, window int, step int, circular bool, greedy bool) func() (int, int, bool) {
+	last := length - 1
+	if last < 0 {
+		last = 0
+	}
+
+	var i, e int
+	return func() (int, int, bool) {
+		if i > last {
+			return 0, 0, false
+		}
+		start := i
+		e = i + window
+		if e > length && !(circular && length > 0) {
+			if greedy {
+				e = length
+			} else {
+				return 0, 0, false
+			}
+		}
+		i += step
+		return start, e, true
+	}
+}
+
+// circularEnd wraps the end position of a window crossing the origin of
+// a circular sequence to the range of [1, length].
+func circularEnd(end int, length int) int {
+	if end <= length || length == 0 {
+		return end
+	}
+	return (end-1)%length + 1
+}
+
+// windowSlice returns the data of a window from slidingWindows.
+// For a window crossing the origin, bases are read from the start of the
+// sequence again, even for windows longer than the sequence.
+func windowSlice(data []byte, start int, end int) []byte {
+	if end <= len(data) {
+		return data[start:end]
+	}
+	s := make([]byte, end-start)
+	for i := range s {
+		s[i] = data[(start+i)%len(data)]
+	}
+	return s
+}
+
 func init() {
 	RootCmd.AddCommand(slidingCmd)
 
@@ -154,4 +240,7 @@ func init() {
 	slidingCmd.Flags().BoolP("circular-genome", "C", false, "circular genome (same to -c/--circular)")
 	slidingCmd.Flags().BoolP("circular", "c", false, "circular genome (same to -C/--circular-genome)")
 	slidingCmd.Flags().StringP("suffix", "S", "_sliding", "suffix added to the sequence ID")
+	slidingCmd.Flags().BoolP("tab", "T", false, "output windows in tabular format (id, start, end, seq), type \"seqkit sliding -h\" for details")
+	slidingCmd.Flags().BoolP("gc", "", false, "append GC content (%) of each window (only for -T/--tab)")
+	slidingCmd.Flags().BoolP("header-line", "H", false, "print header line (only for -T/--tab)")
 }
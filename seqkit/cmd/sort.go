@@ -21,6 +21,7 @@
 package cmd
 
 import (
+	"bufio"
 	"bytes"
 	"fmt"
 	"io"
@@ -33,6 +34,7 @@ import (
 	"github.com/shenwei356/bio/seq"
 	"github.com/shenwei356/bio/seqio/fai"
 	"github.com/shenwei356/bio/seqio/fastx"
+	"github.com/shenwei356/natsort"
 	"github.com/shenwei356/util/stringutil"
 	"github.com/shenwei356/xopen"
 	"github.com/spf13/cobra"
@@ -57,6 +59,47 @@ seqkit will write the sequences to temporary files, and create FASTA index.
 Secondly, seqkit sorts sequence by head and length information
 and extracts sequences by FASTA index.
 
+When sorting by sequence (-s/--by-seq):
+  1. Flag -i/--ignore-case compares sequences case-insensitively.
+  2. Flag -c/--canonical uses the lexically smaller one of the sequence and its
+     reverse complement as the key, so both strands give the same order.
+  3. Ties are broken by sequence IDs, so the output is fully deterministic.
+  4. In the two-pass mode, only sequence prefixes (-L/--seq-prefix-length) are
+     kept in memory, and records are extracted via the FASTA index.
+
+Sorting by multiple keys (-K/--keys):
+  1. Keys are given in order with optional directions (asc or desc, default asc),
+     e.g., -K length:desc,id:asc. Available keys:
+         id, name, seq, length, bases (non-gap bases), gc (GC content)
+  2. The sort is stable, records with equal keys keep the input order.
+  3. Flags -i/--ignore-case, -N/--natural-order (for id and name),
+     -c/--canonical (for seq), -G/--gap-letters (for bases) and
+     -L/--seq-prefix-length (in two-pass mode) still apply.
+  4. It is not compatible with -s, -n, -l, -b and -r.
+  5. In two-pass mode, keys of all records are computed in the first pass.
+
+External sort mode (--external):
+  1. Records are buffered until the size reaches --max-mem, then they are
+     sorted and written to a temporary file (a sorted run) in $TMPDIR
+     (or --tmp-dir). At last, all runs are k-way merged to the output.
+     So files larger than RAM, including FASTQ files, can be sorted.
+  2. All sort keys above are supported, the sort is stable, and the output
+     is the same as that of the default mode, except for the order of
+     records with equal keys (e.g., same lengths), which keep the input order.
+  3. Duplicated IDs are not checked.
+  4. Temporary files are removed on exit, including interruption.
+  5. It is not compatible with -2/--two-pass.
+
+Putting records first (--priority-file):
+  1. Records whose IDs (or full names with -n/--by-name) appear in the file,
+     one per line, are output first in the order of the file, followed by
+     the rest in the order given by other flags.
+  2. IDs in the file but absent from the input are silently skipped.
+     Blank lines and lines starting with "#" are ignored.
+  3. Flag -i/--ignore-case also applies to IDs in the file.
+  4. It works in the two-pass mode, but is not compatible with -K/--keys
+     and --external.
+
 Attention:
   1. For the two-pass mode (-2/--two-pass), The flag -U/--update-faidx is recommended to
      ensure the .fai file matches the FASTA file.
@@ -74,7 +117,9 @@ Attention:
 		fai.MapWholeFile = false
 		runtime.GOMAXPROCS(config.Threads)
 
+		bench := getBenchmarkStats(cmd)
 		files := getFileListFromArgsAndFile(cmd, args, true, "infile-list", true)
+		bench.addInputs(files...)
 
 		inNaturalOrder := getFlagBool(cmd, "natural-order")
 		bySeq := getFlagBool(cmd, "by-seq")
@@ -84,6 +129,7 @@ Attention:
 		gapLetters := getFlagString(cmd, "gap-letters")
 		reverse := getFlagBool(cmd, "reverse")
 		ignoreCase := getFlagBool(cmd, "ignore-case")
+		canonical := getFlagBool(cmd, "canonical")
 		twoPass := getFlagBool(cmd, "two-pass")
 		updateFaidx := getFlagBool(cmd, "update-faidx")
 		seqPrefixLength := getFlagNonNegativeInt(cmd, "seq-prefix-length")
@@ -94,6 +140,66 @@ Attention:
 		if updateFaidx && !twoPass {
 			checkError(fmt.Errorf("flag -U (--update-faidx) must be used with flag -2 (--two-pass)"))
 		}
+		external := getFlagBool(cmd, "external")
+		var maxMem int64
+		var tmpDir string
+		if external {
+			if twoPass {
+				checkError(fmt.Errorf("flag --external is not compatible with -2 (--two-pass)"))
+			}
+			var err error
+			maxMem, err = ParseByteSize(getFlagString(cmd, "max-mem"))
+			if err != nil {
+				checkError(fmt.Errorf("invalid value of flag --max-mem: %s", err))
+			}
+			if maxMem <= 0 {
+				checkError(fmt.Errorf("the value of flag --max-mem should be positive"))
+			}
+			tmpDir = getFlagString(cmd, "tmp-dir")
+		}
+
+		keys := getFlagStringSlice(cmd, "keys")
+
+		priorityFile := getFlagString(cmd, "priority-file")
+		var priority map[string]int
+		if priorityFile != "" {
+			if len(keys) > 0 || external {
+				checkError(fmt.Errorf("flag --priority-file is not compatible with -K (--keys) and --external"))
+			}
+			var err error
+			priority, err = readSortPriorityFile(priorityFile, ignoreCase)
+			checkError(err)
+		}
+
+		if len(keys) > 0 {
+			if bySeq || byName || byLength || byBases || reverse {
+				checkError(fmt.Errorf("flag -K (--keys) is not compatible with -s, -n, -l, -b and -r"))
+			}
+			sortKeys, err := parseSortKeys(keys)
+			checkError(err)
+			opt := &sortKeyOptions{
+				ignoreCase:      ignoreCase,
+				naturalOrder:    inNaturalOrder,
+				canonical:       canonical,
+				gapLetters:      gapLetters,
+				seqPrefixLength: seqPrefixLength,
+			}
+			if external {
+				sortByKeysExternal(files, sortKeys, opt, alphabet, idRegexp, outFile, config.LineWidth, maxMem, tmpDir, quiet, bench)
+			} else if twoPass {
+				if len(files) > 1 {
+					checkError(fmt.Errorf("no more than one file should be given"))
+				}
+				sortByKeysTwoPass(files[0], sortKeys, opt, idRegexp, outFile, updateFaidx, keepTemp, quiet, bench)
+			} else {
+				sortByKeys(files, sortKeys, opt, alphabet, idRegexp, outFile, config.LineWidth, quiet, bench)
+			}
+			return
+		}
+
+		if canonical && !bySeq {
+			checkError(fmt.Errorf("flag -c (--canonical) must be used with flag -s (--by-seq)"))
+		}
 
 		if byBases {
 			byLength = true
@@ -129,6 +235,31 @@ Attention:
 			}
 		}
 
+		if external {
+			// the legacy flags are equivalent to keys
+			var sortKeys []sortKey
+			switch {
+			case bySeq:
+				sortKeys = []sortKey{{kind: sortKeySeq, desc: reverse}, {kind: sortKeyID, desc: reverse}}
+			case byBases:
+				sortKeys = []sortKey{{kind: sortKeyBases, desc: reverse}}
+			case byLength:
+				sortKeys = []sortKey{{kind: sortKeyLength, desc: reverse}}
+			case byName:
+				sortKeys = []sortKey{{kind: sortKeyName, desc: reverse}}
+			default:
+				sortKeys = []sortKey{{kind: sortKeyID, desc: reverse}}
+			}
+			opt := &sortKeyOptions{
+				ignoreCase:   ignoreCase,
+				naturalOrder: inNaturalOrder,
+				canonical:    canonical,
+				gapLetters:   gapLetters,
+			}
+			sortByKeysExternal(files, sortKeys, opt, alphabet, idRegexp, outFile, config.LineWidth, maxMem, tmpDir, quiet, bench)
+			return
+		}
+
 		name2name0 := make(map[string]string, 1000)
 		name2sequence := []stringutil.String2ByteSlice{}
 		name2length := []stringutil.StringCount{}
@@ -158,6 +289,7 @@ Attention:
 						checkError(err)
 						break
 					}
+					bench.addRecord()
 					if fastxReader.IsFastq {
 						config.LineWidth = 0
 						fastx.ForcelyOutputFastq = true
@@ -190,7 +322,9 @@ Attention:
 							length = len(record2.Seq.Seq)
 						}
 						name2length = append(name2length, stringutil.StringCount{Key: name, Count: length})
-					} else if byID || byName || bySeq {
+					} else if bySeq {
+						name2sequence = append(name2sequence, stringutil.String2ByteSlice{Key: name, Value: seqSortKey(record2.Seq, ignoreCase, canonical)})
+					} else if byID || byName {
 						if ignoreCase {
 							name2sequence = append(name2sequence, stringutil.String2ByteSlice{Key: name, Value: bytes.ToLower(record2.Seq.Seq)})
 						} else {
@@ -207,11 +341,7 @@ Attention:
 			}
 
 			if bySeq {
-				if reverse {
-					sort.Sort(stringutil.ReversedByValue{stringutil.String2ByteSliceList(name2sequence)})
-				} else {
-					sort.Sort(stringutil.ByValue{stringutil.String2ByteSliceList(name2sequence)})
-				}
+				sortBySeq(name2sequence, reverse)
 			} else if byLength {
 				if reverse {
 					sort.Sort(stringutil.ReversedStringCountList{stringutil.StringCountList(name2length)})
@@ -227,6 +357,11 @@ Attention:
 				}
 			}
 
+			if priority != nil {
+				name2sequence = sortPrioritizeSequences(name2sequence, priority)
+				name2length = sortPrioritizeLengths(name2length, priority)
+			}
+
 			if !quiet {
 				log.Infof("output ...")
 			}
@@ -256,61 +391,14 @@ Attention:
 
 		file := files[0]
 
-		var alphabet2 *seq.Alphabet
-
-		newFile := file
-		if isStdin(file) || !isPlainFile(file) {
-			if isStdin(file) {
-				newFile = "stdin" + ".fastx"
-			} else {
-				newFile = file + ".fastx"
-			}
-			if !quiet {
-				log.Infof("read and write sequences to temporary file: %s ...", newFile)
-			}
-
-			var nseqs int
-			nseqs, err = copySeqs(file, newFile)
-			checkError(err)
-			if !quiet {
-				log.Infof("%d sequences saved", nseqs)
-			}
-
-			var isFastq bool
-			var err error
-			alphabet2, isFastq, err = fastx.GuessAlphabet(newFile)
-			checkError(err)
-			if isFastq {
-				checkError(os.Remove(newFile))
-				checkError(fmt.Errorf("Sorry, two-pass mode does not support FASTQ format"))
-			}
-		}
-
-		fileFai := newFile + ".seqkit.fai"
-
-		if FileExists(fileFai) && updateFaidx {
-			checkError(os.RemoveAll(fileFai))
-			if !quiet {
-				log.Infof("delete the old FASTA index file: %s", fileFai)
-			}
-		}
-
-		if !quiet {
-			log.Infof("create or read FASTA index ...")
+		newFile, alphabet2, faidx := sortTwoPassPrepare(file, updateFaidx, quiet)
+		if faidx == nil {
+			return
 		}
-
-		faidx := getFaidx(newFile, `^(.+)$`, quiet)
 		defer func() {
 			checkError(faidx.Close())
 		}()
 
-		if len(faidx.Index) == 0 {
-			log.Warningf("  0 records loaded from %s, please check if it matches the fasta file, or switch on the flag -U/--update-faidx", fileFai)
-			return
-		} else if !quiet {
-			log.Infof("  %d records loaded from %s", len(faidx.Index), fileFai)
-		}
-
 		if !bySeq { // if not by seq, just read faidx
 			if !quiet {
 				log.Infof("read sequence IDs and lengths from FASTA index ...")
@@ -367,6 +455,7 @@ Attention:
 					checkError(err)
 					break
 				}
+				bench.addRecord()
 
 				if byName {
 					name = string(record.Name)
@@ -382,12 +471,13 @@ Attention:
 				if ignoreCase {
 					name2name0[strings.ToLower(name)] = name
 					name = strings.ToLower(name)
+				} else {
+					name2name0[name] = name
 				}
 
-				if seqPrefixLength == 0 || len(record.Seq.Seq) <= seqPrefixLength {
-					prefix = record.Seq.Seq
-				} else {
-					prefix = record.Seq.Seq[0:seqPrefixLength]
+				prefix = seqSortKey(record.Seq, ignoreCase, canonical)
+				if seqPrefixLength > 0 && len(prefix) > seqPrefixLength {
+					prefix = prefix[0:seqPrefixLength]
 				}
 				name2sequence = append(name2sequence,
 					stringutil.String2ByteSlice{Key: name, Value: []byte(string(prefix))})
@@ -410,11 +500,7 @@ Attention:
 		}
 
 		if bySeq {
-			if reverse {
-				sort.Sort(stringutil.ReversedByValue{stringutil.String2ByteSliceList(name2sequence)})
-			} else {
-				sort.Sort(stringutil.ByValue{stringutil.String2ByteSliceList(name2sequence)})
-			}
+			sortBySeq(name2sequence, reverse)
 		} else if byLength {
 			if reverse {
 				sort.Sort(stringutil.ReversedStringCountList{stringutil.StringCountList(name2length)})
@@ -430,6 +516,11 @@ Attention:
 			}
 		}
 
+		if priority != nil {
+			name2sequence = sortPrioritizeSequences(name2sequence, priority)
+			name2length = sortPrioritizeLengths(name2length, priority)
+		}
+
 		if !quiet {
 			log.Infof("output ...")
 		}
@@ -490,9 +581,484 @@ func init() {
 	sortCmd.Flags().StringP("gap-letters", "G", "- 	.", "gap letters")
 	sortCmd.Flags().BoolP("reverse", "r", false, "reverse the result")
 	sortCmd.Flags().BoolP("ignore-case", "i", false, "ignore case")
+	sortCmd.Flags().BoolP("canonical", "c", false, "when sorting by sequence, use the smaller one of the sequence and its reverse complement as the key")
 
 	sortCmd.Flags().BoolP("two-pass", "2", false, "two-pass mode read files twice to lower memory usage. (only for FASTA format)")
 	sortCmd.Flags().BoolP("update-faidx", "U", false, "update the fasta index file if it exists. Use this if you are not sure whether the fasta file changed")
 	sortCmd.Flags().BoolP("keep-temp", "k", false, "keep temporary FASTA and .fai file when using 2-pass mode")
+	sortCmd.Flags().StringSliceP("keys", "K", []string{}, `sort by multiple keys in order, with optional directions, e.g., -K length:desc,id:asc. available keys: id, name, seq, length, bases, gc`)
 	sortCmd.Flags().IntP("seq-prefix-length", "L", 10000, "length of sequence prefix on which seqkit sorts by sequences (0 for whole sequence)")
+
+	sortCmd.Flags().BoolP("external", "", false, "external merge sort: write sorted runs to temporary files and merge them, for files larger than RAM. FASTQ supported")
+	sortCmd.Flags().StringP("max-mem", "", "1G", `approximate maximum memory of records in a sorted run in external sort mode (--external), supported units: K, M, G`)
+	sortCmd.Flags().StringP("tmp-dir", "", os.TempDir(), `directory for temporary files in external sort mode (--external), the default value is $TMPDIR`)
+	sortCmd.Flags().StringP("priority-file", "", "", "file of IDs (one per line) whose records are output first, in the order of the file")
+}
+
+// readSortPriorityFile reads IDs from a file, and returns the ranks of IDs.
+// Blank lines and lines starting with "#" are ignored.
+func readSortPriorityFile(file string, ignoreCase bool) (map[string]int, error) {
+	fh, err := xopen.Ropen(file)
+	if err != nil {
+		return nil, fmt.Errorf("read priority file '%s': %s", file, err)
+	}
+	defer fh.Close()
+
+	priority := make(map[string]int, 1024)
+	scanner := bufio.NewScanner(fh)
+	scanner.Buffer(make([]byte, 0, 1<<20), 1<<30)
+	var id string
+	for scanner.Scan() {
+		id = strings.TrimSpace(scanner.Text())
+		if id == "" || id[0] == '#' {
+			continue
+		}
+		if ignoreCase {
+			id = strings.ToLower(id)
+		}
+		if _, ok := priority[id]; !ok {
+			priority[id] = len(priority)
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read priority file '%s': %s", file, err)
+	}
+	return priority, nil
+}
+
+// sortPriorityOrder returns the new order of sorted keys, with keys in priority
+// coming first in the order of their ranks, and the others keeping their order.
+func sortPriorityOrder(n int, key func(i int) string, priority map[string]int) []int {
+	first := make([]int, 0, len(priority))
+	order := make([]int, 0, n)
+	for i := 0; i < n; i++ {
+		if _, ok := priority[key(i)]; ok {
+			first = append(first, i)
+		} else {
+			order = append(order, i)
+		}
+	}
+	sort.SliceStable(first, func(a, b int) bool {
+		return priority[key(first[a])] < priority[key(first[b])]
+	})
+	return append(first, order...)
+}
+
+func sortPrioritizeSequences(list []stringutil.String2ByteSlice, priority map[string]int) []stringutil.String2ByteSlice {
+	order := sortPriorityOrder(len(list), func(i int) string { return list[i].Key }, priority)
+	list2 := make([]stringutil.String2ByteSlice, len(list))
+	for i, j := range order {
+		list2[i] = list[j]
+	}
+	return list2
+}
+
+func sortPrioritizeLengths(list []stringutil.StringCount, priority map[string]int) []stringutil.StringCount {
+	order := sortPriorityOrder(len(list), func(i int) string { return list[i].Key }, priority)
+	list2 := make([]stringutil.StringCount, len(list))
+	for i, j := range order {
+		list2[i] = list[j]
+	}
+	return list2
+}
+
+// sortTwoPassPrepare writes records to a temporary file if the input is not a plain FASTA file,
+// and creates or reads the FASTA index. The returned faidx is nil if no records are found.
+func sortTwoPassPrepare(file string, updateFaidx bool, quiet bool) (string, *seq.Alphabet, *fai.Faidx) {
+	var alphabet2 *seq.Alphabet
+	var err error
+
+	newFile := file
+	if isStdin(file) || !isPlainFile(file) {
+		if isStdin(file) {
+			newFile = "stdin" + ".fastx"
+		} else {
+			newFile = file + ".fastx"
+		}
+		if !quiet {
+			log.Infof("read and write sequences to temporary file: %s ...", newFile)
+		}
+
+		var nseqs int
+		nseqs, err = copySeqs(file, newFile)
+		checkError(err)
+		if !quiet {
+			log.Infof("%d sequences saved", nseqs)
+		}
+
+		var isFastq bool
+		alphabet2, isFastq, err = fastx.GuessAlphabet(newFile)
+		checkError(err)
+		if isFastq {
+			checkError(os.Remove(newFile))
+			checkError(fmt.Errorf("Sorry, two-pass mode does not support FASTQ format"))
+		}
+	}
+
+	fileFai := newFile + ".seqkit.fai"
+
+	if FileExists(fileFai) && updateFaidx {
+		checkError(os.RemoveAll(fileFai))
+		if !quiet {
+			log.Infof("delete the old FASTA index file: %s", fileFai)
+		}
+	}
+
+	if !quiet {
+		log.Infof("create or read FASTA index ...")
+	}
+
+	faidx := getFaidx(newFile, `^(.+)$`, quiet)
+
+	if len(faidx.Index) == 0 {
+		log.Warningf("  0 records loaded from %s, please check if it matches the fasta file, or switch on the flag -U/--update-faidx", fileFai)
+		checkError(faidx.Close())
+		return newFile, alphabet2, nil
+	} else if !quiet {
+		log.Infof("  %d records loaded from %s", len(faidx.Index), fileFai)
+	}
+
+	return newFile, alphabet2, faidx
+}
+
+// seqSortKey returns the key for sorting by sequence.
+func seqSortKey(s *seq.Seq, ignoreCase bool, canonical bool) []byte {
+	key := s.Seq
+	if ignoreCase {
+		key = bytes.ToLower(key)
+	}
+	if canonical {
+		rc := s.RevCom().Seq
+		if ignoreCase {
+			rc = bytes.ToLower(rc)
+		}
+		if bytes.Compare(rc, key) < 0 {
+			key = rc
+		}
+	}
+	return key
+}
+
+// sortBySeq sorts records by sequence, ties are broken by IDs to make the order deterministic.
+func sortBySeq(list []stringutil.String2ByteSlice, reverse bool) {
+	sort.Slice(list, func(i, j int) bool {
+		c := bytes.Compare(list[i].Value, list[j].Value)
+		if c == 0 {
+			c = strings.Compare(list[i].Key, list[j].Key)
+		}
+		if reverse {
+			return c > 0
+		}
+		return c < 0
+	})
+}
+
+const (
+	sortKeyID = iota
+	sortKeyName
+	sortKeySeq
+	sortKeyLength
+	sortKeyBases
+	sortKeyGC
+)
+
+var sortKeyNames = map[string]int{
+	"id":     sortKeyID,
+	"name":   sortKeyName,
+	"seq":    sortKeySeq,
+	"length": sortKeyLength,
+	"bases":  sortKeyBases,
+	"gc":     sortKeyGC,
+}
+
+// sortKey is a key for sorting, with the direction.
+type sortKey struct {
+	kind int
+	desc bool
+}
+
+// sortKeyOptions contains options affecting how keys are computed and compared.
+type sortKeyOptions struct {
+	ignoreCase      bool
+	naturalOrder    bool
+	canonical       bool
+	gapLetters      string
+	seqPrefixLength int // only for two-pass mode
+}
+
+// sortKeyRecord stores precomputed keys of a record.
+type sortKeyRecord struct {
+	record *fastx.Record // nil in two-pass mode
+	head   string        // full head, for extracting sequences in two-pass mode
+
+	id     string
+	name   string
+	seq    []byte
+	length int
+	bases  int
+	gc     float64
+}
+
+// parseSortKeys parses keys like "length:desc".
+func parseSortKeys(keys []string) ([]sortKey, error) {
+	sortKeys := make([]sortKey, 0, len(keys))
+	seen := make(map[int]bool, len(keys))
+	for _, k := range keys {
+		items := strings.Split(strings.ToLower(strings.TrimSpace(k)), ":")
+		if len(items) > 2 {
+			return nil, fmt.Errorf("invalid sort key: %s. format: key or key:asc/desc", k)
+		}
+		kind, ok := sortKeyNames[items[0]]
+		if !ok {
+			return nil, fmt.Errorf("invalid sort key: %s. available: id, name, seq, length, bases, gc", items[0])
+		}
+		if seen[kind] {
+			return nil, fmt.Errorf("duplicated sort key: %s", items[0])
+		}
+		seen[kind] = true
+
+		var desc bool
+		if len(items) == 2 {
+			switch items[1] {
+			case "asc":
+			case "desc":
+				desc = true
+			default:
+				return nil, fmt.Errorf("invalid sort direction: %s. available: asc, desc", items[1])
+			}
+		}
+		sortKeys = append(sortKeys, sortKey{kind: kind, desc: desc})
+	}
+	return sortKeys, nil
+}
+
+// newSortKeyRecord computes keys needed for a record.
+func newSortKeyRecord(record *fastx.Record, keys []sortKey, opt *sortKeyOptions, prefixOnly bool) *sortKeyRecord {
+	r := &sortKeyRecord{}
+	var g, c float64
+	for _, k := range keys {
+		switch k.kind {
+		case sortKeyID:
+			r.id = string(record.ID)
+			if opt.ignoreCase {
+				r.id = strings.ToLower(r.id)
+			}
+		case sortKeyName:
+			r.name = string(record.Name)
+			if opt.ignoreCase {
+				r.name = strings.ToLower(r.name)
+			}
+		case sortKeySeq:
+			r.seq = seqSortKey(record.Seq, opt.ignoreCase, opt.canonical)
+			if prefixOnly && opt.seqPrefixLength > 0 && len(r.seq) > opt.seqPrefixLength {
+				r.seq = r.seq[0:opt.seqPrefixLength]
+			}
+			r.seq = []byte(string(r.seq))
+		case sortKeyLength:
+			r.length = len(record.Seq.Seq)
+		case sortKeyBases:
+			r.bases = record.Seq.Bases(opt.gapLetters)
+		case sortKeyGC:
+			g = record.Seq.BaseContent("G")
+			c = record.Seq.BaseContent("C")
+			r.gc = g + c
+		}
+	}
+	return r
+}
+
+// sortKeyRecords sorts records by multiple keys stably.
+func sortKeyRecords(list []*sortKeyRecord, keys []sortKey, opt *sortKeyOptions) {
+	sort.SliceStable(list, func(i, j int) bool {
+		return compareSortKeyRecords(list[i], list[j], keys, opt) < 0
+	})
+}
+
+// compareSortKeyRecords compares two records by multiple keys, with directions
+// considered. It returns 0 if all keys are equal.
+func compareSortKeyRecords(a, b *sortKeyRecord, keys []sortKey, opt *sortKeyOptions) int {
+	var v int
+	for _, k := range keys {
+		switch k.kind {
+		case sortKeyID:
+			v = compareSortStrings(a.id, b.id, opt)
+		case sortKeyName:
+			v = compareSortStrings(a.name, b.name, opt)
+		case sortKeySeq:
+			v = bytes.Compare(a.seq, b.seq)
+		case sortKeyLength:
+			v = compareInts(a.length, b.length)
+		case sortKeyBases:
+			v = compareInts(a.bases, b.bases)
+		case sortKeyGC:
+			if a.gc < b.gc {
+				v = -1
+			} else if a.gc > b.gc {
+				v = 1
+			} else {
+				v = 0
+			}
+		}
+		if v != 0 {
+			if k.desc {
+				return -v
+			}
+			return v
+		}
+	}
+	return 0
+}
+
+func compareSortStrings(a, b string, opt *sortKeyOptions) int {
+	if a == b {
+		return 0
+	}
+	if opt.naturalOrder {
+		if natsort.Compare(a, b, opt.ignoreCase) {
+			return -1
+		}
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+func compareInts(a, b int) int {
+	if a < b {
+		return -1
+	}
+	if a > b {
+		return 1
+	}
+	return 0
+}
+
+// sortByKeys reads all records into memory and sorts them by multiple keys.
+func sortByKeys(files []string, keys []sortKey, opt *sortKeyOptions, alphabet *seq.Alphabet, idRegexp string,
+	outFile string, lineWidth int, quiet bool, bench *benchmarkStats) {
+
+	if !quiet {
+		log.Infof("read sequences ...")
+	}
+	list := make([]*sortKeyRecord, 0, 1024)
+	var record *fastx.Record
+	var r *sortKeyRecord
+	for _, file := range files {
+		fastxReader, err := fastx.NewReader(alphabet, file, idRegexp)
+		checkError(err)
+		for {
+			record, err = fastxReader.Read()
+			if err != nil {
+				if err == io.EOF {
+					break
+				}
+				checkError(err)
+				break
+			}
+			bench.addRecord()
+			if fastxReader.IsFastq {
+				lineWidth = 0
+				fastx.ForcelyOutputFastq = true
+			}
+
+			record = record.Clone()
+			r = newSortKeyRecord(record, keys, opt, false)
+			r.record = record
+			list = append(list, r)
+		}
+		fastxReader.Close()
+	}
+
+	if !quiet {
+		log.Infof("%d sequences loaded", len(list))
+		log.Infof("sorting ...")
+	}
+	sortKeyRecords(list, keys, opt)
+
+	if !quiet {
+		log.Infof("output ...")
+	}
+	outfh, err := xopen.Wopen(outFile)
+	checkError(err)
+	defer outfh.Close()
+
+	for _, r = range list {
+		r.record.FormatToWriter(outfh, lineWidth)
+	}
+}
+
+// sortByKeysTwoPass computes keys of all records in the first pass, and extracts
+// sequences in order via the FASTA index in the second pass.
+func sortByKeysTwoPass(file string, keys []sortKey, opt *sortKeyOptions, idRegexp string,
+	outFile string, updateFaidx bool, keepTemp bool, quiet bool, bench *benchmarkStats) {
+
+	newFile, alphabet2, faidx := sortTwoPassPrepare(file, updateFaidx, quiet)
+	if faidx == nil {
+		return
+	}
+	defer func() {
+		checkError(faidx.Close())
+	}()
+
+	if !quiet {
+		log.Infof("compute sort keys from FASTA file ...")
+	}
+	fastxReader, err := fastx.NewReader(alphabet2, newFile, idRegexp)
+	checkError(err)
+	list := make([]*sortKeyRecord, 0, len(faidx.Index))
+	heads := make(map[string]struct{}, len(faidx.Index))
+	var record *fastx.Record
+	var r *sortKeyRecord
+	for {
+		record, err = fastxReader.Read()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			checkError(err)
+			break
+		}
+		bench.addRecord()
+
+		r = newSortKeyRecord(record, keys, opt, true)
+		r.head = string(record.Name)
+		if _, ok := heads[r.head]; ok {
+			checkError(fmt.Errorf(`duplicated sequences found: %s. use "seqkit rename" to rename duplicated IDs`, r.head))
+		}
+		heads[r.head] = struct{}{}
+		list = append(list, r)
+	}
+	fastxReader.Close()
+
+	if !quiet {
+		log.Infof("%d sequences loaded", len(list))
+		log.Infof("sorting ...")
+	}
+	sortKeyRecords(list, keys, opt)
+
+	if !quiet {
+		log.Infof("output ...")
+	}
+	outfh, err := xopen.Wopen(outFile)
+	checkError(err)
+	defer outfh.Close()
+
+	for _, r = range list {
+		fr, ok := faidx.Index[r.head]
+		if !ok {
+			checkError(fmt.Errorf(`sequence (%s) not found in file: %s`, r.head, newFile))
+			continue
+		}
+
+		sequence := subseqByFaixNotCleaned(faidx, r.head, fr, 1, -1)
+		outfh.Write([]byte(fmt.Sprintf(">%s\n", r.head)))
+		outfh.Write(sequence)
+		if len(sequence) > 0 && sequence[len(sequence)-1] != '\n' {
+			outfh.WriteString("\n")
+		}
+	}
+
+	if (isStdin(file) || !isPlainFile(file)) && !keepTemp {
+		checkError(os.Remove(newFile))
+		checkError(os.Remove(newFile + ".seqkit.fai"))
+	}
 }
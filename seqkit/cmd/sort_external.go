@@ -0,0 +1,428 @@
+// Copyright © 2016-2024 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"bufio"
+	"container/heap"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/shenwei356/bio/seq"
+	"github.com/shenwei356/bio/seqio/fastx"
+	"github.com/shenwei356/xopen"
+)
+
+// sortExternalBytesPerRecord is the estimated memory overhead of a record
+// in a run, besides the sequence data and keys.
+const sortExternalBytesPerRecord = 256
+
+// sortExternalMaxOpenRuns is the maximum number of runs merged at once,
+// to avoid exceeding the limit of open files.
+const sortExternalMaxOpenRuns = 256
+
+// externalSorter sorts records with bounded memory: records are buffered
+// until the size reaches maxMem, then they are sorted and written to a
+// temporary file (a run), and all runs are k-way merged at last.
+type externalSorter struct {
+	keys     []sortKey
+	opt      *sortKeyOptions
+	maxMem   int64
+	tmpDir   string
+	alphabet *seq.Alphabet
+
+	buf  []*sortKeyRecord
+	size int64
+
+	dir      string
+	runs     []string
+	nRuns    int // for naming run files
+	nSpilled int
+
+	mu     sync.Mutex
+	closed bool
+}
+
+func newExternalSorter(keys []sortKey, opt *sortKeyOptions, maxMem int64, tmpDir string) (*externalSorter, error) {
+	if maxMem <= 0 {
+		return nil, fmt.Errorf("the maximum memory should be positive: %d", maxMem)
+	}
+	return &externalSorter{
+		keys:   keys,
+		opt:    opt,
+		maxMem: maxMem,
+		tmpDir: tmpDir,
+		buf:    make([]*sortKeyRecord, 0, 1024),
+	}, nil
+}
+
+// Add adds a record, which should not be reused by the caller.
+func (s *externalSorter) Add(record *fastx.Record) error {
+	if s.alphabet == nil {
+		s.alphabet = record.Seq.Alphabet
+	}
+	r := newSortKeyRecord(record, s.keys, s.opt, false)
+	r.record = record
+	s.buf = append(s.buf, r)
+	s.size += int64(len(record.Name) + len(record.Seq.Seq) + len(record.Seq.Qual) +
+		len(r.id) + len(r.name) + len(r.seq) + sortExternalBytesPerRecord)
+	if s.size >= s.maxMem {
+		return s.spill()
+	}
+	return nil
+}
+
+// Runs returns the number of sorted runs written to disk.
+func (s *externalSorter) Runs() int { return s.nSpilled }
+
+func (s *externalSorter) ensureDir() error {
+	if s.dir != "" {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	dir, err := os.MkdirTemp(s.tmpDir, "seqkit-sort-")
+	if err != nil {
+		return err
+	}
+	s.dir = dir
+	addCleanup(func() { s.Close() })
+	return nil
+}
+
+func (s *externalSorter) newRunFile() (string, *os.File, error) {
+	if err := s.ensureDir(); err != nil {
+		return "", nil, err
+	}
+	file := filepath.Join(s.dir, fmt.Sprintf("run.%d", s.nRuns))
+	s.nRuns++
+	fh, err := os.Create(file)
+	if err != nil {
+		return "", nil, err
+	}
+	return file, fh, nil
+}
+
+// spill sorts records in memory and writes them to a new run.
+func (s *externalSorter) spill() error {
+	if len(s.buf) == 0 {
+		return nil
+	}
+	sortKeyRecords(s.buf, s.keys, s.opt)
+
+	file, fh, err := s.newRunFile()
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriterSize(fh, os.Getpagesize()*64)
+	for _, r := range s.buf {
+		if err = writeSortRunRecord(w, r.record); err != nil {
+			fh.Close()
+			return err
+		}
+	}
+	if err = w.Flush(); err != nil {
+		fh.Close()
+		return err
+	}
+	if err = fh.Close(); err != nil {
+		return err
+	}
+
+	s.runs = append(s.runs, file)
+	s.nSpilled++
+	for i := range s.buf {
+		s.buf[i] = nil
+	}
+	s.buf = s.buf[:0]
+	s.size = 0
+	return nil
+}
+
+// Output writes all records in order. If no runs were written,
+// records are sorted and written directly from memory.
+func (s *externalSorter) Output(outfh *xopen.Writer, lineWidth int) error {
+	if len(s.runs) == 0 {
+		sortKeyRecords(s.buf, s.keys, s.opt)
+		for _, r := range s.buf {
+			r.record.FormatToWriter(outfh, lineWidth)
+		}
+		return nil
+	}
+
+	if err := s.spill(); err != nil {
+		return err
+	}
+
+	// merge runs in batches, until the number of runs is small enough.
+	// batches are made of consecutive runs, so the sort is still stable.
+	var err error
+	var file string
+	var fh *os.File
+	var w *bufio.Writer
+	for len(s.runs) > sortExternalMaxOpenRuns {
+		runs := make([]string, 0, (len(s.runs)+sortExternalMaxOpenRuns-1)/sortExternalMaxOpenRuns)
+		for i := 0; i < len(s.runs); i += sortExternalMaxOpenRuns {
+			j := i + sortExternalMaxOpenRuns
+			if j > len(s.runs) {
+				j = len(s.runs)
+			}
+
+			file, fh, err = s.newRunFile()
+			if err != nil {
+				return err
+			}
+			w = bufio.NewWriterSize(fh, os.Getpagesize()*64)
+			err = s.merge(s.runs[i:j], func(record *fastx.Record) error {
+				return writeSortRunRecord(w, record)
+			})
+			if err != nil {
+				fh.Close()
+				return err
+			}
+			if err = w.Flush(); err != nil {
+				fh.Close()
+				return err
+			}
+			if err = fh.Close(); err != nil {
+				return err
+			}
+			runs = append(runs, file)
+		}
+		s.runs = runs
+	}
+
+	return s.merge(s.runs, func(record *fastx.Record) error {
+		record.FormatToWriter(outfh, lineWidth)
+		return nil
+	})
+}
+
+// merge k-way merges runs, and removes them after merging.
+func (s *externalSorter) merge(runs []string, fn func(*fastx.Record) error) error {
+	readers := make([]*sortRunReader, 0, len(runs))
+	defer func() {
+		for _, r := range readers {
+			r.fh.Close()
+			os.Remove(r.fh.Name())
+		}
+	}()
+
+	h := &sortRunHeap{keys: s.keys, opt: s.opt, items: make([]*sortRunItem, 0, len(runs))}
+	var record *fastx.Record
+	var err error
+	for i, file := range runs {
+		fh, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		r := &sortRunReader{fh: fh, r: bufio.NewReaderSize(fh, os.Getpagesize()*16), alphabet: s.alphabet}
+		readers = append(readers, r)
+
+		if record, err = r.Read(); err != nil {
+			if err == io.EOF {
+				continue
+			}
+			return err
+		}
+		h.items = append(h.items, s.newSortRunItem(record, i))
+	}
+	heap.Init(h)
+
+	var item *sortRunItem
+	for h.Len() > 0 {
+		item = h.items[0]
+		if err = fn(item.r.record); err != nil {
+			return err
+		}
+
+		record, err = readers[item.run].Read()
+		if err != nil {
+			if err == io.EOF {
+				heap.Pop(h)
+				continue
+			}
+			return err
+		}
+		h.items[0] = s.newSortRunItem(record, item.run)
+		heap.Fix(h, 0)
+	}
+	return nil
+}
+
+func (s *externalSorter) newSortRunItem(record *fastx.Record, run int) *sortRunItem {
+	r := newSortKeyRecord(record, s.keys, s.opt, false)
+	r.record = record
+	return &sortRunItem{r: r, run: run}
+}
+
+// Close removes all temporary files.
+func (s *externalSorter) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	if s.dir != "" {
+		return os.RemoveAll(s.dir)
+	}
+	return nil
+}
+
+// writeSortRunRecord writes a record as length-prefixed ID, name, sequence
+// and quality.
+func writeSortRunRecord(w *bufio.Writer, record *fastx.Record) error {
+	var buf [binary.MaxVarintLen64]byte
+	var n int
+	var err error
+	for _, data := range [][]byte{record.ID, record.Name, record.Seq.Seq, record.Seq.Qual} {
+		n = binary.PutUvarint(buf[:], uint64(len(data)))
+		if _, err = w.Write(buf[:n]); err != nil {
+			return err
+		}
+		if _, err = w.Write(data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sortRunReader reads records from a run.
+type sortRunReader struct {
+	fh       *os.File
+	r        *bufio.Reader
+	alphabet *seq.Alphabet
+}
+
+func (r *sortRunReader) Read() (*fastx.Record, error) {
+	var fields [4][]byte
+	var n uint64
+	var err error
+	for i := range fields {
+		n, err = binary.ReadUvarint(r.r)
+		if err != nil {
+			if i == 0 && err == io.EOF {
+				return nil, io.EOF
+			}
+			return nil, fmt.Errorf("failed to read temporary file %s: %s", r.fh.Name(), err)
+		}
+		fields[i] = make([]byte, n)
+		if _, err = io.ReadFull(r.r, fields[i]); err != nil {
+			return nil, fmt.Errorf("failed to read temporary file %s: %s", r.fh.Name(), err)
+		}
+	}
+	return &fastx.Record{
+		ID:   fields[0],
+		Name: fields[1],
+		Seq:  &seq.Seq{Alphabet: r.alphabet, Seq: fields[2], Qual: fields[3]},
+	}, nil
+}
+
+// sortRunItem is the current record of a run in merging.
+type sortRunItem struct {
+	r   *sortKeyRecord
+	run int
+}
+
+// sortRunHeap is a min-heap of records from runs. Records with equal keys
+// are ordered by the run indexes, to keep the sort stable.
+type sortRunHeap struct {
+	keys  []sortKey
+	opt   *sortKeyOptions
+	items []*sortRunItem
+}
+
+func (h sortRunHeap) Len() int { return len(h.items) }
+func (h sortRunHeap) Less(i, j int) bool {
+	v := compareSortKeyRecords(h.items[i].r, h.items[j].r, h.keys, h.opt)
+	if v != 0 {
+		return v < 0
+	}
+	return h.items[i].run < h.items[j].run
+}
+func (h sortRunHeap) Swap(i, j int)       { h.items[i], h.items[j] = h.items[j], h.items[i] }
+func (h *sortRunHeap) Push(x interface{}) { h.items = append(h.items, x.(*sortRunItem)) }
+func (h *sortRunHeap) Pop() interface{} {
+	n := len(h.items)
+	x := h.items[n-1]
+	h.items = h.items[:n-1]
+	return x
+}
+
+// sortByKeysExternal sorts records by multiple keys with bounded memory.
+func sortByKeysExternal(files []string, keys []sortKey, opt *sortKeyOptions, alphabet *seq.Alphabet, idRegexp string,
+	outFile string, lineWidth int, maxMem int64, tmpDir string, quiet bool, bench *benchmarkStats) {
+
+	sorter, err := newExternalSorter(keys, opt, maxMem, tmpDir)
+	checkError(err)
+	defer sorter.Close()
+
+	if !quiet {
+		log.Infof("read sequences and write sorted runs to temporary files ...")
+	}
+	var record *fastx.Record
+	var n int
+	for _, file := range files {
+		fastxReader, err := fastx.NewReader(alphabet, file, idRegexp)
+		checkError(err)
+		for {
+			record, err = fastxReader.Read()
+			if err != nil {
+				if err == io.EOF {
+					break
+				}
+				checkError(err)
+				break
+			}
+			bench.addRecord()
+			if fastxReader.IsFastq {
+				lineWidth = 0
+				fastx.ForcelyOutputFastq = true
+			}
+
+			checkError(sorter.Add(record.Clone()))
+			n++
+		}
+		fastxReader.Close()
+	}
+
+	if !quiet {
+		log.Infof("%d sequences loaded", n)
+		if sorter.Runs() > 0 {
+			log.Infof("merging sorted runs ...")
+		} else {
+			log.Infof("sorting ...")
+		}
+	}
+	outfh, err := xopen.Wopen(outFile)
+	checkError(err)
+	defer outfh.Close()
+
+	checkError(sorter.Output(outfh, lineWidth))
+	if !quiet && sorter.Runs() > 0 {
+		log.Infof("%d sorted run(s) merged", sorter.Runs())
+	}
+}
@@ -0,0 +1,220 @@
+// Copyright © 2016-2024 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+
+	"github.com/shenwei356/bio/seq"
+	"github.com/shenwei356/bio/seqio/fastx"
+	"github.com/shenwei356/xopen"
+	"github.com/spf13/cobra"
+)
+
+// splitAtGapsCmd represents the split-at-gaps command
+var splitAtGapsCmd = &cobra.Command{
+	GroupID: "edit",
+
+	Use:     "split-at-gaps",
+	Aliases: []string{"split-by-n"},
+	Short:   "break scaffolds into contigs at runs of N",
+	Long: `break scaffolds into contigs at runs of N
+
+Each sequence is split at gaps, i.e., runs of gap letters (-G/--gap-letters)
+no shorter than -n/--min-n, and every contig is outputted as a record named
+<ID>_<i>, with the original location in the header:
+
+    >scaffold1_1 scaffold1:1-2300
+    >scaffold1_2 scaffold1:2401-5000
+
+Attention:
+  1. Locations are 1-based and inclusive. Gaps shorter than -n/--min-n
+     are kept in contigs.
+  2. Sequences without gaps are outputted as a single contig <ID>_1.
+  3. Such gaps at the ends of sequences are removed, and sequences consisting
+     of only gaps produce no contigs.
+  4. Gap letters are case-sensitive, the default value is "Nn".
+  5. An AGP (v2.1) file describing the structure of original sequences
+     can be written via -a/--agp, with contigs as components (W) and gaps
+     as gap lines (N, gap type "scaffold", linkage "yes", evidence
+     "unspecified"). As required by AGP, objects begin and end with
+     components, i.e., gaps at the ends of sequences are not written,
+     and coordinates of objects start from the first contig. Sequences
+     consisting of only gaps are not written either.
+
+Examples:
+  1. Breaking scaffolds at gaps of at least 10 Ns.
+      seqkit split-at-gaps -n 10 scaffolds.fa -o contigs.fa -a scaffolds.agp
+
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		config := getConfigs(cmd)
+		alphabet := config.Alphabet
+		idRegexp := config.IDRegexp
+		lineWidth := config.LineWidth
+		outFile := config.OutFile
+		quiet := config.Quiet
+		seq.AlphabetGuessSeqLengthThreshold = config.AlphabetGuessSeqLength
+		seq.ValidateSeq = false
+		runtime.GOMAXPROCS(config.Threads)
+
+		minN := getFlagPositiveInt(cmd, "min-n")
+		gapLetters := getFlagString(cmd, "gap-letters")
+		agpFile := getFlagString(cmd, "agp")
+
+		if len(gapLetters) == 0 {
+			checkError(fmt.Errorf("value of flag -G (--gap-letters) should not be empty"))
+		}
+		var isGap [256]bool
+		for _, c := range gapLetters {
+			if c > 127 {
+				checkError(fmt.Errorf("value of -G (--gap-letters) contains non-ASCII characters"))
+			}
+			isGap[c] = true
+		}
+
+		bench := getBenchmarkStats(cmd)
+		files := getFileListFromArgsAndFile(cmd, args, true, "infile-list", true)
+		bench.addInputs(files...)
+
+		outfh, err := xopen.Wopen(outFile)
+		checkError(err)
+		defer outfh.Close()
+
+		var agpfh *xopen.Writer
+		if agpFile != "" {
+			agpfh, err = xopen.Wopen(agpFile)
+			checkError(err)
+			defer agpfh.Close()
+			agpfh.WriteString("##agp-version\t2.1\n")
+		}
+
+		var record, contig *fastx.Record
+		var gaps [][2]int
+		var start, end, i, part, offset int
+		var id string
+		var nSeqs, nContigs, nGaps int
+		for _, file := range files {
+			fastxReader, err := fastx.NewReader(alphabet, file, idRegexp)
+			checkError(err)
+			for {
+				record, err = fastxReader.Read()
+				if err != nil {
+					if err == io.EOF {
+						break
+					}
+					checkError(err)
+					break
+				}
+				bench.addRecord()
+				if fastxReader.IsFastq {
+					config.LineWidth = 0
+					fastx.ForcelyOutputFastq = true
+				}
+				nSeqs++
+
+				id = string(record.ID)
+				gaps = findGapRuns(record.Seq.Seq, &isGap, minN)
+				nGaps += len(gaps)
+
+				// AGP objects start from the first contig
+				offset = 0
+				if len(gaps) > 0 && gaps[0][0] == 0 {
+					offset = gaps[0][1]
+				}
+
+				// regions between gaps, 0-based, with the end excluded
+				i, start, part = 0, 0, 0
+				for k := 0; k <= len(gaps); k++ {
+					if k < len(gaps) {
+						end = gaps[k][0]
+					} else {
+						end = len(record.Seq.Seq)
+					}
+
+					if end > start {
+						i++
+						nContigs++
+						contig, err = fastx.NewRecordWithSeq(
+							[]byte(fmt.Sprintf("%s_%d", id, i)),
+							[]byte(fmt.Sprintf("%s_%d %s:%d-%d", id, i, id, start+1, end)),
+							nil,
+							record.Seq.SubSeq(start+1, end))
+						checkError(err)
+						contig.FormatToWriter(outfh, config.LineWidth)
+
+						if agpfh != nil {
+							part++
+							fmt.Fprintf(agpfh, "%s\t%d\t%d\t%d\tW\t%s_%d\t1\t%d\t+\n",
+								id, start+1-offset, end-offset, part, id, i, end-start)
+						}
+					}
+
+					if k < len(gaps) {
+						// gaps at the ends are not written
+						if agpfh != nil && gaps[k][0] > 0 && gaps[k][1] < len(record.Seq.Seq) {
+							part++
+							fmt.Fprintf(agpfh, "%s\t%d\t%d\t%d\tN\t%d\tscaffold\tyes\tunspecified\n",
+								id, gaps[k][0]+1-offset, gaps[k][1]-offset, part, gaps[k][1]-gaps[k][0])
+						}
+						start = gaps[k][1]
+					}
+				}
+			}
+			fastxReader.Close()
+
+			config.LineWidth = lineWidth
+		}
+
+		if !quiet {
+			log.Infof("%d contigs outputted from %d sequences, with %d gaps", nContigs, nSeqs, nGaps)
+		}
+	},
+}
+
+// findGapRuns returns 0-based locations (end excluded) of runs of gap letters
+// no shorter than minLen.
+func findGapRuns(s []byte, isGap *[256]bool, minLen int) [][2]int {
+	var runs [][2]int
+	var j int
+	for i := 0; i < len(s); i++ {
+		if !isGap[s[i]] {
+			continue
+		}
+		for j = i + 1; j < len(s) && isGap[s[j]]; j++ {
+		}
+		if j-i >= minLen {
+			runs = append(runs, [2]int{i, j})
+		}
+		i = j
+	}
+	return runs
+}
+
+func init() {
+	RootCmd.AddCommand(splitAtGapsCmd)
+
+	splitAtGapsCmd.Flags().IntP("min-n", "n", 10, "minimum length of gaps to split at")
+	splitAtGapsCmd.Flags().StringP("gap-letters", "G", "Nn", "gap letters")
+	splitAtGapsCmd.Flags().StringP("agp", "a", "", "write an AGP file describing the structure of original sequences")
+}
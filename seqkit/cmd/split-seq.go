@@ -0,0 +1,196 @@
+// Copyright © 2016-2024 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"runtime"
+
+	"github.com/shenwei356/bio/seq"
+	"github.com/shenwei356/bio/seqio/fastx"
+	"github.com/shenwei356/xopen"
+	"github.com/spf13/cobra"
+)
+
+// splitSeqCmd represents the split-seq command
+var splitSeqCmd = &cobra.Command{
+	GroupID: "edit",
+
+	Use:   "split-seq",
+	Short: "split each record into multiple ones at every occurrence of a delimiter sequence",
+	Long: `split each record into multiple ones at every occurrence of a delimiter sequence
+
+This command is useful for processing concatenated constructs separated by
+a known linker.
+
+Attention:
+  1. The delimiter (-d/--delimiter) is searched on the positive strand only,
+     from left to right, and occurrences do not overlap.
+     Degenerate bases like "RYMM.." are supported by the flag --degenerate.
+  2. The delimiter is dropped by default, use -k/--keep-delimiter to keep it:
+        left,  appended to the fragment on its left (5') side.
+        right, prepended to the fragment on its right (3') side.
+  3. Zero-length fragments are dropped, e.g., at the two ends of a sequence
+     or between two adjacent delimiters.
+  4. Fragments are numbered from 1, and their IDs are appended with
+     "_<number>", e.g., "seq1_1", "seq1_2". Descriptions are kept.
+     Records without the delimiter are outputted unchanged.
+  5. For FASTQ files, qualities are split at the same positions.
+
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		config := getConfigs(cmd)
+		alphabet := config.Alphabet
+		idRegexp := config.IDRegexp
+		lineWidth := config.LineWidth
+		outFile := config.OutFile
+		quiet := config.Quiet
+		seq.AlphabetGuessSeqLengthThreshold = config.AlphabetGuessSeqLength
+		seq.ValidateSeq = false
+		runtime.GOMAXPROCS(config.Threads)
+
+		bench := getBenchmarkStats(cmd)
+		files := getFileListFromArgsAndFile(cmd, args, true, "infile-list", true)
+		bench.addInputs(files...)
+
+		delimiter := getFlagString(cmd, "delimiter")
+		if delimiter == "" {
+			checkError(fmt.Errorf("flag -d (--delimiter) needed"))
+		}
+		keepDelimiter := getFlagString(cmd, "keep-delimiter")
+		switch keepDelimiter {
+		case "none", "left", "right":
+		default:
+			checkError(fmt.Errorf("invalid value of flag -k (--keep-delimiter): %s. available: none, left, right", keepDelimiter))
+		}
+		ignoreCase := getFlagBool(cmd, "ignore-case")
+		degenerate := getFlagBool(cmd, "degenerate")
+
+		var p string
+		if degenerate {
+			s, err := seq.NewSeq(seq.DNAredundant, []byte(delimiter))
+			if err != nil {
+				checkError(fmt.Errorf("invalid delimiter with degenerate bases: %s", delimiter))
+			}
+			p = s.Degenerate2Regexp()
+		} else {
+			p = regexp.QuoteMeta(delimiter)
+		}
+		if ignoreCase {
+			p = "(?i)" + p
+		}
+		re, err := regexp.Compile(p)
+		checkError(err)
+
+		outfh, err := xopen.Wopen(outFile)
+		checkError(err)
+		defer outfh.Close()
+
+		var record *fastx.Record
+		var locs [][]int
+		var sequence *seq.Seq
+		var name0, id0 []byte
+		var begin, end, b, e, i, k int
+		var nRecords, nSplit, nFragments int
+		for _, file := range files {
+			fastxReader, err := fastx.NewReader(alphabet, file, idRegexp)
+			checkError(err)
+
+			for {
+				record, err = fastxReader.Read()
+				if err != nil {
+					if err == io.EOF {
+						break
+					}
+					checkError(err)
+					break
+				}
+				bench.addRecord()
+				if fastxReader.IsFastq {
+					config.LineWidth = 0
+					fastx.ForcelyOutputFastq = true
+				}
+				nRecords++
+
+				locs = re.FindAllIndex(record.Seq.Seq, -1)
+				if len(locs) == 0 {
+					record.FormatToWriter(outfh, config.LineWidth)
+					continue
+				}
+				nSplit++
+
+				sequence = record.Seq
+				name0 = record.Name
+				id0 = record.ID
+				k = 0
+				// fragments are between delimiters, and [begin, end) is in 0-based
+				for i = 0; i <= len(locs); i++ {
+					if i == 0 {
+						begin = 0
+					} else {
+						begin = locs[i-1][1]
+						if keepDelimiter == "right" {
+							begin = locs[i-1][0]
+						}
+					}
+					if i == len(locs) {
+						end = len(sequence.Seq)
+					} else {
+						end = locs[i][0]
+						if keepDelimiter == "left" {
+							end = locs[i][1]
+						}
+					}
+					if end <= begin {
+						continue
+					}
+
+					k++
+					b, e = begin+1, end // 1-based for SubSeq
+					record.Seq = sequence.SubSeq(b, e)
+					record.ID = []byte(fmt.Sprintf("%s_%d", id0, k))
+					record.Name = bytes.Replace(name0, id0, record.ID, 1)
+					record.FormatToWriter(outfh, config.LineWidth)
+					nFragments++
+				}
+			}
+			fastxReader.Close()
+
+			config.LineWidth = lineWidth
+		}
+
+		if !quiet {
+			log.Infof("%d of %d records split into %d fragments", nSplit, nRecords, nFragments)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(splitSeqCmd)
+
+	splitSeqCmd.Flags().StringP("delimiter", "d", "", "delimiter sequence, e.g., a linker")
+	splitSeqCmd.Flags().StringP("keep-delimiter", "k", "none", "keep the delimiter in fragments on the left or right side, available: none, left, right")
+	splitSeqCmd.Flags().BoolP("ignore-case", "i", false, "ignore case when searching the delimiter")
+	splitSeqCmd.Flags().BoolP("degenerate", "", false, "the delimiter contains degenerate bases")
+}
@@ -63,6 +63,31 @@ If you want to cut a sequence into multiple segments.
 Attention:
   1. For the two-pass mode (-2/--two-pass), The flag -U/--update-faidx is recommended to
      ensure the .fai file matches the FASTA file.
+  2. For -b/--by-bp, a part is closed once its cumulative bases reach the given
+     size, records are never split across parts, so a part can be larger than
+     the size. Part numbers are zero-padded to a width (at least 3) estimated
+     from the input file size, so that lexical order matches the numeric order.
+     For stdin or compressed input, parts are renamed at the end if more digits
+     are needed.
+  3. For --bed, the subsequence of each BED interval is extracted from the
+     FASTA file via the FASTA index, and written to a file named by the name
+     column (the 4th one), or "chr_start-end" if the column is absent.
+     Intervals sharing a name are written to the same file, while overlapping
+     intervals with different names each get their own file. Headers are in
+     the format of "chr_start-end:strand name" (1-based). Switch on
+     --strand-aware to output reverse complement sequences for intervals on
+     the negative strand. Only FASTA format is supported, and temporary files
+     are created for stdin or compressed input, removed unless -k/--keep-temp.
+  4. For --group-file, records are assigned to groups with a tab-delimited
+     file of sequence IDs (parsed by --id-regexp) and group names, and
+     written to files named "<prefix><group><ext>", where the prefix is
+     "<infile>." by default, or set by --group-file-prefix. Records not in
+     the file are written to the group "unassigned", or discarded with
+     --drop-unassigned. Records are read in a streaming way, and at most
+     --max-open-files files are opened simultaneously: the least recently
+     used one is closed and reopened in append mode when needed again.
+     Compressed files are appended with new streams, which are supported by
+     common decompressors.
 
 The definition of region is 1-based and with some custom design.
 
@@ -84,7 +109,9 @@ Examples:
 		fai.MapWholeFile = false
 		runtime.GOMAXPROCS(config.Threads)
 
+		bench := getBenchmarkStats(cmd)
 		files := getFileListFromArgsAndFile(cmd, args, true, "infile-list", true)
+		bench.addInputs(files...)
 
 		if len(files) > 1 {
 			checkError(fmt.Errorf("no more than one file should be given"))
@@ -92,6 +119,15 @@ Examples:
 
 		size := getFlagNonNegativeInt(cmd, "by-size")
 		part := getFlagNonNegativeInt(cmd, "by-part")
+		byBpS := getFlagString(cmd, "by-bp")
+		var byBp int64
+		if byBpS != "" {
+			var err error
+			byBp, err = ParseByteSize(byBpS)
+			if err != nil || byBp <= 0 {
+				checkError(fmt.Errorf("invalid value of flag -b/--by-bp, a positive size expected: %s", byBpS))
+			}
+		}
 
 		byID := getFlagBool(cmd, "by-id")
 		region := getFlagString(cmd, "by-region")
@@ -116,11 +152,13 @@ Examples:
 		prefixByPart := getFlagString(cmd, "by-part-prefix")
 		prefixByID := getFlagString(cmd, "by-id-prefix")
 		prefixByRegion := getFlagString(cmd, "by-region-prefix")
+		prefixByBp := getFlagString(cmd, "by-bp-prefix")
 
 		prefixBySizeSet := cmd.Flags().Lookup("by-size-prefix").Changed
 		prefixByPartSet := cmd.Flags().Lookup("by-part-prefix").Changed
 		prefixByIDSet := cmd.Flags().Lookup("by-id-prefix").Changed
 		prefixByRegionSet := cmd.Flags().Lookup("by-region-prefix").Changed
+		prefixByBpSet := cmd.Flags().Lookup("by-bp-prefix").Changed
 
 		file := files[0]
 		isstdin := isStdin(file)
@@ -174,6 +212,331 @@ Examples:
 		var outfh *xopen.Writer
 		var err error
 
+		bedFile := getFlagString(cmd, "bed")
+		strandAware := getFlagBool(cmd, "strand-aware")
+		if strandAware && bedFile == "" {
+			checkError(fmt.Errorf("flag --strand-aware must be used with flag --bed"))
+		}
+		if bedFile != "" {
+			if size > 0 || part > 0 || byID || region != "" || byBp > 0 || twoPass || getFlagString(cmd, "group-file") != "" {
+				checkError(fmt.Errorf("flag --bed is not compatible with -s/-p/-i/-r/-b/-2/--group-file"))
+			}
+
+			Threads = config.Threads // threads of ReadBedFeatures
+			features, err := ReadBedFeatures(bedFile)
+			checkError(err)
+			if !quiet {
+				log.Infof("%d BED intervals loaded from %s", len(features), bedFile)
+			}
+
+			newFile, alphabet2, faidx := sortTwoPassPrepare(file, updateFaidx, quiet)
+			if faidx == nil {
+				return
+			}
+			if alphabet != nil {
+				alphabet2 = alphabet
+			} else if alphabet2 == nil {
+				alphabet2, _, err = fastx.GuessAlphabet(newFile)
+				checkError(err)
+			}
+			defer func() {
+				checkError(faidx.Close())
+				if (isstdin || !isPlainFile(file)) && !keepTemp {
+					checkError(os.Remove(newFile))
+					checkError(os.Remove(newFile + ".seqkit.fai"))
+				}
+			}()
+
+			// the index uses full names, while BED uses sequence IDs
+			idRe, err := regexp.Compile(idRegexp)
+			checkError(err)
+			id2name := make(map[string]string, len(faidx.Index))
+			for name := range faidx.Index {
+				id2name[string(fastx.ParseHeadID(idRe, []byte(name)))] = name
+			}
+
+			fileExt = suffixFA + extension
+
+			// intervals sharing a name go to the same file, in the order of BED
+			names := make([]string, 0, len(features))
+			name2features := make(map[string][]BedFeature, len(features))
+			var name string
+			for _, f := range features {
+				if f.Name != nil && *f.Name != "" {
+					name = *f.Name
+				} else {
+					name = fmt.Sprintf("%s_%d-%d", f.Chr, f.Start, f.End)
+				}
+				if _, ok := name2features[name]; !ok {
+					names = append(names, name)
+				}
+				name2features[name] = append(name2features[name], f)
+			}
+
+			var chr, strand, id, head string
+			var r fai.Record
+			var ok bool
+			var s, e, n int
+			for _, name = range names {
+				outfile = filepath.Join(outdir, strings.ReplaceAll(name, string(os.PathSeparator), "_")+fileExt)
+
+				n = 0
+				for _, f := range name2features[name] {
+					if chr, ok = id2name[f.Chr]; !ok {
+						if !quiet {
+							log.Warningf("sequence (%s) of BED interval not found in file: %s", f.Chr, file)
+						}
+						continue
+					}
+					r = faidx.Index[chr]
+					s, e, ok = seq.SubLocation(r.Length, f.Start, f.End)
+					if !ok {
+						if !quiet {
+							log.Warningf("BED interval (%s:%d-%d) out of range of sequence (%s) with length of %d",
+								f.Chr, f.Start-1, f.End, f.Chr, r.Length)
+						}
+						continue
+					}
+					n++
+					if dryRun {
+						continue
+					}
+					if n == 1 {
+						outfh, err = xopen.Wopen(outfile)
+						checkError(err)
+					}
+
+					strand = "."
+					if f.Strand != nil {
+						strand = *f.Strand
+					}
+					id = fmt.Sprintf("%s_%d-%d:%s", f.Chr, s, e, strand)
+					head = id
+					if f.Name != nil {
+						head += " " + *f.Name
+					}
+					record, err = fastx.NewRecordWithoutValidation(alphabet2, []byte(id), []byte(head), []byte{},
+						subseqByFaix(faidx, chr, r, s, e))
+					checkError(err)
+					if strandAware && strand == "-" {
+						record.Seq.RevComInplace()
+					}
+					record.FormatToWriter(outfh, config.LineWidth)
+				}
+
+				if n == 0 {
+					continue
+				}
+				if !quiet {
+					log.Infof("write %d sequences to file: %s\n", n, outfile)
+				}
+				if !dryRun {
+					checkError(outfh.Close())
+				}
+			}
+			return
+		}
+
+		if groupFile := getFlagString(cmd, "group-file"); groupFile != "" {
+			if size > 0 || part > 0 || byID || region != "" || byBp > 0 || twoPass {
+				checkError(fmt.Errorf("flag --group-file is not compatible with -s/-p/-i/-r/-b/-2"))
+			}
+			dropUnassigned := getFlagBool(cmd, "drop-unassigned")
+			maxOpenFiles := getFlagPositiveInt(cmd, "max-open-files")
+
+			groups, id2group, err := readGroupFile(groupFile)
+			checkError(err)
+			if !quiet {
+				log.Infof("%d sequences of %d groups loaded from %s", len(id2group), len(groups), groupFile)
+			}
+
+			if cmd.Flags().Lookup("group-file-prefix").Changed {
+				prefix = getFlagString(cmd, "group-file-prefix")
+			} else {
+				prefix = filepath.Base(fileName) + "."
+			}
+
+			const unassigned = "unassigned"
+			counts := make(map[string]int, len(groups)+1)
+			pool := newSplitWriterPool(maxOpenFiles)
+			var group string
+			var ok bool
+			fastxReader, err := fastx.NewReader(alphabet, file, idRegexp)
+			checkError(err)
+			for {
+				record, err = fastxReader.Read()
+				if err != nil {
+					if err == io.EOF {
+						break
+					}
+					checkError(err)
+					break
+				}
+				bench.addRecord()
+				if fastxReader.IsFastq {
+					config.LineWidth = 0
+					fastx.ForcelyOutputFastq = true
+				}
+
+				if renameFileExt && isstdin {
+					if len(record.Seq.Qual) > 0 {
+						fileExt = suffixFQ + extension
+					} else {
+						fileExt = suffixFA + extension
+					}
+					renameFileExt = false
+				}
+
+				if group, ok = id2group[string(record.ID)]; !ok {
+					if dropUnassigned {
+						counts[unassigned]++
+						continue
+					}
+					group = unassigned
+				}
+				counts[group]++
+				if dryRun {
+					continue
+				}
+
+				outfile = filepath.Join(outdir, prefix+pathutil.RemoveInvalidPathChars(group, "__")+fileExt)
+				outfh, err = pool.get(outfile)
+				checkError(err)
+				record.FormatToWriter(outfh, config.LineWidth)
+			}
+			fastxReader.Close()
+			checkError(pool.close())
+
+			if !quiet {
+				for _, group = range append(groups, unassigned) {
+					if counts[group] == 0 {
+						continue
+					}
+					if group == unassigned && dropUnassigned {
+						log.Infof("%d unassigned sequences discarded", counts[group])
+						continue
+					}
+					log.Infof("write %d sequences to file: %s", counts[group],
+						filepath.Join(outdir, prefix+pathutil.RemoveInvalidPathChars(group, "__")+fileExt))
+				}
+				if pool.nReopened > 0 {
+					log.Infof("files were reopened %d times, you may increase --max-open-files", pool.nReopened)
+				}
+			}
+			return
+		}
+
+		if byBp > 0 {
+			if size > 0 || part > 0 || byID || region != "" || twoPass {
+				checkError(fmt.Errorf("flag -b/--by-bp is not compatible with -s/-p/-i/-r/-2"))
+			}
+			if !quiet {
+				log.Infof("split into parts of >= %d bases per file", byBp)
+			}
+
+			if prefixByBpSet {
+				prefix = prefixByBp
+			} else {
+				prefix = fmt.Sprintf("%s.part_", filepath.Base(fileName))
+			}
+
+			// estimate the number of parts from the file size,
+			// which is the upper bound of the number of bases for plain files.
+			width := 3
+			if !isstdin && isPlainFile(file) {
+				if fi, err := os.Stat(file); err == nil {
+					if w := len(strconv.FormatInt(fi.Size()/byBp+1, 10)); w > width {
+						width = w
+					}
+				}
+			}
+
+			outfiles := make([]string, 0, 8)
+			newPart := func(n int) string {
+				return filepath.Join(outdir, fmt.Sprintf("%s%0*d%s", prefix, width, n, fileExt))
+			}
+
+			var bases int64
+			var nseqs int
+			n := 0
+			closePart := func() {
+				if !quiet {
+					log.Infof("write %d sequences (%d bases) to file: %s\n", nseqs, bases, outfile)
+				}
+				if !dryRun {
+					checkError(outfh.Close())
+				}
+				bases, nseqs = 0, 0
+			}
+
+			fastxReader, err := fastx.NewReader(alphabet, file, idRegexp)
+			checkError(err)
+			for {
+				record, err = fastxReader.Read()
+				if err != nil {
+					if err == io.EOF {
+						break
+					}
+					checkError(err)
+					break
+				}
+				bench.addRecord()
+				if fastxReader.IsFastq {
+					config.LineWidth = 0
+					fastx.ForcelyOutputFastq = true
+				}
+
+				if renameFileExt && isstdin {
+					if len(record.Seq.Qual) > 0 {
+						fileExt = suffixFQ + extension
+					} else {
+						fileExt = suffixFA + extension
+					}
+					renameFileExt = false
+				}
+
+				if nseqs == 0 {
+					n++
+					outfile = newPart(n)
+					outfiles = append(outfiles, outfile)
+					if !dryRun {
+						outfh, err = xopen.Wopen(outfile)
+						checkError(err)
+					}
+				}
+
+				if !dryRun {
+					record.FormatToWriter(outfh, config.LineWidth)
+				}
+				nseqs++
+				bases += int64(len(record.Seq.Seq))
+
+				if bases >= byBp {
+					closePart()
+				}
+			}
+			fastxReader.Close()
+			if nseqs > 0 {
+				closePart()
+			}
+
+			// more digits are needed than estimated
+			if w := len(strconv.Itoa(n)); w > width {
+				width = w
+				for i, f := range outfiles {
+					outfile = newPart(i + 1)
+					if !dryRun {
+						checkError(os.Rename(f, outfile))
+					}
+				}
+				if !quiet {
+					log.Infof("%d files renamed with part numbers of %d digits", n, width)
+				}
+			}
+
+			return
+		}
+
 		if size > 0 {
 			if !twoPass {
 				if !quiet {
@@ -194,6 +557,7 @@ Examples:
 						checkError(err)
 						break
 					}
+					bench.addRecord()
 					if fastxReader.IsFastq {
 						config.LineWidth = 0
 						fastx.ForcelyOutputFastq = true
@@ -382,6 +746,7 @@ Examples:
 				}
 				allRecords, err := fastx.GetSeqs(file, alphabet, config.Threads, 10, idRegexp)
 				checkError(err)
+				bench.addRecords(int64(len(allRecords)))
 				if !quiet {
 					log.Infof("read %d sequences", len(allRecords))
 				}
@@ -598,6 +963,7 @@ Examples:
 				}
 				allRecords, err := fastx.GetSeqs(file, alphabet, config.Threads, 10, idRegexp)
 				checkError(err)
+				bench.addRecords(int64(len(allRecords)))
 				if !quiet {
 					log.Infof("read %d sequences", len(allRecords))
 				}
@@ -811,6 +1177,7 @@ Examples:
 				}
 				allRecords, err := fastx.GetSeqs(file, alphabet, config.Threads, 10, idRegexp)
 				checkError(err)
+				bench.addRecords(int64(len(allRecords)))
 				if !quiet {
 					log.Infof("read %d sequences", len(allRecords))
 				}
@@ -918,6 +1285,7 @@ Examples:
 					checkError(err)
 					break
 				}
+				bench.addRecord()
 
 				s, e, ok = seq.SubLocation(len(record.Seq.Seq), start, end)
 				if !ok {
@@ -1010,7 +1378,7 @@ Examples:
 			return
 		}
 
-		checkError(fmt.Errorf(`one of flags should be given: -s/-p/-i/-r. type "seqkit split -h" for help`))
+		checkError(fmt.Errorf(`one of flags should be given: -s/-p/-i/-r/-b/--bed/--group-file. type "seqkit split -h" for help`))
 	},
 }
 
@@ -1022,6 +1390,12 @@ func init() {
 	splitCmd.Flags().BoolP("by-id", "i", false, "split squences according to sequence ID")
 	splitCmd.Flags().StringP("by-region", "r", "", "split squences according to subsequence of given region. "+
 		`e.g 1:12 for first 12 bases, -12:-1 for last 12 bases. type "seqkit split -h" for more examples`)
+	splitCmd.Flags().StringP("bed", "", "", "split by BED intervals, writing subsequences of intervals to files named by the name column (only for FASTA format)")
+	splitCmd.Flags().BoolP("strand-aware", "", false, "output reverse complement sequences for BED intervals on the negative strand, used with --bed")
+	splitCmd.Flags().StringP("group-file", "", "", `split by groups in a tab-delimited file of sequence IDs and group names, type "seqkit split -h" for details`)
+	splitCmd.Flags().BoolP("drop-unassigned", "", false, "discard records not in the file of --group-file, instead of writing them to the group \"unassigned\"")
+	splitCmd.Flags().IntP("max-open-files", "", 256, "maximum number of files opened simultaneously for --group-file")
+	splitCmd.Flags().StringP("by-bp", "b", "", "split sequences into multi parts with >= N bases, records are kept whole, supports K/M/G suffix")
 	splitCmd.Flags().BoolP("two-pass", "2", false, "two-pass mode read files twice to lower memory usage. (only for FASTA format)")
 	splitCmd.Flags().BoolP("update-faidx", "U", false, "update the fasta index file if it exists. Use this if you are not sure whether the fasta file changed")
 	splitCmd.Flags().BoolP("dry-run", "d", false, "dry run, just print message and no files will be created.")
@@ -1033,6 +1407,8 @@ func init() {
 	splitCmd.Flags().StringP("by-part-prefix", "", "", "file prefix for --by-part")
 	splitCmd.Flags().StringP("by-id-prefix", "", "", "file prefix for --by-id")
 	splitCmd.Flags().StringP("by-region-prefix", "", "", "file prefix for --by-region")
+	splitCmd.Flags().StringP("by-bp-prefix", "", "", "file prefix for --by-bp")
+	splitCmd.Flags().StringP("group-file-prefix", "", "", "file prefix for --group-file")
 
 	splitCmd.Flags().StringP("extension", "e", "", `set output file extension, e.g., ".gz", ".xz", or ".zst"`)
 }
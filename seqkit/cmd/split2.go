@@ -51,7 +51,8 @@ occupation and fast speed.
 The prefix of output files:
   1. For stdin: stdin
   2. Others: same to the input file
-  3. Set via the options: --by-length-prefix, --by-part-prefix, or --by-size-prefix
+  3. Set via the options: --by-length-prefix, --by-part-prefix, --by-size-prefix,
+     or --by-region-prefix
 
 The extension of output files:
   1. For stdin: .fast[aq]
@@ -61,6 +62,16 @@ The extension of output files:
          seqkit split2 -p 2 -O test tests/hairpin.fa -e .gz
 
 
+Splitting long sequences into overlapping regions (-r/--by-region LEN:OVERLAP):
+  1. Each region is saved to its own file, e.g., for -r 1M:10K, the regions
+     of a 2.5-Mb sequence chr1 are:
+       chr1:0000001-1000000, chr1:0990001-1990000, chr1:1980001-2500000
+     which are saved to files: <prefix>chr1_0000001-1000000<ext>, ...
+     Coordinates (1-based) are left-padded with zeros to sort correctly.
+  2. The last region of a sequence might be shorter than LEN.
+  3. It's designed for inputs with one or a few long sequences, a warning
+     is shown for inputs with more than 10 sequences.
+
 If you want to cut a sequence into multiple segments.
   1. For cutting into even chunks, please use 'kmcp utils split-genomes'
      (https://bioinf.shenwei.me/kmcp/usage/#split-genomes).
@@ -87,7 +98,9 @@ If you want to cut a sequence into multiple segments.
 		fai.MapWholeFile = false
 		runtime.GOMAXPROCS(config.Threads)
 
+		bench := getBenchmarkStats(cmd)
 		files := getFileListFromArgsAndFile(cmd, args, true, "infile-list", true)
+		bench.addInputs(files...)
 
 		if len(files) > 1 {
 			checkError(fmt.Errorf("no more than one file should be given"))
@@ -108,6 +121,29 @@ If you want to cut a sequence into multiple segments.
 			}
 		}
 
+		regionS := getFlagString(cmd, "by-region")
+		var regionLen, regionOverlap int
+		if regionS != "" {
+			items := strings.Split(regionS, ":")
+			if len(items) != 2 {
+				checkError(fmt.Errorf("invalid value of flag -r/--by-region, LEN:OVERLAP expected: %s", regionS))
+			}
+			var v int64
+			v, err = ParseByteSize(items[0])
+			if err != nil || v <= 0 {
+				checkError(fmt.Errorf("invalid region length in value of flag -r/--by-region: %s", regionS))
+			}
+			regionLen = int(v)
+			v, err = ParseByteSize(items[1])
+			if err != nil {
+				checkError(fmt.Errorf("invalid region overlap in value of flag -r/--by-region: %s", regionS))
+			}
+			regionOverlap = int(v)
+			if regionOverlap >= regionLen {
+				checkError(fmt.Errorf("region overlap should be smaller than region length: %s", regionS))
+			}
+		}
+
 		outdir := getFlagString(cmd, "out-dir")
 		force := getFlagBool(cmd, "force")
 
@@ -116,18 +152,30 @@ If you want to cut a sequence into multiple segments.
 		prefixBySize := getFlagString(cmd, "by-size-prefix")
 		prefixByPart := getFlagString(cmd, "by-part-prefix")
 		prefixByLength := getFlagString(cmd, "by-length-prefix")
+		prefixByRegion := getFlagString(cmd, "by-region-prefix")
 
 		prefixBySizeSet := cmd.Flags().Lookup("by-size-prefix").Changed
 		prefixByPartSet := cmd.Flags().Lookup("by-part-prefix").Changed
 		prefixByLengthSet := cmd.Flags().Lookup("by-length-prefix").Changed
+		prefixByRegionSet := cmd.Flags().Lookup("by-region-prefix").Changed
 
-		if size == 0 && parts == 0 && length == 0 {
-			checkError(fmt.Errorf(`one of flags should be given: -s/-p/-l. type "seqkit split2 -h" for help`))
+		if size == 0 && parts == 0 && length == 0 && regionLen == 0 {
+			checkError(fmt.Errorf(`one of flags should be given: -s/-p/-l/-r. type "seqkit split2 -h" for help`))
 		}
 
 		bySize := size > 0
 		byParts := parts > 0
 		byLength := length > 0
+		byRegion := regionLen > 0
+
+		if byRegion {
+			if bySize || byParts || byLength {
+				checkError(fmt.Errorf("flag -r/--by-region can not be used along with -s/-p/-l"))
+			}
+			if read1 != "" && read2 != "" {
+				checkError(fmt.Errorf("flag -r/--by-region does not support paired-end reads"))
+			}
+		}
 
 		if parts >= 1000 {
 			log.Warningf(`value of -p/--parts > 1000 may cause error of "too many open files"`)
@@ -174,6 +222,8 @@ If you want to cut a sequence into multiple segments.
 				log.Infof("split into %d seqs per file", size)
 			} else if byParts {
 				log.Infof("split into %d parts", parts)
+			} else if byRegion {
+				log.Infof("split into regions of %d bp with %d bp overlap", regionLen, regionOverlap)
 			} else {
 				log.Infof("split by sequence length: %s", lengthS)
 			}
@@ -244,7 +294,7 @@ If you want to cut a sequence into multiple segments.
 
 				var flag bool
 
-				if bySize || byLength { // by size or by length
+				if bySize || byLength || byRegion { // by size, by length or by region
 				} else if byParts { // by part
 					outfhs = make([]*xopen.Writer, 0, parts)
 					counts = make([]int, 0, parts)
@@ -258,6 +308,9 @@ If you want to cut a sequence into multiple segments.
 				i := 0 // nth part
 				j := 0
 				var n int64 // length sum
+				var nRecords int
+				var rStart, rEnd, seqLen int
+				var coordFormat string
 				once := true
 				for {
 					record, err = fastxReader.Read()
@@ -268,6 +321,7 @@ If you want to cut a sequence into multiple segments.
 						checkError(err)
 						break
 					}
+					bench.addRecord()
 
 					if once {
 						if fastxReader.IsFastq {
@@ -286,6 +340,51 @@ If you want to cut a sequence into multiple segments.
 						once = false
 					}
 
+					if byRegion {
+						nRecords++
+						if nRecords == 11 {
+							log.Warningf("more than 10 sequences found, flag -r/--by-region is designed for one or a few long sequences")
+						}
+
+						if prefixByRegionSet {
+							prefix = prefixByRegion
+						} else {
+							prefix = fmt.Sprintf("%s.", filepath.Base(fileName))
+						}
+
+						seqLen = len(record.Seq.Seq)
+						coordFormat = fmt.Sprintf("%%0%dd", len(fmt.Sprintf("%d", seqLen)))
+						j = 0
+						for rStart = 0; rStart < seqLen; rStart += regionLen - regionOverlap {
+							rEnd = rStart + regionLen
+							if rEnd > seqLen {
+								rEnd = seqLen
+							}
+
+							coords := fmt.Sprintf(coordFormat+"-"+coordFormat, rStart+1, rEnd)
+							outfile := filepath.Join(outdir, fmt.Sprintf("%s%s_%s%s", prefix, record.ID, coords, fileExt))
+							outfh2, err := xopen.Wopen(outfile)
+							checkError(err)
+
+							r := record.Clone()
+							r.Seq = r.Seq.SubSeqInplace(rStart+1, rEnd)
+							r.ID = []byte(fmt.Sprintf("%s:%s", record.ID, coords))
+							r.Name = r.ID
+							r.Desc = nil
+							r.FormatToWriter(outfh2, config.LineWidth)
+							outfh2.Close()
+							j++
+
+							if rEnd == seqLen {
+								break
+							}
+						}
+						if !quiet {
+							log.Infof("write %d regions of sequence %s to %s", j, record.ID, outdir)
+						}
+						continue
+					}
+
 					n += int64(len(record.Seq.Seq))
 
 					if bySize {
@@ -413,7 +512,8 @@ If you want to cut a sequence into multiple segments.
 				}
 				fastxReader.Close()
 
-				if byParts {
+				if byRegion {
+				} else if byParts {
 					for i, outfh := range outfhs {
 						outfh.Close()
 
@@ -452,12 +552,14 @@ func init() {
 	split2Cmd.Flags().IntP("by-size", "s", 0, "split sequences into multi parts with N sequences")
 	split2Cmd.Flags().IntP("by-part", "p", 0, "split sequences into N parts with the round robin distribution")
 	split2Cmd.Flags().StringP("by-length", "l", "", "split sequences into chunks of >=N bases, supports K/M/G suffix")
+	split2Cmd.Flags().StringP("by-region", "r", "", "split long sequences into overlapping regions in format of LEN:OVERLAP, supports K/M/G suffix, e.g., 1M:10K")
 	split2Cmd.Flags().StringP("out-dir", "O", "", "output directory (default value is $infile.split)")
 	split2Cmd.Flags().BoolP("force", "f", false, "overwrite output directory")
 
 	split2Cmd.Flags().StringP("by-size-prefix", "", "", "file prefix for --by-size")
 	split2Cmd.Flags().StringP("by-part-prefix", "", "", "file prefix for --by-part")
 	split2Cmd.Flags().StringP("by-length-prefix", "", "", "file prefix for --by-length")
+	split2Cmd.Flags().StringP("by-region-prefix", "", "", "file prefix for --by-region")
 
 	split2Cmd.Flags().StringP("extension", "e", "", `set output file extension, e.g., ".gz", ".xz", or ".zst"`)
 }
@@ -0,0 +1,146 @@
+// Copyright © 2016-2024 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"bufio"
+	"container/list"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/shenwei356/xopen"
+)
+
+// readGroupFile reads a tab-delimited file of sequence IDs and group names.
+// Groups are returned in the order of their first appearance.
+func readGroupFile(file string) ([]string, map[string]string, error) {
+	fh, err := xopen.Ropen(file)
+	if err != nil {
+		return nil, nil, fmt.Errorf("read group file %s: %s", file, err)
+	}
+	defer fh.Close()
+
+	groups := make([]string, 0, 8)
+	groupsMap := make(map[string]struct{}, 8)
+	id2group := make(map[string]string, 1024)
+	scanner := bufio.NewScanner(fh)
+	var line string
+	var items []string
+	var lineNum int
+	for scanner.Scan() {
+		lineNum++
+		line = strings.TrimRight(scanner.Text(), "\r\n")
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		items = strings.Split(line, "\t")
+		if len(items) < 2 || items[1] == "" {
+			return nil, nil, fmt.Errorf("read group file %s: 2 columns needed at line %d: %s", file, lineNum, line)
+		}
+		if g, ok := id2group[items[0]]; ok {
+			if g != items[1] {
+				return nil, nil, fmt.Errorf("read group file %s: sequence %s is assigned to multiple groups: %s, %s", file, items[0], g, items[1])
+			}
+			continue
+		}
+		id2group[items[0]] = items[1]
+		if _, ok := groupsMap[items[1]]; !ok {
+			groupsMap[items[1]] = struct{}{}
+			groups = append(groups, items[1])
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, nil, fmt.Errorf("read group file %s: %s", file, err)
+	}
+	return groups, id2group, nil
+}
+
+// splitWriterPool bounds the number of simultaneously opened output files.
+// The least recently used file is closed when the limit is reached,
+// and reopened in append mode when needed again.
+type splitWriterPool struct {
+	max    int
+	ll     *list.List // of *splitWriter, most recently used at the front
+	open   map[string]*list.Element
+	opened map[string]struct{} // files created in this run
+
+	nReopened int
+}
+
+type splitWriter struct {
+	file string
+	fh   *xopen.Writer
+}
+
+func newSplitWriterPool(max int) *splitWriterPool {
+	return &splitWriterPool{
+		max:    max,
+		ll:     list.New(),
+		open:   make(map[string]*list.Element, max),
+		opened: make(map[string]struct{}, max),
+	}
+}
+
+// get returns the writer of a file, which is created in the first call,
+// and appended to afterwards.
+func (p *splitWriterPool) get(file string) (*xopen.Writer, error) {
+	if e, ok := p.open[file]; ok {
+		p.ll.MoveToFront(e)
+		return e.Value.(*splitWriter).fh, nil
+	}
+
+	if p.ll.Len() >= p.max {
+		e := p.ll.Back()
+		w := e.Value.(*splitWriter)
+		if err := w.fh.Close(); err != nil {
+			return nil, err
+		}
+		p.ll.Remove(e)
+		delete(p.open, w.file)
+	}
+
+	flag := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if _, ok := p.opened[file]; ok {
+		flag = os.O_WRONLY | os.O_APPEND
+		p.nReopened++
+	} else {
+		p.opened[file] = struct{}{}
+	}
+	fh, err := xopen.WopenFile(file, flag, 0644)
+	if err != nil {
+		return nil, err
+	}
+	p.open[file] = p.ll.PushFront(&splitWriter{file: file, fh: fh})
+	return fh, nil
+}
+
+// close closes all opened files.
+func (p *splitWriterPool) close() error {
+	for e := p.ll.Front(); e != nil; e = e.Next() {
+		if err := e.Value.(*splitWriter).fh.Close(); err != nil {
+			return err
+		}
+	}
+	p.ll.Init()
+	p.open = make(map[string]*list.Element, p.max)
+	return nil
+}
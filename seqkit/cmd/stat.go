@@ -21,11 +21,14 @@
 package cmd
 
 import (
+	"bufio"
+	"bytes"
 	"fmt"
 	"io"
 	"math"
 	"os"
 	"path/filepath"
+	"regexp"
 	"runtime"
 	"sort"
 	"strconv"
@@ -75,13 +78,88 @@ Columns:
   15. Q20(%)    percentage of bases with the quality score greater than 20
   16. Q30(%)    percentage of bases with the quality score greater than 30
   17. AvgQual   average quality
-  18. GC(%)     percentage of GC content
+  18. GC(%)     percentage of GC content, computed as (G+C)/(A+C+G+T+U),
+                i.e., ambiguous bases (e.g., N) and gaps are not counted in
+                the denominator. It is 0 if there are no such bases.
+                It can also be outputted without -a/--all by --gc.
+  19. stddev_len  sample standard deviation of sequence lengths
+  20. cv_len    coefficient of variation of sequence lengths, i.e.,
+                stddev_len/avg_len. Both are computed in a single pass
+                with Welford's algorithm, and they are 0 for inputs
+                with fewer than two records or of which avg_len is 0.
   
+Per-position quality profile (-P/--per-position, FASTQ only):
+
+  Quality scores of all reads are accumulated for each read position
+  (1-based), and a TSV table is outputted instead of the summary, with
+  columns: file, position, count, mean_q, q25, median, q75.
+  Quality scores are decoded according to -E/--fq-encoding.
+  Memory usage is proportional to the maximum read length.
+
 Attention:
   1. Sequence length metrics (sum_len, min_len, avg_len, max_len, Q1, Q2, Q3)
      count the number of gaps or spaces. You can remove them with "seqkit seq -g":
          seqkit seq -g input.fasta | seqkit stats
 
+Per-record statistics (--per-seq):
+  One row for each record in TSV format, in the order of input, with
+  columns: file, id, length, GC(%), N, AvgQual. AvgQual is "NA" for FASTA,
+  and quality scores are decoded according to -E/--fq-encoding.
+  Records are processed in parallel with -j/--threads.
+
+Merging statistics of multiple shards (--merge):
+  1. Save tabular results (-T) of each shard, optionally with length
+     histograms saved by --accumulate:
+         seqkit stats -T -a shard1.fq.gz --accumulate shard1.lens.tsv > shard1.tsv
+  2. Merge tabular results, records of the same format and type are merged:
+         seqkit stats --merge -T shard*.tsv --lengths-file shard1.lens.tsv,...
+  3. Metrics merged exactly from summaries: num_seqs, sum_len, min_len,
+     avg_len, max_len, sum_gap.
+     Metrics merged approximately (weighted by sum_len, with errors from the
+     rounding of inputs): Q20(%), Q30(%), AvgQual, GC(%), stddev_len and
+     cv_len. The latter two are recomputed exactly if length histograms
+     are given.
+     Metrics which can not be merged from summaries: Q1, Q2, Q3, N50, N50_num,
+     and other N50-like stats. They are recomputed exactly if length histograms
+     of all inputs are given via --lengths-file, otherwise "NA" is reported.
+
+Selecting and ordering columns (--stats-columns):
+  1. Only the given columns are outputted in the given order, for both the
+     tabular (-T) and the default table output, e.g.,
+         seqkit stats -T --stats-columns file,num_seqs,sum_len,N50 *.fq.gz
+  2. Column names are those listed above, and columns "file", "format" and
+     "type" are only outputted when included. N50-like stats like "N90" are
+     also accepted, and they are computed automatically without -N.
+  3. Statistics needed are computed automatically, -a/--all is not needed.
+  4. It is not compatible with --merge, --follow, --per-seq and -P.
+  5. With --group-regexp, the column "group" is also available, and it is
+     inserted after "file" (or at the beginning) if not included.
+
+Statistics of groups of records (--group-regexp):
+  1. Records are grouped by the first capture group of the regular expression
+     matched to sequence IDs, e.g., --group-regexp '^([^|]+)\|' for IDs like
+     "species1|seq1". Records not matched are assigned to the group "NA".
+  2. All statistics are computed for each group, and a column "group" is
+     inserted after "file". One row is outputted for each group in the order of
+     their first appearances, followed by the row of all records of the file,
+     with the group "all".
+  3. Sequence lengths of each group are kept in histograms, so the memory is
+     proportional to the number of distinct lengths, no matter whether the
+     records are sorted by groups.
+  4. Files are processed one by one. It is not compatible with --merge,
+     --follow, --per-seq, -P/--per-position and --accumulate.
+
+Following a growing file (--follow):
+  1. Like "tail -f", records appended to the file are read continuously, and
+     statistics are reprinted to stderr every --interval if new records come.
+  2. Only complete records are counted, a partial record at the end of file
+     is waited for. For FASTA, a record is complete when the next header line
+     appears. FASTQ records should be in four lines.
+  3. Press Ctrl-C (SIGINT) or send SIGTERM to stop, the last FASTA record is
+     counted and final statistics are written to -o/--out-file.
+  4. Only one plain-text file is supported, stdin and compressed files are not.
+     If the file is truncated, statistics are reset.
+
 Tips:
   1. For lots of small files (especially on SDD), use a big value of '-j' to
      parallelize counting.
@@ -108,10 +186,10 @@ Tips:
 			}
 		}
 		gapLettersBytes := []byte(gapLetters)
-		gcLettersBytes := []byte{'g', 'c', 'G', 'C'}
 
 		skipFileCheck := getFlagBool(cmd, "skip-file-check")
 		all := getFlagBool(cmd, "all")
+		gcOnly := getFlagBool(cmd, "gc") && !all
 		tabular := getFlagBool(cmd, "tabular")
 		skipErr := getFlagBool(cmd, "skip-err")
 		fqEncoding := parseQualityEncoding(getFlagString(cmd, "fq-encoding"))
@@ -119,7 +197,6 @@ Tips:
 		stdinLabel := getFlagString(cmd, "stdin-label")
 		replaceStdinLabel := stdinLabel != "-"
 		_NX := getFlagStringSlice(cmd, "N")
-		hasNX := len(_NX) > 0
 
 		NX := make([]float64, len(_NX))
 		var err error
@@ -133,7 +210,78 @@ Tips:
 			}
 		}
 
+		var selCols []string
+		if statsColumns := getFlagStringSlice(cmd, "stats-columns"); len(statsColumns) > 0 {
+			if getFlagBool(cmd, "merge") || getFlagBool(cmd, "follow") || getFlagBool(cmd, "per-seq") || getFlagBool(cmd, "per-position") {
+				checkError(fmt.Errorf("flag --stats-columns is not compatible with --merge, --follow, --per-seq and -P/--per-position"))
+			}
+			selCols, _NX, NX, all, gcOnly, err = statSelectColumns(statsColumns, _NX, NX)
+			checkError(err)
+		}
+
+		bench := getBenchmarkStats(cmd)
 		files := getFileListFromArgsAndFile(cmd, args, !skipFileCheck, "infile-list", !skipFileCheck)
+		bench.addInputs(files...)
+
+		if getFlagString(cmd, "group-regexp") != "" &&
+			(getFlagBool(cmd, "merge") || getFlagBool(cmd, "follow") || getFlagBool(cmd, "per-seq") ||
+				getFlagBool(cmd, "per-position") || getFlagString(cmd, "accumulate") != "") {
+			checkError(fmt.Errorf("flag --group-regexp is not compatible with --merge, --follow, --per-seq, -P/--per-position and --accumulate"))
+		}
+
+		if getFlagBool(cmd, "follow") && (getFlagBool(cmd, "per-position") || getFlagBool(cmd, "merge")) {
+			checkError(fmt.Errorf("flag --follow is not compatible with -P/--per-position or --merge"))
+		}
+
+		if getFlagBool(cmd, "per-seq") {
+			if getFlagBool(cmd, "per-position") || getFlagBool(cmd, "merge") || getFlagBool(cmd, "follow") {
+				checkError(fmt.Errorf("flag --per-seq is not compatible with -P/--per-position, --merge or --follow"))
+			}
+
+			outfh, err := xopen.Wopen(outFile)
+			checkError(err)
+			defer outfh.Close()
+
+			outfh.WriteString("file\tid\tlength\tGC(%)\tN\tAvgQual\n")
+			for _, file := range files {
+				label := file
+				if basename {
+					label = filepath.Base(label)
+				}
+				if replaceStdinLabel && isStdin(label) {
+					label = stdinLabel
+				}
+				err = statPerSeq(outfh, file, label, alphabet, idRegexp, fqEncoding.Offset(), config.Threads, bench)
+				if err != nil {
+					if skipErr {
+						log.Warningf("%s: %s", file, err)
+						continue
+					}
+					checkError(fmt.Errorf("%s: %s", file, err))
+				}
+			}
+			return
+		}
+
+		if getFlagBool(cmd, "per-position") {
+			outfh, err := xopen.Wopen(outFile)
+			checkError(err)
+			defer outfh.Close()
+
+			outfh.WriteString("file\tposition\tcount\tmean_q\tq25\tmedian\tq75\n")
+			for _, file := range files {
+				err = statQualPerPosition(outfh, file, alphabet, idRegexp, fqEncoding.Offset(),
+					basename, replaceStdinLabel, stdinLabel, bench)
+				if err != nil {
+					if skipErr {
+						log.Warningf("%s: %s", file, err)
+						continue
+					}
+					checkError(fmt.Errorf("%s: %s", file, err))
+				}
+			}
+			return
+		}
 
 		style := &stable.TableStyle{
 			Name: "plain",
@@ -143,6 +291,125 @@ Tips:
 			Padding:   "",
 		}
 
+		lengthsFiles := getFlagStringSlice(cmd, "lengths-file")
+		if getFlagBool(cmd, "merge") {
+			outfh, err := xopen.Wopen(outFile)
+			checkError(err)
+			defer outfh.Close()
+
+			mergeStats(outfh, files, lengthsFiles, tabular, style, config.Quiet)
+			return
+		}
+		if len(lengthsFiles) > 0 {
+			checkError(fmt.Errorf("flag --lengths-file only works with --merge"))
+		}
+
+		if groupRegexp := getFlagString(cmd, "group-regexp"); groupRegexp != "" {
+			re, err := regexp.Compile(groupRegexp)
+			if err != nil {
+				checkError(fmt.Errorf("fail to compile the regular expression of --group-regexp: %s", err))
+			}
+			if re.NumSubexp() == 0 {
+				checkError(fmt.Errorf(`the value of --group-regexp should contain a capture group, e.g., '^([^|]+)\|'`))
+			}
+
+			cols := selCols
+			if cols == nil {
+				cols = statDefaultColumns(all, gcOnly, _NX)
+			}
+			if statColumnIndexIn(cols, "group") < 0 {
+				i := statColumnIndexIn(cols, "file") + 1
+				cols = append(cols[:i], append([]string{"group"}, cols[i:]...)...)
+			}
+
+			opt := &statFollowOptions{
+				alphabet:   alphabet,
+				gapLetters: gapLettersBytes,
+				encOffset:  fqEncoding.Offset(),
+				all:        all,
+				gcOnly:     gcOnly,
+				nx:         NX,
+				nxLabels:   _NX,
+			}
+
+			outfh, err := xopen.Wopen(outFile)
+			checkError(err)
+			defer outfh.Close()
+
+			infos := make([]statInfo, 0, 64)
+			var _infos []statInfo
+			for _, file := range files {
+				_infos, err = statGroups(file, re, idRegexp, opt, bench)
+				if err != nil {
+					if skipErr {
+						log.Warningf("%s: %s", file, err)
+						continue
+					}
+					checkError(fmt.Errorf("%s: %s", file, err))
+				}
+				label := file
+				if basename {
+					label = filepath.Base(label)
+				}
+				if replaceStdinLabel && isStdin(label) {
+					label = stdinLabel
+				}
+				for i := range _infos {
+					_infos[i].file = label
+				}
+				infos = append(infos, _infos...)
+			}
+			statWriteColumns(outfh, infos, cols, _NX, tabular, style)
+			return
+		} else if selCols != nil && statColumnIndexIn(selCols, "group") >= 0 {
+			checkError(fmt.Errorf(`column "group" of --stats-columns only works with --group-regexp`))
+		}
+
+		if getFlagBool(cmd, "follow") {
+			if len(files) != 1 {
+				checkError(fmt.Errorf("flag --follow only supports one input file"))
+			}
+			if getFlagString(cmd, "accumulate") != "" {
+				checkError(fmt.Errorf("flag --follow is not compatible with --accumulate"))
+			}
+			interval, err := time.ParseDuration(getFlagString(cmd, "interval"))
+			if err != nil {
+				checkError(fmt.Errorf("invalid value of --interval: %s", err))
+			}
+			if interval <= 0 {
+				checkError(fmt.Errorf("the value of --interval should be positive"))
+			}
+
+			label := files[0]
+			if basename {
+				label = filepath.Base(label)
+			}
+
+			outfh, err := xopen.Wopen(outFile)
+			checkError(err)
+			defer outfh.Close()
+
+			checkError(statFollow(outfh, files[0], &statFollowOptions{
+				alphabet:   alphabet,
+				gapLetters: gapLettersBytes,
+				encOffset:  fqEncoding.Offset(),
+				all:        all,
+				gcOnly:     gcOnly,
+				tabular:    tabular,
+				nx:         NX,
+				nxLabels:   _NX,
+				label:      label,
+				interval:   interval,
+				style:      style,
+			}))
+			return
+		}
+
+		accumulateFile := getFlagString(cmd, "accumulate")
+		accumulate := accumulateFile != ""
+		var lensMu sync.Mutex
+		lensHists := make(map[uint64]*statLengthHist)
+
 		// process bar
 		var pbs *mpb.Progress
 		var bar *mpb.Bar
@@ -181,28 +448,14 @@ Tips:
 		checkError(err)
 		defer outfh.Close()
 
+		cols := selCols
+		if cols == nil {
+			cols = statDefaultColumns(all, gcOnly, _NX)
+		}
+
 		// tabular output
 		if tabular {
-			colnames := []string{
-				"file",
-				"format",
-				"type",
-				"num_seqs",
-				"sum_len",
-				"min_len",
-				"avg_len",
-				"max_len",
-			}
-			if all {
-				colnames = append(colnames, []string{"Q1", "Q2", "Q3", "sum_gap", "N50", "N50_num", "Q20(%)", "Q30(%)", "AvgQual", "GC(%)"}...)
-			}
-
-			if hasNX {
-				for _, x := range _NX {
-					colnames = append(colnames, "N"+x)
-				}
-			}
-			outfh.WriteString(strings.Join(colnames, "\t") + "\n")
+			outfh.WriteString(strings.Join(cols, "\t") + "\n")
 		}
 
 		ch := make(chan statInfo, config.Threads)
@@ -211,7 +464,6 @@ Tips:
 		cancel := make(chan struct{})
 
 		done := make(chan int)
-		var x float64
 		go func() {
 			var id uint64 = 1 // for keepping order
 			buf := make(map[uint64]statInfo)
@@ -232,34 +484,7 @@ Tips:
 					if !tabular {
 						statInfos = append(statInfos, info)
 					} else {
-						fmt.Fprintf(outfh, "%s\t%s\t%s\t%d\t%d\t%d\t%.1f\t%d",
-							info.file,
-							info.format,
-							info.t,
-							info.num,
-							info.lenSum,
-							info.lenMin,
-							info.lenAvg,
-							info.lenMax)
-						if all {
-							fmt.Fprintf(outfh, "\t%.1f\t%.1f\t%.1f\t%d\t%d\t%d\t%.2f\t%.2f\t%.2f\t%.2f",
-								info.Q1,
-								info.Q2,
-								info.Q3,
-								info.gapSum,
-								info.N50,
-								info.L50,
-								info.q20,
-								info.q30,
-								info.avgQual,
-								info.gc)
-						}
-						if hasNX {
-							for _, x = range info.nx {
-								fmt.Fprintf(outfh, "\t%.0f", x)
-							}
-						}
-						outfh.WriteString("\n")
+						statWriteSelectedColumns(outfh, &info, cols, _NX)
 						outfh.Flush()
 					}
 					id++
@@ -273,34 +498,7 @@ Tips:
 					if !tabular {
 						statInfos = append(statInfos, info)
 					} else {
-						fmt.Fprintf(outfh, "%s\t%s\t%s\t%d\t%d\t%d\t%.1f\t%d",
-							info.file,
-							info.format,
-							info.t,
-							info.num,
-							info.lenSum,
-							info.lenMin,
-							info.lenAvg,
-							info.lenMax)
-						if all {
-							fmt.Fprintf(outfh, "\t%.1f\t%.1f\t%.1f\t%d\t%d\t%d\t%.2f\t%.2f\t%.2f\t%.2f",
-								info.Q1,
-								info.Q2,
-								info.Q3,
-								info.gapSum,
-								info.N50,
-								info.L50,
-								info.q20,
-								info.q30,
-								info.avgQual,
-								info.gc)
-						}
-						if hasNX {
-							for _, x = range info.nx {
-								fmt.Fprintf(outfh, "\t%.0f", x)
-							}
-						}
-						outfh.WriteString("\n")
+						statWriteSelectedColumns(outfh, &info, cols, _NX)
 						outfh.Flush()
 					}
 
@@ -322,34 +520,7 @@ Tips:
 					if !tabular {
 						statInfos = append(statInfos, info)
 					} else {
-						fmt.Fprintf(outfh, "%s\t%s\t%s\t%d\t%d\t%d\t%.1f\t%d",
-							info.file,
-							info.format,
-							info.t,
-							info.num,
-							info.lenSum,
-							info.lenMin,
-							info.lenAvg,
-							info.lenMax)
-						if all {
-							fmt.Fprintf(outfh, "\t%.1f\t%.1f\t%.1f\t%d\t%d\t%d\t%.2f\t%.2f\t%.2f\t%.2f",
-								info.Q1,
-								info.Q2,
-								info.Q3,
-								info.gapSum,
-								info.N50,
-								info.L50,
-								info.q20,
-								info.q30,
-								info.avgQual,
-								info.gc)
-						}
-						if hasNX {
-							for _, x = range info.nx {
-								fmt.Fprintf(outfh, "\t%.0f", x)
-							}
-						}
-						outfh.WriteString("\n")
+						statWriteSelectedColumns(outfh, &info, cols, _NX)
 						outfh.Flush()
 					}
 				}
@@ -398,17 +569,12 @@ Tips:
 					}
 				}()
 
-				var gapSum uint64
-				var gcSum uint64
-
-				lensStats := util.NewLengthStats()
+				acc := newStatAccumulator(gapLettersBytes, fqEncoding.Offset(), all, gcOnly, NX)
+				var lensHist map[uint64]uint64
+				if accumulate {
+					lensHist = make(map[uint64]uint64, 256)
+				}
 
-				var errSum, avgQual float64
-				qual_map := seq.QUAL_MAP
-				var q20, q30 int64
-				var q byte
-				var qual int
-				var encodeOffset int = fqEncoding.Offset()
 				var seqFormat, t string
 				var record *fastx.Record
 				var fastxReader *fastx.Reader
@@ -450,6 +616,7 @@ Tips:
 						}
 						break
 					}
+					bench.addRecord()
 
 					if seqFormat == "" {
 						if len(record.Seq.Qual) > 0 {
@@ -459,99 +626,46 @@ Tips:
 						}
 					}
 
-					lensStats.Add(uint64(len(record.Seq.Seq)))
-
-					if all {
-						if fastxReader.IsFastq {
-							for _, q = range record.Seq.Qual {
-								qual = int(q) - encodeOffset
-								if qual >= 20 {
-									q20++
-									if qual >= 30 {
-										q30++
-									}
-								}
-
-								errSum += qual_map[qual]
-							}
-						}
-
-						gapSum += uint64(byteutil.CountBytes(record.Seq.Seq, gapLettersBytes))
-						gcSum += uint64(byteutil.CountBytes(record.Seq.Seq, gcLettersBytes))
+					if fastxReader.IsFastq {
+						acc.add(record.Seq.Seq, record.Seq.Qual)
+					} else {
+						acc.add(record.Seq.Seq, nil)
+					}
+					if accumulate {
+						lensHist[uint64(len(record.Seq.Seq))]++
 					}
 				}
 
 				fastxReader.Close()
 
-				if fastxReader.Alphabet() == seq.DNAredundant {
-					t = "DNA"
-				} else if fastxReader.Alphabet() == seq.RNAredundant {
-					t = "RNA"
-				} else if seqFormat == "" && fastxReader.Alphabet() == seq.Unlimit {
-					t = ""
-				} else {
-					t = fastxReader.Alphabet().String()
-				}
-
-				var n50 uint64
-				var l50 int
-				var q1, q2, q3 float64
-				if all {
-					n50 = lensStats.N50()
-					l50 = lensStats.L50()
-					q1, q2, q3 = lensStats.Q1(), lensStats.Q2(), lensStats.Q3()
-
-					if errSum == 0 {
-						avgQual = 0
-					} else {
-						avgQual = -10 * math.Log10(errSum/float64(lensStats.Sum()))
-					}
-				}
-				var nx []float64
-				if hasNX {
-					nx = make([]float64, len(NX))
-					for i, x := range NX {
-						nx[i] = float64(lensStats.NX(x))
-					}
-				}
+				t = statSeqType(fastxReader.Alphabet(), seqFormat)
 
 				select {
 				case <-cancel:
 					return
 				default:
 				}
-				if lensStats.Count() == 0 {
+				if accumulate {
+					label := file
 					if basename {
-						file = filepath.Base(file)
+						label = filepath.Base(label)
 					}
-					if replaceStdinLabel && isStdin(file) {
-						file = stdinLabel
-					}
-					ch <- statInfo{file, seqFormat, t,
-						0, 0, 0, 0,
-						0, 0, 0, 0,
-						0, 0, 0,
-						0, 0, 0, 0,
-						nx,
-						nil, id}
-				} else {
-					if basename {
-						file = filepath.Base(file)
-					}
-					if replaceStdinLabel && isStdin(file) {
-						file = stdinLabel
+					if replaceStdinLabel && isStdin(label) {
+						label = stdinLabel
 					}
-					ch <- statInfo{file, seqFormat, t,
-						lensStats.Count(), lensStats.Sum(), gapSum, lensStats.Min(),
-						mathutil.Round(lensStats.Mean(), 1), lensStats.Max(), n50, l50,
-						q1, q2, q3,
-						mathutil.Round(float64(q20)/float64(lensStats.Sum())*100, 2),
-						mathutil.Round(float64(q30)/float64(lensStats.Sum())*100, 2),
-						mathutil.Round(avgQual, 2),
-						mathutil.Round(float64(gcSum)/float64(lensStats.Sum())*100, 2),
-						nx,
-						nil, id}
+					lensMu.Lock()
+					lensHists[id] = &statLengthHist{file: label, format: seqFormat, t: t, hist: lensHist}
+					lensMu.Unlock()
 				}
+				if basename {
+					file = filepath.Base(file)
+				}
+				if replaceStdinLabel && isStdin(file) {
+					file = stdinLabel
+				}
+				info := acc.info(file, seqFormat, t)
+				info.id = id
+				ch <- info
 			}(file, id)
 		}
 
@@ -561,6 +675,14 @@ Tips:
 		close(ch)
 		<-done
 
+		if accumulate {
+			select {
+			case <-cancel:
+			default:
+				checkError(writeStatLengthHists(accumulateFile, lensHists))
+			}
+		}
+
 		if !config.Quiet && len(files) > 1 {
 			close(chDuration)
 			<-doneDuration
@@ -577,80 +699,192 @@ Tips:
 			return
 		}
 
-		// format output
-		columns := []stable.Column{
-			{Header: "file"},
-			{Header: "format"},
-			{Header: "type"},
-			{Header: "num_seqs", Align: stable.AlignRight, HumanizeNumbers: true},
-			{Header: "sum_len", Align: stable.AlignRight, HumanizeNumbers: true},
-			{Header: "min_len", Align: stable.AlignRight, HumanizeNumbers: true},
-			{Header: "avg_len", Align: stable.AlignRight, HumanizeNumbers: true},
-			{Header: "max_len", Align: stable.AlignRight, HumanizeNumbers: true},
-		}
-
-		if all {
-			columns = append(columns, []stable.Column{
-				{Header: "Q1", Align: stable.AlignRight, HumanizeNumbers: true},
-				{Header: "Q2", Align: stable.AlignRight, HumanizeNumbers: true},
-				{Header: "Q3", Align: stable.AlignRight, HumanizeNumbers: true},
-				{Header: "sum_gap", Align: stable.AlignRight, HumanizeNumbers: true},
-				{Header: "N50", Align: stable.AlignRight, HumanizeNumbers: true},
-				{Header: "N50_num", Align: stable.AlignRight, HumanizeNumbers: true},
-				{Header: "Q20(%)", Align: stable.AlignRight, HumanizeNumbers: true},
-				{Header: "Q30(%)", Align: stable.AlignRight, HumanizeNumbers: true},
-				{Header: "AvgQual", Align: stable.AlignRight, HumanizeNumbers: true},
-				{Header: "GC(%)", Align: stable.AlignRight, HumanizeNumbers: true},
-				// {Header: "L50", AlignRight: true},
-			}...)
-		}
-		if hasNX {
-			for _, x := range _NX {
-				columns = append(columns, stable.Column{Header: "N" + x, Align: stable.AlignRight, HumanizeNumbers: true})
-			}
-		}
-
-		tbl := stable.New()
-		tbl.HeaderWithFormat(columns)
-		for _, info := range statInfos {
-			row := make([]interface{}, 0, len(columns))
-			row = append(row, info.file)
-			row = append(row, info.format)
-			row = append(row, info.t)
-			row = append(row, humanize.Comma(int64(info.num)))
-			row = append(row, info.lenSum)
-			row = append(row, info.lenMin)
-			row = append(row, info.lenAvg)
-			row = append(row, info.lenMax)
-
-			if all {
-				row = append(row, info.Q1)
-				row = append(row, info.Q2)
-				row = append(row, info.Q3)
-				row = append(row, info.gapSum)
-				row = append(row, info.N50)
-				row = append(row, info.L50)
-				row = append(row, info.q20)
-				row = append(row, info.q30)
-				row = append(row, info.avgQual)
-				row = append(row, info.gc)
-			}
-			if hasNX {
-				for _, x = range info.nx {
-					row = append(row, x)
-				}
-			}
-
-			tbl.AddRow(row)
-		}
-		outfh.Write(tbl.Render(style))
+		statWriteColumns(outfh, statInfos, cols, _NX, false, style)
 	},
 }
 
+// statColumns are names of all columns, except N50-like stats given by -N.
+var statColumns = []string{"file", "format", "type",
+	"num_seqs", "sum_len", "min_len", "avg_len", "max_len",
+	"Q1", "Q2", "Q3", "sum_gap", "N50", "N50_num", "Q20(%)", "Q30(%)", "AvgQual", "GC(%)",
+	"stddev_len", "cv_len"}
+
+var reStatNXColumn = regexp.MustCompile(`^N(\d+(\.\d+)?)$`)
+
+// statColumnIndexIn returns the index of a column in cols, or -1 if not found.
+func statColumnIndexIn(cols []string, name string) int {
+	for i, c := range cols {
+		if c == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func statColumnIndex(name string) int {
+	for i, c := range statColumns {
+		if c == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// statSelectColumns checks column names for --stats-columns, and returns
+// the columns, N50-like stats to compute, and whether all stats or GC content are needed.
+func statSelectColumns(names []string, _NX []string, NX []float64) ([]string, []string, []float64, bool, bool, error) {
+	var all, gc bool
+	cols := make([]string, 0, len(names))
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if seen[name] {
+			return nil, nil, nil, false, false, fmt.Errorf("duplicated column in --stats-columns: %s", name)
+		}
+		seen[name] = true
+		cols = append(cols, name)
+
+		if name == "group" { // for --group-regexp
+			continue
+		}
+
+		i := statColumnIndex(name)
+		if i >= 0 {
+			if name == "GC(%)" {
+				gc = true
+			} else if i >= 8 {
+				all = true
+			}
+			continue
+		}
+
+		found := false
+		for _, x := range _NX {
+			if "N"+x == name {
+				found = true
+				break
+			}
+		}
+		if found {
+			continue
+		}
+		m := reStatNXColumn.FindStringSubmatch(name)
+		if m == nil {
+			return nil, nil, nil, false, false, fmt.Errorf("unknown column in --stats-columns: %s. available: %s, and N50-like stats like N90",
+				name, strings.Join(statColumns, ", "))
+		}
+		x, _ := strconv.ParseFloat(m[1], 64)
+		if x > 100 {
+			return nil, nil, nil, false, false, fmt.Errorf("N50-like stats in --stats-columns should be in the range of [0, 100]: %s", name)
+		}
+		_NX = append(_NX, m[1])
+		NX = append(NX, x)
+	}
+	return cols, _NX, NX, all, gc && !all, nil
+}
+
+// statColumnValue returns the value of a column, formatted as a string for tabular output.
+func statColumnValue(info *statInfo, name string, _NX []string, tabular bool) interface{} {
+	// N50-like stats given by -N, "N50" has the same value as the column of all stats.
+	for i, x := range _NX {
+		if "N"+x == name {
+			if tabular {
+				return fmt.Sprintf("%.0f", info.nx[i])
+			}
+			return info.nx[i]
+		}
+	}
+
+	var v interface{}
+	switch name {
+	case "file":
+		v = info.file
+	case "format":
+		v = info.format
+	case "type":
+		v = info.t
+	case "group":
+		v = info.group
+	case "num_seqs":
+		if !tabular {
+			return humanize.Comma(int64(info.num))
+		}
+		v = info.num
+	case "sum_len":
+		v = info.lenSum
+	case "min_len":
+		v = info.lenMin
+	case "avg_len":
+		if tabular {
+			return fmt.Sprintf("%.1f", info.lenAvg)
+		}
+		v = info.lenAvg
+	case "max_len":
+		v = info.lenMax
+	case "Q1", "Q2", "Q3":
+		q := info.Q1
+		if name == "Q2" {
+			q = info.Q2
+		} else if name == "Q3" {
+			q = info.Q3
+		}
+		if tabular {
+			return fmt.Sprintf("%.1f", q)
+		}
+		v = q
+	case "sum_gap":
+		v = info.gapSum
+	case "N50":
+		v = info.N50
+	case "N50_num":
+		v = info.L50
+	case "stddev_len":
+		if tabular {
+			return fmt.Sprintf("%.2f", info.lenStd)
+		}
+		v = info.lenStd
+	case "cv_len":
+		if tabular {
+			return fmt.Sprintf("%.4f", info.lenCV)
+		}
+		v = info.lenCV
+	case "Q20(%)", "Q30(%)", "AvgQual", "GC(%)":
+		var f float64
+		switch name {
+		case "Q20(%)":
+			f = info.q20
+		case "Q30(%)":
+			f = info.q30
+		case "AvgQual":
+			f = info.avgQual
+		default:
+			f = info.gc
+		}
+		if tabular {
+			return fmt.Sprintf("%.2f", f)
+		}
+		v = f
+	}
+	if tabular {
+		return fmt.Sprintf("%v", v)
+	}
+	return v
+}
+
+// statWriteSelectedColumns writes a row of the columns given by --stats-columns in tabular format.
+func statWriteSelectedColumns(w io.Writer, info *statInfo, cols []string, _NX []string) {
+	values := make([]string, len(cols))
+	for i, c := range cols {
+		values[i] = statColumnValue(info, c, _NX, true).(string)
+	}
+	io.WriteString(w, strings.Join(values, "\t")+"\n")
+}
+
 type statInfo struct {
 	file   string
 	format string
 	t      string
+	group  string // for --group-regexp
 
 	num    uint64
 	lenSum uint64
@@ -672,25 +906,876 @@ type statInfo struct {
 
 	gc float64
 
+	lenStd float64
+	lenCV  float64
+
 	nx []float64
 
 	err error
 	id  uint64
 }
 
+// statAccumulator accumulates statistics of records for the default mode,
+// --group-regexp and --follow.
+type statAccumulator struct {
+	gapLetters []byte
+	encOffset  int
+	all        bool
+	gcOnly     bool
+	nx         []float64
+
+	lensStats *util.LengthStats
+	lensVar   statLenVar
+	gapSum    uint64
+	gcSum     uint64
+	acgtSum   uint64
+	q20, q30  int64
+	errSum    float64
+}
+
+var statGCLetters = []byte{'g', 'c', 'G', 'C'}
+
+// statACGTLetters are bases in the denominator of GC(%).
+var statACGTLetters = []byte{'a', 'c', 'g', 't', 'u', 'A', 'C', 'G', 'T', 'U'}
+
+func newStatAccumulator(gapLetters []byte, encOffset int, all bool, gcOnly bool, nx []float64) *statAccumulator {
+	return &statAccumulator{
+		gapLetters: gapLetters,
+		encOffset:  encOffset,
+		all:        all,
+		gcOnly:     gcOnly,
+		nx:         nx,
+		lensStats:  util.NewLengthStats(),
+	}
+}
+
+// add adds a record with the sequence s and the quality q, which is empty for FASTA.
+func (a *statAccumulator) add(s, q []byte) {
+	a.lensStats.Add(uint64(len(s)))
+	a.lensVar.add(float64(len(s)))
+
+	if a.all {
+		var qual int
+		for _, b := range q {
+			qual = int(b) - a.encOffset
+			if qual >= 20 {
+				a.q20++
+				if qual >= 30 {
+					a.q30++
+				}
+			}
+			a.errSum += seq.QUAL_MAP[qual]
+		}
+		a.gapSum += uint64(byteutil.CountBytes(s, a.gapLetters))
+	}
+	if a.all || a.gcOnly {
+		a.gcSum += uint64(byteutil.CountBytes(s, statGCLetters))
+		a.acgtSum += uint64(byteutil.CountBytes(s, statACGTLetters))
+	}
+}
+
+// info returns the statistics of records added.
+func (a *statAccumulator) info(file, format, t string) statInfo {
+	info := statInfo{file: file, format: format, t: t}
+	if len(a.nx) > 0 {
+		info.nx = make([]float64, len(a.nx))
+	}
+	stats := a.lensStats
+	if stats.Count() == 0 {
+		return info
+	}
+
+	info.num, info.lenSum, info.gapSum = stats.Count(), stats.Sum(), a.gapSum
+	info.lenMin, info.lenMax = stats.Min(), stats.Max()
+	info.lenAvg = mathutil.Round(stats.Mean(), 1)
+
+	sum := float64(stats.Sum())
+	if a.all {
+		info.N50, info.L50 = stats.N50(), stats.L50()
+		info.Q1, info.Q2, info.Q3 = stats.Q1(), stats.Q2(), stats.Q3()
+		info.q20 = mathutil.Round(float64(a.q20)/sum*100, 2)
+		info.q30 = mathutil.Round(float64(a.q30)/sum*100, 2)
+		if a.errSum > 0 {
+			info.avgQual = mathutil.Round(-10*math.Log10(a.errSum/sum), 2)
+		}
+	}
+	if a.acgtSum > 0 {
+		info.gc = mathutil.Round(float64(a.gcSum)/float64(a.acgtSum)*100, 2)
+	}
+	info.lenStd = mathutil.Round(a.lensVar.std(), 2)
+	info.lenCV = mathutil.Round(a.lensVar.cv(), 4)
+	for i, x := range a.nx {
+		info.nx[i] = float64(stats.NX(x))
+	}
+	return info
+}
+
+// statSeqType returns the sequence type in the column "type".
+func statSeqType(alphabet *seq.Alphabet, format string) string {
+	switch {
+	case alphabet == seq.DNAredundant:
+		return "DNA"
+	case alphabet == seq.RNAredundant:
+		return "RNA"
+	case format == "" && alphabet == seq.Unlimit:
+		return ""
+	}
+	return alphabet.String()
+}
+
+// statDefaultColumns returns the columns to output when --stats-columns is not given.
+func statDefaultColumns(all bool, gcOnly bool, _NX []string) []string {
+	cols := make([]string, 0, len(statColumns)+len(_NX))
+	cols = append(cols, statColumns[:8]...)
+	if all {
+		cols = append(cols, statColumns[8:]...)
+	} else if gcOnly {
+		cols = append(cols, "GC(%)")
+	}
+	for _, x := range _NX {
+		cols = append(cols, "N"+x)
+	}
+	return cols
+}
+
+// statLenVar computes the mean and variance of sequence lengths in a single pass,
+// with Welford's algorithm.
+type statLenVar struct {
+	n    float64
+	mean float64
+	m2   float64 // sum of squares of differences from the mean
+}
+
+func (v *statLenVar) add(x float64) {
+	v.n++
+	d := x - v.mean
+	v.mean += d / v.n
+	v.m2 += d * (x - v.mean)
+}
+
+// merge combines the statistics of another set of n values,
+// with the mean and the sum of squares of differences m2.
+func (v *statLenVar) merge(n, mean, m2 float64) {
+	if n == 0 {
+		return
+	}
+	if v.n == 0 {
+		v.n, v.mean, v.m2 = n, mean, m2
+		return
+	}
+	d := mean - v.mean
+	t := v.n + n
+	v.mean += d * n / t
+	v.m2 += m2 + d*d*v.n*n/t
+	v.n = t
+}
+
+// std returns the sample standard deviation, 0 for fewer than two values.
+func (v *statLenVar) std() float64 {
+	if v.n < 2 {
+		return 0
+	}
+	return math.Sqrt(v.m2 / (v.n - 1))
+}
+
+// cv returns the coefficient of variation, 0 if the mean is 0.
+func (v *statLenVar) cv() float64 {
+	if v.mean == 0 {
+		return 0
+	}
+	return v.std() / v.mean
+}
+
 func init() {
 	RootCmd.AddCommand(statCmd)
 
 	statCmd.Flags().BoolP("tabular", "T", false, "output in machine-friendly tabular format")
 	statCmd.Flags().StringP("gap-letters", "G", "- .", "gap letters")
 	statCmd.Flags().BoolP("all", "a", false, "all statistics, including quartiles of seq length, sum_gap, N50")
+	statCmd.Flags().BoolP("gc", "", false, "output GC content (GC(%)) without -a/--all, ambiguous bases and gaps are not counted in the denominator")
 	statCmd.Flags().BoolP("skip-err", "e", false, "skip error, only show warning message")
 	statCmd.Flags().StringP("fq-encoding", "E", "sanger", `fastq quality encoding. available values: 'sanger', 'solexa', 'illumina-1.3+', 'illumina-1.5+', 'illumina-1.8+'.`)
 	statCmd.Flags().BoolP("basename", "b", false, "only output basename of files")
 	statCmd.Flags().StringP("stdin-label", "i", "-", `label for replacing default "-" for stdin`)
 	statCmd.Flags().StringSliceP("N", "N", []string{}, `append other N50-like stats as new columns. value range [0, 100], multiple values supported, e.g., -N 50,90 or -N 50 -N 90`)
 	statCmd.Flags().BoolP("skip-file-check", "S", false, `skip input file checking when given files or a file list.`)
+	statCmd.Flags().StringP("accumulate", "", "", `save histograms of sequence lengths to this file, for merging results later with --merge and --lengths-file`)
+	statCmd.Flags().BoolP("merge", "", false, `merge tabular results (-T) given as input files, type "seqkit stats -h" for details`)
+	statCmd.Flags().StringSliceP("lengths-file", "", []string{}, `length histogram files saved by --accumulate, for merging quartiles and N50 with --merge`)
+	statCmd.Flags().BoolP("per-position", "P", false, `output per-position quality profile (count, mean and quartiles of quality scores) of FASTQ files in TSV format`)
+	statCmd.Flags().StringSliceP("stats-columns", "", []string{}, `only output these columns in the given order, e.g., --stats-columns file,num_seqs,sum_len,N50. type "seqkit stats -h" for details`)
+	statCmd.Flags().BoolP("per-seq", "", false, `output statistics of each record (length, GC(%), number of N, and average quality) in TSV format`)
+	statCmd.Flags().BoolP("follow", "", false, `keep reading records appended to a growing file like "tail -f", type "seqkit stats -h" for details`)
+	statCmd.Flags().StringP("group-regexp", "", "", `compute statistics of groups of records defined by the first capture group of the regular expression matched to IDs, type "seqkit stats -h" for details`)
+	statCmd.Flags().StringP("interval", "", "5s", `refresh interval of statistics printed to stderr in --follow mode, e.g., 500ms, 10s, 1m`)
+
+}
+
+// statLengthHist is the histogram of sequence lengths of a file.
+type statLengthHist struct {
+	file   string
+	format string
+	t      string
+	hist   map[uint64]uint64
+}
+
+// writeStatLengthHists writes length histograms in the order of input files.
+func writeStatLengthHists(file string, hists map[uint64]*statLengthHist) error {
+	outfh, err := xopen.Wopen(file)
+	if err != nil {
+		return err
+	}
+	defer outfh.Close()
+
+	ids := make(sortutil.Uint64Slice, 0, len(hists))
+	for id := range hists {
+		ids = append(ids, id)
+	}
+	sort.Sort(ids)
+
+	outfh.WriteString("file\tformat\ttype\tlength\tcount\n")
+	var lens sortutil.Uint64Slice
+	for _, id := range ids {
+		h := hists[id]
+		lens = lens[:0]
+		for l := range h.hist {
+			lens = append(lens, l)
+		}
+		sort.Sort(lens)
+		for _, l := range lens {
+			fmt.Fprintf(outfh, "%s\t%s\t%s\t%d\t%d\n", h.file, h.format, h.t, l, h.hist[l])
+		}
+	}
+	return nil
+}
+
+// readStatLines reads all lines of a file.
+func readStatLines(file string) ([]string, error) {
+	fh, err := xopen.Ropen(file)
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+
+	lines := make([]string, 0, 16)
+	scanner := bufio.NewScanner(fh)
+	for scanner.Scan() {
+		lines = append(lines, strings.TrimRight(scanner.Text(), "\r"))
+	}
+	return lines, scanner.Err()
+}
+
+// mergedStat holds merged statistics of records with the same format and type.
+type mergedStat struct {
+	format string
+	t      string
+
+	num    uint64
+	lenSum uint64
+	gapSum uint64
+	lenMin uint64
+	lenMax uint64
+
+	// sums weighted by sum_len
+	q20    float64
+	q30    float64
+	errSum float64
+	gc     float64
+
+	lensVar     statLenVar // merged from summaries
+	lensVarHist statLenVar // computed from length histograms
+
+	lens *statLengthCounts
+}
+
+// statLengthCounts is a histogram of sequence lengths, for merging length
+// histograms without adding lengths one by one. Quartiles and N50-like stats
+// are computed in the same way as util.LengthStats.
+type statLengthCounts struct {
+	counts map[uint64]uint64
+	n      uint64
+	sum    uint64
+
+	acc [][2]uint64 // lengths and accumulative counts, in ascending order of lengths
+}
+
+func newStatLengthCounts() *statLengthCounts {
+	return &statLengthCounts{counts: make(map[uint64]uint64, 256)}
+}
+
+// add adds c sequences of length l.
+func (h *statLengthCounts) add(l, c uint64) {
+	h.counts[l] += c
+	h.n += c
+	h.sum += l * c
+	h.acc = nil
+}
+
+func (h *statLengthCounts) sort() {
+	if h.acc != nil {
+		return
+	}
+	h.acc = make([][2]uint64, 0, len(h.counts))
+	for l, c := range h.counts {
+		h.acc = append(h.acc, [2]uint64{l, c})
+	}
+	sort.Slice(h.acc, func(i, j int) bool { return h.acc[i][0] < h.acc[j][0] })
+	for i := 1; i < len(h.acc); i++ {
+		h.acc[i][1] += h.acc[i-1][1]
+	}
+}
+
+// count returns the number of sequences of the i-th shortest length.
+func (h *statLengthCounts) count(i int) uint64 {
+	if i == 0 {
+		return h.acc[0][1]
+	}
+	return h.acc[i][1] - h.acc[i-1][1]
+}
+
+// value returns the iL-th (0-based) length, or the mean of the iL-th
+// and iR-th lengths if even is true.
+func (h *statLengthCounts) value(even bool, iL uint64, iR uint64) float64 {
+	var flag bool
+	var prev uint64
+	for _, data := range h.acc {
+		if flag {
+			return float64(data[0]+prev) / 2
+		}
+		if data[1] >= iL+1 {
+			if !even || data[1] >= iR+1 {
+				return float64(data[0])
+			}
+			flag = true
+			prev = data[0]
+		}
+	}
+	return 0
+}
+
+// quartile returns Q1, Q2 or Q3 for q of 1, 2 or 3.
+func (h *statLengthCounts) quartile(q int) float64 {
+	h.sort()
+	if len(h.acc) == 0 {
+		return 0
+	}
+	if len(h.acc) == 1 {
+		return float64(h.acc[0][0])
+	}
+
+	if q == 2 {
+		if h.n&1 == 0 {
+			return h.value(true, h.n/2-1, h.n/2)
+		}
+		return h.value(false, h.n/2, 0)
+	}
+
+	n, offset := h.n/2, h.n/2
+	if h.n&1 == 1 {
+		n = (h.n + 1) / 2
+	}
+	if q == 1 {
+		offset = 0
+	}
+	if n%2 == 0 {
+		return h.value(true, n/2-1+offset, n/2+offset)
+	}
+	return h.value(false, n/2+offset, 0)
+}
+
+// nx returns the N50-like stat for x in [0, 100], and the number of
+// distinct lengths no shorter than it, which is N50_num for x of 50.
+func (h *statLengthCounts) nx(x float64) (uint64, int) {
+	h.sort()
+	if len(h.acc) == 0 {
+		return 0, 0
+	}
+	if len(h.acc) == 1 {
+		return h.acc[0][0], 1
+	}
+	var sumLen float64
+	boundary := float64(h.sum) * x / 100
+	for i := len(h.acc) - 1; i >= 0; i-- {
+		sumLen += float64(h.acc[i][0] * h.count(i))
+		if sumLen >= boundary {
+			return h.acc[i][0], len(h.acc) - i
+		}
+	}
+	return 0, 0
+}
+
+// mergeStats merges tabular outputs of "seqkit stats".
+// Columns are looked up by names in the header line.
+func mergeStats(outfh *xopen.Writer, files []string, lengthsFiles []string, tabular bool, style *stable.TableStyle, quiet bool) {
+	var header []string
+	var col map[string]int // column indexes
+	var nxs map[string]float64
+
+	merged := make(map[[2]string]*mergedStat)
+	keys := make([][2]string, 0, 4)
+
+	parseUint := func(file string, s string) uint64 {
+		v, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			checkError(fmt.Errorf("%s: invalid integer: %s", file, s))
+		}
+		return v
+	}
+	parseFloat := func(file string, s string) float64 {
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			checkError(fmt.Errorf("%s: invalid number: %s", file, s))
+		}
+		return v
+	}
+	has := func(name string) bool {
+		_, ok := col[name]
+		return ok
+	}
+
+	for _, file := range files {
+		lines, err := readStatLines(file)
+		checkError(err)
+
+		if len(lines) == 0 {
+			continue
+		}
+		h := strings.Split(lines[0], "\t")
+		if header == nil {
+			header = h
+			col = make(map[string]int, len(h))
+			nxs = make(map[string]float64)
+			for i, name := range h {
+				if _, ok := col[name]; !ok {
+					col[name] = i
+				}
+				if statColumnIndex(name) >= 0 {
+					continue
+				}
+				if reStatNXColumn.MatchString(name) {
+					nxs[name] = parseFloat(file, name[1:])
+					continue
+				}
+				checkError(fmt.Errorf("%s: unknown column: %s", file, name))
+			}
+			for _, name := range []string{"format", "type", "num_seqs", "sum_len"} {
+				if !has(name) {
+					checkError(fmt.Errorf("%s: not a tabular output (-T) of seqkit stats, column %s is needed", file, name))
+				}
+			}
+		} else if strings.Join(h, "\t") != strings.Join(header, "\t") {
+			checkError(fmt.Errorf("%s: columns are different from those of previous files", file))
+		}
+
+		var i int
+		var ok bool
+		var m *mergedStat
+		for _, line := range lines[1:] {
+			if line == "" {
+				continue
+			}
+			items := strings.Split(line, "\t")
+			if len(items) != len(header) {
+				checkError(fmt.Errorf("%s: unmatched number of columns: %s", file, line))
+			}
+			key := [2]string{items[col["format"]], items[col["type"]]}
+			m, ok = merged[key]
+			if !ok {
+				m = &mergedStat{format: key[0], t: key[1], lenMin: math.MaxUint64}
+				merged[key] = m
+				keys = append(keys, key)
+			}
+
+			num := parseUint(file, items[col["num_seqs"]])
+			lenSum := parseUint(file, items[col["sum_len"]])
+			m.num += num
+			m.lenSum += lenSum
+			if num > 0 {
+				if i, ok = col["min_len"]; ok {
+					if v := parseUint(file, items[i]); v < m.lenMin {
+						m.lenMin = v
+					}
+				}
+				if i, ok = col["max_len"]; ok {
+					if v := parseUint(file, items[i]); v > m.lenMax {
+						m.lenMax = v
+					}
+				}
+			}
+			w := float64(lenSum)
+			if i, ok = col["sum_gap"]; ok {
+				m.gapSum += parseUint(file, items[i])
+			}
+			if i, ok = col["Q20(%)"]; ok {
+				m.q20 += parseFloat(file, items[i]) * w
+			}
+			if i, ok = col["Q30(%)"]; ok {
+				m.q30 += parseFloat(file, items[i]) * w
+			}
+			if i, ok = col["AvgQual"]; ok {
+				if q := parseFloat(file, items[i]); q > 0 {
+					m.errSum += math.Pow(10, -q/10) * w
+				}
+			}
+			if i, ok = col["GC(%)"]; ok {
+				m.gc += parseFloat(file, items[i]) * w
+			}
+			if i, ok = col["stddev_len"]; ok && num > 0 {
+				sd := parseFloat(file, items[i])
+				m.lensVar.merge(float64(num), float64(lenSum)/float64(num), sd*sd*float64(num-1))
+			}
+		}
+	}
+
+	// length histograms
+	hasLens := len(lengthsFiles) > 0
+	if hasLens {
+		for _, m := range merged {
+			m.lens = newStatLengthCounts()
+		}
+		for _, file := range lengthsFiles {
+			lines, err := readStatLines(file)
+			checkError(err)
+
+			for i, line := range lines {
+				if i == 0 || line == "" {
+					continue
+				}
+				items := strings.Split(line, "\t")
+				if len(items) != 5 {
+					checkError(fmt.Errorf("%s: invalid length histogram: %s", file, line))
+				}
+				m, ok := merged[[2]string{items[1], items[2]}]
+				if !ok {
+					checkError(fmt.Errorf("%s: format and type not found in stats files: %s, %s", file, items[1], items[2]))
+				}
+				l := parseUint(file, items[3])
+				c := parseUint(file, items[4])
+				m.lensVarHist.merge(float64(c), float64(l), 0)
+				m.lens.add(l, c)
+			}
+		}
+		for _, m := range merged {
+			if m.lens.n != m.num {
+				checkError(fmt.Errorf("numbers of sequences in length histograms (%d) and stats files (%d) do not match for %s %s",
+					m.lens.n, m.num, m.format, m.t))
+			}
+		}
+	} else if !quiet && (has("Q1") || has("Q2") || has("Q3") || has("N50") || has("N50_num") || len(nxs) > 0) {
+		log.Warningf("quartiles and N50-like stats can not be merged from summaries, please give length histograms saved by --accumulate via --lengths-file")
+	}
+
+	// value returns the merged value of a column.
+	value := func(m *mergedStat, name string) string {
+		w := math.Max(float64(m.lenSum), 1)
+		switch name {
+		case "file":
+			return "merged"
+		case "format":
+			return m.format
+		case "type":
+			return m.t
+		case "num_seqs":
+			return fmt.Sprintf("%d", m.num)
+		case "sum_len":
+			return fmt.Sprintf("%d", m.lenSum)
+		case "min_len":
+			if m.num == 0 {
+				return "0"
+			}
+			return fmt.Sprintf("%d", m.lenMin)
+		case "avg_len":
+			return fmt.Sprintf("%.1f", mathutil.Round(float64(m.lenSum)/math.Max(float64(m.num), 1), 1))
+		case "max_len":
+			return fmt.Sprintf("%d", m.lenMax)
+		case "sum_gap":
+			return fmt.Sprintf("%d", m.gapSum)
+		case "Q20(%)":
+			return fmt.Sprintf("%.2f", mathutil.Round(m.q20/w, 2))
+		case "Q30(%)":
+			return fmt.Sprintf("%.2f", mathutil.Round(m.q30/w, 2))
+		case "AvgQual":
+			var avgQual float64
+			if m.errSum > 0 {
+				avgQual = -10 * math.Log10(m.errSum/w)
+			}
+			return fmt.Sprintf("%.2f", mathutil.Round(avgQual, 2))
+		case "GC(%)":
+			return fmt.Sprintf("%.2f", mathutil.Round(m.gc/w, 2))
+		case "stddev_len", "cv_len":
+			v := &m.lensVar
+			if hasLens {
+				v = &m.lensVarHist
+			}
+			if name == "stddev_len" {
+				return fmt.Sprintf("%.2f", mathutil.Round(v.std(), 2))
+			}
+			return fmt.Sprintf("%.4f", mathutil.Round(v.cv(), 4))
+		}
+
+		// stats from length histograms
+		if !hasLens {
+			return "NA"
+		}
+		switch name {
+		case "Q1":
+			return fmt.Sprintf("%.1f", m.lens.quartile(1))
+		case "Q2":
+			return fmt.Sprintf("%.1f", m.lens.quartile(2))
+		case "Q3":
+			return fmt.Sprintf("%.1f", m.lens.quartile(3))
+		case "N50_num":
+			_, n := m.lens.nx(50)
+			return fmt.Sprintf("%d", n)
+		case "N50":
+			n50, _ := m.lens.nx(50)
+			return fmt.Sprintf("%d", n50)
+		}
+		nx, _ := m.lens.nx(nxs[name])
+		return fmt.Sprintf("%d", nx)
+	}
+
+	// output
+	rows := make([][]string, 0, len(keys))
+	for _, key := range keys {
+		m := merged[key]
+		row := make([]string, len(header))
+		for i, name := range header {
+			row[i] = value(m, name)
+		}
+		rows = append(rows, row)
+	}
+
+	if tabular {
+		outfh.WriteString(strings.Join(header, "\t") + "\n")
+		for _, row := range rows {
+			outfh.WriteString(strings.Join(row, "\t") + "\n")
+		}
+		return
+	}
+
+	columns := make([]stable.Column, len(header))
+	for i, name := range header {
+		columns[i] = stable.Column{Header: name}
+		if !statIsTextColumn(name) {
+			columns[i].Align = stable.AlignRight
+			columns[i].HumanizeNumbers = true
+		}
+	}
+	tbl := stable.New()
+	tbl.HeaderWithFormat(columns)
+	for _, row := range rows {
+		r := make([]interface{}, len(row))
+		for i, v := range row {
+			if statIsTextColumn(header[i]) {
+				r[i] = v
+			} else if u, err := strconv.ParseUint(v, 10, 64); err == nil {
+				r[i] = u
+			} else if f, err := strconv.ParseFloat(v, 64); err == nil {
+				r[i] = f
+			} else {
+				r[i] = v
+			}
+		}
+		tbl.AddRow(r)
+	}
+	outfh.Write(tbl.Render(style))
+}
+
+// statIsTextColumn checks if a column is not numeric.
+func statIsTextColumn(name string) bool {
+	return name == "file" || name == "format" || name == "type"
+}
+
+// statQualPerPosition accumulates histograms of quality scores for each read position
+// of a FASTQ file and writes the profile to outfh.
+func statQualPerPosition(outfh *xopen.Writer, file string, alphabet *seq.Alphabet, idRegexp string,
+	encodeOffset int, basename bool, replaceStdinLabel bool, stdinLabel string, bench *benchmarkStats) error {
+
+	fastxReader, err := fastx.NewReader(alphabet, file, idRegexp)
+	if err != nil {
+		return err
+	}
+	defer fastxReader.Close()
+
+	// histograms of quality characters ('!' to '~') for each position
+	hists := make([][94]uint64, 0, 1024)
+
+	var record *fastx.Record
+	var i int
+	var q byte
+	for {
+		record, err = fastxReader.Read()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return err
+		}
+		bench.addRecord()
+
+		if !fastxReader.IsFastq {
+			return fmt.Errorf("flag -P (--per-position) only supports FASTQ format")
+		}
+
+		for len(hists) < len(record.Seq.Qual) {
+			hists = append(hists, [94]uint64{})
+		}
+		for i, q = range record.Seq.Qual {
+			if q < 33 || q > 126 {
+				return fmt.Errorf("invalid quality character '%c' in record: %s", q, record.ID)
+			}
+			hists[i][q-33]++
+		}
+	}
+
+	if basename {
+		file = filepath.Base(file)
+	}
+	if replaceStdinLabel && isStdin(file) {
+		file = stdinLabel
+	}
+
+	var n, sum uint64
+	var j int
+	var c uint64
+	for i = range hists {
+		n, sum = 0, 0
+		for j, c = range hists[i] {
+			n += c
+			sum += c * uint64(j)
+		}
+		fmt.Fprintf(outfh, "%s\t%d\t%d\t%.2f\t%d\t%d\t%d\n", file, i+1, n,
+			float64(sum)/float64(n)+float64(33-encodeOffset),
+			histQuantile(&hists[i], n, 0.25)+33-encodeOffset,
+			histQuantile(&hists[i], n, 0.5)+33-encodeOffset,
+			histQuantile(&hists[i], n, 0.75)+33-encodeOffset,
+		)
+	}
+	return nil
+}
+
+// statPerSeqChunkSize is the number of records processed in a goroutine for --per-seq.
+const statPerSeqChunkSize = 256
+
+// statPerSeq writes statistics of each record in TSV format, in the order of input.
+// Records are processed in chunks by multiple goroutines.
+func statPerSeq(outfh *xopen.Writer, file string, label string, alphabet *seq.Alphabet, idRegexp string,
+	encodeOffset int, threads int, bench *benchmarkStats) error {
+
+	fastxReader, err := fastx.NewReader(alphabet, file, idRegexp)
+	if err != nil {
+		return err
+	}
+	defer fastxReader.Close()
+
+	type statPerSeqChunk struct {
+		id      uint64
+		records []*fastx.Record
+		text    []byte
+	}
 
+	ch := make(chan *statPerSeqChunk, threads)
+	done := make(chan int)
+
+	// collect results in order
+	go func() {
+		var id uint64 = 1
+		buf := make(map[uint64]*statPerSeqChunk, threads)
+		var c *statPerSeqChunk
+		var ok bool
+		for chunk := range ch {
+			buf[chunk.id] = chunk
+			for {
+				if c, ok = buf[id]; !ok {
+					break
+				}
+				outfh.Write(c.text)
+				delete(buf, id)
+				id++
+			}
+		}
+		done <- 1
+	}()
+
+	var wg sync.WaitGroup
+	tokens := make(chan int, threads)
+	compute := func(chunk *statPerSeqChunk) {
+		defer func() {
+			wg.Done()
+			<-tokens
+		}()
+
+		var buf bytes.Buffer
+		var g, c float64
+		for _, record := range chunk.records {
+			g = record.Seq.BaseContent("G")
+			c = record.Seq.BaseContent("C")
+			fmt.Fprintf(&buf, "%s\t%s\t%d\t%.2f\t%d\t", label, record.ID, len(record.Seq.Seq),
+				(g+c)*100, record.Seq.BaseCount("N"))
+			if len(record.Seq.Qual) > 0 {
+				fmt.Fprintf(&buf, "%.2f\n", record.Seq.AvgQual(encodeOffset))
+			} else {
+				buf.WriteString("NA\n")
+			}
+		}
+		chunk.text = buf.Bytes()
+		chunk.records = nil
+		ch <- chunk
+	}
+
+	var record *fastx.Record
+	var id uint64
+	records := make([]*fastx.Record, 0, statPerSeqChunkSize)
+	for {
+		record, err = fastxReader.Read()
+		if err != nil {
+			if err == io.EOF {
+				err = nil
+				break
+			}
+			break
+		}
+		bench.addRecord()
+
+		records = append(records, record.Clone())
+		if len(records) == statPerSeqChunkSize {
+			id++
+			tokens <- 1
+			wg.Add(1)
+			go compute(&statPerSeqChunk{id: id, records: records})
+			records = make([]*fastx.Record, 0, statPerSeqChunkSize)
+		}
+	}
+	if err == nil && len(records) > 0 {
+		id++
+		tokens <- 1
+		wg.Add(1)
+		go compute(&statPerSeqChunk{id: id, records: records})
+	}
+
+	wg.Wait()
+	close(ch)
+	<-done
+
+	return err
+}
+
+// histQuantile returns the bin index of the p-quantile (nearest-rank method)
+// of a histogram with n observations.
+func histQuantile(hist *[94]uint64, n uint64, p float64) int {
+	rank := uint64(math.Ceil(p * float64(n)))
+	if rank == 0 {
+		rank = 1
+	}
+	var acc uint64
+	for i, c := range hist {
+		acc += c
+		if acc >= rank {
+			return i
+		}
+	}
+	return len(hist) - 1
 }
 
 func median(sorted []int64) int64 {
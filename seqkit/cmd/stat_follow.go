@@ -0,0 +1,326 @@
+// Copyright © 2016-2024 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"regexp"
+	"syscall"
+	"time"
+
+	"github.com/shenwei356/bio/seq"
+	"github.com/shenwei356/stable"
+	"github.com/shenwei356/xopen"
+)
+
+// statFollowPollInterval is the waiting time before checking new data at the end of file.
+const statFollowPollInterval = 500 * time.Millisecond
+
+// statFollowOptions contains options of "stats --follow".
+type statFollowOptions struct {
+	alphabet   *seq.Alphabet
+	gapLetters []byte
+	encOffset  int
+	all        bool
+	gcOnly     bool
+	tabular    bool
+	nx         []float64
+	nxLabels   []string
+	label      string
+	interval   time.Duration
+	style      *stable.TableStyle
+}
+
+// statFollower accumulates statistics of complete records appended to a growing file.
+type statFollower struct {
+	opt *statFollowOptions
+
+	format string
+	t      string
+	acc    *statAccumulator
+
+	buf     []byte // data not parsed yet
+	scanned int    // position in buf already searched for the next FASTA header
+	line    int    // line number in the file of the first line in buf
+}
+
+func newStatFollower(opt *statFollowOptions) *statFollower {
+	f := &statFollower{opt: opt, line: 1}
+	f.reset()
+	return f
+}
+
+func (f *statFollower) reset() {
+	f.format = ""
+	f.t = ""
+	f.acc = f.opt.newAccumulator()
+	f.buf = f.buf[:0]
+	f.scanned = 0
+	f.line = 1
+}
+
+// Feed appends data and parses records which are complete.
+func (f *statFollower) Feed(data []byte) error {
+	f.buf = append(f.buf, data...)
+	return f.parse(false)
+}
+
+// Finish parses the last record, which is only complete for FASTA
+// when the last line ends with a newline symbol.
+func (f *statFollower) Finish() error {
+	if err := f.parse(true); err != nil {
+		return err
+	}
+	if len(bytes.TrimSpace(f.buf)) > 0 {
+		log.Warningf("%s: the last incomplete record (line %d) is ignored", f.opt.label, f.line)
+	}
+	return nil
+}
+
+func (f *statFollower) parse(final bool) error {
+	var i, n int
+	for {
+		// skip blank lines
+		for len(f.buf) > 0 {
+			i = bytes.IndexByte(f.buf, '\n')
+			if i < 0 || len(bytes.TrimSpace(f.buf[:i])) > 0 {
+				break
+			}
+			f.consume(i + 1)
+		}
+		if len(f.buf) == 0 {
+			return nil
+		}
+
+		if f.format == "" {
+			switch f.buf[0] {
+			case '>':
+				f.format = "FASTA"
+			case '@':
+				f.format = "FASTQ"
+			default:
+				return fmt.Errorf("invalid FASTA/Q format at line %d", f.line)
+			}
+		}
+
+		if f.format == "FASTA" {
+			n = f.nextFastaRecord(final)
+		} else {
+			var err error
+			n, err = f.nextFastqRecord()
+			if err != nil {
+				return err
+			}
+		}
+		if n == 0 {
+			return nil
+		}
+		f.consume(n)
+	}
+}
+
+func (f *statFollower) consume(n int) {
+	f.line += bytes.Count(f.buf[:n], []byte{'\n'})
+	f.buf = append(f.buf[:0], f.buf[n:]...)
+	f.scanned = 0
+}
+
+// nextFastaRecord returns the length of the first record in buf,
+// or 0 if the record is not complete yet.
+func (f *statFollower) nextFastaRecord(final bool) int {
+	var end int
+	i := bytes.Index(f.buf[f.scanned:], []byte("\n>"))
+	if i >= 0 {
+		end = f.scanned + i + 1
+	} else if final && f.buf[len(f.buf)-1] == '\n' {
+		end = len(f.buf)
+	} else {
+		if len(f.buf) > 1 {
+			f.scanned = len(f.buf) - 1
+		}
+		return 0
+	}
+
+	record := f.buf[:end]
+	j := bytes.IndexByte(record, '\n')
+	s := bytes.Replace(record[j+1:], []byte{'\n'}, nil, -1)
+	s = bytes.Replace(s, []byte{'\r'}, nil, -1)
+	f.add(s, nil)
+	return end
+}
+
+// nextFastqRecord returns the length of the first record in buf,
+// or 0 if the four lines of the record are not complete yet.
+func (f *statFollower) nextFastqRecord() (int, error) {
+	var lines [4][]byte
+	var i, end int
+	for k := 0; k < 4; k++ {
+		i = bytes.IndexByte(f.buf[end:], '\n')
+		if i < 0 {
+			return 0, nil
+		}
+		lines[k] = bytes.TrimRight(f.buf[end:end+i], "\r")
+		end += i + 1
+	}
+	if len(lines[0]) == 0 || lines[0][0] != '@' {
+		return 0, fmt.Errorf("invalid FASTQ format at line %d: the header line should start with '@'", f.line)
+	}
+	if len(lines[2]) == 0 || lines[2][0] != '+' {
+		return 0, fmt.Errorf("invalid FASTQ format at line %d: the third line should start with '+'", f.line+2)
+	}
+	if len(lines[1]) != len(lines[3]) {
+		return 0, fmt.Errorf("mismatched lengths of sequence and quality at line %d", f.line)
+	}
+	f.add(lines[1], lines[3])
+	return end, nil
+}
+
+func (f *statFollower) add(s, q []byte) {
+	if f.t == "" {
+		f.t = statFollowSeqType(f.opt.alphabet, s)
+	}
+
+	f.acc.add(s, q)
+}
+
+func statFollowSeqType(alphabet *seq.Alphabet, s []byte) string {
+	if alphabet == nil {
+		if len(s) > seq.AlphabetGuessSeqLengthThreshold && seq.AlphabetGuessSeqLengthThreshold > 0 {
+			s = s[:seq.AlphabetGuessSeqLengthThreshold]
+		}
+		alphabet = seq.GuessAlphabetLessConservatively(s)
+	}
+	return statSeqType(alphabet, "FASTA")
+}
+
+// Info returns the current statistics.
+func (f *statFollower) Info() statInfo {
+	return f.acc.info(f.opt.label, f.format, f.t)
+}
+
+// newAccumulator returns a statAccumulator with the options.
+func (opt *statFollowOptions) newAccumulator() *statAccumulator {
+	return newStatAccumulator(opt.gapLetters, opt.encOffset, opt.all, opt.gcOnly, opt.nx)
+}
+
+// writeStatFollowInfo writes statistics in the same format of "seqkit stats".
+func writeStatFollowInfo(w io.Writer, info statInfo, opt *statFollowOptions) {
+	statWriteColumns(w, []statInfo{info}, statDefaultColumns(opt.all, opt.gcOnly, opt.nxLabels),
+		opt.nxLabels, opt.tabular, opt.style)
+}
+
+var reStatFollowCompressed = regexp.MustCompile(`(?i)\.(gz|xz|zst|bz2)$`)
+
+// statFollow keeps reading records appended to a file, like "tail -f",
+// reprints statistics to stderr periodically, and writes the final
+// statistics to outfh after receiving SIGINT or SIGTERM.
+func statFollow(outfh *xopen.Writer, file string, opt *statFollowOptions) error {
+	if isStdin(file) {
+		return fmt.Errorf("flag --follow does not support stdin")
+	}
+	if reStatFollowCompressed.MatchString(file) {
+		return fmt.Errorf("flag --follow does not support compressed file: %s", file)
+	}
+
+	fh, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigChan)
+
+	ticker := time.NewTicker(opt.interval)
+	defer ticker.Stop()
+
+	f := newStatFollower(opt)
+	chunk := make([]byte, 1<<20)
+	var offset int64
+	var n int
+	var nLast uint64
+	var printed bool
+	var info os.FileInfo
+
+	for {
+		// read all available data
+		for {
+			n, err = fh.Read(chunk)
+			if n > 0 {
+				offset += int64(n)
+				if err := f.Feed(chunk[:n]); err != nil {
+					return fmt.Errorf("%s: %s", file, err)
+				}
+			}
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+
+			select {
+			case <-sigChan:
+				return statFollowFinish(outfh, f)
+			default:
+			}
+		}
+
+		// check truncation
+		info, err = fh.Stat()
+		if err != nil {
+			return err
+		}
+		if info.Size() < offset {
+			log.Warningf("%s: file truncated, statistics are reset", file)
+			if _, err = fh.Seek(0, io.SeekStart); err != nil {
+				return err
+			}
+			offset = 0
+			f.reset()
+			nLast, printed = 0, false
+		}
+
+		select {
+		case <-sigChan:
+			return statFollowFinish(outfh, f)
+		case <-ticker.C:
+			if !printed || f.acc.lensStats.Count() != nLast {
+				writeStatFollowInfo(os.Stderr, f.Info(), opt)
+				nLast, printed = f.acc.lensStats.Count(), true
+			}
+		case <-time.After(statFollowPollInterval):
+		}
+	}
+}
+
+func statFollowFinish(outfh *xopen.Writer, f *statFollower) error {
+	if err := f.Finish(); err != nil {
+		return fmt.Errorf("%s: %s", f.opt.label, err)
+	}
+	writeStatFollowInfo(outfh, f.Info(), f.opt)
+	return nil
+}
@@ -0,0 +1,143 @@
+// Copyright © 2016-2024 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/shenwei356/bio/seqio/fastx"
+	"github.com/shenwei356/stable"
+)
+
+// statGroupAll is the group label of the row of all records of a file.
+const statGroupAll = "all"
+
+// statGroups computes statistics of records of each group, defined by the first
+// capture group of re matched to IDs, followed by the statistics of all records.
+// Groups are in the order of their first appearances, and records not matched are
+// assigned to the group "NA".
+//
+// Sequence lengths are kept in histograms, so the memory is proportional to
+// the number of distinct lengths in each group, rather than the number of records.
+func statGroups(file string, re *regexp.Regexp, idRegexp string, opt *statFollowOptions, bench *benchmarkStats) ([]statInfo, error) {
+	fastxReader, err := fastx.NewReader(opt.alphabet, file, idRegexp)
+	if err != nil {
+		return nil, err
+	}
+
+	groups := make(map[string]*statAccumulator, 64)
+	order := make([]string, 0, 64)
+	all := opt.newAccumulator()
+
+	var record *fastx.Record
+	var qual []byte
+	var m [][]byte
+	var group string
+	var acc *statAccumulator
+	var ok bool
+	var format string
+	for {
+		record, err = fastxReader.Read()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+		bench.addRecord()
+
+		if format == "" {
+			if len(record.Seq.Qual) > 0 {
+				format = "FASTQ"
+			} else {
+				format = "FASTA"
+			}
+		}
+
+		m = re.FindSubmatch(record.ID)
+		if len(m) > 1 && len(m[1]) > 0 {
+			group = string(m[1])
+		} else {
+			group = "NA"
+		}
+		if acc, ok = groups[group]; !ok {
+			acc = opt.newAccumulator()
+			groups[group] = acc
+			order = append(order, group)
+		}
+
+		qual = nil
+		if fastxReader.IsFastq {
+			qual = record.Seq.Qual
+		}
+		acc.add(record.Seq.Seq, qual)
+		all.add(record.Seq.Seq, qual)
+	}
+	fastxReader.Close()
+
+	t := statSeqType(fastxReader.Alphabet(), format)
+
+	infos := make([]statInfo, 0, len(order)+1)
+	var info statInfo
+	for _, group = range order {
+		info = groups[group].info("", format, t)
+		info.group = group
+		infos = append(infos, info)
+	}
+	info = all.info("", format, t)
+	info.group = statGroupAll
+	infos = append(infos, info)
+
+	return infos, nil
+}
+
+// statWriteColumns writes statistics of the given columns, in tabular format
+// or a table.
+func statWriteColumns(w io.Writer, infos []statInfo, cols []string, _NX []string, tabular bool, style *stable.TableStyle) {
+	if tabular {
+		io.WriteString(w, strings.Join(cols, "\t")+"\n")
+		for i := range infos {
+			statWriteSelectedColumns(w, &infos[i], cols, _NX)
+		}
+		return
+	}
+
+	columns := make([]stable.Column, len(cols))
+	for i, c := range cols {
+		if k := statColumnIndex(c); (k >= 0 && k < 3) || c == "group" { // file, format, type
+			columns[i] = stable.Column{Header: c}
+		} else {
+			columns[i] = stable.Column{Header: c, Align: stable.AlignRight, HumanizeNumbers: true}
+		}
+	}
+	tbl := stable.New()
+	tbl.HeaderWithFormat(columns)
+	for i := range infos {
+		row := make([]interface{}, len(cols))
+		for j, c := range cols {
+			row[j] = statColumnValue(&infos[i], c, _NX, false)
+		}
+		tbl.AddRow(row)
+	}
+	w.Write(tbl.Render(style))
+}
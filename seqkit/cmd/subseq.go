@@ -21,6 +21,8 @@
 package cmd
 
 import (
+	"bufio"
+	"bytes"
 	"fmt"
 	"io"
 	"os"
@@ -54,9 +56,73 @@ Attention:
      "seqtk subseq seqs.fasta id.txt" equals to
      "seqkit grep -f id.txt seqs.fasta"
 
+Translation (--translate):
+  1. Subsequences are translated to proteins with the genetic code of --transl-table
+     from the frame of --frame (1, 2 or 3), only FASTA format is supported.
+  2. For features on the negative strand in GTF/BED, subsequences are reverse
+     complemented before the translation, and up/down stream sequences are included.
+  3. Trailing one or two bases of incomplete codons at the end are ignored.
+     Subsequences shorter than a codon are skipped. Codons with unknown or
+     degenerate bases are translated to 'X'.
+  4. For GTF, the frame (column 8) of features is respected, i.e., 0-2 bases
+     at the start of a feature are skipped to reach the first complete codon,
+     included upstream flanks are translated in the same frame, and --frame
+     is applied after that. It's ignored for -f/--only-flank.
+
+Extracting around motifs (--around-motif):
+  1. All occurrences of the motif, including overlapping ones, are searched
+     on both strands, as "seqkit locate -d -i" does. Degenerate bases are
+     supported, and the case is ignored. For motifs equal to their reverse
+     complement, only the positive strand is searched.
+  2. For each occurrence, the motif and --flank N bases on both sides are
+     outputted as a record, clamped at sequence ends. Matches on the negative
+     strand are reverse complemented. The header contains the location of the
+     window, the strand, and the location of the match, e.g.,
+       >chr1_91-128:+_flank:10 GAATTC:101-118
+  3. Records are read in a streaming way, with -r/--region, --gtf, --bed
+     and -u/-d/-f not allowed.
+
+Extracting by protein coordinates (--protein-region):
+  1. Input sequences are treated as CDSs, and the 1-based amino acid region
+     "aa_start:aa_end" is mapped to nucleotide coordinates in the frame of
+     --frame (1, 2, 3, -1, -2, -3):
+       nt_start = (aa_start - 1) * 3 + frame
+       nt_end   = aa_end * 3 + frame - 1
+     For a negative frame, the CDS is on the negative strand, i.e., the
+     sequence is reverse complemented before the mapping and the extracted
+     region is in the coding strand.
+  2. The header contains the location on the positive strand of the input
+     sequence, the strand, and the amino acid region, e.g.,
+       >cds1_31-60:-_aa:11-20
+  3. Sequences too short to cover the region are skipped with a warning.
+     A warning is also given for sequences whose length from the frame is not
+     a multiple of 3.
+  4. Records are read in a streaming way, with -r/--region, --gtf, --bed,
+     --chr, -u/-d/-f and --around-motif not allowed. With --translate, the
+     extracted regions are translated from the frame 1.
+
+Converting GTF features to BED (--emit-bed):
+  1. With --gtf, flag --emit-bed outputs the features to be extracted in BED6
+     format instead of subsequences, for checking coordinates before the
+     extraction. Sequence files are not read.
+  2. Features are filtered by --chr and --feature, and regions include the
+     up/down stream flanks of -u/-d/-f, which are strand-aware as in the
+     extraction, i.e., the up stream of a feature on the negative strand is
+     on its right side. Start positions are converted to 0-based, and clamped
+     to 0, while end positions are not clamped as sequence lengths are unknown.
+     Empty regions, e.g., up stream flanks of features at sequence starts,
+     are skipped.
+  3. The name column is the value of --gtf-tag (default gene_id), followed by
+     "|" and the transcript_id if the feature has one, e.g., "gene1|tx1".
+     Missing values are outputted as ".". The score column is 0, and the
+     strand column is "+", "-" or "." for unknown strands.
+  4. Features are outputted in the order of the GTF file.
+
 Recommendation:
   1. Use plain FASTA file, so seqkit could utilize FASTA index.
   2. The flag -U/--update-faidx is recommended to ensure the .fai file matches the FASTA file.
+  3. For FASTQ files or BGZF-compressed files, create the record-offset index file with
+     "seqkit index build", which is used with --chr, --gtf or --bed.
 
 The definition of region is 1-based and with some custom design.
 
@@ -77,7 +143,9 @@ Examples:
 		Threads = config.Threads
 		runtime.GOMAXPROCS(config.Threads)
 
+		bench := getBenchmarkStats(cmd)
 		files := getFileListFromArgsAndFile(cmd, args, true, "infile-list", true)
+		bench.addInputs(files...)
 		chrs := getFlagStringSlice(cmd, "chr")
 		chrs2 := make([]string, len(chrs))
 		for i, chr := range chrs {
@@ -89,11 +157,18 @@ Examples:
 			chrsMap[chr] = struct{}{}
 		}
 		region := getFlagString(cmd, "region")
+		aroundMotif := getFlagString(cmd, "around-motif")
+		proteinRegion := getFlagString(cmd, "protein-region")
+		motifFlank := getFlagNonNegativeInt(cmd, "flank")
 		appendRegionCoord := getFlagBool(cmd, "region-coord")
 
 		gtfFile := getFlagString(cmd, "gtf")
 		bedFile := getFlagString(cmd, "bed")
 		gtfTag := getFlagString(cmd, "gtf-tag")
+		emitBed := getFlagBool(cmd, "emit-bed")
+		if emitBed && gtfFile == "" {
+			checkError(fmt.Errorf("flag --emit-bed should be used with --gtf"))
+		}
 		choosedFeatures := getFlagStringSlice(cmd, "feature")
 		choosedFeatures2 := make([]string, len(choosedFeatures))
 		for i, f := range choosedFeatures {
@@ -122,10 +197,145 @@ Examples:
 
 		updateFaidx := getFlagBool(cmd, "update-faidx")
 
-		outfh, err := xopen.Wopen(outFile)
+		var outfh *xopen.Writer
+		var err error
+
+		var translator *subseqTranslator
+		var frame int
+		if proteinRegion != "" {
+			if region != "" || gtfFile != "" || bedFile != "" || len(chrs) > 0 ||
+				upStream > 0 || downStream > 0 || onlyFlank || aroundMotif != "" {
+				checkError(fmt.Errorf("flag --protein-region is not compatible with -r/--region, --gtf, --bed, --chr, -u/--up-stream, -d/--down-stream, -f/--only-flank and --around-motif"))
+			}
+			frame = getFlagInt(cmd, "frame")
+			if frame == 0 || frame < -3 || frame > 3 {
+				checkError(fmt.Errorf("invalid frame: %d, available values for --protein-region: 1, 2, 3, -1, -2, -3", frame))
+			}
+		}
+
+		if getFlagBool(cmd, "translate") {
+			if proteinRegion != "" { // extracted regions start with complete codons
+				translator, err = newSubseqTranslator(getFlagPositiveInt(cmd, "transl-table"), 1)
+			} else {
+				translator, err = newSubseqTranslator(getFlagPositiveInt(cmd, "transl-table"), getFlagPositiveInt(cmd, "frame"))
+			}
+			checkError(err)
+		}
+
+		outfh, err = xopen.Wopen(outFile)
 		checkError(err)
 		defer outfh.Close()
 
+		if aroundMotif != "" {
+			if region != "" || gtfFile != "" || bedFile != "" || len(chrs) > 0 ||
+				upStream > 0 || downStream > 0 || onlyFlank {
+				checkError(fmt.Errorf("flag --around-motif is not compatible with -r/--region, --gtf, --bed, --chr, -u/--up-stream, -d/--down-stream and -f/--only-flank"))
+			}
+			motif, err := newSubseqMotif(aroundMotif)
+			checkError(err)
+			var record *fastx.Record
+			var n int
+			for _, file := range files {
+				fastxReader, err := fastx.NewReader(alphabet, file, idRegexp)
+				checkError(err)
+				for {
+					record, err = fastxReader.Read()
+					if err != nil {
+						if err == io.EOF {
+							break
+						}
+						checkError(err)
+						break
+					}
+					bench.addRecord()
+					if fastxReader.IsFastq {
+						if translator != nil {
+							checkError(fmt.Errorf("flag --translate only supports FASTA format"))
+						}
+						config.LineWidth = 0
+						fastx.ForcelyOutputFastq = true
+					}
+					n += subseqAroundMotif(outfh, record, config.LineWidth, motif, motifFlank, translator)
+				}
+				fastxReader.Close()
+				config.LineWidth = lineWidth
+			}
+			if !quiet {
+				log.Infof("%d motif occurrences found", n)
+			}
+			if translator != nil && !quiet {
+				translator.report()
+			}
+			return
+		} else if cmd.Flags().Lookup("flank").Changed {
+			checkError(fmt.Errorf("flag --flank only works with --around-motif"))
+		}
+
+		if proteinRegion != "" {
+			if !reProteinRegion.MatchString(proteinRegion) {
+				checkError(fmt.Errorf(`invalid protein region: %s, the format is "aa_start:aa_end", e.g., 10:20`, proteinRegion))
+			}
+			r := strings.Split(proteinRegion, ":")
+			aaStart, _ := strconv.Atoi(r[0])
+			aaEnd, _ := strconv.Atoi(r[1])
+			if aaStart == 0 || aaEnd < aaStart {
+				checkError(fmt.Errorf("invalid protein region: %s, aa_start should be > 0 and <= aa_end", proteinRegion))
+			}
+
+			var record *fastx.Record
+			var n, nShort, nPartial int
+			var ok, partial bool
+			for _, file := range files {
+				fastxReader, err := fastx.NewReader(alphabet, file, idRegexp)
+				checkError(err)
+				for {
+					record, err = fastxReader.Read()
+					if err != nil {
+						if err == io.EOF {
+							break
+						}
+						checkError(err)
+						break
+					}
+					bench.addRecord()
+					if fastxReader.IsFastq {
+						if translator != nil {
+							checkError(fmt.Errorf("flag --translate only supports FASTA format"))
+						}
+						config.LineWidth = 0
+						fastx.ForcelyOutputFastq = true
+					}
+					ok, partial = subseqByProteinRegion(outfh, record, config.LineWidth, aaStart, aaEnd, frame, translator)
+					if !ok {
+						nShort++
+						if !quiet {
+							log.Warningf("sequence too short to cover the protein region (%d bp): %s", len(record.Seq.Seq), record.ID)
+						}
+						continue
+					}
+					if partial {
+						nPartial++
+					}
+					n++
+				}
+				fastxReader.Close()
+				config.LineWidth = lineWidth
+			}
+			if !quiet {
+				if nPartial > 0 {
+					log.Warningf("%d sequences have lengths from the frame %d not being a multiple of 3", nPartial, frame)
+				}
+				if nShort > 0 {
+					log.Warningf("%d sequences are skipped for being too short", nShort)
+				}
+				log.Infof("%d subsequences extracted", n)
+			}
+			if translator != nil && !quiet {
+				translator.report()
+			}
+			return
+		}
+
 		idRe, err := regexp.Compile(idRegexp)
 		if err != nil {
 			checkError(fmt.Errorf("fail to compile regexp: %s", idRegexp))
@@ -158,14 +368,35 @@ Examples:
 			gtfFeaturesMap = make(map[string]type2gtfFeatures)
 
 			gtf.Threads = config.Threads // threads of gtf.ReadFeatures
+			tags := []string{gtfTag}
+			if emitBed && gtfTag != "transcript_id" {
+				tags = append(tags, "transcript_id")
+			}
 			var features []gtf.Feature
-			if len(chrs) > 0 || len(choosedFeatures) > 0 {
-				features, err = gtf.ReadFilteredFeatures(gtfFile, chrs, choosedFeatures, []string{gtfTag})
+			if emitBed {
+				// attributes are parsed locally, as gtf.ReadFilteredFeatures drops the last one
+				features, err = readGTFFeaturesForBed(gtfFile, chrs, choosedFeatures, tags)
+			} else if len(chrs) > 0 || len(choosedFeatures) > 0 {
+				features, err = gtf.ReadFilteredFeatures(gtfFile, chrs, choosedFeatures, tags)
 			} else {
-				features, err = gtf.ReadFilteredFeatures(gtfFile, []string{}, []string{}, []string{gtfTag})
+				features, err = gtf.ReadFilteredFeatures(gtfFile, []string{}, []string{}, tags)
 			}
 			checkError(err)
 
+			if emitBed {
+				if !quiet {
+					log.Infof("%d GTF features loaded", len(features))
+				}
+				outfh, err := xopen.Wopen(outFile)
+				checkError(err)
+				defer outfh.Close()
+
+				for _, feature := range features {
+					writeGTFFeatureAsBed(outfh, feature, onlyFlank, upStream, downStream, gtfTag)
+				}
+				return
+			}
+
 			var chr, feat string
 			for _, feature := range features {
 				chr = feature.SeqName
@@ -211,7 +442,7 @@ Examples:
 				log.Infof("%d BED features loaded", len(features))
 			}
 		} else {
-			checkError(fmt.Errorf("one of the options needed: -r/--region, --bed, --gtf"))
+			checkError(fmt.Errorf("one of the options needed: -r/--region, --bed, --gtf, --around-motif"))
 		}
 
 		for _, file := range files {
@@ -267,6 +498,11 @@ Examples:
 
 								s, e, _ := seq.SubLocation(r.Length, start, end)
 								subseq := subseqByFaix(faidx, chr2, r, start, end)
+								if translator != nil {
+									if subseq, ok = translator.translate(subseq); !ok {
+										continue
+									}
+								}
 								outfh.WriteString(fmt.Sprintf(">%s_%d-%d %s\n",
 									chr, s, e, chr2))
 								outfh.Write(byteutil.WrapByteSlice(subseq, config.LineWidth))
@@ -299,7 +535,7 @@ Examples:
 
 							subseqByGTFFile(outfh, record, config.LineWidth,
 								gtfFeaturesMap, choosedFeatures,
-								onlyFlank, upStream, downStream, gtfTag)
+								onlyFlank, upStream, downStream, gtfTag, translator)
 						}
 
 						continue
@@ -325,7 +561,7 @@ Examples:
 
 							subSeqByBEDFile(outfh, record, config.LineWidth,
 								bedFeatureMap,
-								onlyFlank, upStream, downStream)
+								onlyFlank, upStream, downStream, translator)
 						}
 
 						continue
@@ -336,6 +572,62 @@ Examples:
 
 			}
 
+			// selected sequences could be read directly with the index (seqkit index)
+			if region == "" || len(chrs) > 0 {
+				if idx := loadSeqIndex(file, quiet); idx != nil {
+					if idx.fastq {
+						if translator != nil {
+							checkError(fmt.Errorf("flag --translate only supports FASTA format"))
+						}
+						config.LineWidth = 0
+						fastx.ForcelyOutputFastq = true
+					}
+					idxReader, err := newSeqIndexReader(file, idx, alphabet, idRegexp)
+					checkError(err)
+
+					var id string
+					var ok bool
+					for i, name := range idx.names {
+						id = string(fastx.ParseHeadID(idRe, name))
+						if region != "" {
+							_, ok = chrsMap[id]
+						} else if gtfFile != "" {
+							_, ok = gtfFeaturesMap[id]
+						} else {
+							_, ok = bedFeatureMap[id]
+						}
+						if !ok {
+							continue
+						}
+
+						fastxReader, err := idxReader.seek(idx.offsets[i])
+						checkError(err)
+						record, err := fastxReader.Read()
+						if err != nil {
+							checkError(fmt.Errorf("%s: failed to read record via the index, please rebuild it: %s", file, err))
+						}
+						bench.addRecord()
+
+						if region != "" {
+							subseqByRegion(outfh, record, config.LineWidth, start, end, appendRegionCoord, translator)
+						} else if gtfFile != "" {
+							subseqByGTFFile(outfh, record, config.LineWidth,
+								gtfFeaturesMap, choosedFeatures,
+								onlyFlank, upStream, downStream, gtfTag, translator)
+						} else {
+							subSeqByBEDFile(outfh, record, config.LineWidth,
+								bedFeatureMap,
+								onlyFlank, upStream, downStream, translator)
+						}
+						fastxReader.Close()
+					}
+					checkError(idxReader.Close())
+
+					config.LineWidth = lineWidth
+					continue
+				}
+			}
+
 			var record *fastx.Record
 			var fastxReader *fastx.Reader
 			// Parse all sequences
@@ -355,17 +647,21 @@ Examples:
 					checkError(err)
 					break
 				}
+				bench.addRecord()
 				if fastxReader.IsFastq {
+					if translator != nil {
+						checkError(fmt.Errorf("flag --translate only supports FASTA format"))
+					}
 					config.LineWidth = 0
 					fastx.ForcelyOutputFastq = true
 				}
 
 				if region != "" {
 					if noChrs {
-						subseqByRegion(outfh, record, config.LineWidth, start, end, appendRegionCoord)
+						subseqByRegion(outfh, record, config.LineWidth, start, end, appendRegionCoord, translator)
 					} else {
 						if _, ok = chrsMap[string(record.ID)]; ok {
-							subseqByRegion(outfh, record, config.LineWidth, start, end, appendRegionCoord)
+							subseqByRegion(outfh, record, config.LineWidth, start, end, appendRegionCoord, translator)
 						}
 					}
 				} else if gtfFile != "" {
@@ -376,7 +672,7 @@ Examples:
 
 					subseqByGTFFile(outfh, record, config.LineWidth,
 						gtfFeaturesMap, choosedFeatures,
-						onlyFlank, upStream, downStream, gtfTag)
+						onlyFlank, upStream, downStream, gtfTag, translator)
 
 				} else if bedFile != "" {
 					seqname = string(record.ID)
@@ -386,20 +682,32 @@ Examples:
 
 					subSeqByBEDFile(outfh, record, config.LineWidth,
 						bedFeatureMap,
-						onlyFlank, upStream, downStream)
+						onlyFlank, upStream, downStream, translator)
 				}
 			}
 			fastxReader.Close()
 
 			config.LineWidth = lineWidth
 		}
+
+		if translator != nil && !quiet {
+			translator.report()
+		}
 	},
 }
 
 type type2gtfFeatures map[string][]gtf.Feature
 
-func subseqByRegion(outfh *xopen.Writer, record *fastx.Record, lineWidth int, start, end int, appendRegionCoord bool) {
+func subseqByRegion(outfh *xopen.Writer, record *fastx.Record, lineWidth int, start, end int, appendRegionCoord bool,
+	translator *subseqTranslator) {
 	record.Seq = record.Seq.SubSeq(start, end)
+	if translator != nil {
+		aa, ok := translator.translate(record.Seq.Seq)
+		if !ok {
+			return
+		}
+		record.Seq, _ = seq.NewSeqWithoutValidation(seq.Protein, aa)
+	}
 	if appendRegionCoord {
 		record.Name = []byte(fmt.Sprintf("%s:%d-%d %s", record.ID, start, end, record.Desc))
 	}
@@ -408,7 +716,7 @@ func subseqByRegion(outfh *xopen.Writer, record *fastx.Record, lineWidth int, st
 
 func subseqByGTFFile(outfh *xopen.Writer, record *fastx.Record, lineWidth int,
 	gtfFeaturesMap map[string]type2gtfFeatures, choosedFeatures []string,
-	onlyFlank bool, upStream int, downStream int, gtfTag string) {
+	onlyFlank bool, upStream int, downStream int, gtfTag string, translator *subseqTranslator) {
 
 	seqname := string(record.ID)
 
@@ -428,46 +736,16 @@ func subseqByGTFFile(outfh *xopen.Writer, record *fastx.Record, lineWidth int,
 			}
 		}
 		for _, feature := range gtfFeaturesMap[seqname][featureType] {
-			s, e = feature.Start, feature.End
+			s, e = gtfFeatureRegion(feature, onlyFlank, upStream, downStream)
+			if s < 1 {
+				s = 1
+			}
+			if e > len(record.Seq.Seq) {
+				e = len(record.Seq.Seq)
+			}
 			if feature.Strand != nil && *feature.Strand == "-" {
-				if onlyFlank {
-					if upStream > 0 {
-						s = feature.End + 1
-						e = feature.End + upStream
-					} else {
-						s = feature.Start - downStream
-						e = feature.Start - 1
-					}
-				} else {
-					s = feature.Start - downStream // seq.SubSeq will check it
-					e = feature.End + upStream
-				}
-				if s < 1 {
-					s = 1
-				}
-				if e > len(record.Seq.Seq) {
-					e = len(record.Seq.Seq)
-				}
 				subseq = record.Seq.SubSeq(s, e).RevComInplace()
 			} else {
-				if onlyFlank {
-					if upStream > 0 {
-						s = feature.Start - upStream
-						e = feature.Start - 1
-					} else {
-						s = e + 1
-						e = e + downStream
-					}
-				} else {
-					s = feature.Start - upStream
-					e = feature.End + downStream
-				}
-				if s < 1 {
-					s = 1
-				}
-				if e > len(record.Seq.Seq) {
-					e = len(record.Seq.Seq)
-				}
 				subseq = record.Seq.SubSeq(s, e)
 			}
 
@@ -503,6 +781,14 @@ func subseqByGTFFile(outfh *xopen.Writer, record *fastx.Record, lineWidth int,
 				flankInfo = ""
 			}
 			outname = fmt.Sprintf("%s_%d-%d:%s%s %s", record.ID, feature.Start, feature.End, strand, flankInfo, tag)
+			if translator != nil {
+				aa, ok := translator.translate(subseq.Seq[gtfFeatureFrameOffset(feature, onlyFlank, s, e, len(subseq.Seq)):])
+				if !ok {
+					continue
+				}
+				subseq, _ = seq.NewSeqWithoutValidation(seq.Protein, aa)
+			}
+
 			var newRecord *fastx.Record
 			var err error
 			if len(subseq.Qual) > 0 {
@@ -516,9 +802,209 @@ func subseqByGTFFile(outfh *xopen.Writer, record *fastx.Record, lineWidth int,
 	}
 }
 
+// gtfFeatureFrameOffset returns the number of bases to skip before translating
+// the subsequence of region [s, e] of a GTF feature, so that the translation
+// starts from the first complete codon given by the frame (column 8) of the feature.
+// Included upstream flanks are translated in the same frame. l is the length of the subsequence.
+func gtfFeatureFrameOffset(feature gtf.Feature, onlyFlank bool, s, e int, l int) int {
+	if feature.Frame == nil || onlyFlank {
+		return 0
+	}
+	var up int // length of the included upstream flank
+	if feature.Strand != nil && *feature.Strand == "-" {
+		up = e - feature.End
+	} else {
+		up = feature.Start - s
+	}
+	if up < 0 {
+		up = 0
+	}
+	offset := (up + *feature.Frame) % 3
+	if offset > l {
+		offset = l
+	}
+	return offset
+}
+
+// gtfFeatureRegion returns the 1-based region of a GTF feature to extract,
+// with up/down stream flanks on the strand of the feature.
+// The region is not clamped to the sequence.
+func gtfFeatureRegion(feature gtf.Feature, onlyFlank bool, upStream, downStream int) (s, e int) {
+	if feature.Strand != nil && *feature.Strand == "-" {
+		if onlyFlank {
+			if upStream > 0 {
+				return feature.End + 1, feature.End + upStream
+			}
+			return feature.Start - downStream, feature.Start - 1
+		}
+		return feature.Start - downStream, feature.End + upStream
+	}
+	if onlyFlank {
+		if upStream > 0 {
+			return feature.Start - upStream, feature.Start - 1
+		}
+		return feature.End + 1, feature.End + downStream
+	}
+	return feature.Start - upStream, feature.End + downStream
+}
+
+// writeGTFFeatureAsBed writes the region of a GTF feature to extract in BED6 format.
+// Empty regions are skipped.
+func writeGTFFeatureAsBed(outfh *xopen.Writer, feature gtf.Feature, onlyFlank bool, upStream, downStream int, gtfTag string) {
+	s, e := gtfFeatureRegion(feature, onlyFlank, upStream, downStream)
+	if s < 1 {
+		s = 1
+	}
+	if e < s {
+		return
+	}
+
+	strand := "."
+	if feature.Strand != nil {
+		strand = *feature.Strand
+	}
+
+	name, txID := ".", ""
+	for _, arrtribute := range feature.Attributes {
+		if arrtribute.Tag == gtfTag && name == "." && arrtribute.Value != "" {
+			name = arrtribute.Value
+		} else if arrtribute.Tag == "transcript_id" && txID == "" {
+			txID = arrtribute.Value
+		}
+	}
+	if txID != "" && txID != name {
+		name += "|" + txID
+	}
+
+	fmt.Fprintf(outfh, "%s\t%d\t%d\t%s\t0\t%s\n", feature.SeqName, s-1, e, name, strand)
+}
+
+// readGTFFeaturesForBed reads GTF features of given chromosomes and feature types
+// (all if empty) for --emit-bed, with attributes of the given tags.
+// Coordinates and strands are parsed in the same way as gtf.ReadFilteredFeatures.
+func readGTFFeaturesForBed(file string, chrs []string, feats []string, tags []string) ([]gtf.Feature, error) {
+	toMap := func(list []string) map[string]struct{} {
+		m := make(map[string]struct{}, len(list))
+		for _, v := range list {
+			m[strings.ToLower(v)] = struct{}{}
+		}
+		return m
+	}
+	chrsMap, featsMap := toMap(chrs), toMap(feats)
+	tagsMap := make(map[string]struct{}, len(tags))
+	for _, t := range tags {
+		tagsMap[t] = struct{}{}
+	}
+
+	fh, err := xopen.Ropen(file)
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+
+	features := make([]gtf.Feature, 0, 1024)
+	scanner := bufio.NewScanner(fh)
+	scanner.Buffer(make([]byte, 0, 1<<20), 1<<30)
+	var line string
+	var items []string
+	var start, end int
+	var ok bool
+	for scanner.Scan() {
+		line = strings.TrimRight(scanner.Text(), "\r\n")
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+		items = strings.Split(line, "\t")
+		if len(items) != 9 {
+			continue
+		}
+		if len(chrs) > 0 {
+			if _, ok = chrsMap[strings.ToLower(items[0])]; !ok {
+				continue
+			}
+		}
+		if len(feats) > 0 {
+			if _, ok = featsMap[strings.ToLower(items[2])]; !ok {
+				continue
+			}
+		}
+
+		if start, err = strconv.Atoi(items[3]); err != nil {
+			return nil, fmt.Errorf("%s: bad start: %s", items[0], items[3])
+		}
+		if end, err = strconv.Atoi(items[4]); err != nil {
+			return nil, fmt.Errorf("%s: bad end: %s", items[0], items[4])
+		}
+		strand := items[6]
+		switch strand {
+		case ".":
+		case "+", "-":
+			if start > end {
+				if strand == "+" {
+					return nil, fmt.Errorf(`%s: start (%d) should be < end (%d) when the strand is "+"`, items[0], start, end)
+				}
+				start, end = end, start
+			}
+		default:
+			return nil, fmt.Errorf("%s: illegal strand: %s", items[0], strand)
+		}
+
+		features = append(features, gtf.Feature{
+			SeqName:    items[0],
+			Source:     items[1],
+			Feature:    items[2],
+			Start:      start,
+			End:        end,
+			Strand:     &strand,
+			Attributes: parseGTFAttributes(items[8], tagsMap),
+		})
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+	return features, nil
+}
+
+// parseGTFAttributes parses attributes of the given tags in the 9th column of GTF,
+// e.g., 'gene_id "g1"; transcript_id "t1";', where the last ";" is optional.
+func parseGTFAttributes(s string, tags map[string]struct{}) []gtf.Attribute {
+	attrs := make([]gtf.Attribute, 0, len(tags))
+	var i, j int
+	var quoted bool
+	var field, tag, value string
+	var ok bool
+	for i <= len(s) {
+		// find the end of the field, ";" in quoted values are kept
+		for j, quoted = i, false; j < len(s) && (quoted || s[j] != ';'); j++ {
+			if s[j] == '"' {
+				quoted = !quoted
+			}
+		}
+		field = strings.TrimSpace(s[i:j])
+		i = j + 1
+
+		if field == "" {
+			continue
+		}
+		if k := strings.IndexAny(field, " \t"); k > 0 {
+			tag, value = field[:k], strings.TrimSpace(field[k+1:])
+		} else {
+			tag, value = field, ""
+		}
+		if _, ok = tags[tag]; !ok {
+			continue
+		}
+		if len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
+			value = value[1 : len(value)-1]
+		}
+		attrs = append(attrs, gtf.Attribute{Tag: tag, Value: value})
+	}
+	return attrs
+}
+
 func subSeqByBEDFile(outfh *xopen.Writer, record *fastx.Record, lineWidth int,
 	bedFeatureMap map[string][]BedFeature,
-	onlyFlank bool, upStream, downStream int) {
+	onlyFlank bool, upStream, downStream int, translator *subseqTranslator) {
 	seqname := string(record.ID)
 
 	var strand, geneID, outname, flankInfo string
@@ -597,6 +1083,14 @@ func subSeqByBEDFile(outfh *xopen.Writer, record *fastx.Record, lineWidth int,
 			flankInfo = ""
 		}
 		outname = fmt.Sprintf("%s_%d-%d:%s%s %s", record.ID, feature.Start, feature.End, strand, flankInfo, geneID)
+		if translator != nil {
+			aa, ok := translator.translate(subseq.Seq)
+			if !ok {
+				continue
+			}
+			subseq, _ = seq.NewSeqWithoutValidation(seq.Protein, aa)
+		}
+
 		var newRecord *fastx.Record
 		var err error
 		if len(subseq.Qual) > 0 {
@@ -624,7 +1118,216 @@ func init() {
 	subseqCmd.Flags().IntP("down-stream", "d", 0, "down stream length")
 	subseqCmd.Flags().BoolP("only-flank", "f", false, "only return up/down stream sequence")
 	subseqCmd.Flags().StringP("bed", "", "", "by tab-delimited BED file")
+	subseqCmd.Flags().StringP("around-motif", "", "", `extract windows around all occurrences of the motif (degenerate bases supported), type "seqkit subseq -h" for details`)
+	subseqCmd.Flags().IntP("flank", "", 0, "number of bases on both sides of motif occurrences to extract with --around-motif")
 	subseqCmd.Flags().StringP("gtf-tag", "", "gene_id", `output this tag as sequence comment`)
+	subseqCmd.Flags().BoolP("emit-bed", "", false, `output GTF features to extract in BED6 format instead of subsequences, type "seqkit subseq -h" for details`)
 
+	subseqCmd.Flags().BoolP("translate", "", false, `translate subsequences to proteins, type "seqkit subseq -h" for details`)
+	subseqCmd.Flags().IntP("transl-table", "", 1, `translate table/genetic code for --translate, type 'seqkit translate --help' for more details`)
+	subseqCmd.Flags().IntP("frame", "", 1, "frame of subsequences to translate with --translate, available values: 1, 2, 3. For --protein-region, it's the frame of CDSs, negative values (-1, -2, -3) are for CDSs on the negative strand")
+	subseqCmd.Flags().StringP("protein-region", "", "", `extract the nucleotide region of CDSs by 1-based amino acid coordinates "aa_start:aa_end", type "seqkit subseq -h" for details`)
 	subseqCmd.Flags().BoolP("update-faidx", "U", false, "update the fasta index file if it exists. Use this if you are not sure whether the fasta file changed")
 }
+
+// subseqTranslator translates subsequences to proteins.
+type subseqTranslator struct {
+	table *seq.CodonTable
+	frame int
+
+	nPartial int // subsequences with incomplete codons at the end
+	nShort   int // subsequences shorter than a codon
+}
+
+// subseqMotif holds regular expressions of a motif on both strands.
+type subseqMotif struct {
+	motif string
+	re    *regexp.Regexp // positive strand
+	reRC  *regexp.Regexp // reverse complement, nil for palindromic motifs
+}
+
+func newSubseqMotif(motif string) (*subseqMotif, error) {
+	s, err := seq.NewSeq(seq.DNAredundant, []byte(motif))
+	if err != nil {
+		s, err = seq.NewSeq(seq.RNAredundant, []byte(motif))
+		if err != nil {
+			return nil, fmt.Errorf("invalid DNA/RNA motif: %s", motif)
+		}
+	}
+	m := &subseqMotif{motif: motif}
+	m.re, err = regexp.Compile("(?i)" + s.Degenerate2Regexp())
+	if err != nil {
+		return nil, err
+	}
+	rc := s.RevCom()
+	if !bytes.EqualFold(rc.Seq, s.Seq) {
+		m.reRC, err = regexp.Compile("(?i)" + rc.Degenerate2Regexp())
+		if err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// subseqMotifLocations returns 1-based locations of all occurrences,
+// overlapping ones included.
+func subseqMotifLocations(s []byte, re *regexp.Regexp) [][2]int {
+	var locs [][2]int
+	var loc []int
+	var offset, begin int
+	for {
+		loc = re.FindIndex(s[offset:])
+		if loc == nil {
+			break
+		}
+		begin = offset + loc[0] + 1
+		locs = append(locs, [2]int{begin, offset + loc[1]})
+		offset = begin
+		if offset >= len(s) {
+			break
+		}
+	}
+	return locs
+}
+
+// subseqAroundMotif outputs motif occurrences with flanking sequences of a record,
+// and returns the number of occurrences.
+func subseqAroundMotif(outfh *xopen.Writer, record *fastx.Record, lineWidth int,
+	motif *subseqMotif, flank int, translator *subseqTranslator) int {
+	var n int
+	l := len(record.Seq.Seq)
+	var s, e int
+	var subseq *seq.Seq
+	var outname string
+	var newRecord *fastx.Record
+	var err error
+	for _, strand := range []string{"+", "-"} {
+		re := motif.re
+		if strand == "-" {
+			if motif.reRC == nil {
+				break
+			}
+			re = motif.reRC
+		}
+		for _, loc := range subseqMotifLocations(record.Seq.Seq, re) {
+			n++
+			s, e = loc[0]-flank, loc[1]+flank
+			if s < 1 {
+				s = 1
+			}
+			if e > l {
+				e = l
+			}
+			subseq = record.Seq.SubSeq(s, e)
+			if strand == "-" {
+				subseq.RevComInplace()
+			}
+			if translator != nil {
+				aa, ok := translator.translate(subseq.Seq)
+				if !ok {
+					continue
+				}
+				subseq, _ = seq.NewSeqWithoutValidation(seq.Protein, aa)
+			}
+
+			outname = fmt.Sprintf("%s_%d-%d:%s_flank:%d %s:%d-%d", record.ID, s, e, strand, flank, motif.motif, loc[0], loc[1])
+			if len(subseq.Qual) > 0 {
+				newRecord, err = fastx.NewRecordWithQualWithoutValidation(record.Seq.Alphabet, []byte(outname), []byte(outname), []byte{}, subseq.Seq, subseq.Qual)
+			} else {
+				newRecord, err = fastx.NewRecordWithoutValidation(record.Seq.Alphabet, []byte(outname), []byte(outname), []byte{}, subseq.Seq)
+			}
+			checkError(err)
+			newRecord.FormatToWriter(outfh, lineWidth)
+		}
+	}
+	return n
+}
+
+var reProteinRegion = regexp.MustCompile(`^\d+:\d+$`)
+
+// subseqByProteinRegion outputs the nucleotide region of a CDS corresponding to
+// the 1-based amino acid region in the frame (1, 2, 3, -1, -2, -3).
+// It returns false if the sequence is too short to cover the region,
+// and whether the length of the CDS from the frame is not a multiple of 3.
+func subseqByProteinRegion(outfh *xopen.Writer, record *fastx.Record, lineWidth int,
+	aaStart, aaEnd, frame int, translator *subseqTranslator) (bool, bool) {
+	l := len(record.Seq.Seq)
+	strand := "+"
+	offset := frame
+	if frame < 0 {
+		strand = "-"
+		offset = -frame
+	}
+	partial := (l-offset+1)%3 != 0
+
+	// coordinates on the coding strand
+	s := (aaStart-1)*3 + offset
+	e := aaEnd*3 + offset - 1
+	if e > l {
+		return false, partial
+	}
+
+	var subseq *seq.Seq
+	if strand == "+" {
+		subseq = record.Seq.SubSeq(s, e)
+	} else {
+		// coordinates on the positive strand
+		s, e = l-e+1, l-s+1
+		subseq = record.Seq.SubSeq(s, e)
+		subseq.RevComInplace()
+	}
+	if translator != nil {
+		aa, ok := translator.translate(subseq.Seq)
+		if !ok {
+			return true, partial
+		}
+		subseq, _ = seq.NewSeqWithoutValidation(seq.Protein, aa)
+	}
+
+	outname := fmt.Sprintf("%s_%d-%d:%s_aa:%d-%d", record.ID, s, e, strand, aaStart, aaEnd)
+	var newRecord *fastx.Record
+	var err error
+	if len(subseq.Qual) > 0 {
+		newRecord, err = fastx.NewRecordWithQualWithoutValidation(record.Seq.Alphabet, []byte(outname), []byte(outname), []byte{}, subseq.Seq, subseq.Qual)
+	} else {
+		newRecord, err = fastx.NewRecordWithoutValidation(record.Seq.Alphabet, []byte(outname), []byte(outname), []byte{}, subseq.Seq)
+	}
+	checkError(err)
+	newRecord.FormatToWriter(outfh, lineWidth)
+	return true, partial
+}
+
+func newSubseqTranslator(translTable int, frame int) (*subseqTranslator, error) {
+	table, ok := seq.CodonTables[translTable]
+	if !ok {
+		return nil, fmt.Errorf("invalid translate table: %d", translTable)
+	}
+	if frame > 3 {
+		return nil, fmt.Errorf("invalid frame: %d, available values: 1, 2, 3", frame)
+	}
+	return &subseqTranslator{table: table, frame: frame}, nil
+}
+
+// translate returns the protein sequence, and false if the subsequence is too short.
+func (t *subseqTranslator) translate(s []byte) ([]byte, bool) {
+	n := len(s) - t.frame + 1
+	if n < 3 {
+		t.nShort++
+		return nil, false
+	}
+	if n%3 != 0 {
+		t.nPartial++
+	}
+	aa, err := t.table.Translate(s, t.frame, false, false, true, false)
+	checkError(err)
+	return aa, true
+}
+
+func (t *subseqTranslator) report() {
+	if t.nPartial > 0 {
+		log.Infof("%d subsequences have incomplete codons at the end, which are ignored in translation", t.nPartial)
+	}
+	if t.nShort > 0 {
+		log.Warningf("%d subsequences shorter than a codon are skipped", t.nShort)
+	}
+}
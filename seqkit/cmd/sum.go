@@ -109,7 +109,9 @@ Examples:
 		rna2dna := getFlagBool(cmd, "rna2dna")
 		singleStrand := getFlagBool(cmd, "single-strand")
 
+		bench := getBenchmarkStats(cmd)
 		files := getFileListFromArgsAndFile(cmd, args, true, "infile-list", true)
+		bench.addInputs(files...)
 
 		// process bar
 		var pbs *mpb.Progress
@@ -305,6 +307,7 @@ Examples:
 							log.Warningf(fmt.Sprintf("skip file: %s: %s", file, err))
 							return
 						}
+						bench.addRecord()
 
 						if n >= 1 {
 							// checkError(fmt.Errorf("only one sequence is allowed for circular genome"))
@@ -454,6 +457,7 @@ Examples:
 							log.Warningf(fmt.Sprintf("%s: %s", file, err))
 							return
 						}
+						bench.addRecord()
 
 						_seq = record.Seq
 
@@ -46,7 +46,9 @@ var tab2faCmd = &cobra.Command{
 		outFile := config.OutFile
 		runtime.GOMAXPROCS(config.Threads)
 
+		bench := getBenchmarkStats(cmd)
 		files := getFileListFromArgsAndFile(cmd, args, true, "infile-list", true)
+		bench.addInputs(files...)
 
 		commentPrefixes := getFlagStringSlice(cmd, "comment-line-prefix")
 		bufferSizeS := getFlagString(cmd, "buffer-size")
@@ -98,6 +100,7 @@ var tab2faCmd = &cobra.Command{
 				if len(items) < 2 {
 					checkError(fmt.Errorf("at least two columns needed: %s", line))
 				}
+				bench.addRecord()
 
 				if len(items) == 3 && (len(items[2]) > 0 || isFastq) { // fastq
 					isFastq = true
@@ -0,0 +1,160 @@
+// Copyright © 2016-2024 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"math"
+)
+
+// Nearest-neighbor parameters of DNA duplexes in 1 M NaCl, from
+// SantaLucia J Jr. (1998) PNAS 95:1460-1465.
+// Bases are indexed as A: 0, C: 1, G: 2, T: 3.
+var tmNNdH = [4][4]float64{ // kcal/mol
+	{-7.9, -8.4, -7.8, -7.2},  // AA, AC, AG, AT
+	{-8.5, -8.0, -10.6, -7.8}, // CA, CC, CG, CT
+	{-8.2, -9.8, -8.0, -8.4},  // GA, GC, GG, GT
+	{-7.2, -8.2, -8.5, -7.9},  // TA, TC, TG, TT
+}
+
+var tmNNdS = [4][4]float64{ // cal/(K·mol)
+	{-22.2, -22.4, -21.0, -20.4},
+	{-22.7, -19.9, -27.2, -21.0},
+	{-22.2, -24.4, -19.9, -22.4},
+	{-21.3, -22.2, -22.7, -22.2},
+}
+
+const (
+	tmInitGCdH = 0.1 // initiation with a terminal G·C
+	tmInitGCdS = -2.8
+	tmInitATdH = 2.3 // initiation with a terminal A·T
+	tmInitATdS = 4.1
+	tmSymdS    = -1.4 // symmetry correction of self-complementary sequences
+	tmR        = 1.987
+)
+
+// tmBases maps IUPAC codes to indexes of the bases they represent.
+var tmBases [256][]int
+
+func init() {
+	for b, bases := range map[byte][]int{
+		'A': {0}, 'C': {1}, 'G': {2}, 'T': {3}, 'U': {3},
+		'R': {0, 2}, 'Y': {1, 3}, 'S': {1, 2}, 'W': {0, 3}, 'K': {2, 3}, 'M': {0, 1},
+		'B': {1, 2, 3}, 'D': {0, 2, 3}, 'H': {0, 1, 3}, 'V': {0, 1, 2},
+		'N': {0, 1, 2, 3},
+	} {
+		tmBases[b] = bases
+		tmBases[b+32] = bases
+	}
+}
+
+// tmParams holds the conditions for computing melting temperatures.
+type tmParams struct {
+	Na         float64 // concentration of Na+, M
+	PrimerConc float64 // concentration of oligos, M
+	Average    bool    // averaging parameters of all possible bases for ambiguous bases
+}
+
+// oligoTm computes the nearest-neighbor melting temperature (°C) of a DNA oligo,
+// with the salt correction of SantaLucia (1998). NaN is returned for sequences
+// shorter than 2 bp.
+func oligoTm(s []byte, p *tmParams) (float64, error) {
+	if len(s) < 2 {
+		return math.NaN(), nil
+	}
+
+	var ambiguous bool
+	for i, b := range s {
+		switch len(tmBases[b]) {
+		case 0:
+			return 0, fmt.Errorf("invalid base '%c' at position %d for computing Tm", b, i+1)
+		case 1:
+		default:
+			if !p.Average {
+				return 0, fmt.Errorf("ambiguous base '%c' at position %d for computing Tm, you may use --tm-ambiguous average", b, i+1)
+			}
+			ambiguous = true
+		}
+	}
+
+	var dH, dS float64
+	var n int
+	var sumH, sumS float64
+	var bases1, bases2 []int
+	for i := 0; i < len(s)-1; i++ {
+		bases1, bases2 = tmBases[s[i]], tmBases[s[i+1]]
+		if len(bases1) == 1 && len(bases2) == 1 {
+			dH += tmNNdH[bases1[0]][bases2[0]]
+			dS += tmNNdS[bases1[0]][bases2[0]]
+			continue
+		}
+		sumH, sumS, n = 0, 0, 0
+		for _, a := range bases1 {
+			for _, b := range bases2 {
+				sumH += tmNNdH[a][b]
+				sumS += tmNNdS[a][b]
+				n++
+			}
+		}
+		dH += sumH / float64(n)
+		dS += sumS / float64(n)
+	}
+
+	// initiation
+	for _, b := range [2]byte{s[0], s[len(s)-1]} {
+		sumH, sumS = 0, 0
+		for _, a := range tmBases[b] {
+			if a == 1 || a == 2 {
+				sumH += tmInitGCdH
+				sumS += tmInitGCdS
+			} else {
+				sumH += tmInitATdH
+				sumS += tmInitATdS
+			}
+		}
+		dH += sumH / float64(len(tmBases[b]))
+		dS += sumS / float64(len(tmBases[b]))
+	}
+
+	// salt correction
+	dS += 0.368 * float64(len(s)-1) * math.Log(p.Na)
+
+	x := 4.0
+	if !ambiguous && tmSelfComplementary(s) {
+		dS += tmSymdS
+		x = 1
+	}
+
+	return dH*1000/(dS+tmR*math.Log(p.PrimerConc/x)) - 273.15, nil
+}
+
+// tmSelfComplementary checks if an unambiguous sequence equals its reverse complement.
+func tmSelfComplementary(s []byte) bool {
+	if len(s)%2 != 0 {
+		return false
+	}
+	for i, j := 0, len(s)-1; i < j; i, j = i+1, j-1 {
+		if tmBases[s[i]][0]+tmBases[s[j]][0] != 3 {
+			return false
+		}
+	}
+	return true
+}
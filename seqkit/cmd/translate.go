@@ -21,12 +21,14 @@
 package cmd
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"os"
 	"runtime"
 	"sort"
 	"strconv"
+	"strings"
 
 	"github.com/shenwei356/bio/seq"
 	"github.com/shenwei356/bio/seqio/fastx"
@@ -60,6 +62,54 @@ Note:
         MGR -> R
         YTR -> L
 
+  2. Flag --report-stops outputs a TSV table of records with internal stop
+     codons, instead of protein sequences, for checking CDS sequences.
+     The stop codons recognized depend on the translate table (-T).
+     The terminal stop codon is not counted as an internal one. With --trim,
+     consecutive stop codons at the end are all treated as terminal ones.
+     Columns:
+        1. id         sequence ID
+        2. frame      translation frame
+        3. aa_len     length of the translated sequence, including stops
+        4. stops      number of internal stop codons
+        5. aa_pos     positions of internal stops in the translated sequence
+        6. nt_pos     start positions of internal stop codons in the input
+                      sequence (positive strand)
+
+  3. Flag --to-stop truncates the translated sequence of each frame at the
+     first stop codon, which is dropped unless --keep-stop is given. With
+     --trim, trailing 'X's before the stop are removed, and the stop kept by
+     --keep-stop is retained.
+     Records without any stop codon in a frame (possibly truncated) are reported
+     in warning messages unless --quiet is given.
+
+  4. Flag --codon-report outputs a TSV table of every codon and its amino
+     acid for each record and frame, instead of protein sequences.
+     The translate table (-T), frames (-f), -x and -M are honored.
+     Columns:
+        1. id         sequence ID
+        2. frame      translation frame
+        3. aa_pos     position in the translated sequence
+        4. nt_start   start position of the codon in the input sequence (positive strand)
+        5. nt_end     end position of the codon in the input sequence (positive strand)
+        6. codon      the codon, reverse complemented for negative frames
+        7. aa         the amino acid, "NA" for the final partial codon
+        8. note       ".": regular codon,
+                      "ambiguous": codon with ambiguous bases, translated to
+                                   a specific amino acid,
+                      "unresolved": codon with ambiguous or unknown bases,
+                                    translated to 'X',
+                      "partial": the final incomplete codon of one or two
+                                 bases, which is not translated.
+
+  5. Flag --longest-frame translates all six frames, and only outputs the one
+     with the longest stretch without stop codons, with the frame appended to
+     the ID, e.g., "seq1_frame=-2". Ties are broken by fewer internal stops,
+     and then by the order of frames: 1, 2, 3, -1, -2, -3, i.e., toward the
+     forward strand and the lowest frame number. It is not compatible with
+     -f/--frame, --clean, --report-stops and --codon-report, while flags like
+     --to-stop, --trim and -s/--out-subseqs apply to the chosen frame.
+
 Translate Tables/Genetic Codes:
 
     # https://www.ncbi.nlm.nih.gov/Taxonomy/taxonomyhome.html/index.cgi?chapter=tgencodes
@@ -127,12 +177,42 @@ Translate Tables/Genetic Codes:
 		listTableAmb := getFlagInt(cmd, "list-transl-table-with-amb-codons")
 		appendFrame := getFlagBool(cmd, "append-frame")
 		skipTranslateErrors := getFlagBool(cmd, "skip-translate-errors")
+		reportStops := getFlagBool(cmd, "report-stops")
+		toStop := getFlagBool(cmd, "to-stop")
+		keepStop := getFlagBool(cmd, "keep-stop")
+		codonReport := getFlagBool(cmd, "codon-report")
+		longestFrame := getFlagBool(cmd, "longest-frame")
+		if longestFrame {
+			if cmd.Flags().Lookup("frame").Changed || clean || reportStops || codonReport {
+				checkError(fmt.Errorf("flag --longest-frame is not compatible with -f/--frame, --clean, --report-stops and --codon-report"))
+			}
+			appendFrame = true
+		}
+		allFrames := []int{1, 2, 3, -1, -2, -3}
 
 		outSubseqs := getFlagBool(cmd, "out-subseqs")
 		minLen := getFlagNonNegativeInt(cmd, "min-len")
 		if outSubseqs && !appendFrame {
 			appendFrame = true
 		}
+		if reportStops {
+			if outSubseqs {
+				checkError(fmt.Errorf("flag --report-stops is not compatible with -s/--out-subseqs"))
+			}
+			if clean {
+				checkError(fmt.Errorf("flag --report-stops is not compatible with --clean"))
+			}
+		}
+		if keepStop && !toStop {
+			checkError(fmt.Errorf("flag --keep-stop must be used with --to-stop"))
+		}
+		if toStop && (outSubseqs || clean || reportStops) {
+			checkError(fmt.Errorf("flag --to-stop is not compatible with -s/--out-subseqs, --clean and --report-stops"))
+		}
+
+		if codonReport && (reportStops || outSubseqs || toStop || trim || clean) {
+			checkError(fmt.Errorf("flag --codon-report is not compatible with --report-stops, -s/--out-subseqs, --to-stop, --trim and --clean"))
+		}
 
 		outfh, err := xopen.Wopen(outFile)
 		checkError(err)
@@ -162,7 +242,9 @@ Translate Tables/Genetic Codes:
 			return
 		}
 
+		bench := getBenchmarkStats(cmd)
 		files := getFileListFromArgsAndFile(cmd, args, true, "infile-list", true)
+		bench.addInputs(files...)
 
 		var record *fastx.Record
 		var _seq *seq.Seq
@@ -170,6 +252,13 @@ Translate Tables/Genetic Codes:
 		once := true
 		var i, start, _start, _end, _len int
 		var a byte
+		var stops []int
+		var aaPos, ntPos []string
+		if reportStops {
+			outfh.WriteString("id\tframe\taa_len\tstops\taa_pos\tnt_pos\n")
+		} else if codonReport {
+			outfh.WriteString("id\tframe\taa_pos\tnt_start\tnt_end\tcodon\taa\tnote\n")
+		}
 		for _, file := range files {
 			fastxReader, err := fastx.NewReader(alphabet, file, idRegexp)
 			checkError(err)
@@ -183,6 +272,7 @@ Translate Tables/Genetic Codes:
 					checkError(err)
 					break
 				}
+				bench.addRecord()
 
 				if once {
 					if !(record.Seq.Alphabet == seq.DNA || record.Seq.Alphabet == seq.DNAredundant ||
@@ -192,8 +282,34 @@ Translate Tables/Genetic Codes:
 					once = false
 				}
 
+				if longestFrame {
+					frame, err = translateLongestFrame(record.Seq, translTable, allFrames, allowUnknownCodon, markInitCodonAsM)
+					if err != nil {
+						if skipTranslateErrors {
+							outfh.WriteString(fmt.Sprintf(">%s %s\n\n", record.ID, record.Desc))
+							continue
+						}
+						if err == seq.ErrUnknownCodon {
+							log.Error("unknown codon detected, you can use flag -x/--allow-unknown-codon to translate it to 'X'.")
+							os.Exit(-1)
+						}
+						checkError(err)
+					}
+					frames = []int{frame}
+				}
+
 				for _, frame = range frames {
-					_seq, err = record.Seq.Translate(translTable, frame, trim, clean, allowUnknownCodon, markInitCodonAsM)
+					if codonReport {
+						err = translateCodonReport(outfh, record, seq.CodonTables[translTable], frame, allowUnknownCodon, markInitCodonAsM)
+						if err == seq.ErrUnknownCodon {
+							log.Error("unknown codon detected, you can use flag -x/--allow-unknown-codon to translate it to 'X'.")
+							os.Exit(-1)
+						}
+						checkError(err)
+						continue
+					}
+
+					_seq, err = record.Seq.Translate(translTable, frame, trim && !reportStops && !toStop, clean, allowUnknownCodon, markInitCodonAsM)
 
 					if err != nil {
 						if skipTranslateErrors {
@@ -208,6 +324,44 @@ Translate Tables/Genetic Codes:
 					}
 					checkError(err)
 
+					if toStop {
+						j := bytes.IndexByte(_seq.Seq, '*')
+						if j >= 0 {
+							_seq.Seq = _seq.Seq[:j]
+						} else if !config.Quiet {
+							log.Warningf("no stop codon found in frame %d of %s, which might be truncated", frame, record.ID)
+						}
+						// trim before appending the stop kept by --keep-stop
+						if trim {
+							_seq.Seq = bytes.TrimRight(_seq.Seq, "X")
+						}
+						if j >= 0 && keepStop {
+							_seq.Seq = append(_seq.Seq, '*')
+						}
+					}
+
+					if reportStops {
+						stops = internalStops(_seq.Seq, trim, stops[:0])
+						if len(stops) == 0 {
+							continue
+						}
+						_len = len(record.Seq.Seq)
+						aaPos = aaPos[:0]
+						ntPos = ntPos[:0]
+						for _, i = range stops {
+							aaPos = append(aaPos, strconv.Itoa(i+1))
+							if frame > 0 {
+								ntPos = append(ntPos, strconv.Itoa(i*3+frame))
+							} else {
+								ntPos = append(ntPos, strconv.Itoa(_len-i*3+frame-1))
+							}
+						}
+						outfh.WriteString(fmt.Sprintf("%s\t%d\t%d\t%d\t%s\t%s\n",
+							record.ID, frame, len(_seq.Seq), len(stops),
+							strings.Join(aaPos, ","), strings.Join(ntPos, ",")))
+						continue
+					}
+
 					if outSubseqs {
 						start = -1
 						_len = len(record.Seq.Seq)
@@ -278,6 +432,60 @@ Translate Tables/Genetic Codes:
 	},
 }
 
+// translateLongestFrame returns the frame with the longest stretch without stops,
+// with ties broken by fewer internal stops and then the order of frames.
+func translateLongestFrame(s *seq.Seq, translTable int, frames []int, allowUnknownCodon bool, markInitCodonAsM bool) (int, error) {
+	var best, bestStretch, bestStops int
+	var stretch, run, nStops int
+	var stops []int
+	for i, frame := range frames {
+		p, err := s.Translate(translTable, frame, false, false, allowUnknownCodon, markInitCodonAsM)
+		if err != nil {
+			return 0, err
+		}
+
+		stretch, run = 0, 0
+		for _, a := range p.Seq {
+			if a == '*' {
+				run = 0
+				continue
+			}
+			run++
+			if run > stretch {
+				stretch = run
+			}
+		}
+		stops = internalStops(p.Seq, false, stops[:0])
+		nStops = len(stops)
+
+		if i == 0 || stretch > bestStretch || (stretch == bestStretch && nStops < bestStops) {
+			best, bestStretch, bestStops = frame, stretch, nStops
+		}
+	}
+	return best, nil
+}
+
+// internalStops returns 0-based positions of internal stop symbols ('*')
+// in a protein sequence. The last stop is treated as the terminal one,
+// and all consecutive stops at the end are excluded if trim is true.
+func internalStops(protein []byte, trim bool, stops []int) []int {
+	end := len(protein)
+	if end > 0 && protein[end-1] == '*' {
+		end--
+		if trim {
+			for end > 0 && (protein[end-1] == '*' || protein[end-1] == 'X') {
+				end--
+			}
+		}
+	}
+	for i, a := range protein[:end] {
+		if a == '*' {
+			stops = append(stops, i)
+		}
+	}
+	return stops
+}
+
 func init() {
 	RootCmd.AddCommand(translateCmd)
 	translateCmd.Flags().IntP("transl-table", "T", 1, `translate table/genetic code, type 'seqkit translate --help' for more details`)
@@ -292,4 +500,111 @@ func init() {
 	translateCmd.Flags().BoolP("out-subseqs", "s", false, `output individual amino acid subsequences seperated by the stop symbol "*"`)
 	translateCmd.Flags().IntP("min-len", "m", 0, `the minimum length of amino acid sequence`)
 	translateCmd.Flags().BoolP("skip-translate-errors", "e", false, `skip errors during translate and output blank sequence`)
+	translateCmd.Flags().BoolP("to-stop", "", false, `truncate the translated sequence of each frame at the first stop codon`)
+	translateCmd.Flags().BoolP("keep-stop", "", false, `keep the stop symbol "*" when using --to-stop`)
+	translateCmd.Flags().BoolP("codon-report", "", false, `output a TSV table of codons and translated amino acids, instead of protein sequences`)
+	translateCmd.Flags().BoolP("longest-frame", "", false, `translate all six frames, and only output the one with the longest stretch without stop codons`)
+	translateCmd.Flags().BoolP("report-stops", "", false, `output a TSV table of records with internal stop codons and their positions, instead of protein sequences`)
+}
+
+// translateCodonReport writes codons and their amino acids of a frame of a record.
+func translateCodonReport(outfh *xopen.Writer, record *fastx.Record, table *seq.CodonTable,
+	frame int, allowUnknownCodon bool, markInitCodonAsM bool) error {
+	s := record.Seq.Seq
+	l := len(s)
+	codon := make([]byte, 3)
+	var aa byte
+	var err error
+	var ambiguous bool
+	var note string
+	var k, start, end int
+	first := true
+
+	write := func() error {
+		aa, err = table.Get(codon, allowUnknownCodon)
+		if err != nil {
+			return err
+		}
+		if markInitCodonAsM {
+			if first {
+				if _, ok := table.InitCodons[strings.ToUpper(string(codon))]; ok {
+					aa = 'M'
+				}
+				first = false
+			} else if aa == '*' {
+				first = true
+			}
+		}
+
+		ambiguous = false
+		for _, b := range codon {
+			switch b {
+			case 'A', 'C', 'G', 'T', 'U', 'a', 'c', 'g', 't', 'u':
+			default:
+				ambiguous = true
+			}
+		}
+		if !ambiguous {
+			note = "."
+		} else if aa == 'X' {
+			note = "unresolved"
+		} else {
+			note = "ambiguous"
+		}
+
+		k++
+		fmt.Fprintf(outfh, "%s\t%d\t%d\t%d\t%d\t%s\t%c\t%s\n", record.ID, frame, k, start, end, codon, aa, note)
+		return nil
+	}
+
+	var i int
+	if frame > 0 {
+		for i = frame - 1; i+3 <= l; i += 3 {
+			copy(codon, s[i:i+3])
+			start, end = i+1, i+3
+			if err = write(); err != nil {
+				return err
+			}
+		}
+		if i < l {
+			fmt.Fprintf(outfh, "%s\t%d\t%d\t%d\t%d\t%s\tNA\tpartial\n", record.ID, frame, k+1, i+1, l, s[i:])
+		}
+		return nil
+	}
+
+	// ambiguous bases are complemented too
+	alphabet := seq.DNAredundant
+	if record.Seq.Alphabet == seq.RNA || record.Seq.Alphabet == seq.RNAredundant {
+		alphabet = seq.RNAredundant
+	}
+	rc := func(b byte) (byte, error) {
+		p, err := alphabet.PairLetter(b)
+		if err != nil {
+			return b, fmt.Errorf("%s: %s", record.ID, err)
+		}
+		return p, nil
+	}
+	for i = l + frame; i >= 2; i -= 3 {
+		for j := 0; j < 3; j++ {
+			if codon[j], err = rc(s[i-j]); err != nil {
+				return err
+			}
+		}
+		start, end = i-1, i+1
+		if err = write(); err != nil {
+			return err
+		}
+	}
+	if i >= 0 {
+		partial := make([]byte, 0, 2)
+		var b byte
+		for ; i >= 0; i-- {
+			if b, err = rc(s[i]); err != nil {
+				return err
+			}
+			partial = append(partial, b)
+		}
+		fmt.Fprintf(outfh, "%s\t%d\t%d\t%d\t%d\t%s\tNA\tpartial\n", record.ID, frame, k+1, 1, len(partial), partial)
+	}
+	return nil
 }
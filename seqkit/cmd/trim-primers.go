@@ -0,0 +1,282 @@
+// Copyright © 2016-2024 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"runtime"
+
+	"github.com/shenwei356/bio/seq"
+	"github.com/shenwei356/bio/seqio/fastx"
+	"github.com/shenwei356/bwt"
+	"github.com/shenwei356/bwt/fmi"
+	"github.com/shenwei356/xopen"
+	"github.com/spf13/cobra"
+)
+
+// trimPrimersCmd represents the trim-primers command
+var trimPrimersCmd = &cobra.Command{
+	GroupID: "edit",
+
+	Use:   "trim-primers",
+	Short: "trim primers of multiple primer pairs with the best-matching pair",
+	Long: `trim primers of multiple primer pairs with the best-matching pair
+
+This command is designed for multiplex amplicon sequencing, where each read
+is matched against all primer pairs with the same matcher of "seqkit amplicon",
+and trimmed by the best-matching pair.
+
+Primer file:
+  A 3- or 2-column tab-delimited file, with columns of pair name, forward
+  primer (5'-primer-3') and reverse primer (5'-primer-3'), the same as
+  -p/--primer-file of "seqkit amplicon". Degenerate bases are allowed when
+  -m/--max-mismatch is 0. Blank lines and lines starting with "#" are ignored.
+
+Choosing the best pair:
+  1. A pair matches a read if the forward primer and the reverse complement
+     of the reverse primer are found in order on either strand, or only the
+     forward primer is found for a pair with only the forward primer.
+  2. The pair with the fewest total mismatches is chosen. Ties are broken
+     by the longer total length of primers, then the match on the positive
+     strand, then the earlier pair in the file.
+
+Output:
+  1. Regions outside of the primers, and primers themselves (unless
+     -k/--keep-primers is given), are trimmed, in the original orientation
+     of the read. Quality scores of FASTQ records are trimmed in lockstep.
+  2. The pair name and the strand are appended to the header, e.g.,
+     "read1 primer=pair1 strand=+". Switch on -M/--output-mismatches to also
+     append the mismatches of 5' and 3' primers.
+  3. Reads matching no pair, or with overlapping primers, are saved to
+     -u/--untrimmed if given, or discarded otherwise.
+
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		config := getConfigs(cmd)
+		alphabet := config.Alphabet
+		idRegexp := config.IDRegexp
+		lineWidth := config.LineWidth
+		outFile := config.OutFile
+		quiet := config.Quiet
+		seq.AlphabetGuessSeqLengthThreshold = config.AlphabetGuessSeqLength
+		seq.ValidateSeq = false
+		runtime.GOMAXPROCS(config.Threads)
+		bwt.CheckEndSymbol = false
+
+		bench := getBenchmarkStats(cmd)
+		files := getFileListFromArgsAndFile(cmd, args, true, "infile-list", true)
+		bench.addInputs(files...)
+
+		primerFile := getFlagString(cmd, "primers")
+		if primerFile == "" {
+			checkError(fmt.Errorf("flag -p (--primers) needed"))
+		}
+		maxMismatch := getFlagNonNegativeInt(cmd, "max-mismatch")
+		onlyPositiveStrand := getFlagBool(cmd, "only-positive-strand")
+		keepPrimers := getFlagBool(cmd, "keep-primers")
+		outputMismatches := getFlagBool(cmd, "output-mismatches")
+		untrimmedFile := getFlagString(cmd, "untrimmed")
+
+		list, err := loadPrimers(primerFile)
+		checkError(err)
+		primers, err := parsePrimers(list)
+		checkError(err)
+		if len(primers) == 0 {
+			checkError(fmt.Errorf("no valid primer pairs found in file: %s", primerFile))
+		}
+
+		finders := make([]*AmpliconFinder, len(primers))
+		for i, primer := range primers {
+			finders[i], err = NewAmpliconFinder([]byte{'A'}, primer[1], primer[2], maxMismatch)
+			checkError(err)
+		}
+		if !quiet {
+			log.Infof("%d primer pair(s) loaded", len(primers))
+		}
+
+		outfh, err := xopen.Wopen(outFile)
+		checkError(err)
+		defer outfh.Close()
+
+		var outfhU *xopen.Writer
+		if untrimmedFile != "" {
+			outfhU, err = xopen.Wopen(untrimmedFile)
+			checkError(err)
+			defer outfhU.Close()
+		}
+
+		strands := []byte{'+', '-'}
+		if onlyPositiveStrand {
+			strands = strands[:1]
+		}
+
+		var record *fastx.Record
+		var sequence []byte
+		var index *fmi.FMIndex
+		var finder *AmpliconFinder
+		var loc, mis []int
+		var b, e, L int
+		var best *trimPrimersMatch
+		var m trimPrimersMatch
+		var nTrimmed, nUntrimmed int
+		for _, file := range files {
+			fastxReader, err := fastx.NewReader(alphabet, file, idRegexp)
+			checkError(err)
+
+			for {
+				record, err = fastxReader.Read()
+				if err != nil {
+					if err == io.EOF {
+						break
+					}
+					checkError(err)
+					break
+				}
+				bench.addRecord()
+				if fastxReader.IsFastq {
+					config.LineWidth = 0
+					fastx.ForcelyOutputFastq = true
+				}
+
+				best = nil
+				L = len(record.Seq.Seq)
+				if L > 0 {
+					for _, strand := range strands {
+						if strand == '+' {
+							sequence = bytes.ToUpper(record.Seq.Seq)
+						} else {
+							sequence = bytes.ToUpper(record.Seq.RevCom().Seq)
+						}
+						if maxMismatch > 0 {
+							index = fmi.NewFMIndex()
+							_, err = index.Transform(sequence)
+							checkError(err)
+						}
+
+						for i := range finders {
+							finder = finders[i]
+							finder.ResetWithFMIndex(sequence, index, maxMismatch)
+
+							loc, mis, err = finder.Location()
+							checkError(err)
+							if loc == nil {
+								continue
+							}
+
+							// 1-based location of the insert, or the whole amplicon
+							b, e = loc[0], loc[1]
+							if !keepPrimers {
+								b += len(finder.F)
+								if len(finder.R) > 0 {
+									e -= len(finder.R)
+								} else {
+									e = L
+								}
+							} else if len(finder.R) == 0 {
+								e = L
+							}
+							if b > e {
+								continue
+							}
+
+							m = trimPrimersMatch{
+								pair:   i,
+								strand: strand,
+								begin:  b,
+								end:    e,
+								mis5:   mis[0],
+								mis3:   mis[1],
+								length: len(finder.F) + len(finder.R),
+							}
+							if best == nil || m.betterThan(best) {
+								best = &trimPrimersMatch{}
+								*best = m
+							}
+						}
+					}
+				}
+
+				if best == nil {
+					nUntrimmed++
+					if outfhU != nil {
+						record.FormatToWriter(outfhU, config.LineWidth)
+					}
+					continue
+				}
+
+				// back to the location on the positive strand
+				if best.strand == '-' {
+					best.begin, best.end = L-best.end+1, L-best.begin+1
+				}
+				record.Seq = record.Seq.SubSeq(best.begin, best.end)
+				if outputMismatches {
+					record.Name = []byte(fmt.Sprintf("%s primer=%s strand=%c mismatches=%d(%d+%d)",
+						record.Name, primers[best.pair][0], best.strand,
+						best.mis5+best.mis3, best.mis5, best.mis3))
+				} else {
+					record.Name = []byte(fmt.Sprintf("%s primer=%s strand=%c",
+						record.Name, primers[best.pair][0], best.strand))
+				}
+				record.FormatToWriter(outfh, config.LineWidth)
+				nTrimmed++
+			}
+			fastxReader.Close()
+
+			config.LineWidth = lineWidth
+		}
+
+		if !quiet {
+			log.Infof("%d records trimmed, %d records untrimmed", nTrimmed, nUntrimmed)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(trimPrimersCmd)
+
+	trimPrimersCmd.Flags().StringP("primers", "p", "", "3- or 2-column tabular primer file, with columns of pair name, forward primer and reverse primer")
+	trimPrimersCmd.Flags().IntP("max-mismatch", "m", 0, "max mismatch when matching primers, no degenerate bases allowed")
+	trimPrimersCmd.Flags().BoolP("only-positive-strand", "P", false, "only search on positive strand")
+	trimPrimersCmd.Flags().BoolP("keep-primers", "k", false, "keep primer sequences, only trim regions outside of primers")
+	trimPrimersCmd.Flags().BoolP("output-mismatches", "M", false, "append the total mismatches and mismatches of 5' end and 3' end")
+	trimPrimersCmd.Flags().StringP("untrimmed", "u", "", "file for saving records matching no primer pair")
+}
+
+// trimPrimersMatch is a match of a primer pair on a read.
+type trimPrimersMatch struct {
+	pair       int
+	strand     byte
+	begin, end int // 1-based location of the region to keep, on the matched strand
+	mis5, mis3 int
+	length     int // total length of primers
+}
+
+// betterThan tells whether the match is better than another one.
+// Matches are compared in the order of pairs and strands, so only
+// fewer mismatches or longer primers make a later match better.
+func (m *trimPrimersMatch) betterThan(o *trimPrimersMatch) bool {
+	if m.mis5+m.mis3 != o.mis5+o.mis3 {
+		return m.mis5+m.mis3 < o.mis5+o.mis3
+	}
+	return m.length > o.length
+}
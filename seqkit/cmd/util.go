@@ -24,6 +24,7 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"math"
 	"math/rand"
 	"os"
 	"os/exec"
@@ -409,6 +410,38 @@ func RevCompDNA(s string) string {
 	return string(tmp)
 }
 
+// seqEntropy returns the Shannon entropy (in bits, 0-2) of base composition
+// of a nucleotide sequence. Only A, C, G and T/U (case-insensitive) are counted,
+// ambiguous bases are excluded. 0 is returned if there's no unambiguous base.
+func seqEntropy(s []byte) float64 {
+	var counts [4]int
+	for _, b := range s {
+		switch b {
+		case 'A', 'a':
+			counts[0]++
+		case 'C', 'c':
+			counts[1]++
+		case 'G', 'g':
+			counts[2]++
+		case 'T', 't', 'U', 'u':
+			counts[3]++
+		}
+	}
+	n := counts[0] + counts[1] + counts[2] + counts[3]
+	if n == 0 {
+		return 0
+	}
+	var e, p float64
+	for _, c := range counts {
+		if c == 0 {
+			continue
+		}
+		p = float64(c) / float64(n)
+		e -= p * math.Log2(p)
+	}
+	return e
+}
+
 func maxStrLen(slice []string) int {
 	l := 0
 	for _, s := range slice {
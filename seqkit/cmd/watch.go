@@ -154,7 +154,9 @@ var watchCmd = &cobra.Command{
 			seq.ValidateSeq = true
 		}
 
+		bench := getBenchmarkStats(cmd)
 		files := getFileListFromArgsAndFile(cmd, args, true, "infile-list", true)
+		bench.addInputs(files...)
 
 		outfh, err := xopen.Wopen(outFile)
 		checkError(err)
@@ -189,6 +191,7 @@ var watchCmd = &cobra.Command{
 					checkError(err)
 					break
 				}
+				bench.addRecord()
 
 				if checkSeqType {
 					isFastq = fastxReader.IsFastq
@@ -0,0 +1,235 @@
+// Copyright © 2016-2024 Wei Shen <shenwei356@gmail.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
+
+	"github.com/shenwei356/bio/seq"
+	"github.com/shenwei356/bio/seqio/fastx"
+	"github.com/shenwei356/xopen"
+	"github.com/spf13/cobra"
+)
+
+// winstatsCmd represents the winstats command
+var winstatsCmd = &cobra.Command{
+	GroupID: "basic",
+
+	Use:     "winstats",
+	Aliases: []string{"window-stats"},
+	Short:   "statistics of GC content, N content and quality in sliding windows",
+	Long: `statistics of GC content, N content and quality in sliding windows
+
+Columns:
+  1. id       sequence ID
+  2. start    0-based start position of the window
+  3. end      end position of the window (exclusive)
+  4. length   window length
+  5. GC       GC content (%)
+  6. N        N content (%)
+  7. avg.qual average quality, only for FASTQ
+
+Windows are generated in the same way as "seqkit sliding":
+  1. The last window shorter than the window size is skipped, unless
+     -g/--greedy is given.
+  2. For circular genomes (-c/--circular), the last windows cross the origin,
+     and their end positions are wrapped to the start of the sequence.
+     Windows can be longer than the sequence, bases are read from the start
+     again in this case.
+
+Output in bedGraph format (-B/--bedgraph):
+  1. Only one value column, chosen by -f/--field (gc, n, qual).
+  2. No header line.
+  3. A window crossing the origin of a circular genome is written as
+     two intervals with the same value, which do not overlap for windows
+     longer than the sequence.
+  4. Flag --n-frac is a shortcut of "-B -f n" for gap tracks of assemblies,
+     but outputs the fraction (0-1) of N bases, instead of the percentage.
+     Both "N" and "n" are counted, and other bases (including other
+     ambiguous ones) are not. E.g.,
+       seqkit winstats --n-frac -W 10000 -s 10000 -g genome.fa > gaps.bedGraph
+
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		config := getConfigs(cmd)
+		alphabet := config.Alphabet
+		idRegexp := config.IDRegexp
+		outFile := config.OutFile
+		seq.AlphabetGuessSeqLengthThreshold = config.AlphabetGuessSeqLength
+		seq.ValidateSeq = false
+		runtime.GOMAXPROCS(config.Threads)
+
+		bench := getBenchmarkStats(cmd)
+		files := getFileListFromArgsAndFile(cmd, args, true, "infile-list", true)
+		bench.addInputs(files...)
+
+		greedy := getFlagBool(cmd, "greedy")
+		circular := getFlagBool(cmd, "circular")
+		step := getFlagInt(cmd, "step")
+		window := getFlagInt(cmd, "window")
+		if step == 0 || window == 0 {
+			checkError(fmt.Errorf("both flags -s (--step) and -W (--window) needed"))
+		}
+		if step < 1 {
+			checkError(fmt.Errorf("value of flag -s (--step) should be greater than 0: %d ", step))
+		}
+		if window < 1 {
+			checkError(fmt.Errorf("value of flag -W (--window) should be greater than 0: %d ", window))
+		}
+		qBase := getFlagPositiveInt(cmd, "qual-ascii-base")
+		bedGraph := getFlagBool(cmd, "bedgraph")
+		field := strings.ToLower(getFlagString(cmd, "field"))
+		switch field {
+		case "gc", "n", "qual":
+		default:
+			checkError(fmt.Errorf("invalid value of flag -f (--field): %s, available values: gc, n, qual", field))
+		}
+		noHeaderRow := getFlagBool(cmd, "no-header-row")
+		nFrac := getFlagBool(cmd, "n-frac")
+		if nFrac {
+			if cmd.Flags().Lookup("field").Changed && field != "n" {
+				checkError(fmt.Errorf("flag --n-frac is not compatible with -f/--field %s", field))
+			}
+			bedGraph = true
+			field = "n"
+		}
+		valueFormat := "%s\t%d\t%d\t%.2f\n"
+		if nFrac {
+			valueFormat = "%s\t%d\t%d\t%.4f\n"
+		}
+
+		outfh, err := xopen.Wopen(outFile)
+		checkError(err)
+		defer outfh.Close()
+
+		var record *fastx.Record
+		var checkFastq bool
+		var isFastq bool
+		var i, e, l int
+		var ok bool
+		var nextWindow func() (int, int, bool)
+		var s *seq.Seq
+		var gc, n, q, v float64
+		for _, file := range files {
+			fastxReader, err := fastx.NewReader(alphabet, file, idRegexp)
+			checkError(err)
+			checkFastq = true
+			for {
+				record, err = fastxReader.Read()
+				if err != nil {
+					if err == io.EOF {
+						break
+					}
+					checkError(err)
+					break
+				}
+				bench.addRecord()
+
+				if checkFastq {
+					isFastq = fastxReader.IsFastq
+					if bedGraph && field == "qual" && !isFastq {
+						checkError(fmt.Errorf("FASTQ format needed for -f/--field qual: %s", file))
+					}
+					if !bedGraph && !noHeaderRow {
+						if isFastq {
+							outfh.WriteString("#id\tstart\tend\tlength\tGC\tN\tavg.qual\n")
+						} else {
+							outfh.WriteString("#id\tstart\tend\tlength\tGC\tN\n")
+						}
+						noHeaderRow = true
+					}
+					checkFastq = false
+				}
+
+				l = len(record.Seq.Seq)
+				nextWindow = slidingWindows(l, window, step, circular, greedy)
+				for {
+					i, e, ok = nextWindow()
+					if !ok {
+						break
+					}
+
+					if isFastq {
+						s, _ = seq.NewSeqWithQualWithoutValidation(record.Seq.Alphabet,
+							windowSlice(record.Seq.Seq, i, e), windowSlice(record.Seq.Qual, i, e))
+						q = s.AvgQual(qBase)
+					} else {
+						s, _ = seq.NewSeqWithoutValidation(record.Seq.Alphabet, windowSlice(record.Seq.Seq, i, e))
+					}
+					gc = s.GC() * 100
+					n = s.BaseContent("n") * 100
+
+					if !bedGraph {
+						e = circularEnd(e, l)
+						if isFastq {
+							fmt.Fprintf(outfh, "%s\t%d\t%d\t%d\t%.2f\t%.2f\t%.2f\n", record.ID, i, e, len(s.Seq), gc, n, q)
+						} else {
+							fmt.Fprintf(outfh, "%s\t%d\t%d\t%d\t%.2f\t%.2f\n", record.ID, i, e, len(s.Seq), gc, n)
+						}
+						continue
+					}
+
+					switch field {
+					case "gc":
+						v = gc
+					case "n":
+						v = n
+						if nFrac {
+							v = n / 100
+						}
+					case "qual":
+						v = q
+					}
+					if e <= l {
+						fmt.Fprintf(outfh, valueFormat, record.ID, i, e, v)
+					} else { // crossing the origin
+						fmt.Fprintf(outfh, valueFormat, record.ID, i, l, v)
+						e -= l
+						if e > i { // windows longer than the sequence
+							e = i
+						}
+						if e > 0 {
+							fmt.Fprintf(outfh, valueFormat, record.ID, 0, e, v)
+						}
+					}
+				}
+			}
+			fastxReader.Close()
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(winstatsCmd)
+
+	winstatsCmd.Flags().IntP("step", "s", 0, "step size")
+	winstatsCmd.Flags().IntP("window", "W", 0, "window size")
+	winstatsCmd.Flags().BoolP("greedy", "g", false, "greedy mode, i.e., reporting last windows even shorter than the windows size")
+	winstatsCmd.Flags().BoolP("circular", "c", false, "circular genome")
+	winstatsCmd.Flags().IntP("qual-ascii-base", "b", 33, "ASCII BASE, 33 for Phred+33")
+	winstatsCmd.Flags().BoolP("bedgraph", "B", false, "output in bedGraph format, with the value chosen by -f/--field")
+	winstatsCmd.Flags().StringP("field", "f", "gc", "value for bedGraph output, available values: gc, n, qual")
+	winstatsCmd.Flags().BoolP("n-frac", "", false, `output fractions (0-1) of N bases in bedGraph format, i.e., "-B -f n" with fractions instead of percentages`)
+	winstatsCmd.Flags().BoolP("no-header-row", "H", false, "do not print header row")
+}
@@ -105,6 +105,153 @@ fun() {
 run seq_rna2dna fun
 assert_in_stdout "TCATATGCTTGTCTCAAAGATTA"
 
+# --complement-only-iupac: every IUPAC code, with the case preserved
+fun() {
+    echo -e ">dna\nACGTRYSWKMBDHVNacgtryswkmbdhvn-." | $app seq --complement-only-iupac -s -w 0
+}
+run seq_complement_iupac fun
+assert_equal $(cat $STDOUT_FILE) "TGCAYRSWMKVHDBNtgcayrswmkvhdbn-."
+
+# RNA: A is complemented to U
+fun() {
+    echo -e ">rna\nACGURYSWKMBDHVNacguryswkmbdhvn" | $app seq --complement-only-iupac -s -w 0
+}
+run seq_complement_iupac_rna fun
+assert_equal $(cat $STDOUT_FILE) "UGCAYRSWMKVHDBNugcayrswmkvhdbn"
+
+# reverse complement, with T and U mixed
+fun() {
+    echo -e ">mix\nAATUGC" | $app seq --complement-only-iupac -r -s -w 0
+}
+run seq_complement_iupac_rc fun
+assert_equal $(cat $STDOUT_FILE) "GCAATT"
+
+# --drop-ambiguous and --max-n-frac
+fun(){ echo -e ">a\nACGTN\n>b\nACGT\n>c\nACGTR" | $app seq --drop-ambiguous -n; }
+run seq_drop_ambiguous fun
+assert_equal $(cat $STDOUT_FILE | paste -sd,) "b"
+
+fun(){ echo -e ">a\nACGTN\n>b\nACGT\n>c\nANNNN" | $app seq --max-n-frac 0.2 -n; }
+run seq_max_n_frac fun
+assert_equal $(cat $STDOUT_FILE | paste -sd,) "a,b"
+
+# not for protein sequences, where N is asparagine
+fun(){ echo -e ">p\nMNNNKLVW" | $app seq --drop-ambiguous; }
+run seq_drop_ambiguous_protein fun
+assert_exit_code 255
+
+# -i/--only-id: the first words are used for headers not matching --id-regexp
+fun(){ echo -e ">x y z\nACGT\n>1 desc\nAC" | $app seq -i -n --id-regexp '^(\d+)'; }
+run seq_only_id_unmatched fun
+assert_equal $(cat $STDOUT_FILE | paste -sd,) "x,1"
+
+fun(){ echo -e ">gi|110645304|ref|NC_002516.2| Pseud\nAC" | $app seq -i -n --id-ncbi; }
+run seq_only_id_ncbi fun
+assert_equal $(cat $STDOUT_FILE) "NC_002516.2"
+
+# --validate-lengths: records with unequal lengths of sequences and qualities
+fun(){ echo -e "@a\nACGT\n+\nIIII\n@b\nACG\n+\nIIII" | $app seq --validate-lengths; }
+run seq_validate_lengths fun
+assert_exit_code 1
+assert_equal $(cat $STDOUT_FILE | tr "\t" , | paste -sd,) "file,index,id,seq_len,qual_len,-,2,b,3,4"
+
+fun(){ echo -e "@a\nACGT\n+\nIIII" | $app seq --validate-lengths; }
+run seq_validate_lengths_ok fun
+assert_exit_code 0
+
+# --concat-all: records are concatenated with spacers, and positions are saved in BED
+fun(){ echo -e ">a\nACG\n>b\nTT" | $app seq --concat-all --spacer 2 --concat-bed t.concat.bed; }
+run seq_concat_all fun
+assert_equal $(cat $STDOUT_FILE | paste -sd,) ">concatenated,ACGNNTT"
+assert_equal $(cat t.concat.bed | tr "\t" , | paste -sd,) "concatenated,0,3,a,concatenated,5,7,b"
+rm t.concat.bed
+
+fun(){ echo -e "@a\nACG\n+\nIII\n@b\nTT\n+\n55" | $app seq --concat-all --spacer 1 --spacer-qual '!' --concat-id x; }
+run seq_concat_all_fastq fun
+assert_equal $(cat $STDOUT_FILE | paste -sd,) "@x,ACGNTT,+,III!55"
+
+# --spacer-qual is needed for FASTQ
+fun(){ echo -e "@a\nACG\n+\nIII" | $app seq --concat-all --spacer 1; }
+run seq_concat_all_fastq_no_qual fun
+assert_exit_code 255
+
+# --dna2rna keeps the case, and is skipped for protein sequences
+fun(){ echo -e ">d\nACGTt" | $app seq --dna2rna -s; }
+run seq_dna2rna fun
+assert_equal $(cat $STDOUT_FILE) "ACGUu"
+
+fun(){ echo -e ">p\nMEEPQSTT" | $app seq --dna2rna -s; }
+run seq_dna2rna_protein fun
+assert_equal $(cat $STDOUT_FILE) "MEEPQSTT"
+
+fun(){ echo -e ">d\nACGT" | $app seq --dna2rna --rna2dna; }
+run seq_dna2rna_rna2dna fun
+assert_exit_code 255
+
+# --both-strands: filters are applied before, and qualities are reversed
+fun(){ echo -e "@a x\nAACG\n+\nI5+!\n@b\nA\n+\nI" | $app seq --both-strands -m 2; }
+run seq_both_strands fun
+assert_equal "$(cat $STDOUT_FILE | paste -sd,)" "@a x,AACG,+,I5+!,@a_rc x,CGTT,+,!+5I"
+
+fun(){ echo -e ">a x\nAACG" | $app seq --both-strands --rc-suffix /rc -i; }
+run seq_both_strands_suffix fun
+assert_equal $(cat $STDOUT_FILE | paste -sd,) ">a,AACG,>a/rc,CGTT"
+
+fun(){ echo -e ">p\nMEEPQ" | $app seq --both-strands; }
+run seq_both_strands_protein fun
+assert_exit_code 255
+
+# --max-bases: the current record is completed, and filtered records are not counted
+fun(){ echo -e ">a\nAAA\n>b\nC\n>c\nGG-GG\n>d\nTT" | $app seq --max-bases 5 -m 2 -g -n; }
+run seq_max_bases fun
+assert_equal $(cat $STDOUT_FILE | paste -sd,) "a,c"
+
+fun(){ echo -e ">a\nAAA" | $app seq --max-bases 1X; }
+run seq_max_bases_invalid fun
+assert_exit_code 255
+
+# --verify-hash: hashes from the output of "seqkit fx2tab -n -i -s"
+echo -e ">a\nACGT\n>b\nGGCC\n>c\nTT" > t.hash.fa
+$app fx2tab -n -i -s t.hash.fa > t.hash.tsv
+run seq_verify_hash $app seq --verify-hash t.hash.tsv t.hash.fa
+assert_exit_code 0
+assert_equal $(cat $STDOUT_FILE | tr "\t" , | paste -sd,) "file,id,status,expected,observed"
+
+# comment lines and blank lines are ignored; sequences are compared in lower case
+(echo "# c"; echo; awk '$1 != "c"' t.hash.tsv) > t.hash2.tsv
+fun(){ echo -e ">a\nacgt\n>b\nGGCA\n>c\nTT" | $app seq --verify-hash t.hash2.tsv; }
+run seq_verify_hash_mismatch fun
+assert_exit_code 1
+assert_equal $(cut -f 2,3 $STDOUT_FILE | tr "\t" , | paste -sd,) "id,status,b,mismatch,c,extra"
+
+fun(){ echo -e ">a\nACGT" | $app seq --verify-hash t.hash2.tsv --hash-case-sensitive; }
+run seq_verify_hash_case_sensitive fun
+assert_exit_code 1
+assert_equal $(cut -f 2,3 $STDOUT_FILE | tr "\t" , | paste -sd,) "id,status,a,mismatch,b,missing"
+rm t.hash.fa t.hash.tsv t.hash2.tsv
+
+# paired-end reads are reversed and complemented mate by mate
+mkdir -p t.seqpe
+echo -e "@r1/1\nACGG\n+\nABCD\n@r2/1\nTT\n+\nII" > t.seqpe/r_1.fq
+echo -e "@r1/2\nAAC\n+\nEFG\n@r2/2\nGA\n+\nI#" > t.seqpe/r_2.fq
+run seq_paired_revcom $app seq -1 t.seqpe/r_1.fq -2 t.seqpe/r_2.fq -r -p -t dna
+assert_equal "$(cat t.seqpe/r_1.seq.fq | paste -sd,)" "@r1/1,CCGT,+,DCBA,@r2/1,AA,+,II"
+assert_equal "$(cat t.seqpe/r_2.seq.fq | paste -sd,)" "@r1/2,GTT,+,GFE,@r2/2,TC,+,#I"
+
+# names are kept in the directory of -O/--out-dir
+run seq_paired_outdir $app seq -1 t.seqpe/r_1.fq -2 t.seqpe/r_2.fq -r -O t.seqpe/out
+assert_equal $(ls t.seqpe/out | paste -sd,) "r_1.fq,r_2.fq"
+
+run seq_paired_outfile $app seq -1 t.seqpe/r_1.fq -2 t.seqpe/r_2.fq -r -o t.seqpe/o.fq
+assert_exit_code 255
+
+# unpaired reads
+echo -e "@r3/2\nAAC\n+\nEFG\n@r2/2\nGA\n+\nI#" > t.seqpe/u_2.fq
+run seq_paired_unpaired $app seq -1 t.seqpe/r_1.fq -2 t.seqpe/u_2.fq -r -O t.seqpe/out2
+assert_exit_code 255
+assert_in_stderr "unpaired reads: r1/1 and r3/2"
+rm -r t.seqpe
+
 # ------------------------------------------------------------
 #                         subseq
 # ------------------------------------------------------------
@@ -144,6 +291,43 @@ fun () {
 run subseq_region fun
 assert_equal N $(cat $STDOUT_FILE)
 
+# --translate respects the frame of GTF features
+echo -e ">chr1\nAAATGAAACCCGGGTTTAAA" > t.gtf.fa
+echo -e "chr1\ttest\tCDS\t3\t14\t.\t+\t1\tgene_id \"g1\";" > t.gtf
+echo -e "chr1\ttest\tCDS\t3\t14\t.\t+\t.\tgene_id \"g2\";" >> t.gtf
+echo -e "chr1\ttest\tCDS\t3\t17\t.\t-\t2\tgene_id \"g3\";" >> t.gtf
+run subseq_gtf_translate_frame $app subseq --gtf t.gtf t.gtf.fa --translate
+assert_equal $($app seq -s $STDOUT_FILE | paste -sd,) "*NP,MKPG,TRVS"
+rm t.gtf t.gtf.fa*
+
+# --protein-region
+fun() {
+    echo -e ">cds\nATGAAACCCGGGTTTTAA" | $app subseq --protein-region 2:4 --translate
+}
+run subseq_protein_region fun
+assert_equal $($app seq -s $STDOUT_FILE) "KPG"
+
+# --protein-region is not compatible with --around-motif
+run subseq_protein_region_around_motif $app subseq --protein-region 2:4 --around-motif ACGT tests/hairpin.fa
+assert_exit_code 255
+assert_in_stderr "not compatible"
+
+# --emit-bed: GTF features in BED6, with the last attribute kept
+printf 'chr1\tt\tCDS\t2\t5\t.\t+\t0\tgene_id "g1"; transcript_id "t1";\nchr1\tt\tCDS\t10\t6\t.\t-\t0\tgene_id "g2"; transcript_id "t2"\n' > t.gtf
+fun(){ $app subseq --gtf t.gtf --emit-bed -u 1; }
+run subseq_gtf_emit_bed fun
+assert_equal $(cat $STDOUT_FILE | tr "\t" , | paste -sd,) "chr1,0,5,g1|t1,0,+,chr1,5,11,g2|t2,0,-"
+rm t.gtf
+
+# --around-motif: windows of matches on both strands, clamped at sequence ends
+fun(){ echo -e ">chr1\nAAGGATCAATTGATCCTT" | $app subseq --around-motif GGATC --flank 3; }
+run subseq_around_motif fun
+assert_equal "$(cat $STDOUT_FILE | paste -sd,)" ">chr1_1-10:+_flank:3 GGATC:3-7,AAGGATCAAT,>chr1_9-18:-_flank:3 GGATC:12-16,AAGGATCAAT"
+
+fun(){ echo -e ">chr1\nAAGGATCAA" | $app subseq --around-motif GGATC --flank 1 -r 1:3; }
+run subseq_around_motif_region fun
+assert_exit_code 255
+
 # ------------------------------------------------------------
 # gtf
 # seq=">seq\nacgtnACGTN"
@@ -187,6 +371,32 @@ fun () {
 run sliding fun
 assert_equal $(echo -e "acgtn\nACGTN" | md5sum | cut -d" " -f 1) $(cat $STDOUT_FILE | md5sum | cut -d" " -f 1)
 
+# -T/--tab with --gc, the end of the window crossing the origin is smaller than the start
+fun () {
+    testseq | $app sliding -W 4 -s 3 -T -H --gc -C
+}
+run sliding_tab_circular fun
+assert_equal $(cat $STDOUT_FILE | tr "\t" , | paste -sd,) "id,start,end,seq,GC,seq,1,4,acgt,50.00,seq,4,7,tnAC,25.00,seq,7,10,CGTN,50.00,seq,10,3,Nacg,50.00"
+
+# circular windows longer than the sequence, bases are read from the start again
+fun () {
+    echo -e ">s\nACGTA" | $app sliding -W 7 -s 2 -T -C
+}
+run sliding_tab_circular_long_window fun
+assert_equal $(cat $STDOUT_FILE | tr "\t" , | paste -sd,) "s,1,2,ACGTAAC,s,3,4,GTAACGT,s,5,1,AACGTAA"
+
+fun () {
+    echo -e ">s\nACGTA" | $app sliding -W 12 -s 4 -T -C
+}
+run sliding_tab_circular_longer_window fun
+assert_equal $(cat $STDOUT_FILE | tr "\t" , | paste -sd,) "s,1,2,ACGTAACGTAAC,s,5,1,AACGTAACGTAA"
+
+# qualities of windows for FASTQ
+fun () {
+    echo -e "@s\nACGTAC\n+\nIII555" | $app sliding -W 3 -s 3 -T
+}
+run sliding_tab_fastq fun
+assert_equal $(cat $STDOUT_FILE | tr "\t" , | paste -sd,) "s,1,3,ACG,III,s,4,6,TAC,555"
 
 # ------------------------------------------------------------
 #                            fq2fa, fx2tab, tab2fx
@@ -237,6 +447,80 @@ float_gt $RQ 0.99
 assert_equal $? 1
 rm seqkit.tsv corr_len.tsv corr_qual.tsv
 
+# fa2fq: synthetic qualities, and per-record qualities of --qual-from-file
+echo -e "r1\tphred\t30\nr2\tstring\t!#%" > t.quals
+fun(){ echo -e ">r1\nACGT\n>r2\nACG\n>r3\nAC" | $app fa2fq --qual-from-file t.quals; }
+run fa2fq_qual_from_file fun
+assert_equal $(cat $STDOUT_FILE | paste -sd,) "@r1,ACGT,+,????,@r2,ACG,+,!#%,@r3,AC,+,II"
+
+# a numeric quality string with the same length of the sequence
+echo -e "r1\tstring\t30" > t.quals
+fun(){ echo -e ">r1\nAC" | $app fa2fq --qual-from-file t.quals; }
+run fa2fq_qual_from_file_string fun
+assert_equal $(cat $STDOUT_FILE | paste -sd,) "@r1,AC,+,30"
+
+echo -e "r1\t30" > t.quals
+fun(){ echo -e ">r1\nAC" | $app fa2fq --qual-from-file t.quals; }
+run fa2fq_qual_from_file_error fun
+assert_exit_code 255
+rm t.quals
+
+# fx2tab -c/--columns: columns in the given order, the ones of other flags are appended
+fun(){ echo -e ">a x\nACGGN\n>b\nAT" | $app fx2tab -c id,length,gc,count:N -H; }
+run fx2tab_columns fun
+assert_equal $(cat $STDOUT_FILE | tr "\t" , | paste -sd,) "#id,length,GC,N,a,5,60.00,1,b,2,0.00,0"
+
+fun(){ echo -e ">a x\nACGGN\n>b\nAT" | $app fx2tab -c seq,id -l; }
+run fx2tab_columns_appended fun
+assert_equal $(cat $STDOUT_FILE | tr "\t" , | paste -sd,) "ACGGN,a,5,AT,b,2"
+
+# names of columns are the same as names of flags
+fun(){ echo -e "@a\nACGG\n+\nIII5" | $app fx2tab -c id,gc-skew,avg-qual,seq-hash; }
+run fx2tab_columns_flag_names fun
+assert_equal $(cat $STDOUT_FILE | tr "\t" , | paste -sd,) "a,33.33,25.89,9fcc813f138aec044851886bbe862c32"
+
+fun(){ echo -e "@a\nACGG\n+\nIII5" | $app fx2tab -c avg.qual; }
+run fx2tab_columns_dot_name fun
+assert_exit_code 255
+
+fun(){ echo -e ">a\nACGT" | $app fx2tab -c foo; }
+run fx2tab_columns_invalid fun
+assert_exit_code 255
+
+# fq2fa --min-entropy: ambiguous bases are excluded
+fun(){ echo -e "@a\nAAAAAA\n+\nIIIIII\n@b\nACGT\n+\nIIII\n@c\nACNNNN\n+\nIIIIII\n@d\nAACC\n+\nIIII\n@e\nNNNN\n+\nIIII" | $app fq2fa --min-entropy 1 | $app seq -n; }
+run fq2fa_min_entropy fun
+assert_equal $(cat $STDOUT_FILE | paste -sd,) "b,c,d"
+
+# --stream-seq: CRLF line endings and empty records in the streaming mode
+fun(){ echo -ne ">a x\r\nACGT\r\nac\r\n\r\n>b\n\n>c\nNN\n" | $app fx2tab --stream-seq; }
+run fx2tab_stream_seq fun
+assert_equal "$(cat $STDOUT_FILE | tr "\t" , | paste -sd";")" "a x,ACGTac,;b,,;c,NN,"
+
+# other columns are not supported
+fun(){ echo -e ">a\nACGT" | $app fx2tab --stream-seq -l; }
+run fx2tab_stream_seq_columns fun
+assert_exit_code 255
+
+# --tm
+fun(){
+    echo -e ">a\nACGTACGTACGTACGTACGT\n>b\nA" | $app fx2tab -n --tm -H
+}
+run fx2tab_tm fun
+assert_equal $(cat $STDOUT_FILE | tr "\t" , | paste -sd,) "#name,Tm,a,56.13,b,NaN"
+
+fun(){
+    echo -e ">c\nACGNACGT" | $app fx2tab -n --tm
+}
+run fx2tab_tm_ambiguous fun
+assert_exit_code 255
+
+fun(){
+    echo -e ">c\nACGNACGT" | $app fx2tab -n --tm --tm-ambiguous average
+}
+run fx2tab_tm_ambiguous_average fun
+assert_equal $(cat $STDOUT_FILE | tr "\t" ,) "c,20.95"
+
 # ------------------------------------------------------------
 #                       grep
 # ------------------------------------------------------------
@@ -264,10 +548,143 @@ run grep_by_regexp_list $app grep -r -n -f list $file
 assert_equal $($app fx2tab $STDOUT_FILE | wc -l) $($app seq -n $file | grep -E "Homo|Mus" | wc -l)
 rm list
 
+# --merge-regexp gives the same result as checking regular expressions one by one
+echo -en "Homo\nMus\n^cel-.*-3p\n" > list
+run grep_merge_regexp $app grep -r -n --merge-regexp -f list $file
+assert_equal $(cat $STDOUT_FILE | md5sum | cut -d" " -f 1) $($app grep -r -n -f list $file | md5sum | cut -d" " -f 1)
+rm list
+
+# --literal: patterns with regular expression metacharacters are not allowed
+fun(){ echo -e ">a.1\nACGT\n>b\nACGT" | $app grep --literal -p a.1 -p b | $app seq -n; }
+run grep_literal fun
+assert_equal $(cat $STDOUT_FILE | paste -sd,) "a.1,b"
+
+fun(){ echo -e ">a\nACGT" | $app grep --literal -p "a*"; }
+run grep_literal_metachar fun
+assert_exit_code 255
+
+# -R/--region: the existing part of the region is searched for short records
+fun(){ echo -e ">a\nAAAAAAGGCC\n>b\nGGCC\n>c\nGGCCAAAAAA" | $app grep -s -P -p GGCC -R 1:6 | $app seq -n; }
+run grep_region fun
+assert_equal $(cat $STDOUT_FILE | paste -sd,) "b,c"
+
+# --skip-short: short records are not outputted, even with -v
+fun(){ echo -e ">a\nAAAAAAGGCC\n>b\nGGCC\n>c\nGGCCAAAAAA" | $app grep -s -P -p GGCC -R 1:6 --skip-short | $app seq -n; }
+run grep_region_skip_short fun
+assert_equal $(cat $STDOUT_FILE | paste -sd,) "c"
+
+fun(){ echo -e ">a\nAAAAAAGGCC\n>b\nGGCC\n>c\nGGCCAAAAAA" | $app grep -s -P -p GGCC -R 1:6 --skip-short -v | $app seq -n; }
+run grep_region_skip_short_invert fun
+assert_equal $(cat $STDOUT_FILE | paste -sd,) "a"
+
+# --and: all sequence patterns should be found, on either strand
+fun(){ echo -e ">a\nAAAACCCC\n>b\nAAAAGGGT\n>c\nGGGGTTTT" | $app grep -s -p AAAA -p GGGG --and | $app seq -n; }
+run grep_and fun
+assert_equal $(cat $STDOUT_FILE | paste -sd,) "a,c"
+
+fun(){ echo -e ">a\nAAAAGGGG\n>b\nAAAACCCC\n>c\nGGGGTTTT" | $app grep -s -P -p AAAA -p GGGG --and -v | $app seq -n; }
+run grep_and_invert fun
+assert_equal $(cat $STDOUT_FILE | paste -sd,) "b,c"
+
+# --by-desc: IDs are not matched
+fun(){ echo -e ">plasmid1 chromosome\nA\n>chr1 plasmid pX\nC\n>nodesc\nG" | $app grep --by-desc -r -i -p PLASMID | $app seq -n; }
+run grep_by_desc fun
+assert_equal "$(cat $STDOUT_FILE | paste -sd,)" "chr1 plasmid pX"
+
+# the whole description is matched without -r, it starts from the end of the match of --id-regexp
+fun(){ echo -e ">a pX y\nA\n>b|x pX\nG\n>pX\nC" | $app grep --by-desc --id-regexp '^(\w+)\|?' -p "x pX" | $app seq -n; }
+run grep_by_desc_id_regexp fun
+assert_equal "$(cat $STDOUT_FILE | paste -sd,)" "b|x pX"
+
+# --reject-file: the input is partitioned into matched and other records
+$app head -n 100 $file | $app seq -n -i > list
+fun(){ $app grep -f list $file --reject-file t.rejected.fa.gz; }
+run grep_reject_file fun
+assert_equal $($app seq -n $STDOUT_FILE | wc -l) $(cat list | wc -l)
+assert_equal $($app grep -f list -v $file | md5sum | cut -d" " -f 1) $($app seq t.rejected.fa.gz | md5sum | cut -d" " -f 1)
+rm list t.rejected.fa.gz
+
+# --illumina-tile: new and old styles of Illumina read IDs, malformed ones are skipped, even with -v
+echo -e "@M1:7:FC:1:1101:100:200 1:N:0\nA\n+\nI\n@M1:7:FC:1:2101:100:200 1:N:0\nC\n+\nI\n@HWI:1:1101:5:6\nG\n+\nI\n@bad\nT\n+\nI" > t.tile.fq
+run grep_illumina_tile $app grep --illumina-tile 1101 t.tile.fq
+assert_equal $($app seq -n -i $STDOUT_FILE | paste -sd,) "M1:7:FC:1:1101:100:200,HWI:1:1101:5:6"
+assert_in_stderr "1 records with malformed Illumina read IDs skipped"
+
+run grep_illumina_tile_invert $app grep --illumina-tile 1101 -v t.tile.fq
+assert_equal $($app seq -n -i $STDOUT_FILE | paste -sd,) "M1:7:FC:1:2101:100:200"
+rm t.tile.fq
+
+# --bed and --bed-report: overlapping intervals are merged for covered bases
+echo -e "chr1\t0\t5\nchr1\t3\t8\nchr3\t1\t2\nchr1\t10\t12" > t.bed
+fun(){ echo -e ">chr1\nACGTACGTACGT\n>chr2\nAA\n>chr3\nCC" | $app grep --bed t.bed --bed-report t.bed.report | $app seq -n; }
+run grep_bed fun
+assert_equal $(cat $STDOUT_FILE | paste -sd,) "chr1,chr3"
+assert_equal $(cat t.bed.report | tr "\t" , | paste -sd,) "id,length,intervals,covered_bases,chr1,12,3,10,chr3,2,1,1"
+rm t.bed t.bed.report
+
+# --max-matches: stop after outputting N records, also for non-matching ones with -v
+run grep_max_matches $app grep -r -p "^hsa" --max-matches 3 $file
+assert_equal $($app seq -n -i $STDOUT_FILE | paste -sd,) $($app grep -r -p "^hsa" $file | $app head -n 3 | $app seq -n -i | paste -sd,)
+
+run grep_max_matches_invert $app grep -r -p "^hsa" -v --max-matches 2 $file
+assert_equal $($app seq -n -i $STDOUT_FILE | paste -sd,) $($app grep -r -p "^hsa" -v $file | $app head -n 2 | $app seq -n -i | paste -sd,)
+
+# --in-refs: query sequences are searched on both strands of references
+echo -e ">ref1\nACGTTGCAAGGT\n>ref2\nTTTTCCCC" > t.refs.fa
+echo -e ">q1\nGTTGC\n>q2\nCCTTGC\n>q3\nGGAAAA\n>q4\nggggaaaa\n>q5\nGTAGC" > t.query.fa
+run grep_in_refs $app grep --in-refs t.refs.fa t.query.fa --in-refs-report t.refs.tsv
+assert_equal $($app seq -n $STDOUT_FILE | paste -sd,) "q1,q2,q3"
+assert_equal $(cat t.refs.tsv | tr "\t" , | paste -sd,) "id,ref,strand,start,end,mismatches,q1,ref1,+,3,7,0,q2,ref1,-,6,11,0,q3,ref2,-,1,6,0"
+
+run grep_in_refs_positive_strand $app grep --in-refs t.refs.fa t.query.fa -P -i
+assert_equal $($app seq -n $STDOUT_FILE | paste -sd,) "q1"
+
+run grep_in_refs_ignore_case $app grep --in-refs t.refs.fa t.query.fa -i
+assert_equal $($app seq -n $STDOUT_FILE | paste -sd,) "q1,q2,q3,q4"
+
+run grep_in_refs_mismatch_invert $app grep --in-refs t.refs.fa t.query.fa -m 1 -v
+assert_equal $($app seq -n $STDOUT_FILE | paste -sd,) "q4"
+
+run grep_in_refs_pattern $app grep --in-refs t.refs.fa t.query.fa -p A
+assert_exit_code 255
+rm t.refs.fa t.query.fa t.refs.tsv
+
 # ------------------------------------------------------------
 #                       locate
 # ------------------------------------------------------------
 
+# --best-only: the fewest mismatches, and then the leftmost
+fun() {
+    echo -e ">s\nACGGTACCTACGTTTT" | $app locate -p ACGT -m 1 --best-only -P
+}
+run locate_best_only fun
+assert_equal $(sed -n 2p $STDOUT_FILE | cut -f 5,6,8 | tr "\t" ,) "10,13,0"
+assert_equal $(cat $STDOUT_FILE | wc -l) 2
+
+# the same with multiple threads
+fun() {
+    $app locate -p ACGTA,TTTA -m 1 --best-only -j 4 tests/hairpin.fa | md5sum
+}
+run locate_best_only_threads fun
+assert_equal $(cat $STDOUT_FILE) $($app locate -p ACGTA,TTTA -m 1 --best-only -j 1 tests/hairpin.fa | md5sum)
+
+# --density
+fun() {
+    echo -e ">s\nAAAAACCCCCAAAAA" | $app locate -p AA --density -P --window 5
+}
+run locate_density fun
+assert_equal $(cut -f 3-5 $STDOUT_FILE | sed 1d | tr "\t" , | paste -sd ';') "1,5,4;6,10,0;11,15,4"
+
+fun() {
+    echo -e ">s\nAAAAACCCCCAAAAA" | $app locate -p AA --density -P --non-overlapping
+}
+run locate_density_non_overlapping fun
+assert_equal $(sed -n 2p $STDOUT_FILE | cut -f 5) 4
+
+# --flank: flanks could be shorter near the ends, and are reverse complemented on the negative strand
+fun(){ echo -e ">s\nAACCGGTTAC" | $app locate -p CGG --flank 3; }
+run locate_flank fun
+assert_equal $(cat $STDOUT_FILE | sed 1d | tr "\t" , | paste -sd,) "s,CGG,CGG,+,4,6,CGG,AAC,TTA,3,3,s,CGG,CGG,-,3,5,CGG,AAC,TT,3,2"
 
 # ------------------------------------------------------------
 #                       rmdup
@@ -294,6 +711,82 @@ run "rmdup -s" fun
 assert_in_stderr "9 duplicated records removed"
 assert_equal $(cat $STDOUT_FILE | md5sum | cut -d" " -f 1) $(testseq | md5sum | cut -d" " -f 1)
 
+# rmdup with --max-mem spills hash values to disk, with the same result
+file=tests/reads_1.fq.gz
+tmpdir=tests/rmdup_tmp
+mkdir -p $tmpdir
+run rmdup_max_mem $app rmdup -s --max-mem 1K --tmp-dir $tmpdir $file
+assert_in_stderr "spilled to"
+assert_equal $($app rmdup -s $file | $app seq -n | md5sum) $(cat $STDOUT_FILE | $app seq -n | md5sum)
+assert_equal $(ls $tmpdir | wc -l) 0
+
+# temporary files are removed on errors
+fun() {
+    (zcat $file; echo -e "@bad\nACGT\n+\nII") | $app rmdup -s --max-mem 1K --tmp-dir $tmpdir
+}
+run rmdup_max_mem_error fun
+assert_in_stderr "unequal sequence and quality"
+assert_equal $(ls $tmpdir | wc -l) 0
+rm -r $tmpdir
+
+# rmdup: duplicated read pairs
+echo -e "@r1/1\nACGT\n+\nIIII\n@r2/1\nACGT\n+\nIIII\n@r3/1\nACGT\n+\nIIII" > t_1.fq
+echo -e "@r1/2\nTTGG\n+\nIIII\n@r2/2\nTTGG\n+\nIIII\n@r3/2\nTTGA\n+\nIIII" > t_2.fq
+fun(){ $app rmdup -1 t_1.fq -2 t_2.fq --quiet; }
+run rmdup_paired fun
+assert_equal $($app seq -n t_1.rmdup.fq | paste -sd,)/$($app seq -n t_2.rmdup.fq | paste -sd,) "r1/1,r3/1/r1/2,r3/2"
+rm t_1.rmdup.fq t_2.rmdup.fq
+
+# -o/--out-file is not supported for paired reads
+fun(){ $app rmdup -1 t_1.fq -2 t_2.fq -o t.fq; }
+run rmdup_paired_out_file fun
+assert_exit_code 255
+rm -f t_1.fq t_2.fq t_1.rmdup.fq t_2.rmdup.fq t.fq
+
+# --size-out
+fun(){
+    echo -e ">f;size=9\nGG\n>c\nTTTT\n>a\nACGT\n>b\nACGT\n>e\nAAAA\n>d\nacgt" \
+        | $app rmdup -s -i --size-out --sort-by-size --cluster-file t.c | $app seq -n
+}
+run rmdup_size_out fun
+assert_equal $(cat $STDOUT_FILE | paste -sd,) "a;size=3,c;size=2,f;size=1"
+assert_equal $(cat t.c | tr "\t" , | paste -sd,) "a,a,a,b,a,d,c,c,c,e,f;size=9,f;size=9"
+rm t.c
+
+fun(){
+    echo -e ">c\nTTTT\n>e\nAAAA" | $app rmdup -s -P --size-out | $app seq -n
+}
+run rmdup_size_out_positive_strand fun
+assert_equal $(cat $STDOUT_FILE | paste -sd,) "c;size=1,e;size=1"
+
+fun(){
+    echo -e ">c\nTTTT" | $app rmdup -s --sort-by-size
+}
+run rmdup_sort_by_size_without_size_out fun
+assert_exit_code 255
+
+# --sorted: adjacent duplicates of sorted input are removed
+fun(){ echo -e ">a\nAC\n>a\nGG\n>b\nTT\n>c\nAA\n>c\nCC\n>c\nGG" | $app rmdup --sorted -D t.dup.txt | $app fx2tab | cut -f 1,2; }
+run rmdup_sorted fun
+assert_equal $(cat $STDOUT_FILE | tr "\t" , | paste -sd,) "a,AC,b,TT,c,AA"
+assert_equal "$(cat t.dup.txt | tr "\t" , | paste -sd ';')" "2,a, a;3,c, c, c"
+rm t.dup.txt
+
+# descending order, with --size-out
+fun(){ echo -e ">c\nAC\n>b\nGG\n>b\nTT" | $app rmdup --sorted --size-out | $app seq -n; }
+run rmdup_sorted_descending fun
+assert_equal $(cat $STDOUT_FILE | paste -sd,) "c;size=1,b;size=2"
+
+# only the positive strand is compared for -s
+fun(){ echo -e ">x\nAA\n>y\nAA\n>z\nTT\n>w\nTT" | $app rmdup --sorted -s --size-out | $app seq -n; }
+run rmdup_sorted_by_seq fun
+assert_equal $(cat $STDOUT_FILE | paste -sd,) "x;size=2,z;size=2"
+
+fun(){ echo -e ">a\nAC\n>c\nGG\n>b\nTT" | $app rmdup --sorted; }
+run rmdup_sorted_unsorted fun
+assert_exit_code 255
+assert_in_stderr "input is not sorted, found at record: b"
+
 # ------------------------------------------------------------
 #                       common
 # ------------------------------------------------------------
@@ -327,12 +820,160 @@ assert_equal $(ls stdin.split/* | wc -l  | tail -n 1) $(testseq | $app seq -n -i
 assert_equal $(cat stdin.split/* | $app stat -a | md5sum | cut -d" " -f 1) $(testseq | $app stat -a | md5sum | cut -d" " -f 1)
 rm -r stdin.split
 
+# split2 -r/--by-region: overlapping regions of long sequences, with zero-padded coordinates
+fun(){ echo -e ">chr1\nAAAAACCCCCGGGGGTT" | $app split2 -r 8:3 -O t.split2; }
+run split2_by_region fun
+assert_equal $(ls t.split2 | paste -sd,) "stdin.chr1_01-08.fasta,stdin.chr1_06-13.fasta,stdin.chr1_11-17.fasta"
+assert_equal $(cat t.split2/* | $app fx2tab | cut -f 1,2 | tr "\t" , | paste -sd,) "chr1:01-08,AAAAACCC,chr1:06-13,CCCCCGGG,chr1:11-17,GGGGGTT"
+rm -r t.split2
+
+# -b/--by-bp: a part is closed once its bases reach the size, records are kept whole
+fun() {
+    echo -e ">a\nAAAA\n>b\nCC\n>c\nGGGGGG\n>d\nT" | $app split -b 5 -O t.split
+}
+run split_by_bp fun
+assert_equal $(ls t.split | paste -sd,) "stdin.part_001.fasta,stdin.part_002.fasta,stdin.part_003.fasta"
+assert_equal $(for f in t.split/*; do $app seq -n $f | paste -sd+; done | paste -sd,) "a+b,c,d"
+rm -r t.split
+
+# --bed: intervals sharing a name are written to the same file, with --strand-aware
+echo -e ">chr1\nACGTACGTAACC" > t.split.fa
+echo -e "chr1\t0\t4\tgA\t0\t+\nchr1\t8\t12\tgA\t0\t-\nchr1\t2\t6" > t.split.bed
+fun(){ $app split t.split.fa --bed t.split.bed --strand-aware -O t.split; }
+run split_bed fun
+assert_equal $(ls t.split | paste -sd,) "chr1_3-6.fasta,gA.fasta"
+assert_equal "$(cat t.split/gA.fasta | paste -sd,)" ">chr1_1-4:+ gA,ACGT,>chr1_9-12:- gA,GGTT"
+assert_equal $(cat t.split/chr1_3-6.fasta | paste -sd,) ">chr1_3-6:.,GTAC"
+rm -r t.split t.split.*
+
+# --group-file: records not in the file are written to the group "unassigned"
+echo -e "a\tg1\nb\tg2\nc\tg1" > t.split.groups
+fun(){
+    echo -e ">a x\nAAAA\n>b\nCC\n>c\nGG\n>d\nT" | $app split --group-file t.split.groups --max-open-files 1 -O t.split
+}
+run split_group_file fun
+assert_equal $(ls t.split | paste -sd,) "stdin.g1.fasta,stdin.g2.fasta,stdin.unassigned.fasta"
+assert_equal "$(for f in t.split/*; do $app seq -n $f | paste -sd+; done | paste -sd,)" "a x+c,b,d"
+assert_in_stderr "files were reopened 1 times"
+rm -r t.split
+
+# compressed files are appended with new streams after being reopened
+echo -e ">a x\nAAAA\n>b\nCC\n>c\nGG\n>d\nT" | gzip -c > t.split.fa.gz
+fun(){
+    $app split t.split.fa.gz --group-file t.split.groups --max-open-files 1 --drop-unassigned --group-file-prefix p_ -O t.split
+}
+run split_group_file_drop_unassigned fun
+assert_equal $(ls t.split | paste -sd,) "p_g1.fa.gz,p_g2.fa.gz"
+assert_equal $($app seq -i -n t.split/p_g1.fa.gz | paste -sd,) "a,c"
+rm -r t.split
+
+run split_group_file_by_size $app split t.split.fa.gz --group-file t.split.groups -s 2 -O t.split
+assert_exit_code 255
+rm -fr t.split t.split.*
+
 # ------------------------------------------------------------
 #                       sample
 # ------------------------------------------------------------
 file=tests/hairpin.fa
 assert_equal $(cat $file | $app sample -p 0.1 | $app stat -a | md5sum | cut -d" " -f 1) $(cat $file | $app sample -p 0.1 | $app stat -a | md5sum | cut -d" " -f 1)
 
+# --per-group with --groups and --early-stop
+fun() {
+    echo -e ">A_1\nA\n>B_1\nC\n>A_2\nG\n>B_2\nT\n>C_1\nA"
+}
+fun > t.group.fa
+run sample_per_group $app sample --per-group 2 --group-regexp '^(\w+)_\d+' --groups A --early-stop t.group.fa
+assert_equal $($app seq -n $STDOUT_FILE | paste -sd,) "A_1,A_2"
+
+# unsatisfied groups are not complete when the next group starts, without --sorted
+run sample_per_group_unsorted $app sample --per-group 3 --group-regexp '^(\w+)_\d+' --groups A,B --early-stop t.group.fa
+assert_equal $($app seq -n $STDOUT_FILE | paste -sd,) "A_1,B_1,A_2,B_2"
+
+# --sorted
+fun() {
+    echo -e ">A_1\nA\n>A_2\nG\n>B_1\nC\n>B_2\nT\n>C_1\nA"
+}
+fun > t.group.fa
+run sample_per_group_sorted $app sample --per-group 3 --group-regexp '^(\w+)_\d+' --groups A --early-stop --sorted t.group.fa
+assert_equal $($app seq -n $STDOUT_FILE | paste -sd,) "A_1,A_2"
+rm t.group.fa
+
+# --prob-file: per-record probabilities
+echo -e "r1\t1\nr2\t0" > t.probs
+fun(){ echo -e ">r1\nA\n>r2\nC\n>r3\nG" | $app sample --prob-file t.probs --default-prob 1 2>/dev/null | $app seq -n; }
+run sample_prob_file fun
+assert_equal $(cat $STDOUT_FILE | paste -sd,) "r1,r3"
+
+# NaN is not a valid probability
+echo -e "r1\tNaN" > t.probs
+fun(){ echo -e ">r1\nA" | $app sample --prob-file t.probs; }
+run sample_prob_file_nan fun
+assert_exit_code 255
+rm t.probs
+
+# --folds: records are partitioned into disjoint files of nearly equal sizes
+fun(){ echo -e ">r1\nA\n>r2\nC\n>r3\nG\n>r4\nT\n>r5\nA" | $app sample --folds 2 --out-prefix t_fold --quiet; }
+run sample_folds fun
+assert_equal $(cat t_fold_1.fasta t_fold_2.fasta | $app seq -n | sort | paste -sd,)/$(for f in t_fold_1.fasta t_fold_2.fasta; do $app seq -n $f | wc -l; done | sort -n | paste -sd,) "r1,r2,r3,r4,r5/2,3"
+rm t_fold_*
+
+# --folds with read pairs, mate suffixes are ignored
+echo -e "@r1/1\nA\n+\nI\n@r2/1\nC\n+\nI" > t_1.fq
+echo -e "@r1/2\nG\n+\nI\n@r2/2\nT\n+\nI" > t_2.fq
+fun(){ $app sample --folds 2 --read1 t_1.fq --read2 t_2.fq --out-prefix t_fold --quiet; }
+run sample_folds_paired fun
+assert_equal $($app seq -n t_fold_1_R1.fq | sed 's/\/1$//')$($app seq -n t_fold_2_R1.fq | sed 's/\/1$//') $($app seq -n t_fold_1_R2.fq | sed 's/\/2$//')$($app seq -n t_fold_2_R2.fq | sed 's/\/2$//')
+rm t_fold_*
+
+# positional arguments are not allowed for read pairs
+fun(){ $app sample --folds 2 --read1 t_1.fq --read2 t_2.fq t_1.fq; }
+run sample_folds_paired_args fun
+assert_exit_code 255
+rm t_1.fq t_2.fq
+
+# --target-size: close to the target, and compressed files are read twice with the same result
+run sample_target_size $app sample --target-size 1M -s 3 $file
+assert_equal $(awk -v n=$(cat $STDOUT_FILE | wc -c) 'BEGIN { print (n > 0.9 * 1048576 && n < 1.1 * 1048576) }') 1
+assert_equal $(cat $STDOUT_FILE | md5sum | cut -d" " -f 1) $($app sample --target-size 1M -s 3 $file.gz | md5sum | cut -d" " -f 1)
+
+# the whole input is outputted for a larger target
+run sample_target_size_large $app sample --target-size 100M $file.gz
+assert_equal $(cat $STDOUT_FILE | md5sum | cut -d" " -f 1) $(cat $file | md5sum | cut -d" " -f 1)
+
+fun(){ cat $file | $app sample --target-size 1M; }
+run sample_target_size_stdin fun
+assert_exit_code 255
+
+# --duration: all records are sampled before the end of input
+fun(){ echo -e ">a\nA\n>b\nC\n>c\nG" | $app sample -n 10 --duration 10s; }
+run sample_duration_end_of_input fun
+assert_equal $($app seq -n $STDOUT_FILE | paste -sd,) "a,b,c"
+assert_in_stderr "stopped by end of input"
+
+# the record being read on stopping is discarded
+fun(){ (echo -e ">a\nA\n>b\nC"; sleep 2) | $app sample -n 10 --duration 1s; }
+run sample_duration_timeout fun
+assert_equal $($app seq -n $STDOUT_FILE | paste -sd,) "a"
+assert_in_stderr "stopped by timeout"
+
+# the input is kept open by a writer in the background, which is killed after the signal
+fun(){
+    mkfifo t.sample.fifo
+    (echo -e ">a\nA\n>b\nC\n>c"; sleep 30) > t.sample.fifo &
+    timeout -s INT 3 $app sample -p 1 --duration 1h t.sample.fifo
+    kill %1
+}
+run sample_duration_signal fun
+assert_equal $($app seq -n $STDOUT_FILE | paste -sd,) "a,b"
+assert_in_stderr "stopped by signal"
+rm t.sample.fifo
+
+fun(){ echo -e ">a\nA" | $app sample -n 1 --duration 0s; }
+run sample_duration_invalid fun
+assert_exit_code 255
+
+run sample_duration_two_pass $app sample -n 1 --duration 1s -2 $file
+assert_exit_code 255
 
 # ------------------------------------------------------------
 #                       head
@@ -341,6 +982,13 @@ assert_equal $(cat $file | $app sample -p 0.1 | $app stat -a | md5sum | cut -d"
 run head $app head -n 10 $file
 assert_equal 10 $(grep -c ">" $STDOUT_FILE)
 
+# -l/--lines: raw lines of compressed files, without parsing
+run head_lines $app head -l 6 tests/reads_1.fq.gz
+assert_equal $(cat $STDOUT_FILE | md5sum | cut -d" " -f 1) $(zcat tests/reads_1.fq.gz | head -n 6 | md5sum | cut -d" " -f 1)
+
+fun(){ echo -e "@broken\nACGT\n+\nII" | $app head -l 2; }
+run head_lines_malformed fun
+assert_equal $(cat $STDOUT_FILE | paste -sd,) "@broken,ACGT"
 
 # ------------------------------------------------------------
 #                       replace
@@ -350,6 +998,79 @@ testseq() {
 }
 assert_equal $(testseq | $app replace -p e -r n | $app seq -n -i) snq
 
+# {rand:N} with capture groups
+fun() {
+    echo -e ">abc_1 x\nA\n>def_2\nC" | $app replace -p '^(\w+)_\d+' -r '$1{rand:4}'
+}
+run replace_rand_capture fun
+assert_equal $($app seq -n $STDOUT_FILE | grep -cE '^(abc[0-9A-Za-z]{4} x|def[0-9A-Za-z]{4})$') 2
+
+# random strings are unique
+fun() {
+    $app replace -p '.+' -r 'read_{rand:8}' tests/hairpin.fa | $app seq -n | sort | uniq | wc -l
+}
+run replace_rand_unique fun
+assert_equal $(cat $STDOUT_FILE) $(grep -c '^>' tests/hairpin.fa)
+
+# -s/--by-seq with capture variables, qualities are kept for FASTQ
+fun(){ echo -e "@s\nACGTTTTTACGT\n+\nIIIIIIIIIIII" | $app replace -s -p '(ACGT)TTTT(ACGT)' -r '${1}NNNN${2}'; }
+run replace_by_seq_fastq fun
+assert_equal $(cat $STDOUT_FILE | paste -sd,) "@s,ACGTNNNNACGT,+,IIIIIIIIIIII"
+
+# the sequence length of FASTQ records should not change
+fun(){ echo -e "@s\nACGTTTTTACGT\n+\nIIIIIIIIIIII" | $app replace -s -p 'TTTT' -r ''; }
+run replace_by_seq_fastq_length fun
+assert_exit_code 255
+
+# --if-miss: a template for names not matched by -p
+fun(){ echo -e ">abc_ok x\nA\n>def y\nC\n>ghi_ok\nG" | $app replace -p '^(\w+)_ok' -r '$1' --if-miss 'unmatched_{nr} $0' | $app seq -n; }
+run replace_if_miss fun
+assert_equal "$(cat $STDOUT_FILE | paste -sd,)" "abc x,unmatched_2 def y,ghi"
+
+# --normalize-id and --id-case: whitespace is trimmed and collapsed, descriptions are not changed
+fun(){ echo -e ">  Seq1 \t a   B  \nA\n>sEQ2\nC" | $app replace --normalize-id --id-case upper | $app seq -n; }
+run replace_normalize_id fun
+assert_equal "$(cat $STDOUT_FILE | paste -sd,)" "SEQ1 a B,SEQ2"
+
+# combined with -p/-r
+fun(){ echo -e ">  Seq1 \t a   B  \nA" | $app replace --normalize-id --id-case lower -p '^' -r 's1_' | $app seq -n; }
+run replace_normalize_id_pattern fun
+assert_equal "$(cat $STDOUT_FILE)" "s1_seq1 a B"
+
+fun(){ echo -e ">a\nA" | $app replace --id-case upper; }
+run replace_id_case_alone fun
+assert_exit_code 255
+
+# --id-range: out-of-range positions are clamped, with prefixes added by -p/-r
+fun(){ echo -e ">A123456789 d\nA\n>ab x\nC" | $app replace --id-range -6:-1 -p '^' -r 'S1_' | $app seq -n; }
+run replace_id_range fun
+assert_equal "$(cat $STDOUT_FILE | paste -sd,)" "S1_456789 d,S1_ab x"
+
+# IDs with empty ranges are kept unchanged
+fun(){ echo -e ">A123456789 d\nA\n>ab x\nC" | $app replace --id-range 5:20 | $app seq -n; }
+run replace_id_range_empty fun
+assert_equal "$(cat $STDOUT_FILE | paste -sd,)" "456789 d,ab x"
+
+# {kv:NAME}: values of multiple columns of the key-value file
+echo -e "id\tspecies\tstrain\ns1\tecoli\tK12\ns2\tbsub" > t.kv.tsv
+fun(){
+    echo -e ">s1 x\nA\n>s2\nC\n>s3\nG" | $app replace -p '^(\S+)' -r '{kv:species}_{kv:strain}' -k t.kv.tsv -m NA | $app seq -n
+}
+run replace_kv_columns fun
+assert_equal "$(cat $STDOUT_FILE | paste -sd,)" "ecoli_K12 x,bsub_NA,NA_NA"
+
+# names are left unchanged for missing columns with -U/--keep-untouch
+fun(){
+    echo -e ">s1 x\nA\n>s2\nC\n>s3\nG" | $app replace -p '^(\S+)' -r '{kv:species}_{kv:strain}' -k t.kv.tsv -U | $app seq -n
+}
+run replace_kv_columns_keep_untouch fun
+assert_equal "$(cat $STDOUT_FILE | paste -sd,)" "ecoli_K12 x,s2,s3"
+
+fun(){ echo -e ">s1\nA" | $app replace -p '^(\S+)' -r '{kv:foo}' -k t.kv.tsv; }
+run replace_kv_unknown_column fun
+assert_exit_code 255
+rm t.kv.tsv
+
 # ------------------------------------------------------------
 #                       rename
 # ------------------------------------------------------------
@@ -405,6 +1126,62 @@ assert_equal $(cat $file | $app stat -a | md5sum | cut -d" " -f 1) $(cat t.sort.
 assert_equal $(cat $file | $app stat -a | md5sum | cut -d" " -f 1) $(cat t.sort.s | $app stat -a | md5sum | cut -d" " -f 1)
 rm t.sort.*
 
+# sort --external with small --max-mem, the same result as in-memory sort
+fq=tests/reads_1.fq.gz
+tmpdir=tests/sort_tmp
+mkdir -p $tmpdir
+run sort_external $app sort -s --external --max-mem 10K --tmp-dir $tmpdir $fq
+assert_in_stderr "sorted run(s) merged"
+assert_equal $($app sort -s $fq | md5sum) $(cat $STDOUT_FILE | md5sum)
+assert_equal $(ls $tmpdir | wc -l) 0
+
+# temporary files are removed on errors
+fun() {
+    (zcat $fq; echo -e "@bad\nACGT\n+\nII") | $app sort -s --external --max-mem 10K --tmp-dir $tmpdir
+}
+run sort_external_error fun
+assert_in_stderr "unequal sequence and quality"
+assert_equal $(ls $tmpdir | wc -l) 0
+rm -r $tmpdir
+
+# sort -s -c/--canonical: both strands give the same key, ties are broken by IDs
+fun(){ echo -e ">a\nTTTT\n>b\nCCCC\n>c\nAAAA" | $app sort -s -c; }
+run sort_canonical fun
+assert_equal $(cat $STDOUT_FILE | $app fx2tab -n | paste -sd,) "a,c,b"
+
+# -K/--keys: multiple keys with directions, records with equal keys keep the input order
+fun(){ echo -e ">c\nAAA\n>a\nGG\n>b\nCCC\n>d\nTT" | $app sort -K length:desc,id:asc | $app seq -n; }
+run sort_keys fun
+assert_equal $(cat $STDOUT_FILE | paste -sd,) "b,c,a,d"
+
+fun(){ echo -e ">c\nAAA\n>a\nGG\n>b\nCCC\n>d\nTT" | $app sort -K length | $app seq -n; }
+run sort_keys_stable fun
+assert_equal $(cat $STDOUT_FILE | paste -sd,) "a,d,c,b"
+
+fun(){ echo -e ">a\nA" | $app sort -K length -l; }
+run sort_keys_incompatible fun
+assert_exit_code 255
+
+# -W/--window: all records are outputted, deterministically, at most N positions earlier
+fun(){
+    $app shuffle -W 100 -s 11 $file > t.shu.1
+    $app shuffle -W 100 -s 11 $file > t.shu.2
+}
+run shuffle_window fun
+assert_equal $(cat t.shu.1 | $app stat -a | md5sum | cut -d" " -f 1) $(cat $file | $app stat -a | md5sum | cut -d" " -f 1)
+assert_equal $(cat t.shu.1 | md5sum | cut -d" " -f 1) $(cat t.shu.2 | md5sum | cut -d" " -f 1)
+$app seq -n -i $file > t.shu.ids.0
+$app seq -n -i t.shu.1 > t.shu.ids.1
+assert_equal $(awk 'NR == FNR { i[$1] = FNR; next } i[$1] - FNR > 100' t.shu.ids.0 t.shu.ids.1 | wc -l) 0
+rm t.shu.*
+
+# --priority-file: given IDs first in the order of the file, absent ones are skipped
+echo -e "d\n# comment\nx\n\nb" > t.priority
+fun(){ echo -e ">c\nA\n>a\nG\n>b\nC\n>d\nT" | $app sort -l --priority-file t.priority | $app seq -n; }
+run sort_priority_file fun
+assert_equal $(cat $STDOUT_FILE | paste -sd,) "d,b,a,c"
+rm t.priority
+
 #-------------------------------------------------------------
 #                       bam
 #-------------------------------------------------------------
@@ -531,6 +1308,49 @@ cmp tests/bundler_stats_merged.tsv tests/bundler_stats_bulk.tsv
 assert_equal $? 0
 rm -fr tests/bundler_test tests/bundler_stats_merged.tsv tests/bundler_stats_bulk.tsv 
 
+# revcomp-bam: reads are restored in the original orientation
+fun(){ $app revcomp-bam $BAM | $app fx2tab -i | sort; }
+run revcomp_bam fun
+assert_equal $(cat $STDOUT_FILE | md5sum | cut -d" " -f 1) $($app fx2tab -i $PCS_FQ | sort | md5sum | cut -d" " -f 1)
+
+# values of BAM tags are appended to headers
+echo -e "@r1\nACG\n+\n555" | $app fq2ubam -r g1 -o t.rcbam.bam
+fun(){ $app revcomp-bam -T RG t.rcbam.bam | $app seq -n; }
+run revcomp_bam_tags fun
+assert_equal "$(cat $STDOUT_FILE)" "$(echo -e 'r1\tRG:Z:g1')"
+rm t.rcbam.bam
+
+# bam2fq: single-end reads are restored in the original orientation
+fun(){ $app bam2fq -e .fq -O t.bam2fq $BAM; }
+run bam2fq fun
+assert_equal $($app fx2tab -i t.bam2fq/reads.fq | sort | md5sum | cut -d" " -f 1) $($app fx2tab -i $PCS_FQ | sort | md5sum | cut -d" " -f 1)
+
+# a warning is given for the existing output directory without -f/--force
+fun(){ $app bam2fq -e .fq -O t.bam2fq $BAM; }
+run bam2fq_existed_outdir fun
+assert_in_stderr "outdir not empty: t.bam2fq"
+rm -r t.bam2fq
+
+# bam2fq --split-rg: reads without known read groups are "unassigned"
+fun(){ $app bam2fq --split-rg -e .fq -O t.bam2fq $BAM; }
+run bam2fq_split_rg fun
+assert_equal $(ls t.bam2fq | paste -sd,) "unassigned.fq"
+assert_in_stderr "reads without known read groups saved with the prefix: unassigned"
+rm -r t.bam2fq
+
+echo -e "@r1\nACG\n+\n555\n@r2\nTT\n+\nII" | $app fq2ubam -r g1 -o t.bam2fq.bam
+fun(){ $app bam2fq --split-rg -e .fq -O t.bam2fq t.bam2fq.bam; }
+run bam2fq_split_rg_g1 fun
+assert_equal $(ls t.bam2fq | paste -sd,) "g1.fq"
+assert_equal $($app seq -n t.bam2fq/g1.fq | paste -sd,) "r1,r2"
+rm -r t.bam2fq t.bam2fq.bam
+
+# the BAM file can also be given with -b/--bam
+fun(){ $app bam2fq -e .fq -O t.bam2fq -b $BAM; }
+run bam2fq_bam_flag fun
+assert_equal $($app fx2tab -i t.bam2fq/reads.fq | sort | md5sum | cut -d" " -f 1) $($app fx2tab -i $PCS_FQ | sort | md5sum | cut -d" " -f 1)
+rm -r t.bam2fq
+
 # ------------------------------------------------------------
 #                       fish
 # ------------------------------------------------------------
@@ -728,3 +1548,693 @@ fun(){
 run faidx_region fun
 assert_equal $($app grep -p $ref $file | $app subseq -r 5:-5 | $app seq -s -w 0) $(cat $outFile | $app seq -s -w 0)
 rm $idFile $outFile
+
+# -l/--region-file with custom names, duplicated names are made unique
+echo -e ">chr1 desc\nACGTNacgtn\n>chr2\nGGGGCCCC" > t.faidx.fa
+echo -e "chr1:2-4\tgeneA\nchr2:1-2\nchr1:9-10\tgeneA" > t.regions
+fun(){
+    $app faidx t.faidx.fa -l t.regions
+}
+run faidx_region_file_names fun
+assert_equal $(cat $STDOUT_FILE | paste -sd,) ">geneA,CGT,>chr2:1-2,GG,>geneA_2,tn"
+rm t.faidx.fa* t.regions
+
+# --write-fai: the index written on the fly is the same as the one created from the output
+echo -e ">chr1 desc\nACGTNacgtnACGTNacgtn\n>chr2\nGGGGCCCC" > t.faidx.fa
+fun(){
+    $app faidx t.faidx.fa chr1:3-17 chr2 -w 6 --write-fai -o t.faidx.out.fa
+}
+run faidx_write_fai fun
+mv t.faidx.out.fa.fai t.faidx.fai
+$app faidx t.faidx.out.fa
+assert_equal $(cat t.faidx.fai | md5sum | cut -d" " -f 1) $(cat t.faidx.out.fa.fai | md5sum | cut -d" " -f 1)
+assert_equal $(cat t.faidx.fai | tr "\t" , | paste -sd,) "chr1:3-17,15,11,6,7,chr2,8,35,6,7"
+rm t.faidx.*
+
+# --full-header is the same as -f/--full-head, coordinates are appended to full headers of regions
+echo -e ">chr1 desc\nACGTNacgtn" > t.faidx.fa
+fun(){ $app faidx t.faidx.fa --full-header chr1 chr1:2-4; }
+run faidx_full_header fun
+assert_equal "$(cat $STDOUT_FILE | paste -sd,)" ">chr1 desc,ACGTNacgtn,>chr1 desc:2-4,CGT"
+assert_equal $(cat $STDOUT_FILE | md5sum | cut -d" " -f 1) $($app faidx t.faidx.fa -f chr1 chr1:2-4 | md5sum | cut -d" " -f 1)
+rm t.faidx.fa*
+
+# ------------------------------------------------------------
+#                       benchmark
+# ------------------------------------------------------------
+
+# --benchmark reports to stderr without changing the output
+file=tests/hairpin.fa
+run benchmark $app head -n 1 $file --benchmark
+assert_in_stderr "[benchmark] elapsed time:"
+assert_equal $(grep -c "^>" $STDOUT_FILE) 1
+assert_in_stderr "records: 1 ("
+assert_in_stderr "records/s)"
+
+# records of all input files are counted
+seqbench(){
+    $app seq $file $file --benchmark -o t.bench.fa
+}
+run benchmark_records seqbench
+assert_in_stderr "records: 57290 ("
+assert_in_stderr "input: "
+rm t.bench.fa
+
+# ------------------------------------------------------------
+#                       translate
+# ------------------------------------------------------------
+
+# --trim does not remove the stop kept by --keep-stop
+fun() {
+    echo -e ">s\nATGAAANNNTAGAAA" | $app translate --to-stop --keep-stop --trim -w 0 | $app seq -s
+}
+run translate_keep_stop_trim fun
+assert_equal $(cat $STDOUT_FILE) "MK*"
+
+fun() {
+    echo -e ">s\nATGAAANNNTAGAAA" | $app translate --to-stop --trim -w 0 | $app seq -s
+}
+run translate_to_stop_trim fun
+assert_equal $(cat $STDOUT_FILE) "MK"
+
+# --report-stops: the terminal stop codon is not counted
+fun(){ echo -e ">a\nATGTAAAAATGATAG\n>b\nATGAAATAG" | $app translate --report-stops; }
+run translate_report_stops fun
+assert_equal $(cat $STDOUT_FILE | tr "\t" , | paste -sd,) "id,frame,aa_len,stops,aa_pos,nt_pos,a,1,5,2,2,4,4,10"
+
+# stop codons depend on the translate table, TGA is W in table 2
+fun(){ echo -e ">a\nATGTGAAAA" | $app translate --report-stops -T 2 | sed 1d | wc -l; }
+run translate_report_stops_table fun
+assert_equal $(cat $STDOUT_FILE) 0
+
+# --codon-report: ambiguous, unresolved and partial codons
+fun(){ echo -e ">s\nATGYTATAGNNNGC" | $app translate --codon-report | sed 1d; }
+run translate_codon_report fun
+assert_equal $(cat $STDOUT_FILE | tr "\t" , | paste -sd";") "s,1,1,1,3,ATG,M,.;s,1,2,4,6,YTA,L,ambiguous;s,1,3,7,9,TAG,*,.;s,1,4,10,12,NNN,X,unresolved;s,1,5,13,14,GC,NA,partial"
+
+# positions on the positive strand for negative frames
+fun(){ echo -e ">s\nATGAAATAG" | $app translate --codon-report -f -1 | sed 1d; }
+run translate_codon_report_negative fun
+assert_equal $(cat $STDOUT_FILE | tr "\t" , | paste -sd";") "s,-1,1,7,9,CTA,L,.;s,-1,2,4,6,TTT,F,.;s,-1,3,1,3,CAT,H,."
+
+# ambiguous bases and RNA are complemented in negative frames
+fun(){ echo -e ">d\nGRATCA" | $app translate --codon-report -f -1 | sed 1d | cut -f 6-; }
+run translate_codon_report_negative_frame_ambiguous fun
+assert_equal $(cat $STDOUT_FILE | tr "\t" , | paste -sd,) "TGA,*,.,TYC,X,unresolved"
+
+fun(){ echo -e ">r\nGAYAUGNUA" | $app translate --codon-report -f -1 | sed 1d | cut -f 6-; }
+run translate_codon_report_negative_frame_rna fun
+assert_equal $(cat $STDOUT_FILE | tr "\t" , | paste -sd,) "UAN,X,unresolved,CAU,H,.,RUC,X,unresolved"
+
+# --longest-frame
+fun(){
+    echo -e ">s\nATGTAAATGAAAAAA" | $app translate --longest-frame | $app seq -i | $app fx2tab
+}
+run translate_longest_frame fun
+assert_equal "$(cat $STDOUT_FILE | tr "\t" ,)" "s_frame=-1,FFHLH,"
+
+fun(){
+    echo -e ">s\nTTACTATCAT" | $app translate --longest-frame | $app seq -s
+}
+run translate_longest_frame_forward fun
+assert_equal "$(cat $STDOUT_FILE)" "LLS"
+
+fun(){
+    echo -e ">s\nATGTAAATGAAAAAA" | $app translate --longest-frame -f 2
+}
+run translate_longest_frame_with_frame fun
+assert_exit_code 255
+
+# ------------------------------------------------------------
+#                       count-motif
+# ------------------------------------------------------------
+
+# regular expressions with anchors are matched against the whole sequence
+file=t.motif.fa
+echo -e ">s\nAAAA" > $file
+run count_motif_regexp_anchor $app count-motif -r -p "^A" -p "^AA" -p "A$" -p "\bA" $file
+assert_equal $(sed -n 2p $STDOUT_FILE | cut -f 2- | tr "\t" ,) "1,1,1,1"
+
+run count_motif_regexp_anchor_overlapping $app count-motif -r -p "^A" -p "^AA" -p "A$" -p "AA" --overlapping $file
+assert_equal $(sed -n 2p $STDOUT_FILE | cut -f 2- | tr "\t" ,) "1,1,1,3"
+rm $file
+
+# ------------------------------------------------------------
+#                       split-at-gaps
+# ------------------------------------------------------------
+
+# AGP objects begin and end with components, all-gap sequences are skipped
+echo -e ">s1\nNNNNACGTNNNNNAANNN\n>s2\nNNNNN" > t.gaps.fa
+run split_at_gaps_agp $app split-at-gaps -n 2 t.gaps.fa -a t.gaps.agp
+assert_equal $(grep -c '^>' $STDOUT_FILE) 2
+assert_equal $(grep -v '^#' t.gaps.agp | cut -f 1-5 | tr "\t" , | paste -sd ';') "s1,1,4,1,W;s1,5,9,2,N;s1,10,11,3,W"
+rm t.gaps.*
+
+# ------------------------------------------------------------
+#                       stats
+# ------------------------------------------------------------
+
+# --merge with length histograms gives the same result as stats of all records
+file=tests/hairpin.fa
+$app split2 -p 3 $file -O t.stats -f 2> /dev/null
+for f in t.stats/*; do
+    $app stats -T -a -N 90 $f --accumulate $f.lens > $f.tsv
+done
+run stats_merge $app stats --merge -T t.stats/*.fa.tsv --lengths-file $(ls t.stats/*.lens | paste -sd ,)
+assert_equal $(sed 1d $STDOUT_FILE | cut -f 2- | md5sum) $($app stats -T -a -N 90 $file | sed 1d | cut -f 2- | md5sum)
+rm -r t.stats
+
+# --gc: GC(%) without -a, ambiguous bases and gaps are not counted in the denominator
+fun(){ echo -e ">a\nGCNNAT\n>b\nGG-" | $app stats --gc -T; }
+run stats_gc fun
+assert_equal $(cat $STDOUT_FILE | sed 1d | cut -f 4,5,9 | tr "\t" ,) "2,9,66.67"
+
+# -P/--per-position: quality profile of reads with different lengths
+fun(){ echo -e "@a\nACGT\n+\nII55\n@b\nAC\n+\n+I" | $app stats -P; }
+run stats_per_position fun
+assert_equal $(cat $STDOUT_FILE | tr "\t" , | paste -sd,) "file,position,count,mean_q,q25,median,q75,-,1,2,25.00,10,10,40,-,2,2,40.00,40,40,40,-,3,1,20.00,20,20,20,-,4,1,20.00,20,20,20"
+
+# FASTQ only
+fun(){ echo -e ">a\nACGT" | $app stats -P; }
+run stats_per_position_fasta fun
+assert_exit_code 255
+
+# --follow: records appended are counted, and final statistics are written on SIGINT
+fun(){
+    echo -e ">a\nACGT\n>b\nAC" > t.follow.fa
+    (sleep 1; echo -e ">c\nA" >> t.follow.fa) &
+    timeout -s INT 3 $app stats --follow --interval 1s -T t.follow.fa
+}
+run stats_follow fun
+assert_equal $(cat $STDOUT_FILE | tr "\t" , | paste -sd,) "file,format,type,num_seqs,sum_len,min_len,avg_len,max_len,t.follow.fa,FASTA,DNA,3,7,1,2.3,4"
+rm t.follow.fa
+
+# stdin is not supported
+fun(){ echo -e ">a\nACGT" | $app stats --follow; }
+run stats_follow_stdin fun
+assert_exit_code 255
+
+# --per-seq: one row for each record, in the order of input
+fun(){ echo -e ">a\nACGNn\n>b\nGG" | $app stats --per-seq; }
+run stats_per_seq fun
+assert_equal $(cat $STDOUT_FILE | tr "\t" , | paste -sd,) "file,id,length,GC(%),N,AvgQual,-,a,5,40.00,2,NA,-,b,2,100.00,0,NA"
+
+fun(){ $app stats --per-seq -j 4 tests/reads_1.fq.gz | cut -f 2 | sed 1d; }
+run stats_per_seq_threads fun
+assert_equal $(cat $STDOUT_FILE | md5sum | cut -d" " -f 1) $($app seq -n -i tests/reads_1.fq.gz | md5sum | cut -d" " -f 1)
+
+# --stats-columns: only the given columns in the given order, N50-like stats are computed without -N
+fun(){ echo -e ">a\nACGT\n>b\nAC" | $app stats -T --stats-columns file,num_seqs,N90,sum_len,N50_num; }
+run stats_columns fun
+assert_equal $(cat $STDOUT_FILE | tr "\t" , | paste -sd,) "file,num_seqs,N90,sum_len,N50_num,-,2,2,6,1"
+
+# -N 50 does not duplicate the column N50
+fun(){ echo -e ">a\nACGT\n>b\nAC" | $app stats -T --stats-columns N50,max_len -N 50; }
+run stats_columns_nx fun
+assert_equal $(cat $STDOUT_FILE | tr "\t" , | paste -sd,) "N50,max_len,4,4"
+
+# the column "group" is inserted with --group-regexp
+fun(){ echo -e ">x|a\nACGT\n>y|b\nAC" | $app stats -T --stats-columns num_seqs --group-regexp '^(\w)\|'; }
+run stats_columns_group fun
+assert_equal $(cat $STDOUT_FILE | tr "\t" , | paste -sd,) "group,num_seqs,x,1,y,1,all,2"
+
+fun(){ echo -e ">a\nACGT" | $app stats --stats-columns foo; }
+run stats_columns_unknown fun
+assert_exit_code 255
+
+# stats --group-regexp: unmatched records are assigned to "NA"
+fun(){ echo -e ">x|1\nACGT\n>y|1\nAC\n>x|2\nAA\n>z\nA" | $app stats -T --group-regexp '^(\w)\|' | cut -f 2,5,6; }
+run stats_group_regexp fun
+assert_equal $(cat $STDOUT_FILE | tr "\t" , | paste -sd,) "group,num_seqs,sum_len,x,2,6,y,1,2,NA,1,1,all,4,9"
+
+fun(){ echo -e ">x|1\nACGT" | $app stats --group-regexp '\w'; }
+run stats_group_regexp_no_capture_group fun
+assert_exit_code 255
+
+# stddev_len (sample standard deviation of sequence lengths) and cv_len, outputted with -a
+fun(){ echo -e ">a\nAAAA\n>b\nCC\n>c\nGGGGGG" | $app stats -a -T; }
+run stats_stddev_len fun
+assert_equal $(head -n 1 $STDOUT_FILE | cut -f 19,20 | tr "\t" ,) "stddev_len,cv_len"
+assert_equal $(tail -n 1 $STDOUT_FILE | cut -f 19,20 | tr "\t" ,) "2.00,0.5000"
+
+fun(){ echo -e ">a\nAAAAAAAAAA\n>b\nC" | $app stats -T --stats-columns avg_len,stddev_len,cv_len; }
+run stats_stddev_len_columns fun
+assert_equal $(cat $STDOUT_FILE | tr "\t" , | paste -sd,) "avg_len,stddev_len,cv_len,5.5,6.36,1.1571"
+
+# zeros for a single sequence
+fun(){ echo -e ">a\nAAAA" | $app stats -T --stats-columns stddev_len,cv_len; }
+run stats_stddev_len_single fun
+assert_equal $(cat $STDOUT_FILE | tr "\t" , | paste -sd,) "stddev_len,cv_len,0.00,0.0000"
+
+# ------------------------------------------------------------
+#                       qc-filter
+# ------------------------------------------------------------
+
+# qc-filter: a pair is dropped if either mate fails a gate, mate suffixes are ignored
+echo -e "@r1/1\nACGT\n+\nIIII\n@r2/1\nAC\n+\nII\n@r3/1\nACGT\n+\nIIII" > t_1.fq
+echo -e "@r1/2\nACGT\n+\nIIII\n@r2/2\nACGT\n+\nIIII\n@r3/2\nA\n+\nI" > t_2.fq
+fun(){ $app qc-filter -m 3 -1 t_1.fq -2 t_2.fq --quiet; }
+run qc_filter_paired fun
+assert_equal $($app seq -n t_1.filtered.fq | paste -sd,)/$($app seq -n t_2.filtered.fq | paste -sd,) "r1/1/r1/2"
+rm t_1.filtered.fq t_2.filtered.fq
+
+# unequal numbers of reads
+echo -e "@r1/2\nACGT\n+\nIIII" > t_2.fq
+fun(){ $app qc-filter -m 3 -1 t_1.fq -2 t_2.fq --quiet; }
+run qc_filter_paired_unequal fun
+assert_exit_code 255
+rm -f t_1.fq t_2.fq t_1.filtered.fq t_2.filtered.fq
+
+# -o/--out-file is not supported for paired reads
+echo -e "@r1/1\nACGT\n+\nIIII" > t_1.fq
+echo -e "@r1/2\nACGT\n+\nIIII" > t_2.fq
+fun(){ $app qc-filter -m 3 -1 t_1.fq -2 t_2.fq -o t.fq; }
+run qc_filter_paired_out_file fun
+assert_exit_code 255
+rm -f t_1.fq t_2.fq t.fq
+
+# ------------------------------------------------------------
+#                       count-barcodes
+# ------------------------------------------------------------
+
+# count-barcodes: inline barcodes, with up to one mismatch, N in reads is a mismatch
+echo -e "s1\tACGT\ns2\tTTTT" > t.barcodes
+fun(){ echo -e ">r1\nACGTAA\n>r2\nACGAAA\n>r3\nNNNNAA\n>r4\nTTTTAA" | $app count-barcodes -b t.barcodes -m 1; }
+run count_barcodes fun
+assert_equal $(cat $STDOUT_FILE | cut -f 1-3 | tr "\t" , | paste -sd,) "sample,barcode,reads,s1,ACGT,2,s2,TTTT,1,undetermined,-,1,ambiguous,-,0"
+
+# barcodes with bases other than A, C, G, T are not allowed
+echo -e "s1\tNNNN" > t.barcodes
+fun(){ echo -e ">r1\nNNNNAA" | $app count-barcodes -b t.barcodes; }
+run count_barcodes_n fun
+assert_exit_code 255
+rm t.barcodes
+
+# ------------------------------------------------------------
+#                       kmer-cardinality
+# ------------------------------------------------------------
+
+# kmer-cardinality: the k-mer of all A's is counted, k-mers with N are skipped
+fun(){ echo -e ">a\nAAAAAAAAAAC\n>b\nACGTNACGT" | $app kmer-cardinality -k 5; }
+run kmer_cardinality fun
+assert_equal $(cat $STDOUT_FILE | sed 1d | cut -f 4) 2
+
+# ------------------------------------------------------------
+#                       fq2ubam
+# ------------------------------------------------------------
+
+# fq2ubam: read pairs are restored by bam2fq, with the read group kept
+fun(){
+    echo -e "@r1\nACGTA\n+\nIIIII\n@r2\nGGG\n+\n##I" > t.r1.fq
+    echo -e "@r1\nTTT\n+\nIII\n@r2\nCC\n+\nII" > t.r2.fq
+    $app fq2ubam -1 t.r1.fq -2 t.r2.fq -r g1 -s S1 -o t.ubam.bam
+    $app bam2fq --split-rg -e .fq -O t.ubam t.ubam.bam
+}
+run fq2ubam fun
+assert_exit_code 0
+assert_equal $(cat t.ubam/g1_1.fq | md5sum | cut -d" " -f 1) $(cat t.r1.fq | md5sum | cut -d" " -f 1)
+assert_equal $(cat t.ubam/g1_2.fq | md5sum | cut -d" " -f 1) $(cat t.r2.fq | md5sum | cut -d" " -f 1)
+rm -r t.r1.fq t.r2.fq t.ubam.bam t.ubam
+
+# suffixes /1 and /2 of mates are removed from the read names
+fun(){
+    echo -e "@r1/1\nACG\n+\n555" > t.r1.fq
+    echo -e "@r1/2\nTTT\n+\n555" > t.r2.fq
+    $app fq2ubam -1 t.r1.fq -2 t.r2.fq -o t.ubam.bam
+    $app bam2fq -e .fq -O t.ubam t.ubam.bam
+}
+run fq2ubam_mate_suffix fun
+assert_exit_code 0
+assert_equal $(cat t.ubam/reads_1.fq | head -n 1) "@r1"
+assert_equal $(cat t.ubam/reads_2.fq | head -n 1) "@r1"
+rm -r t.r1.fq t.r2.fq t.ubam.bam t.ubam
+
+# qualities out of the range of Phred+33 are reported as errors
+fun(){ echo -e "@r1\nACG\n+\nII " | $app fq2ubam -o t.ubam.bam; }
+run fq2ubam_bad_qual fun
+assert_exit_code 255
+rm -f t.ubam.bam
+
+# ------------------------------------------------------------
+#                       winstats
+# ------------------------------------------------------------
+
+# winstats: GC, N and average quality of windows, the last short window with -g
+fun(){ echo -e "@s\nGGCCAANNTT\n+\nIIII5555++" | $app winstats -W 4 -s 4 -g -H; }
+run winstats fun
+assert_equal $(cat $STDOUT_FILE | tr "\t" , | paste -sd,) "s,0,4,4,100.00,0.00,40.00,s,4,8,4,0.00,50.00,20.00,s,8,10,2,0.00,0.00,10.00"
+
+# a window crossing the origin of a circular genome is split in bedGraph format
+fun(){ echo -e ">s\nGGCCAANNTT" | $app winstats -W 4 -s 4 -c -B; }
+run winstats_circular_bedgraph fun
+assert_equal $(cat $STDOUT_FILE | tr "\t" , | paste -sd,) "s,0,4,100.00,s,4,8,0.00,s,8,10,50.00,s,0,2,50.00"
+
+# circular windows longer than the sequence, intervals in bedGraph format do not overlap
+fun(){ echo -e ">s\nGGCCA" | $app winstats -W 7 -s 2 -c; }
+run winstats_circular_long_window fun
+assert_equal $(cat $STDOUT_FILE | tr "\t" , | paste -sd,) "#id,start,end,length,GC,N,s,0,2,7,85.71,0.00,s,2,4,7,85.71,0.00,s,4,1,7,71.43,0.00"
+
+fun(){ echo -e ">s\nGGCCA" | $app winstats -W 12 -s 2 -c -B; }
+run winstats_circular_longer_window_bedgraph fun
+assert_equal $(cat $STDOUT_FILE | tr "\t" , | paste -sd,) "s,0,5,83.33,s,2,5,83.33,s,0,2,83.33,s,4,5,75.00,s,0,4,75.00"
+
+# --n-frac: fractions of N bases in bedGraph format, both cases are counted
+fun(){ echo -e ">s\nGGCCAnNRTT" | $app winstats --n-frac -W 5 -s 5; }
+run winstats_n_frac fun
+assert_equal $(cat $STDOUT_FILE | tr "\t" , | paste -sd,) "s,0,5,0.0000,s,5,10,0.4000"
+
+# ------------------------------------------------------------
+#                       composition
+# ------------------------------------------------------------
+
+# composition: counts of each record, with degenerate bases and gaps counted separately
+fun(){ echo -e ">a\nAACGTNR-a\n>b\nGG" | $app composition -i -r; }
+run composition_per_record fun
+assert_equal $(cat $STDOUT_FILE | tr "\t" , | paste -sd,) "file,id,length,A,C,G,T,U,N,other,gap,-,a,9,3,1,1,1,0,1,1,1,-,b,2,0,0,2,0,0,0,0,0"
+
+# counts of all records, the percentages are computed with the sum of lengths
+fun(){ echo -e ">a\nAACGTNR-a\n>b\nGG" | $app composition -i | grep -P "^-\t(G|gap)\t"; }
+run composition fun
+assert_equal $(cat $STDOUT_FILE | tr "\t" , | paste -sd,) "-,G,3,27.27,-,gap,1,9.09"
+
+# ------------------------------------------------------------
+#                       orf
+# ------------------------------------------------------------
+
+# orf: the longest ORF on the positive strand
+fun(){ echo -e ">s x\nCCATGAAATAGCCTTACCCTTTCATGG" | $app orf -m 6; }
+run orf fun
+assert_equal "$(cat $STDOUT_FILE | paste -sd,)" ">s_orf1 frame=+3 strand=+ begin=3 end=11 len=9 x,ATGAAATAG"
+
+# all ORFs in six frames, with coordinates on the positive strand
+fun(){ echo -e ">s x\nCCATGAAATAGCCTTACCCTTTCATGG" | $app orf -m 6 -b -a -p | $app fx2tab | cut -f 1,2 | tr "\t" "|"; }
+run orf_both_strands fun
+assert_equal "$(cat $STDOUT_FILE | paste -sd,)" "s_orf1 frame=+3 strand=+ begin=3 end=11 len=9 x|MK*,s_orf2 frame=-3 strand=- begin=14 end=25 len=12 x|MKG*"
+
+# ------------------------------------------------------------
+#                       index
+# ------------------------------------------------------------
+
+# index build: grep and range with the record-offset index give the same results
+fun(){
+    cp tests/hairpin.fa t.idx.fa
+    $app grep -p hsa-let-7a-1 -p cel-lin-4 t.idx.fa > t.grep.0
+    $app range -r 10:11 t.idx.fa > t.range.0
+    $app index build t.idx.fa
+    $app grep -p hsa-let-7a-1 -p cel-lin-4 t.idx.fa > t.grep.1
+    $app range -r 10:11 t.idx.fa > t.range.1
+}
+run index_build fun
+assert_exit_code 0
+assert_in_stderr "records loaded from index file"
+assert_equal $(cat t.grep.1 | md5sum | cut -d" " -f 1) $(cat t.grep.0 | md5sum | cut -d" " -f 1)
+assert_equal $(cat t.range.1 | md5sum | cut -d" " -f 1) $(cat t.range.0 | md5sum | cut -d" " -f 1)
+
+# an outdated index file is ignored
+fun(){ touch -d '2000-01-01' t.idx.fa; $app range -r 10:11 t.idx.fa; }
+run index_outdated fun
+assert_in_stderr "ignore the outdated index file"
+assert_equal $(cat $STDOUT_FILE | md5sum | cut -d" " -f 1) $(cat t.range.0 | md5sum | cut -d" " -f 1)
+rm t.idx.fa t.idx.fa.skidx t.grep.* t.range.*
+
+# ------------------------------------------------------------
+#                       consensus
+# ------------------------------------------------------------
+
+# consensus: ties give N, or degenerate bases with -a, gap-only columns give "-"
+testaln() {
+    echo -e ">s1\nACGT-A\n>s2\nACGA-A\n>s3\nATGT-G\n>s4\nacca-g"
+}
+fun(){ testaln | $app consensus | $app seq -s; }
+run consensus fun
+assert_equal $(cat $STDOUT_FILE) "ACGN-N"
+
+fun(){ testaln | $app consensus -a --id c; }
+run consensus_ambiguous fun
+assert_equal $(cat $STDOUT_FILE | paste -sd,) ">c,ACGW-R"
+
+# sequences should be aligned
+fun(){ echo -e ">s1\nACGT\n>s2\nACG" | $app consensus; }
+run consensus_unaligned fun
+assert_exit_code 255
+
+# ------------------------------------------------------------
+#                       bam-relabel
+# ------------------------------------------------------------
+
+# bam-relabel: read groups of an unaligned BAM file created by fq2ubam
+echo -e "@r1 x\nACG\n+\n555\n@r2\nGGG\n+\n555" | $app fq2ubam -r g1 -o t.relabel.bam
+fun(){ echo -e ">r1 x\nACG\n>r3\nAA\n>r2\nGG" | $app bam-relabel -b t.relabel.bam -T RG | $app seq -n; }
+run bam_relabel fun
+assert_equal "$(cat $STDOUT_FILE | paste -sd,)" "r1 x RG:g1,r3,r2 RG:g1"
+
+# records without the tag are dropped
+fun(){ echo -e ">r1 x\nACG\n>r3\nAA\n>r2\nGG" | $app bam-relabel -b t.relabel.bam -T RG -r '{tag}_{id}' -m drop | $app seq -n; }
+run bam_relabel_drop fun
+assert_equal $(cat $STDOUT_FILE | paste -sd,) "g1_r1,g1_r2"
+rm t.relabel.bam
+
+# ------------------------------------------------------------
+#                       trim-primers
+# ------------------------------------------------------------
+
+# trim-primers: the longer pair is chosen for ties of mismatches, reads are trimmed in the original orientation
+echo -e "p2\tACGTA\tCCTTA\np1\tACGTAC\tCCTTAG" > t.primers
+fun(){
+    echo -e "@r1\nGGACGTACTTTTCTAAGGTT\n+\nIIIIIIII5555IIIIIIII\n@r2\nAAAAAAAAAA\n+\nIIIIIIIIII\n@r3\nAACCTTAGAAAAGTACGTCC\n+\nIIIIIIII5555IIIIIIII" \
+        | $app trim-primers -p t.primers -u t.untrimmed.fq
+}
+run trim_primers fun
+assert_equal "$(cat $STDOUT_FILE | paste -sd,)" "@r1 primer=p1 strand=+,TTTT,+,5555,@r3 primer=p1 strand=-,AAAA,+,5555"
+assert_equal $($app seq -n t.untrimmed.fq) "r2"
+
+fun(){ echo -e ">r1\nGGACGTACTTTTCTAAGGTT" | $app trim-primers -p t.primers -k -M; }
+run trim_primers_keep fun
+assert_equal "$(cat $STDOUT_FILE | paste -sd,)" ">r1 primer=p1 strand=+ mismatches=0(0+0),ACGTACTTTTCTAAGG"
+rm t.primers t.untrimmed.fq
+
+# ------------------------------------------------------------
+#                       split-seq
+# ------------------------------------------------------------
+
+# split-seq: zero-length fragments are dropped, qualities are split at the same positions
+fun(){ echo -e "@s x\nGGATCCAAGGATCCGGATCCTT\n+\nIIIIII12IIIIIIIIIIII34\n@t\nAAAA\n+\nIIII" | $app split-seq -d GGATCC; }
+run split_seq fun
+assert_equal "$(cat $STDOUT_FILE | paste -sd,)" "@s_1 x,AA,+,12,@s_2 x,TT,+,34,@t,AAAA,+,IIII"
+
+# degenerate bases, with the delimiter kept on the left side
+fun(){ echo -e ">s\nAAGAATCCTTGGATCCCC" | $app split-seq -d GRATCC --degenerate -k left | $app fx2tab | cut -f 1,2; }
+run split_seq_degenerate fun
+assert_equal $(cat $STDOUT_FILE | tr "\t" , | paste -sd,) "s_1,AAGAATCC,s_2,TTGGATCC,s_3,CC"
+
+# ------------------------------------------------------------
+#                       pair
+# ------------------------------------------------------------
+
+# pair --check-only: unpaired and out-of-order reads are reported, and no reads are written
+fun(){
+    echo -e "@r1\nA\n+\nI\n@r2\nA\n+\nI\n@r3\nA\n+\nI" > t.p1.fq
+    echo -e "@r1\nA\n+\nI\n@r3\nA\n+\nI\n@r4\nA\n+\nI" > t.p2.fq
+    $app pair --check-only -1 t.p1.fq -2 t.p2.fq
+}
+run pair_check_only fun
+assert_exit_code 1
+assert_in_stdout "paired reads: 2"
+assert_in_stdout "paired reads not in the same order: 1"
+assert_equal $(grep "#" $STDOUT_FILE | sed "s/^ *//" | tr "\t" , | paste -sd,) "#2,r2,#3,r4"
+assert_equal $(ls t.p1.* t.p2.* | wc -l) 2
+
+# out-of-order reads are not errors
+fun(){ echo -e "@r2\nA\n+\nI\n@r1\nA\n+\nI\n@r3\nA\n+\nI" > t.p2.fq; $app pair --check-only -1 t.p1.fq -2 t.p2.fq; }
+run pair_check_only_ok fun
+assert_exit_code 0
+rm t.p1.fq t.p2.fq
+
+# ------------------------------------------------------------
+#                       sanitize-id
+# ------------------------------------------------------------
+
+# sanitize-id: disallowed characters are replaced, long IDs are truncated, duplicated ones are made unique
+fun(){ echo -e ">sp|P69905|HBA_HUMAN Hemoglobin\nACGT\n>a|b\nA\n>a_b x\nC\n>verylongid\nG" | $app sanitize-id -m t.mapping -l 8 | $app seq -n; }
+run sanitize_id fun
+assert_equal "$(cat $STDOUT_FILE | paste -sd,)" "sp_P6990 Hemoglobin,a_b,a_b_2 x,verylong"
+assert_equal $(cat t.mapping | tr "\t" , | paste -sd,) "sp|P69905|HBA_HUMAN,sp_P6990,a|b,a_b,a_b,a_b_2,verylongid,verylong"
+rm t.mapping
+
+# only the parsed ID is kept with --id-ncbi
+fun(){ echo -e ">gi|110645304|ref|NC_002516.2| Pseud\nA" | $app sanitize-id --id-ncbi | $app seq -n; }
+run sanitize_id_ncbi fun
+assert_equal "$(cat $STDOUT_FILE)" "NC_002516.2 Pseud"
+
+# the first word is sanitized if --id-regexp does not match
+fun(){ echo -e ">a|b c d\nA" | $app sanitize-id --id-regexp "^(\d+)" | $app seq -n; }
+run sanitize_id_regexp_unmatched fun
+assert_equal "$(cat $STDOUT_FILE)" "a_b c d"
+
+# ------------------------------------------------------------
+#                       mask-convert
+# ------------------------------------------------------------
+
+# mask-convert: lowercase letters are hard-masked or unmasked, gaps and qualities are kept
+fun(){ echo -e "@s\nACgt-nA\n+\nIIII5II" | $app mask-convert --soft2hard; }
+run mask_convert_soft2hard fun
+assert_equal $(cat $STDOUT_FILE | paste -sd,) "@s,ACNN-NA,+,IIII5II"
+
+fun(){ echo -e ">s\nACgt-nA" | $app mask-convert --strip-soft; }
+run mask_convert_strip_soft fun
+assert_equal $(cat $STDOUT_FILE | paste -sd,) ">s,ACGT-NA"
+
+# only one mode
+fun(){ echo -e ">s\nACgt" | $app mask-convert --soft2hard --strip-soft; }
+run mask_convert_modes fun
+assert_exit_code 255
+
+# ------------------------------------------------------------
+#                       amplicon
+# ------------------------------------------------------------
+
+# --both-strands: the strand is placed before the mismatches
+fun(){ echo -e ">a\nGGACGTACTTTTCTAAGGTT\n>b\nAACCTTAGAAAAGTACGTCC" | $app amplicon -F ACGTAC -R CCTTAG --both-strands -M; }
+run amplicon_both_strands fun
+assert_equal "$(cat $STDOUT_FILE | paste -sd,)" ">a strand=+ mismatches=0(0+0),ACGTACTTTTCTAAGG,>b strand=- mismatches=0(0+0),ACGTACTTTTCTAAGG"
+
+fun(){ echo -e ">a\nA" | $app amplicon -F ACGTAC -R CCTTAG --both-strands -P; }
+run amplicon_both_strands_positive fun
+assert_exit_code 255
+
+# ------------------------------------------------------------
+#                       deinterleave
+# ------------------------------------------------------------
+
+# deinterleave: consecutive records are split into two files
+fun(){ echo -e "@r1/1\nA\n+\nI\n@r1/2\nC\n+\nI\n@r2/1\nG\n+\nI\n@r2/2\nT\n+\nI" | $app deinterleave -1 t_1.fq -2 t_2.fq; }
+run deinterleave fun
+assert_equal $($app seq -n t_1.fq | paste -sd,)/$($app seq -n t_2.fq | paste -sd,) "r1/1,r2/1/r1/2,r2/2"
+rm t_1.fq t_2.fq
+
+# --check-only: an odd number of records is inconsistent
+fun(){ echo -e "@r1/1\nA\n+\nI\n@r2/2\nC\n+\nI\n@r3\nG\n+\nI" | $app deinterleave --check-only; }
+run deinterleave_check_only fun
+assert_exit_code 1
+assert_in_stdout "pairs validated: 0"
+assert_in_stdout "inconsistent pairs: 2"
+
+# ------------------------------------------------------------
+#                       recode
+# ------------------------------------------------------------
+
+# recode: codons with the highest usage for proteins and CDSs
+echo -e "ATG\t1\nGAA\t30\nGAG\t10\nTTC\t20\nTTT\t5\nTAA\t3\nTAG\t1\nTGA\t1" > t.usage
+fun(){ echo -e ">p\nMEF*" | $app recode -u t.usage -t protein; echo -e ">c\nATGGAGTTTTGA" | $app recode -u t.usage; }
+run recode fun
+assert_equal $(cat $STDOUT_FILE | paste -sd,) ">p,ATGGAATTCTAA,>c,ATGGAATTCTAA"
+
+# --avoid-sites: sites spanning adjacent codons are avoided
+fun(){ echo -e ">p\nMEF*" | $app recode -u t.usage -t protein --avoid-sites GAATTC; }
+run recode_avoid_sites fun
+assert_equal $(cat $STDOUT_FILE | paste -sd,) ">p,ATGGAATTTTAA"
+
+# no codons for W
+fun(){ echo -e ">c\nATGTGG" | $app recode -u t.usage; }
+run recode_missing_codons fun
+assert_exit_code 255
+rm t.usage
+
+# ------------------------------------------------------------
+#                       guess-encoding
+# ------------------------------------------------------------
+
+# Phred+33
+fun(){ echo -e "@r1\nACG\n+\nI#5\n@r2\nA\n+\nh" | $app guess-encoding; }
+run guess_encoding fun
+assert_equal $(cat $STDOUT_FILE | tr "\t" , | paste -sd,) "file,records,min_qual,max_qual,encoding,compatible,-,2,#(35),h(104),Sanger,Illumina-1.8+,Sanger"
+
+# Phred+64
+fun(){ echo -e "@r1\nACG\n+\nhgB" | $app guess-encoding | cut -f 3-5; }
+run guess_encoding_phred64 fun
+assert_equal $(cat $STDOUT_FILE | tr "\t" , | paste -sd,) "min_qual,max_qual,encoding,B(66),h(104),Illumina-1.5+"
+
+# only leading records are checked
+fun(){ echo -e "@r1\nACG\n+\nI#5\n@r2\nA\n+\nh" | $app guess-encoding -n 1 | cut -f 2,4; }
+run guess_encoding_nrecords fun
+assert_equal $(cat $STDOUT_FILE | tr "\t" , | paste -sd,) "records,max_qual,1,I(73)"
+
+fun(){ echo -e ">a\nA" | $app guess-encoding; }
+run guess_encoding_fasta fun
+assert_exit_code 255
+
+# ------------------------------------------------------------
+#                       fold
+# ------------------------------------------------------------
+
+# both sequences and qualities of FASTQ are wrapped, with the separator line being "+"
+fun(){ echo -e "@r1 x\nACGTA\n+r1 x\nIIIII\n@r2\nAC\n+\nII" | $app fold -w 2; }
+run fold_fastq fun
+assert_equal "$(cat $STDOUT_FILE | paste -sd,)" "@r1 x,AC,GT,A,+,II,II,I,@r2,AC,+,II"
+
+# unwrapping multi-line FASTQ
+fun(){ echo -e "@r1 x\nACG\nTA\n+\nIII\nII" | $app fold -w 0; }
+run fold_unwrap fun
+assert_equal "$(cat $STDOUT_FILE | paste -sd,)" "@r1 x,ACGTA,+,IIIII"
+
+fun(){ echo -e ">a\nACGTACG" | $app fold -w 3; }
+run fold_fasta fun
+assert_equal $(cat $STDOUT_FILE | paste -sd,) ">a,ACG,TAC,G"
+
+# ------------------------------------------------------------
+#                       concat
+# ------------------------------------------------------------
+
+# paired-end reads: read1 + spacer + read2, with the IDs and descriptions of read1
+echo -e "@r1/1 d1\nACGG\n+\nABCD\n@r2/1\nTT\n+\nII" > t.concat_1.fq
+echo -e "@r1/2 d2\nAAC\n+\nEFG\n@r2/2\nGA\n+\nI#" > t.concat_2.fq
+run concat_paired $app concat -1 t.concat_1.fq -2 t.concat_2.fq --separator-n 2 --spacer-qual "#"
+assert_equal "$(cat $STDOUT_FILE | paste -sd,)" "@r1 d1,ACGGNNAAC,+,ABCD##EFG,@r2,TTNNGA,+,II##I#"
+
+run concat_paired_revcom_read2 $app concat -1 t.concat_1.fq -2 t.concat_2.fq --separator-n 0 --revcom-read2
+assert_equal "$(cat $STDOUT_FILE | paste -sd,)" "@r1 d1,ACGGGTT,+,ABCDGFE,@r2,TTTC,+,II#I"
+
+# unequal numbers of reads
+echo -e "@r1/2 d2\nAAC\n+\nEFG" > t.concat_3.fq
+run concat_paired_unequal $app concat -1 t.concat_1.fq -2 t.concat_3.fq
+assert_exit_code 255
+
+# flags for paired-end reads are not allowed for concatenating files
+run concat_separator_n_without_read1 $app concat --separator-n 2 t.concat_1.fq t.concat_2.fq
+assert_exit_code 255
+rm t.concat_*.fq
+
+# ------------------------------------------------------------
+#                       hpc
+# ------------------------------------------------------------
+
+# qualities of the first bases of runs, and run lengths
+fun(){ echo -e "@r1\nAAACGGt\n+\n5I#!#+I" | $app hpc --run-lengths t.hpc.tsv; }
+run hpc fun
+assert_equal $(cat $STDOUT_FILE | paste -sd,) "@r1,ACGt,+,5!#I"
+assert_equal $(cat t.hpc.tsv | tr "\t" ,) "r1,3,1,2,1"
+assert_in_stderr "compression ratio: 0.5714"
+
+# restoring with the run lengths, the quality of a collapsed position is repeated
+fun(){ echo -e "@r1\nACGt\n+\n5!#I" | $app hpc -R --run-lengths t.hpc.tsv; }
+run hpc_restore fun
+assert_equal $(cat $STDOUT_FILE | paste -sd,) "@r1,AAACGGt,+,555!##I"
+
+fun(){ echo -e ">b\nACGt" | $app hpc -R --run-lengths t.hpc.tsv; }
+run hpc_restore_unmatched fun
+assert_exit_code 255
+rm t.hpc.tsv
+
+# --qual-mode
+fun(){ echo -e "@r1\nAAACGGt\n+\n5I#!#+I" | $app hpc --qual-mode max; }
+run hpc_qual_max fun
+assert_equal $(cat $STDOUT_FILE | paste -sd,) "@r1,ACGt,+,I!+I"
+
+fun(){ echo -e "@r1\nAAACGGt\n+\n5I#!#+I" | $app hpc --qual-mode mean; }
+run hpc_qual_mean fun
+assert_equal $(cat $STDOUT_FILE | paste -sd,) "@r1,ACGt,+,6!'I"
+
+# case sensitive unless -i
+fun(){ echo -e ">a\nAAaCC" | $app hpc; echo -e ">a\nAAaCC" | $app hpc -i; }
+run hpc_ignore_case fun
+assert_equal $($app seq -s $STDOUT_FILE | paste -sd,) "AaC,AC"